@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Corentin-cott/ServeurSentinel/internal/audit"
+	"github.com/Corentin-cott/ServeurSentinel/internal/db"
+	"github.com/Corentin-cott/ServeurSentinel/internal/models"
+	"github.com/spf13/cobra"
+)
+
+// Types of actors accepted by --actor-type
+var auditActorTypes = []string{models.ActorCLI, models.ActorAPI, models.ActorDiscord, models.ActorScheduler}
+
+// Command: serversentinel audit
+func newAuditCmd() *cobra.Command {
+	var since time.Duration
+	var filter db.AuditFilter
+	var jsonOutput bool
+
+	var auditCmd = &cobra.Command{
+		Use:   "audit",
+		Short: "Shows who started, stopped or changed the servers, the most recent first",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			redirectMessagesForJSON(jsonOutput)
+			if filter.ActorType != "" && !slices.Contains(auditActorTypes, filter.ActorType) {
+				log.Fatalf("FATAL ERROR: INVALID ACTOR TYPE %q, EXPECTED %s", filter.ActorType, strings.Join(auditActorTypes, ", "))
+			}
+			initCLI()
+
+			if since > 0 {
+				filter.Since = time.Now().Add(-since).UTC()
+			}
+			entries, err := db.GetAuditEntries(filter)
+			if err != nil {
+				log.Fatalf("FATAL ERROR GETTING AUDIT LOG: %v", err)
+			}
+			printAuditEntries(entries, jsonOutput)
+		},
+	}
+	auditCmd.Flags().DurationVar(&since, "since", 168*time.Hour, "only the actions of this last duration, 0 for all")
+	auditCmd.Flags().IntVar(&filter.ServerID, "server", 0, "only the actions on a server, by ID")
+	auditCmd.Flags().StringVar(&filter.Action, "action", "", "only an action, ex: start-server, stop-server, assign-slot, check-stop")
+	auditCmd.Flags().StringVar(&filter.ActorType, "actor-type", "", "only a type of actor: "+strings.Join(auditActorTypes, ", "))
+	auditCmd.Flags().StringVar(&filter.Actor, "actor", "", "only an actor: system user, token name, Discord user or task name")
+	auditCmd.Flags().IntVar(&filter.Limit, "limit", 50, "most actions shown, 0 for no limit")
+	auditCmd.Flags().BoolVar(&jsonOutput, "json", false, "print JSON instead of text")
+	return auditCmd
+}
+
+func printAuditEntries(entries []models.AuditEntry, jsonOutput bool) {
+	if jsonOutput {
+		if entries == nil {
+			entries = []models.AuditEntry{}
+		}
+		printJSON(entries)
+		return
+	}
+	if len(entries) == 0 {
+		fmt.Println("No action found.")
+		return
+	}
+
+	fmt.Printf("Actions (%d) :\n", len(entries))
+	for _, entry := range entries {
+		result := "✔"
+		if !entry.Success {
+			result = "✘"
+		}
+		line := fmt.Sprintf("  %s %s  %-14s  %s:%s", result, formatLocalTime(entry.Date), entry.Action, entry.ActorType, entry.Actor)
+		if entry.ServerID > 0 {
+			line += "  server " + audit.ServerLabel(entry.ServerID)
+		}
+		if entry.Parameters != "" {
+			line += "  " + entry.Parameters
+		}
+		fmt.Println(line)
+		if !entry.Success {
+			fmt.Println("      " + strings.ReplaceAll(strings.TrimSpace(entry.Error), "\n", "\n      "))
+		}
+	}
+}
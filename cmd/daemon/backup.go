@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/Corentin-cott/ServeurSentinel/config"
+	"github.com/Corentin-cott/ServeurSentinel/internal/audit"
+	"github.com/Corentin-cott/ServeurSentinel/internal/backup"
+	"github.com/Corentin-cott/ServeurSentinel/internal/models"
+	"github.com/Corentin-cott/ServeurSentinel/internal/tmux"
+	"github.com/spf13/cobra"
+)
+
+// Command: serversentinel backup [list|create|restore]
+func newBackupCmd() *cobra.Command {
+	var backupCmd = &cobra.Command{
+		Use:   "backup",
+		Short: "Manages the world backups of the game servers",
+	}
+
+	// Command: serversentinel backup list [id]
+	var listCmd = &cobra.Command{
+		Use:   "list [id]",
+		Short: "Lists the world backups of a server",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			initCLI()
+			server := getServerFromArg(args[0])
+
+			backups, err := backup.ListBackups(server)
+			if err != nil {
+				log.Fatalf("FATAL ERROR LISTING BACKUPS: %v", err)
+			}
+			if len(backups) == 0 {
+				fmt.Println("No backup found for server " + server.Nom)
+				return
+			}
+
+			fmt.Printf("Backups of server %s :\n", server.Nom)
+			for _, b := range backups {
+				fmt.Printf("  - %s  %s  %s\n", b.ID, b.CreatedAt.In(config.Get().Location()).Format("02/01/2006 15:04:05"), formatSize(b.Size))
+			}
+		},
+	}
+
+	// Command: serversentinel backup create [id]
+	var createCmd = &cobra.Command{
+		Use:   "create [id]",
+		Short: "Creates a world backup of a server",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			initCLI()
+			server := getServerFromArg(args[0])
+
+			fmt.Printf("Creating a backup of server %s ...\n", server.Nom)
+			b, err := backup.CreateBackup(server)
+			if err != nil {
+				log.Fatalf("FATAL ERROR CREATING BACKUP: %v", err)
+			}
+			fmt.Printf("Backup %s created (%s) : %s\n", b.ID, formatSize(b.Size), b.Path)
+		},
+	}
+
+	// Command: serversentinel backup restore [id] [backup-id]
+	var restoreCmd = &cobra.Command{
+		Use:   "restore [id] [backup-id]",
+		Short: "Restores a world backup of a stopped server",
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			initCLI()
+			server := getServerFromArg(args[0])
+			backupID := args[1]
+
+			// Never replace the world under a running server
+			isRunning, err := tmux.IsServerRunning(server.Nom)
+			if err != nil {
+				log.Fatalf("FATAL ERROR CHECKING IF SERVER IS RUNNING: %v", err)
+			}
+			if isRunning {
+				log.Fatalf("FATAL ERROR: SERVER %s IS RUNNING, STOP IT BEFORE RESTORING A BACKUP", server.Nom)
+			}
+
+			fmt.Printf("Restoring backup %s of server %s ...\n", backupID, server.Nom)
+			asidePath, err := backup.RestoreBackup(server, backupID)
+			// The audit log posts it in the admin channel, with the place of the previous world
+			audit.Record(audit.CLI(), models.AuditRestoreBackup, server.ID, audit.Params("backup", backupID, "previousWorld", asidePath), err)
+			if err != nil {
+				log.Fatalf("FATAL ERROR RESTORING BACKUP: %v", err)
+			}
+			if asidePath != "" {
+				fmt.Println("The previous world has been moved to " + asidePath)
+			}
+		},
+	}
+
+	backupCmd.AddCommand(listCmd)
+	backupCmd.AddCommand(createCmd)
+	backupCmd.AddCommand(restoreCmd)
+	return backupCmd
+}
+
+// Function to display a size in bytes in a human readable way
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
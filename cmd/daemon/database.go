@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/Corentin-cott/ServeurSentinel/config"
+	"github.com/Corentin-cott/ServeurSentinel/internal/db"
+	"github.com/spf13/cobra"
+)
+
+// Command: serversentinel db [migrate|status|rollback]
+func newDatabaseCmd() *cobra.Command {
+	var databaseCmd = &cobra.Command{
+		Use:   "db",
+		Short: "Manages the database schema",
+	}
+
+	// Command: serversentinel db migrate
+	var migrateCmd = &cobra.Command{
+		Use:   "migrate",
+		Short: "Applies the pending schema migrations",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			initCLI()
+
+			applied, err := db.Migrate()
+			for _, migration := range applied {
+				fmt.Printf("✔ Migration %04d_%s applied\n", migration.Version, migration.Name)
+			}
+			if err != nil {
+				log.Fatalf("FATAL ERROR APPLYING MIGRATIONS: %v", err)
+			}
+			if len(applied) == 0 {
+				fmt.Println("♦ The database schema is up to date.")
+			}
+		},
+	}
+
+	// Command: serversentinel db status
+	var statusCmd = &cobra.Command{
+		Use:   "status",
+		Short: "Lists the schema migrations and whether they are applied",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			initCLI()
+
+			states, err := db.GetMigrationsStatus()
+			if err != nil {
+				log.Fatalf("FATAL ERROR GETTING MIGRATIONS STATUS: %v", err)
+			}
+			for _, state := range states {
+				if !state.Applied {
+					fmt.Printf("  ✘ %04d_%s  pending\n", state.Version, state.Name)
+				} else {
+					fmt.Printf("  ✔ %04d_%s  applied on %s\n", state.Version, state.Name, state.AppliedAt.In(config.Get().Location()).Format("02/01/2006 15:04:05"))
+				}
+			}
+		},
+	}
+
+	// Command: serversentinel db rollback
+	var steps int
+	var rollbackCmd = &cobra.Command{
+		Use:   "rollback",
+		Short: "Rolls back the last applied schema migrations",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			if steps < 1 {
+				log.Fatalf("FATAL ERROR: STEPS MUST BE AT LEAST 1")
+			}
+			initCLI()
+
+			rolledBack, err := db.Rollback(steps)
+			for _, migration := range rolledBack {
+				fmt.Printf("✔ Migration %04d_%s rolled back\n", migration.Version, migration.Name)
+			}
+			if err != nil {
+				log.Fatalf("FATAL ERROR ROLLING BACK MIGRATIONS: %v", err)
+			}
+			if len(rolledBack) == 0 {
+				fmt.Println("♦ No migration to roll back.")
+			}
+		},
+	}
+	rollbackCmd.Flags().IntVar(&steps, "steps", 1, "number of migrations to roll back")
+
+	databaseCmd.AddCommand(migrateCmd)
+	databaseCmd.AddCommand(statusCmd)
+	databaseCmd.AddCommand(rollbackCmd)
+	return databaseCmd
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Corentin-cott/ServeurSentinel/internal/leaderboards"
+	"github.com/spf13/cobra"
+)
+
+// Command: serversentinel leaderboards [show|publish]
+func newLeaderboardsCmd() *cobra.Command {
+	var leaderboardsCmd = &cobra.Command{
+		Use:   "leaderboards",
+		Short: "Shows or publishes the leaderboards of the configuration",
+	}
+
+	// Command: serversentinel leaderboards show
+	var jsonOutput bool
+	var showCmd = &cobra.Command{
+		Use:   "show",
+		Short: "Prints the leaderboards without posting them",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			redirectMessagesForJSON(jsonOutput)
+			initCLI()
+			boards, err := leaderboards.BuildAll(time.Now())
+			if err != nil {
+				log.Fatalf("FATAL ERROR: %v", err)
+			}
+			if jsonOutput {
+				printJSON(boards)
+				return
+			}
+			if len(boards) == 0 {
+				fmt.Println("No leaderboard in the configuration.")
+				return
+			}
+			for _, leaderboard := range boards {
+				fmt.Println("♦ " + leaderboard.Title)
+				if len(leaderboard.Entries) == 0 {
+					fmt.Println("  Nobody is ranked yet.")
+				}
+				for i, entry := range leaderboard.Entries {
+					fmt.Printf("  %2d. %s : %s\n", i+1, entry.Name, entry.Formatted)
+				}
+			}
+		},
+	}
+	showCmd.Flags().BoolVar(&jsonOutput, "json", false, "print JSON instead of text")
+
+	// Command: serversentinel leaderboards publish
+	var publishCmd = &cobra.Command{
+		Use:   "publish",
+		Short: "Posts the leaderboards on Discord now, or updates their messages",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			initCLI()
+			if err := leaderboards.PublishAll(); err != nil {
+				log.Fatalf("FATAL ERROR PUBLISHING LEADERBOARDS: %v", err)
+			}
+			fmt.Println("✔ Leaderboards published.")
+		},
+	}
+
+	leaderboardsCmd.AddCommand(showCmd)
+	leaderboardsCmd.AddCommand(publishCmd)
+	return leaderboardsCmd
+}
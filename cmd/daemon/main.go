@@ -1,26 +1,44 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"strconv"
+	"syscall"
 	"time"
+	_ "time/tzdata" // The configured time zone is found even on systems without the IANA database
 
 	"github.com/Corentin-cott/ServeurSentinel/config"
+	"github.com/Corentin-cott/ServeurSentinel/internal/accounts"
+	"github.com/Corentin-cott/ServeurSentinel/internal/api"
+	"github.com/Corentin-cott/ServeurSentinel/internal/audit"
 	"github.com/Corentin-cott/ServeurSentinel/internal/console"
+	"github.com/Corentin-cott/ServeurSentinel/internal/control"
 	"github.com/Corentin-cott/ServeurSentinel/internal/db"
+	"github.com/Corentin-cott/ServeurSentinel/internal/discord"
 	periodic "github.com/Corentin-cott/ServeurSentinel/internal/events"
+	"github.com/Corentin-cott/ServeurSentinel/internal/logging"
+	"github.com/Corentin-cott/ServeurSentinel/internal/metrics"
+	"github.com/Corentin-cott/ServeurSentinel/internal/models"
+	"github.com/Corentin-cott/ServeurSentinel/internal/systemd"
 	"github.com/Corentin-cott/ServeurSentinel/internal/tmux"
-	"github.com/Corentin-cott/ServeurSentinel/internal/triggers"
 	"github.com/spf13/cobra"
 )
 
+var logger = logging.For("daemon")
+
+// Path of the configuration file given with the --config flag
+var configPath string
+
 func main() {
 	var rootCmd = &cobra.Command{
 		Use:   "serversentinel",
 		Short: "ServerSentinel manages Minecraft and Palworld servers in tmux sessions.",
 	}
+	rootCmd.PersistentFlags().StringVar(&configPath, "config", "", "path of the configuration file (default $"+config.ConfigPathEnv+" or "+config.DefaultConfigPath+")")
 
 	// Command: serversentinel start-server [id]
 	var startServerCmd = &cobra.Command{
@@ -53,7 +71,7 @@ func main() {
 		Args:  cobra.NoArgs,
 		Run: func(cmd *cobra.Command, args []string) {
 			// Because it's a CLI command, we need to load the configuration file
-			err := config.LoadConfig("/opt/serversentinel/config.json")
+			err := config.LoadConfig(config.ResolveConfigPath(configPath))
 			if err != nil {
 				log.Fatalf("FATAL ERROR LOADING CONFIG JSON FILE: %v", err)
 				return
@@ -68,7 +86,7 @@ func main() {
 
 			// Check if the right tmux servers are running
 			fmt.Printf("Checking and starting servers that are supposed to be running now!\n")
-			message, err := tmux.CheckRunningServers()
+			message, err := tmux.CheckRunningServers(audit.CLI())
 			if err != nil {
 				log.Fatalf("FATAL ERROR CHECKING RUNNING SERVERS: %v", err)
 				return
@@ -80,8 +98,10 @@ func main() {
 	// Command: serversentinel daemon
 	var daemonCmd = &cobra.Command{
 		Use:   "daemon",
-		Short: "Runs ServerSentinel in daemon mode",
-		Run:   runDaemon,
+		Short: "Runs ServerSentinel in daemon mode, until SIGINT or SIGTERM",
+		RunE:  runDaemon,
+		// A fatal error of the daemon is not a usage error
+		SilenceUsage: true,
 	}
 
 	// Add commands to root
@@ -89,6 +109,13 @@ func main() {
 	rootCmd.AddCommand(startServerCmd)
 	rootCmd.AddCommand(stopServerCmd)
 	rootCmd.AddCommand(checkServerCmd)
+	rootCmd.AddCommand(newBackupCmd())
+	rootCmd.AddCommand(newAuditCmd())
+	rootCmd.AddCommand(newDatabaseCmd())
+	rootCmd.AddCommand(newPlayersCmd())
+	rootCmd.AddCommand(newLeaderboardsCmd())
+	rootCmd.AddCommand(newReportCmd())
+	rootCmd.AddCommand(newInstallServiceCmd())
 
 	// Execute CLI
 	if err := rootCmd.Execute(); err != nil {
@@ -97,56 +124,140 @@ func main() {
 	}
 }
 
-func runDaemon(cmd *cobra.Command, args []string) {
-	fmt.Println("Starting the Server Sentinel daemon (" + time.Now().Format("02/01/2006 15:04:05") + ") ...")
+// How long the daemon waits for the running tasks, then for the Discord outbox, when it shuts down
+var shutdownTimeout = 30 * time.Second
+
+func runDaemon(cmd *cobra.Command, args []string) error {
+	logger.Info("Starting the Server Sentinel daemon")
 
 	// Load the configuration file
-	err := config.LoadConfig("/opt/serversentinel/config.json")
+	configFile := config.ResolveConfigPath(configPath)
+	err := config.LoadConfig(configFile)
 	if err != nil {
-		log.Fatalf("FATAL ERROR LOADING CONFIG JSON FILE: %v", err)
-		return
-	}
-
-	if !config.AppConfig.PeriodicEvents.ServersCheckEnabled {
-		fmt.Println("♟ Periodic task : Servers check disabled.")
-	}
-	if !config.AppConfig.PeriodicEvents.MinecraftStatsEnabled {
-		fmt.Println("♟ Periodic task : Minecraft statistics retrieval disabled.")
+		return fmt.Errorf("FATAL ERROR LOADING CONFIG JSON FILE: %v", err)
 	}
 
 	// Check that the bot configuation exists
-	if len(config.AppConfig.Bots) == 0 {
-		log.Fatalf("FATAL ERROR: NO BOT CONFIGURATION FOUND")
-		return
+	if len(config.Get().Bots) == 0 {
+		return fmt.Errorf("FATAL ERROR: NO BOT CONFIGURATION FOUND")
 	} else {
-		fmt.Println("✔ Bot configuration loaded :")
-		for botName, botConfig := range config.AppConfig.Bots {
-			fmt.Println("  -", botName, ":", botConfig)
+		for botName, botConfig := range config.Get().Bots {
+			logger.Info("Bot configuration loaded", "bot", botName, "activated", botConfig.Activated)
 		}
 	}
 
 	// Initialize the connection to the database
 	err = db.ConnectToDatabase()
 	if err != nil {
-		log.Fatalf("FATAL ERROR TESTING DATABASE CONNECTION: %v", err)
+		return fmt.Errorf("FATAL ERROR TESTING DATABASE CONNECTION: %v", err)
 	}
 
-	// Start the periodic service
-	go func() {
-		err := periodic.StartPeriodicTask(config.AppConfig.PeriodicEventsMin)
-		if err != nil {
-			log.Fatalf("FATAL ERROR STARTING PERIODIC TASK: %v", err)
-		}
-	}()
-	fmt.Println("✔ Periodic service started, interval is set to", config.AppConfig.PeriodicEventsMin, "minutes.")
+	// The schema is never changed by the daemon itself, a migration is applied on purpose with "serversentinel db migrate".
+	// The daemon doesn't start on an older schema: it would write rows the pending migrations then rewrite, ex: the dates
+	// already in UTC shifted again by migration 0002
+	pending, err := db.CountPendingMigrations()
+	if err != nil {
+		return fmt.Errorf("FATAL ERROR CHECKING DATABASE SCHEMA: %v", err)
+	}
+	if pending > 0 {
+		return fmt.Errorf("FATAL ERROR: %d DATABASE MIGRATIONS ARE PENDING, RUN \"serversentinel db migrate\" FIRST", pending)
+	}
+
+	// The servers and the slots are kept in memory, the log listeners need them for every line
+	cachedRepo := db.NewCachedRepository(db.GetRepository())
+	if err := cachedRepo.Refresh(); err != nil {
+		logger.Warn("Servers not loaded, they will be loaded on the first read", logging.Err(err))
+	}
+	db.SetRepository(cachedRepo)
+
+	// The triggers send their Discord messages through the outbox, so reading the logs never waits for Discord
+	sentinel := NewSentinel(cachedRepo, discord.OutboxNotifier{})
+	scheduler := sentinel.Scheduler
+
+	// Register the scheduled tasks and start the scheduler
+	err = periodic.RegisterTasks(scheduler, config.Get())
+	if err != nil {
+		return fmt.Errorf("FATAL ERROR REGISTERING SCHEDULED TASKS: %v", err)
+	}
+	// Cancelled on SIGINT or SIGTERM, everything started below stops with it
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// The Discord messages of the triggers are sent in the background
+	go discord.RunOutbox(ctx)
+	go cachedRepo.RunRefresh(ctx)
+	go accounts.RunDiscordLinks(ctx, sentinel.Players, sentinel.Notifier)
+
+	// The Prometheus metrics, only when an address is configured
+	sentinel.LoadServerStates()
+	if listen := config.Get().Metrics.Listen; listen != "" {
+		go func() {
+			if err := metrics.Serve(ctx, listen); err != nil {
+				logger.Error("Metrics not served", logging.Err(err))
+			}
+		}()
+		logger.Info("Metrics served", "url", "http://"+listen+"/metrics")
+	}
+
+	// The HTTP API, only when an address is configured
+	if listen := config.Get().API.Listen; listen != "" {
+		go func() {
+			if err := api.Serve(ctx, listen); err != nil {
+				logger.Error("API not served", logging.Err(err))
+			}
+		}()
+		logger.Info("API served", "url", "http://"+listen+"/api/")
+	}
+
+	scheduler.Start(ctx)
+	logger.Info("Scheduler started", "tasks", len(scheduler.Status()))
 
 	// Create a list of triggers and create a wait group
-	// triggersList := triggers.GetTriggers([]string{"MinecraftServerStarted", "MinecraftServerStopped", "PlayerJoinedMinecraftServer"}) // Example with selected triggers
-	triggersList := triggers.GetTriggers([]string{})
-	fmt.Println("✔ Triggers loaded : ", len(triggersList), " triggers.")
-	console.ProcessLogFiles("/opt/serversentinel/serverslog/", triggersList)
+	// The "triggers" configuration key selects triggers by name, ex: ["MinecraftServerStarted", "PlayerJoinedMinecraftServer"]. Empty means all triggers
+	triggersList := sentinel.Triggers(config.Get().Triggers)
+	logger.Info("Triggers loaded", "triggers", len(triggersList))
+
+	// Reload the configuration on SIGHUP or when the file changes
+	go watchConfigReload(ctx, configFile, sentinel)
+
+	// Tell systemd the daemon is ready, and keep its watchdog notified while the log listeners are healthy
+	systemd.NotifyReady(daemonStatus(scheduler))
+	go systemd.RunWatchdog(ctx, console.CheckListenersHealth)
+
+	// Returns when the daemon is asked to stop, or earlier if there is nothing to listen to
+	listenErr := console.ProcessLogFiles(ctx, config.Get().ServersLogPath, triggersList)
+	if listenErr == nil {
+		<-ctx.Done()
+	}
+	stop()
+	logger.Info("Stopping the Server Sentinel daemon")
+	systemd.NotifyStopping()
+
+	scheduler.Stop()
+	if !scheduler.Wait(shutdownTimeout) {
+		logger.Warn("Some scheduled tasks were still running, they are abandoned", "timeout", shutdownTimeout.String())
+	}
+
+	if dropped := discord.FlushOutbox(shutdownTimeout); dropped > 0 {
+		logger.Warn("Discord messages not sent before stopping", "dropped", dropped)
+	} else {
+		logger.Info("Discord outbox flushed")
+	}
 
-	fmt.Println("♦ Server Sentinel daemon stopped.")
+	if err := db.CloseDatabase(); err != nil {
+		logger.Error("Database not closed", logging.Err(err))
+	}
+
+	if listenErr != nil {
+		return fmt.Errorf("FATAL ERROR WHILE LISTENING TO THE LOG FILES: %v", listenErr)
+	}
+	logger.Info("Server Sentinel daemon stopped")
+	return nil
+}
+
+// daemonStatus is the status displayed by "systemctl status"
+func daemonStatus(scheduler *periodic.Scheduler) string {
+	return fmt.Sprintf("Listening to the logs in %s, %d scheduled tasks", config.Get().ServersLogPath, len(scheduler.Status()))
 }
 
 // Function to start a server by its ID. This function is use in the CLI command "start-server"
@@ -165,7 +276,7 @@ func commandStartStopServerWithID(serverID string, action string) {
 	}
 
 	// Because it's a CLI command, we need to load the configuration file
-	err = config.LoadConfig("/opt/serversentinel/config.json")
+	err = config.LoadConfig(config.ResolveConfigPath(configPath))
 	if err != nil {
 		log.Fatalf("FATAL ERROR LOADING CONFIG JSON FILE: %v", err)
 		return
@@ -177,46 +288,46 @@ func commandStartStopServerWithID(serverID string, action string) {
 		log.Fatalf("FATAL ERROR TESTING DATABASE CONNECTION: %v", err)
 	}
 
-	// Now we check if the server exists in the database
-	server, err := db.GetServerById(serverIDInt)
-	if err != nil {
-		log.Fatalf("FATAL ERROR GETTING SERVER BY ID: %v", err)
-		return
-	}
-
-	// If the action is "stop", we stop the server
+	// The API does the same through the control package
 	if action == "stop" {
-		// Stop the server
-		err = tmux.StopServerTmux(server.Nom)
+		err = control.StopServer(audit.CLI(), serverIDInt)
 		if err != nil {
 			log.Fatalf("FATAL ERROR STOPPING SERVER: %v", err)
-			return
 		}
 		return
 	}
 
-	// If the action is "start", we first check if the server is supposed to be running
-	primaryServerID := db.GetPrimaryServerId()
-	secondaryServerID := db.GetSecondaryServerId()
-	partenariatServerID := db.GetPartenariatServerId()
+	// A server that is not supposed to be running is started as the secondary server
+	message, err := control.StartServer(audit.CLI(), serverIDInt)
+	if err != nil {
+		log.Fatalf("FATAL ERROR STARTING SERVER: %v", err)
+	}
+	fmt.Println(message)
+}
 
-	if server.ID != primaryServerID && server.ID != secondaryServerID && server.ID != partenariatServerID {
-		// The server is not supposed to be running, then we start it as a secondary server
-		err = db.SetSecondaryServerId(server.ID)
-		if err != nil {
-			log.Fatalf("FATAL ERROR SETTING SECONDARY SERVER ID: %v", err)
-			return
-		}
-	} else {
-		// The server is supposed to be running, then it will be started by "CheckRunningServers" function
+// Function to load the configuration file and connect to the database, used by the CLI commands
+func initCLI() {
+	err := config.LoadConfig(config.ResolveConfigPath(configPath))
+	if err != nil {
+		log.Fatalf("FATAL ERROR LOADING CONFIG JSON FILE: %v", err)
 	}
 
-	// Start the server
-	message, err := tmux.CheckRunningServers()
+	err = db.ConnectToDatabase()
 	if err != nil {
-		log.Fatalf("FATAL ERROR CHECKING RUNNING SERVERS: %v", err)
-		return
+		log.Fatalf("FATAL ERROR TESTING DATABASE CONNECTION: %v", err)
 	}
+}
 
-	fmt.Println(message)
+// Function to get a server from a CLI argument containing its ID
+func getServerFromArg(serverID string) models.Server {
+	serverIDInt, err := strconv.Atoi(serverID)
+	if err != nil {
+		log.Fatalf("FATAL ERROR: SERVER ID IS NOT A NUMBER: %v", err)
+	}
+
+	server, err := db.GetServerById(serverIDInt)
+	if err != nil {
+		log.Fatalf("FATAL ERROR GETTING SERVER BY ID: %v", err)
+	}
+	return server
 }
@@ -0,0 +1,436 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Corentin-cott/ServeurSentinel/config"
+	"github.com/Corentin-cott/ServeurSentinel/internal/db"
+	"github.com/Corentin-cott/ServeurSentinel/internal/logging"
+	"github.com/Corentin-cott/ServeurSentinel/internal/models"
+	"github.com/spf13/cobra"
+)
+
+// playerSummary is a player as shown by "players list" and "players search"
+type playerSummary struct {
+	ID              int       `json:"id"`
+	Game            string    `json:"game"`
+	AccountID       string    `json:"accountId"`
+	Name            string    `json:"name"`
+	FirstConnection time.Time `json:"firstConnection"`
+	LastConnection  time.Time `json:"lastConnection"`
+	DiscordUserID   int       `json:"discordUserId,omitempty"`
+}
+
+// playerDetails is a player as shown by "players show"
+type playerDetails struct {
+	playerSummary
+	Names       []playerNameView   `json:"names"`
+	DiscordUser *discordUserView   `json:"discordUser,omitempty"`
+	Playtime    int64              `json:"playtimeSeconds"`
+	Servers     []playerServerView `json:"servers"`
+}
+
+type discordUserView struct {
+	ID        int    `json:"id"`
+	DiscordID string `json:"discordId"`
+	Name      string `json:"name"`
+}
+
+type playerNameView struct {
+	Name      string    `json:"name"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+}
+
+type playerServerView struct {
+	ServerID        int            `json:"serverId"`
+	ServerName      string         `json:"serverName"`
+	Playtime        int64          `json:"playtimeSeconds"`
+	Deaths          int            `json:"deaths"`
+	Kills           int            `json:"kills"`
+	PlayerKills     int            `json:"playerKills"`
+	BlocksDestroyed int            `json:"blocksDestroyed"`
+	BlocksPlaced    int            `json:"blocksPlaced"`
+	TotalDistance   int            `json:"totalDistanceCm"`
+	Distances       map[string]int `json:"distancesCm"`
+	Advancements    int            `json:"advancements"`
+	RecordedAt      time.Time      `json:"recordedAt"`
+}
+
+// playerActivity is how much a player played on a server during a period, as shown by "players activity"
+type playerActivity struct {
+	ServerID   int       `json:"serverId"`
+	ServerName string    `json:"serverName"`
+	AccountID  string    `json:"accountId"`
+	Name       string    `json:"name"`
+	From       time.Time `json:"from"`
+	To         time.Time `json:"to"`
+	Playtime   int64     `json:"playtimeSeconds"`
+	models.MinecraftStatsCounters
+}
+
+// Command: serversentinel players [list|show|search|activity]
+func newPlayersCmd() *cobra.Command {
+	var playersCmd = &cobra.Command{
+		Use:   "players",
+		Short: "Inspects the players known by the database",
+	}
+
+	var jsonOutput bool
+	playersCmd.PersistentFlags().BoolVar(&jsonOutput, "json", false, "print JSON instead of text")
+
+	// Filters shared by list and search
+	var game string
+	var serverID int
+	var seenWithin time.Duration
+	addFilterFlags := func(cmd *cobra.Command) {
+		cmd.Flags().StringVar(&game, "game", "", "only the players of a game, ex: Minecraft")
+		cmd.Flags().IntVar(&serverID, "server", 0, "only the players seen on a server, by ID")
+		cmd.Flags().DurationVar(&seenWithin, "seen-within", 0, "only the players connected during this duration, ex: 168h")
+	}
+	filter := func(search string) db.PlayerFilter {
+		playerFilter := db.PlayerFilter{Jeu: game, ServerID: serverID, Search: search}
+		if seenWithin > 0 {
+			playerFilter.SeenSince = time.Now().Add(-seenWithin)
+		}
+		return playerFilter
+	}
+
+	// Command: serversentinel players list
+	var listCmd = &cobra.Command{
+		Use:   "list",
+		Short: "Lists the players, the last seen first",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			redirectMessagesForJSON(jsonOutput)
+			initCLI()
+			printPlayers(findPlayers(filter("")), jsonOutput)
+		},
+	}
+	addFilterFlags(listCmd)
+
+	// Command: serversentinel players search [text]
+	var searchCmd = &cobra.Command{
+		Use:   "search [text]",
+		Short: "Searches the players by name or account ID",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			redirectMessagesForJSON(jsonOutput)
+			initCLI()
+			printPlayers(findPlayers(filter(args[0])), jsonOutput)
+		},
+	}
+	addFilterFlags(searchCmd)
+
+	// Command: serversentinel players show [player]
+	var showCmd = &cobra.Command{
+		Use:   "show [player]",
+		Short: "Shows a player, by ID, account ID or name",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			redirectMessagesForJSON(jsonOutput)
+			initCLI()
+			player := getPlayerFromArg(args[0])
+			printPlayerDetails(getPlayerDetails(player), jsonOutput)
+		},
+	}
+
+	// Command: serversentinel players activity
+	var since, until time.Duration
+	var activityCmd = &cobra.Command{
+		Use:   "activity",
+		Short: "Shows how much the players played during a period, the most active first",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			redirectMessagesForJSON(jsonOutput)
+			initCLI()
+			now := time.Now()
+			from, to := now.Add(-since), now.Add(-until)
+			if !from.Before(to) {
+				log.Fatalf("FATAL ERROR: --since MUST BE LONGER THAN --until")
+			}
+			printActivity(getActivity(serverID, from, to), from, to, jsonOutput)
+		},
+	}
+	activityCmd.Flags().DurationVar(&since, "since", 7*24*time.Hour, "start of the period, before now")
+	activityCmd.Flags().DurationVar(&until, "until", 0, "end of the period, before now")
+	activityCmd.Flags().IntVar(&serverID, "server", 0, "only a server, by ID")
+
+	playersCmd.AddCommand(listCmd)
+	playersCmd.AddCommand(searchCmd)
+	playersCmd.AddCommand(showCmd)
+	playersCmd.AddCommand(activityCmd)
+	return playersCmd
+}
+
+func findPlayers(filter db.PlayerFilter) []playerSummary {
+	players, err := db.FindPlayers(filter)
+	if err != nil {
+		log.Fatalf("FATAL ERROR FINDING PLAYERS: %v", err)
+	}
+	summaries := make([]playerSummary, 0, len(players))
+	for _, player := range players {
+		summaries = append(summaries, getPlayerSummary(player))
+	}
+	return summaries
+}
+
+func getPlayerSummary(player models.Player) playerSummary {
+	summary := playerSummary{
+		ID:              player.ID,
+		Game:            player.Jeu,
+		AccountID:       player.CompteID,
+		FirstConnection: player.PremiereCo,
+		LastConnection:  player.DerniereCo,
+	}
+	if player.UtilisateurID != -1 {
+		summary.DiscordUserID = player.UtilisateurID
+	}
+	if names, err := db.GetPlayerNames(player.ID); err == nil && len(names) > 0 {
+		summary.Name = names[0].Pseudo
+	}
+	return summary
+}
+
+// getPlayerFromArg finds a player by ID, account ID or name, a name must match a single player
+func getPlayerFromArg(arg string) models.Player {
+	if playerID, err := strconv.Atoi(arg); err == nil {
+		player, err := db.GetPlayerById(playerID)
+		if err != nil {
+			log.Fatalf("FATAL ERROR GETTING PLAYER BY ID: %v", err)
+		}
+		return player
+	}
+	if player, err := db.GetPlayerByUUID(arg); err == nil {
+		return player
+	}
+
+	players, err := db.FindPlayers(db.PlayerFilter{Search: arg})
+	if err != nil {
+		log.Fatalf("FATAL ERROR FINDING PLAYERS: %v", err)
+	}
+	var matches []models.Player
+	for _, player := range players {
+		names, _ := db.GetPlayerNames(player.ID)
+		for _, name := range names {
+			if strings.EqualFold(name.Pseudo, arg) {
+				matches = append(matches, player)
+				break
+			}
+		}
+	}
+	switch len(matches) {
+	case 0:
+		log.Fatalf("FATAL ERROR: NO PLAYER NAMED %s", arg)
+	case 1:
+		return matches[0]
+	default:
+		var ids []string
+		for _, player := range matches {
+			ids = append(ids, strconv.Itoa(player.ID))
+		}
+		log.Fatalf("FATAL ERROR: SEVERAL PLAYERS WERE NAMED %s (IDS %s), USE AN ID", arg, strings.Join(ids, ", "))
+	}
+	return models.Player{}
+}
+
+func getPlayerDetails(player models.Player) playerDetails {
+	details := playerDetails{playerSummary: getPlayerSummary(player), Names: []playerNameView{}, Servers: []playerServerView{}}
+
+	names, err := db.GetPlayerNames(player.ID)
+	if err != nil {
+		log.Fatalf("FATAL ERROR GETTING PLAYER NAMES: %v", err)
+	}
+	for _, name := range names {
+		details.Names = append(details.Names, playerNameView{Name: name.Pseudo, FirstSeen: name.PremiereVue, LastSeen: name.DerniereVue})
+	}
+
+	if player.UtilisateurID != -1 {
+		user, err := db.GetDiscordUser(player.UtilisateurID)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "✘ "+err.Error())
+		} else {
+			details.DiscordUser = &discordUserView{ID: user.ID, DiscordID: user.DiscordID, Name: user.Pseudo}
+		}
+	}
+
+	allStats, err := db.GetMinecraftPlayerGameStatistics(player.CompteID)
+	if err != nil {
+		log.Fatalf("FATAL ERROR GETTING PLAYER STATISTICS: %v", err)
+	}
+	for _, stats := range allStats {
+		serverName, err := db.GetServerNameById(stats.ServerID)
+		if err != nil {
+			serverName = "?"
+		}
+		playtime := ticksToSeconds(stats.TimePlayed)
+		details.Playtime += playtime
+		details.Servers = append(details.Servers, playerServerView{
+			ServerID:        stats.ServerID,
+			ServerName:      serverName,
+			Playtime:        playtime,
+			Deaths:          stats.Deaths,
+			Kills:           stats.Kills,
+			PlayerKills:     stats.PlayerKills,
+			BlocksDestroyed: stats.BlocksDestroyed,
+			BlocksPlaced:    stats.BlocksPlaced,
+			TotalDistance:   stats.TotalDistance,
+			Distances:       stats.Distances,
+			Advancements:    len(stats.Achievements),
+			RecordedAt:      stats.LastRecordedTime,
+		})
+	}
+	return details
+}
+
+func getActivity(serverID int, from time.Time, to time.Time) []playerActivity {
+	deltas, err := db.GetMinecraftStatsDeltas(serverID, from, to)
+	if err != nil {
+		log.Fatalf("FATAL ERROR GETTING STATISTICS DELTAS: %v", err)
+	}
+	activity := make([]playerActivity, 0, len(deltas))
+	for _, delta := range deltas {
+		serverName, err := db.GetServerNameById(delta.ServerID)
+		if err != nil {
+			serverName = "?"
+		}
+		name := ""
+		if player, err := db.GetPlayerByUUID(delta.PlayerUUID); err == nil {
+			name = getPlayerSummary(player).Name
+		}
+		activity = append(activity, playerActivity{
+			ServerID:               delta.ServerID,
+			ServerName:             serverName,
+			AccountID:              delta.PlayerUUID,
+			Name:                   name,
+			From:                   delta.From,
+			To:                     delta.To,
+			Playtime:               ticksToSeconds(delta.TimePlayed),
+			MinecraftStatsCounters: delta.MinecraftStatsCounters,
+		})
+	}
+	return activity
+}
+
+func printActivity(activity []playerActivity, from time.Time, to time.Time, jsonOutput bool) {
+	if jsonOutput {
+		printJSON(activity)
+		return
+	}
+	fmt.Printf("Activity from %s to %s :\n", formatLocalTime(from), formatLocalTime(to))
+	if len(activity) == 0 {
+		fmt.Println("  No statistics recorded during this period.")
+		return
+	}
+	for _, player := range activity {
+		name := player.Name
+		if name == "" {
+			name = player.AccountID
+		}
+		fmt.Printf("  - %s on %s : %s played, %d deaths, %d kills, %d blocks destroyed, %d blocks placed, %d m travelled, %d advancements\n",
+			name, player.ServerName, formatPlaytime(player.Playtime), player.Deaths, player.Kills,
+			player.BlocksDestroyed, player.BlocksPlaced, player.TotalDistance/100, player.Advancements)
+	}
+}
+
+// Minecraft counts the play time in ticks, 20 per second
+func ticksToSeconds(ticks int) int64 {
+	return int64(ticks) / 20
+}
+
+func printPlayers(players []playerSummary, jsonOutput bool) {
+	if jsonOutput {
+		printJSON(players)
+		return
+	}
+	if len(players) == 0 {
+		fmt.Println("No player found.")
+		return
+	}
+
+	fmt.Printf("Players (%d) :\n", len(players))
+	for _, player := range players {
+		name := player.Name
+		if name == "" {
+			name = "?"
+		}
+		linked := ""
+		if player.DiscordUserID != 0 {
+			linked = "  ♦ linked"
+		}
+		fmt.Printf("  - #%d  %s  %s  %s  last seen %s%s\n", player.ID, player.Game, name, player.AccountID, formatLocalTime(player.LastConnection), linked)
+	}
+}
+
+func printPlayerDetails(details playerDetails, jsonOutput bool) {
+	if jsonOutput {
+		printJSON(details)
+		return
+	}
+
+	fmt.Printf("Player #%d (%s)\n", details.ID, details.Game)
+	fmt.Println("  Account ID : " + details.AccountID)
+	if len(details.Names) > 0 {
+		var names []string
+		for _, name := range details.Names {
+			names = append(names, name.Name)
+		}
+		fmt.Println("  Names : " + strings.Join(names, ", "))
+	}
+	fmt.Println("  First connection : " + formatLocalTime(details.FirstConnection))
+	fmt.Println("  Last connection : " + formatLocalTime(details.LastConnection))
+	if details.DiscordUser != nil {
+		fmt.Printf("  Discord user : %s (%s)\n", details.DiscordUser.Name, details.DiscordUser.DiscordID)
+	} else {
+		fmt.Println("  Discord user : not linked")
+	}
+	fmt.Println("  Total playtime : " + formatPlaytime(details.Playtime))
+
+	if len(details.Servers) == 0 {
+		return
+	}
+	fmt.Println("  Statistics by server :")
+	for _, server := range details.Servers {
+		fmt.Printf("    - %s (#%d) : %s played, %d deaths, %d kills, %d player kills, %d blocks destroyed, %d blocks placed, %d m travelled, %d advancements, recorded on %s\n",
+			server.ServerName, server.ServerID, formatPlaytime(server.Playtime), server.Deaths, server.Kills, server.PlayerKills,
+			server.BlocksDestroyed, server.BlocksPlaced, server.TotalDistance/100, server.Advancements, formatLocalTime(server.RecordedAt))
+	}
+}
+
+// Where the messages of the commands are written, with --json they go to stderr with the logs so scripts can read the JSON
+// on stdout as is
+var messageOutput io.Writer = os.Stdout
+
+func redirectMessagesForJSON(jsonOutput bool) {
+	if jsonOutput {
+		messageOutput = os.Stderr
+		logging.SetOutput(os.Stderr)
+	}
+}
+
+func printJSON(value any) {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(value); err != nil {
+		log.Fatalf("FATAL ERROR ENCODING JSON: %v", err)
+	}
+}
+
+// formatLocalTime shows a date in the time zone of the configuration, "never" if it is unknown
+func formatLocalTime(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	return t.In(config.Get().Location()).Format("02/01/2006 15:04:05")
+}
+
+func formatPlaytime(seconds int64) string {
+	return fmt.Sprintf("%dh%02dm", seconds/3600, seconds%3600/60)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Corentin-cott/ServeurSentinel/config"
+	"github.com/Corentin-cott/ServeurSentinel/internal/console"
+	"github.com/Corentin-cott/ServeurSentinel/internal/discord"
+	periodic "github.com/Corentin-cott/ServeurSentinel/internal/events"
+	"github.com/Corentin-cott/ServeurSentinel/internal/logging"
+	"github.com/Corentin-cott/ServeurSentinel/internal/systemd"
+)
+
+// How often the configuration file is checked for changes
+var configWatchInterval = 5 * time.Second
+
+// Settings that are only read when the daemon starts, a change is reported but needs a restart
+var restartOnlyPrefixes = []string{"db.", "serversLogPath", "statePath", "metrics.", "api.listen"}
+
+// Settings used to register the scheduled tasks, the scheduler is only reloaded when one of them changed
+var schedulerPrefixes = []string{"scheduler.", "restarts.", "periodicEvents"}
+
+// Only one reload at a time, SIGHUP and the file watcher can fire together
+var reloadMutex sync.Mutex
+
+// watchConfigReload reloads the configuration on SIGHUP or when the configuration file changes, until the context is cancelled
+func watchConfigReload(ctx context.Context, configFile string, sentinel *Sentinel) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	lastModTime, lastSize := statConfigFile(configFile)
+	ticker := time.NewTicker(configWatchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+			logger.Info("SIGHUP received, reloading the configuration")
+		case <-ticker.C:
+			modTime, size := statConfigFile(configFile)
+			if modTime.Equal(lastModTime) && size == lastSize {
+				continue
+			}
+			logger.Info("Configuration file changed, reloading the configuration")
+		}
+
+		lastModTime, lastSize = statConfigFile(configFile)
+		reloadConfig(configFile, sentinel)
+	}
+}
+
+func statConfigFile(configFile string) (time.Time, int64) {
+	info, err := os.Stat(configFile)
+	if err != nil {
+		return time.Time{}, -1
+	}
+	return info.ModTime(), info.Size()
+}
+
+// reloadConfig validates the new configuration, then swaps it and re-registers the triggers and the scheduled tasks
+func reloadConfig(configFile string, sentinel *Sentinel) {
+	scheduler := sentinel.Scheduler
+	reloadMutex.Lock()
+	defer reloadMutex.Unlock()
+
+	systemd.NotifyReloading()
+	defer func() { systemd.NotifyReady(daemonStatus(scheduler)) }()
+
+	previous := config.Get()
+	next, err := config.ReadConfig(configFile)
+	if err != nil {
+		logger.Error("Configuration not reloaded, the current one is kept", logging.Err(err))
+		sendReloadReport("✘ Configuration non rechargée", "La configuration actuelle est conservée.\n\n"+err.Error(), previous.EmbedColors.Error)
+		return
+	}
+
+	changes := config.Diff(previous, &next)
+	if len(changes) == 0 {
+		logger.Info("Configuration reloaded, nothing changed")
+		return
+	}
+
+	// The tasks are registered against the new configuration before it is applied, so an invalid schedule keeps everything as it was
+	if hasChangeWithPrefix(changes, schedulerPrefixes) {
+		err = scheduler.Reload(func(s *periodic.Scheduler) error {
+			return periodic.RegisterTasks(s, &next)
+		})
+	}
+	if err != nil {
+		logger.Error("Configuration not reloaded, the current one is kept", logging.Err(err))
+		sendReloadReport("✘ Configuration non rechargée", "La configuration actuelle est conservée.\n\n"+err.Error(), previous.EmbedColors.Error)
+		return
+	}
+
+	config.Set(next)
+	console.SetTriggers(sentinel.Triggers(next.Triggers))
+
+	var report strings.Builder
+	needsRestart := false
+	for _, change := range changes {
+		report.WriteString("- " + change)
+		if hasChangeWithPrefix([]string{change}, restartOnlyPrefixes) {
+			report.WriteString(" *(redémarrage nécessaire)*")
+			needsRestart = true
+		}
+		report.WriteString("\n")
+	}
+
+	logger.Info("Configuration reloaded", "changes", changes)
+	color := next.EmbedColors.Good
+	if needsRestart {
+		color = next.EmbedColors.Warning
+	}
+	sendReloadReport("♦ Configuration rechargée", report.String(), color)
+}
+
+func hasChangeWithPrefix(changes []string, prefixes []string) bool {
+	for _, change := range changes {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(change, prefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func sendReloadReport(title string, message string, color string) {
+	err := discord.SendDiscordEmbed(config.Get().Bots["mineotterBot"], config.Get().DiscordChannels.BotAdminChannelID, title, message, color)
+	if err != nil {
+		logger.Error("Discord message not sent", logging.Err(err))
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/Corentin-cott/ServeurSentinel/config"
+	"github.com/Corentin-cott/ServeurSentinel/internal/discord"
+	"github.com/Corentin-cott/ServeurSentinel/internal/reports"
+	"github.com/spf13/cobra"
+)
+
+// Command: serversentinel report [--since 168h] [--format markdown|html|json] [--output file] [--post]
+func newReportCmd() *cobra.Command {
+	var since, format, output string
+	var post bool
+	var reportCmd = &cobra.Command{
+		Use:   "report",
+		Short: "Makes the activity report of a period ending now",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			redirectMessagesForJSON(format == "json" && output == "")
+			initCLI()
+			period, err := time.ParseDuration(since)
+			if err != nil || period <= 0 {
+				log.Fatalf("FATAL ERROR: --since must be a duration like 168h, found %q", since)
+			}
+			now := time.Now()
+			report, err := reports.Build(now.Add(-period), now)
+			if err != nil {
+				log.Fatalf("FATAL ERROR BUILDING REPORT: %v", err)
+			}
+
+			if post {
+				conf := config.Get()
+				if conf.Report.ChannelID == "" {
+					log.Fatalf("FATAL ERROR: report.channelID is empty, the report can't be posted")
+				}
+				if err := discord.SendDiscordEmbedWithModel(conf.Bots["mineotterBot"], conf.Report.ChannelID, reports.Embed(report)); err != nil {
+					log.Fatalf("FATAL ERROR POSTING REPORT: %v", err)
+				}
+				fmt.Fprintln(messageOutput, "✔ Report posted.")
+			}
+
+			var content string
+			switch format {
+			case "markdown":
+				content = reports.Markdown(report)
+			case "html":
+				content, err = reports.HTML(report)
+				if err != nil {
+					log.Fatalf("FATAL ERROR RENDERING REPORT: %v", err)
+				}
+			case "json":
+				if output == "" {
+					printJSON(report)
+					return
+				}
+				data, err := json.MarshalIndent(report, "", "  ")
+				if err != nil {
+					log.Fatalf("FATAL ERROR ENCODING JSON: %v", err)
+				}
+				content = string(data) + "\n"
+			default:
+				log.Fatalf("FATAL ERROR: --format must be markdown, html or json, found %q", format)
+			}
+
+			if output == "" {
+				fmt.Print(content)
+				return
+			}
+			if err := os.WriteFile(output, []byte(content), 0640); err != nil {
+				log.Fatalf("FATAL ERROR WRITING REPORT: %v", err)
+			}
+			fmt.Println("✔ Report written to " + output)
+		},
+	}
+	reportCmd.Flags().StringVar(&since, "since", "168h", "period of the report, ending now")
+	reportCmd.Flags().StringVarP(&format, "format", "f", "markdown", "markdown, html or json")
+	reportCmd.Flags().StringVarP(&output, "output", "o", "", "file to write the report to, instead of the standard output")
+	reportCmd.Flags().BoolVar(&post, "post", false, "also post the report on Discord")
+	return reportCmd
+}
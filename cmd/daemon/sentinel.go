@@ -0,0 +1,55 @@
+package main
+
+import (
+	"time"
+
+	"github.com/Corentin-cott/ServeurSentinel/internal/db"
+	"github.com/Corentin-cott/ServeurSentinel/internal/discord"
+	periodic "github.com/Corentin-cott/ServeurSentinel/internal/events"
+	"github.com/Corentin-cott/ServeurSentinel/internal/logging"
+	"github.com/Corentin-cott/ServeurSentinel/internal/metrics"
+	"github.com/Corentin-cott/ServeurSentinel/internal/models"
+	"github.com/Corentin-cott/ServeurSentinel/internal/triggers"
+)
+
+// Sentinel is the daemon, it holds the repositories and the notifier given to the triggers and the account links. The
+// scheduled tasks, the API and the CLI commands still use the db, discord and config packages directly
+type Sentinel struct {
+	Servers   db.ServerRepository
+	Players   db.PlayerRepository
+	Stats     db.StatsRepository
+	Events    db.EventRepository
+	Notifier  discord.Notifier
+	Actions   *triggers.Actions
+	Scheduler *periodic.Scheduler
+}
+
+// NewSentinel wires a Sentinel on a repository and a notifier, ex: db.NewMemoryRepository() and a discord.RecordingNotifier
+func NewSentinel(repo db.Repository, notifier discord.Notifier) *Sentinel {
+	return &Sentinel{
+		Servers:   repo,
+		Players:   repo,
+		Stats:     repo,
+		Events:    repo,
+		Notifier:  notifier,
+		Actions:   triggers.NewActions(repo, repo, repo, notifier),
+		Scheduler: periodic.NewScheduler(),
+	}
+}
+
+// Triggers returns the triggers selected by name, all of them if none is selected
+func (s *Sentinel) Triggers(selectedTriggers []string) []models.Trigger {
+	return triggers.GetTriggers(s.Actions, selectedTriggers)
+}
+
+// LoadServerStates sets the running servers in the metrics from their last start, stop or crash, the triggers keep them up to date
+func (s *Sentinel) LoadServerStates() {
+	states, err := s.Events.GetLastServerStates(time.Now().UTC())
+	if err != nil {
+		logger.Error("Server states not loaded", logging.Err(err))
+		return
+	}
+	for _, state := range states {
+		metrics.SetServerUp(state.ServerID, state.Type == models.EventServerStarted)
+	}
+}
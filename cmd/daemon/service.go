@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"text/template"
+	"time"
+
+	"github.com/Corentin-cott/ServeurSentinel/config"
+	"github.com/spf13/cobra"
+)
+
+// Unit file of the daemon. The game servers run in tmux sessions started by the daemon, so the hardening must not hide
+// /tmp (tmux socket) nor the home directories (servers folders), and KillMode=process keeps them alive when the daemon
+// is restarted
+var unitTemplate = template.Must(template.New("unit").Parse(`[Unit]
+Description=ServerSentinel, game servers manager
+Documentation=https://github.com/Corentin-cott/ServeurSentinel
+Wants=network-online.target
+After=network-online.target mysql.service mariadb.service
+
+[Service]
+Type=notify
+NotifyAccess=main
+User={{.User}}
+Group={{.Group}}
+ExecStart={{.Executable}} daemon --config {{.ConfigPath}}
+ExecReload=/bin/kill -HUP $MAINPID
+Restart=on-failure
+RestartSec=10s
+WatchdogSec={{.WatchdogSec}}
+TimeoutStopSec={{.TimeoutStopSec}}
+KillMode=process
+
+NoNewPrivileges=yes
+ProtectSystem=full
+ProtectKernelTunables=yes
+ProtectKernelModules=yes
+ProtectKernelLogs=yes
+ProtectControlGroups=yes
+ProtectClock=yes
+RestrictSUIDSGID=yes
+RestrictRealtime=yes
+RestrictNamespaces=yes
+LockPersonality=yes
+RestrictAddressFamilies=AF_UNIX AF_INET AF_INET6
+UMask=0027
+
+[Install]
+WantedBy=multi-user.target
+`))
+
+// unitSettings are the values of the unit file
+type unitSettings struct {
+	User           string
+	Group          string
+	Executable     string
+	ConfigPath     string
+	WatchdogSec    int
+	TimeoutStopSec int
+}
+
+// Command: serversentinel install-service
+func newInstallServiceCmd() *cobra.Command {
+	var userName string
+	var unitPath string
+	var watchdog time.Duration
+	var dryRun bool
+
+	var installServiceCmd = &cobra.Command{
+		Use:   "install-service",
+		Short: "Writes the systemd unit file of the daemon and creates its directories",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			configFile, err := filepath.Abs(config.ResolveConfigPath(configPath))
+			if err != nil {
+				log.Fatalf("FATAL ERROR RESOLVING CONFIG PATH: %v", err)
+			}
+			if err := config.LoadConfig(configFile); err != nil {
+				log.Fatalf("FATAL ERROR LOADING CONFIG JSON FILE: %v", err)
+			}
+
+			executable, err := os.Executable()
+			if err != nil {
+				log.Fatalf("FATAL ERROR GETTING EXECUTABLE PATH: %v", err)
+			}
+			if executable, err = filepath.EvalSymlinks(executable); err != nil {
+				log.Fatalf("FATAL ERROR GETTING EXECUTABLE PATH: %v", err)
+			}
+
+			serviceUser, err := user.Lookup(userName)
+			if err != nil {
+				log.Fatalf("FATAL ERROR: USER %s NOT FOUND, IT MUST BE THE USER RUNNING THE GAME SERVERS: %v", userName, err)
+			}
+			group, err := user.LookupGroupId(serviceUser.Gid)
+			if err != nil {
+				log.Fatalf("FATAL ERROR: GROUP OF USER %s NOT FOUND: %v", userName, err)
+			}
+
+			// The stop timeout leaves time to the shutdown of the daemon, which waits for the tasks then for the Discord outbox
+			var unit bytes.Buffer
+			err = unitTemplate.Execute(&unit, unitSettings{
+				User:           serviceUser.Username,
+				Group:          group.Name,
+				Executable:     executable,
+				ConfigPath:     configFile,
+				WatchdogSec:    int(watchdog / time.Second),
+				TimeoutStopSec: int(2*shutdownTimeout/time.Second) + 15,
+			})
+			if err != nil {
+				log.Fatalf("FATAL ERROR GENERATING UNIT FILE: %v", err)
+			}
+
+			if dryRun {
+				fmt.Print(unit.String())
+				return
+			}
+
+			uid, _ := strconv.Atoi(serviceUser.Uid)
+			gid, _ := strconv.Atoi(serviceUser.Gid)
+			for _, dir := range []string{config.Get().LogPath, config.Get().ServersLogPath, config.Get().StatePath, config.Get().Backups.Path} {
+				if dir == "" {
+					continue
+				}
+				if err := createServiceDir(dir, uid, gid); err != nil {
+					log.Fatalf("FATAL ERROR CREATING SERVICE DIRECTORY: %v", err)
+				}
+				fmt.Println("✔ Directory " + dir + " owned by " + serviceUser.Username)
+			}
+
+			// The configuration contains secrets, only the service user may read it
+			if err := os.Chown(configFile, 0, gid); err != nil {
+				fmt.Println("✘ Could not give the configuration file to the group " + group.Name + ": " + err.Error())
+			} else if err := os.Chmod(configFile, 0640); err != nil {
+				fmt.Println("✘ Could not restrict the permissions of the configuration file: " + err.Error())
+			}
+
+			if err := os.WriteFile(unitPath, unit.Bytes(), 0644); err != nil {
+				log.Fatalf("FATAL ERROR WRITING UNIT FILE (ARE YOU ROOT?): %v", err)
+			}
+			fmt.Println("✔ Unit file written to " + unitPath)
+			fmt.Println("♦ Run \"systemctl daemon-reload && systemctl enable --now " + filepath.Base(unitPath) + "\" to start the daemon.")
+		},
+	}
+	installServiceCmd.Flags().StringVar(&userName, "user", "serversentinel", "user running the daemon, must be the one running the game servers")
+	installServiceCmd.Flags().StringVar(&unitPath, "unit-path", "/etc/systemd/system/serversentinel.service", "path of the unit file")
+	installServiceCmd.Flags().DurationVar(&watchdog, "watchdog", time.Minute, "systemd watchdog timeout, 0 disables it")
+	installServiceCmd.Flags().BoolVar(&dryRun, "dry-run", false, "print the unit file instead of installing it")
+	return installServiceCmd
+}
+
+// createServiceDir creates a directory owned by the service user, not readable by the other users
+func createServiceDir(dir string, uid int, gid int) error {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return fmt.Errorf("ERROR WHILE CREATING %s: %v", dir, err)
+	}
+	if err := os.Chown(dir, uid, gid); err != nil {
+		return fmt.Errorf("ERROR WHILE CHANGING THE OWNER OF %s: %v", dir, err)
+	}
+	if err := os.Chmod(dir, 0750); err != nil {
+		return fmt.Errorf("ERROR WHILE CHANGING THE PERMISSIONS OF %s: %v", dir, err)
+	}
+	return nil
+}
@@ -2,9 +2,14 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"strings"
+	"sync/atomic"
+	"time"
 
+	"github.com/Corentin-cott/ServeurSentinel/internal/logging"
 	"github.com/Corentin-cott/ServeurSentinel/internal/models"
 )
 
@@ -15,26 +20,230 @@ type Config struct {
 	DiscordWebhooks   map[string]models.DiscordWebhookConfig `json:"discordWebhooks"`
 	DB                models.DatabaseConfig                  `json:"db"`
 	PeriodicEvents    models.PeriodicEventsConfig            `json:"periodicEvents"`
+	Scheduler         models.SchedulerConfig                 `json:"scheduler"`
+	Restarts          models.RestartsConfig                  `json:"restarts"`
+	Backups           models.BackupConfig                    `json:"backups"`
+	EmbedColors       models.EmbedColorsConfig               `json:"embedColors"`
+	MinecraftAPI      models.MinecraftAPIConfig              `json:"minecraftAPI"`
+	StatsHistory      models.StatsHistoryConfig              `json:"statsHistory"`
+	Leaderboards      models.LeaderboardsConfig              `json:"leaderboards"`
+	Report            models.ReportConfig                    `json:"report"`
+	Metrics           models.MetricsConfig                   `json:"metrics"`
+	API               models.APIConfig                       `json:"api"`
+	Logging           models.LoggingConfig                   `json:"logging"`
+	Triggers          []string                               `json:"triggers"`
 	LogPath           string                                 `json:"logPath"`
+	ServersLogPath    string                                 `json:"serversLogPath"`
+	StatePath         string                                 `json:"statePath"`
+	Timezone          string                                 `json:"timezone"`
 	PeriodicEventsMin int                                    `json:"periodicEventsMin"`
+
+	// The time zone loaded once when the configuration is read
+	location *time.Location
+}
+
+// DefaultConfigPath is the configuration file used when neither the --config flag nor SERVERSENTINEL_CONFIG are set
+const DefaultConfigPath = "/opt/serversentinel/config.json"
+
+// ConfigPathEnv is the environment variable that can contain the path of the configuration file
+const ConfigPathEnv = "SERVERSENTINEL_CONFIG"
+
+// The configuration in use, swapped as a whole when reloaded so readers never see a half-updated configuration
+var appConfig atomic.Pointer[Config]
+
+var logger = logging.For("config")
+
+// Get returns the configuration in use. The returned configuration must not be modified
+func Get() *Config {
+	conf := appConfig.Load()
+	if conf == nil {
+		return &Config{} // Not loaded yet
+	}
+	return conf
+}
+
+// Set replaces the configuration in use, and the level, format and secrets of the logs with it
+func Set(conf Config) {
+	appConfig.Store(&conf)
+	logging.SetSecrets(conf.Secrets()...)
+	if err := logging.Setup(conf.Logging.Level, conf.Logging.Format); err != nil {
+		logger.Error("Log settings not applied", logging.Err(err))
+	}
+}
+
+// Secrets returns the values of the configuration that are never displayed, ex: the bot tokens
+func (c *Config) Secrets() []string {
+	var values []string
+	for key, encoded := range flattenConfig(c) {
+		var value string
+		if !secretKeys[key[strings.LastIndex(key, ".")+1:]] || json.Unmarshal([]byte(encoded), &value) != nil {
+			continue
+		}
+		if value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// Location returns the time zone of the configuration. Dates are stored in UTC and only shown in this time zone
+func (c *Config) Location() *time.Location {
+	if c.location != nil {
+		return c.location
+	}
+	location, err := time.LoadLocation(c.Timezone) // Configuration not read from a file
+	if err != nil {
+		return time.UTC // Not possible once validated
+	}
+	return location
 }
 
-var AppConfig Config
+// ResolveConfigPath returns the configuration file to use : the flag value, else the environment variable, else the default path
+func ResolveConfigPath(flagValue string) string {
+	if flagValue != "" {
+		return flagValue
+	}
+	if envValue := os.Getenv(ConfigPathEnv); envValue != "" {
+		return envValue
+	}
+	return DefaultConfigPath
+}
 
 // LoadConfig loads the configuration from a JSON file
 func LoadConfig(configPath string) error {
-	file, err := os.Open(configPath)
+	conf, err := ReadConfig(configPath)
 	if err != nil {
-		return fmt.Errorf("error opening configuration file: %v", err)
+		return err
 	}
-	defer file.Close()
 
-	// Decode the JSON file
-	decoder := json.NewDecoder(file)
-	if err := decoder.Decode(&AppConfig); err != nil {
-		return fmt.Errorf("error decoding configuration: %v", err)
+	Set(conf)
+	logger.Info("Configuration loaded", "path", configPath)
+	return nil
+}
+
+// ReadConfig reads, completes and validates a configuration file without applying it
+func ReadConfig(configPath string) (Config, error) {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return Config{}, fmt.Errorf("error opening configuration file: %v", err)
 	}
 
-	fmt.Printf("✔ Configuration loaded successfully\n")
-	return nil
+	// First pass on a generic map, only to find the keys that don't exist in the Config struct
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return Config{}, fmt.Errorf("error decoding configuration: %s", describeJSONError(data, err))
+	}
+	problems := findUnknownKeys(raw)
+
+	var conf Config
+	if err := json.Unmarshal(data, &conf); err != nil {
+		return Config{}, fmt.Errorf("error decoding configuration: %s", describeJSONError(data, err))
+	}
+
+	applyDefaults(&conf)
+	applyEnvOverrides(&conf)
+	problems = append(problems, Validate(conf)...)
+	conf.location, _ = time.LoadLocation(conf.Timezone) // Left nil when invalid, it is reported above
+
+	if len(problems) > 0 {
+		return Config{}, fmt.Errorf("invalid configuration %s:\n  - %s", configPath, strings.Join(problems, "\n  - "))
+	}
+	return conf, nil
+}
+
+// applyDefaults fills the optional settings that are not in the configuration file
+func applyDefaults(conf *Config) {
+	if conf.DB.Driver == "" {
+		conf.DB.Driver = "mysql"
+	}
+	if conf.LogPath == "" {
+		conf.LogPath = "/var/log/serversentinel/"
+	}
+	if conf.ServersLogPath == "" {
+		conf.ServersLogPath = "/opt/serversentinel/serverslog/"
+	}
+	if conf.StatePath == "" {
+		conf.StatePath = "/var/lib/serversentinel/"
+	}
+	if conf.Timezone == "" {
+		conf.Timezone = "Europe/Paris"
+	}
+	if conf.MinecraftAPI.ProfilesURL == "" {
+		conf.MinecraftAPI.ProfilesURL = "https://api.mojang.com/users/profiles/minecraft/"
+	}
+	if conf.MinecraftAPI.HeadsURL == "" {
+		conf.MinecraftAPI.HeadsURL = "https://minotar.net/helm/"
+	}
+	if conf.MinecraftAPI.UUIDCacheTTL == "" {
+		conf.MinecraftAPI.UUIDCacheTTL = "168h"
+	}
+	if conf.StatsHistory.KeepAll == "" {
+		conf.StatsHistory.KeepAll = "168h"
+	}
+	if conf.StatsHistory.KeepDaily == "" {
+		conf.StatsHistory.KeepDaily = "8760h"
+	}
+	if conf.Leaderboards.Size == 0 {
+		conf.Leaderboards.Size = 10
+	}
+	// A token without a role gets the fewest rights
+	for name, token := range conf.API.Tokens {
+		if token.Role == "" {
+			token.Role = models.RoleViewer
+			conf.API.Tokens[name] = token
+		}
+	}
+	if conf.Logging.Level == "" {
+		conf.Logging.Level = "info"
+	}
+	if conf.Logging.Format == "" {
+		conf.Logging.Format = "text"
+	}
+	if conf.Report.Period == "" {
+		conf.Report.Period = "168h"
+	}
+	if conf.Report.KeepEvents == "" {
+		conf.Report.KeepEvents = "8760h"
+	}
+	if conf.EmbedColors.Good == "" {
+		conf.EmbedColors.Good = "#9adfba"
+	}
+	if conf.EmbedColors.Warning == "" {
+		conf.EmbedColors.Warning = "#ff8c00"
+	}
+	if conf.EmbedColors.Error == "" {
+		conf.EmbedColors.Error = "#ff0000"
+	}
+}
+
+// describeJSONError adds the line and column to a JSON decoding error
+func describeJSONError(data []byte, err error) string {
+	var offset int64
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		offset = syntaxErr.Offset
+	case errors.As(err, &typeErr):
+		offset = typeErr.Offset
+		if typeErr.Field != "" {
+			return fmt.Sprintf("line %s: \"%s\" must be of type %s, found %s", lineAndColumn(data, offset), typeErr.Field, typeErr.Type, typeErr.Value)
+		}
+	default:
+		return err.Error()
+	}
+	return fmt.Sprintf("line %s: %v", lineAndColumn(data, offset), err)
+}
+
+func lineAndColumn(data []byte, offset int64) string {
+	line, column := 1, 1
+	for i := int64(0); i < offset && i < int64(len(data)); i++ {
+		if data[i] == '\n' {
+			line++
+			column = 1
+		} else {
+			column++
+		}
+	}
+	return fmt.Sprintf("%d, column %d", line, column)
 }
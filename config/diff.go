@@ -0,0 +1,77 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Keys whose values must never be displayed, only reported as changed
+var secretKeys = map[string]bool{
+	"password": true,
+	"botToken": true,
+	"url":      true,
+	"token":    true,
+}
+
+// Diff returns a human readable line for each setting that differs between two configurations, secrets are hidden
+func Diff(previous *Config, next *Config) []string {
+	previousValues := flattenConfig(previous)
+	nextValues := flattenConfig(next)
+
+	keys := make(map[string]bool)
+	for key := range previousValues {
+		keys[key] = true
+	}
+	for key := range nextValues {
+		keys[key] = true
+	}
+
+	var changes []string
+	for _, key := range sortedKeys(keys) {
+		previousValue, inPrevious := previousValues[key]
+		nextValue, inNext := nextValues[key]
+		if inPrevious && inNext && previousValue == nextValue {
+			continue
+		}
+
+		lastPart := key[strings.LastIndex(key, ".")+1:]
+		switch {
+		case secretKeys[lastPart]:
+			changes = append(changes, key+" changed")
+		case !inPrevious:
+			changes = append(changes, fmt.Sprintf("%s added: %s", key, nextValue))
+		case !inNext:
+			changes = append(changes, fmt.Sprintf("%s removed", key))
+		default:
+			changes = append(changes, fmt.Sprintf("%s: %s → %s", key, previousValue, nextValue))
+		}
+	}
+	return changes
+}
+
+// flattenConfig turns a configuration into a map of "path.to.key" -> JSON value
+func flattenConfig(conf *Config) map[string]string {
+	values := make(map[string]string)
+	data, err := json.Marshal(conf)
+	if err != nil {
+		return values
+	}
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return values
+	}
+	flattenValue(raw, "", values)
+	return values
+}
+
+func flattenValue(value interface{}, path string, values map[string]string) {
+	if object, ok := value.(map[string]interface{}); ok && len(object) > 0 {
+		for key, child := range object {
+			flattenValue(child, joinPath(path, key), values)
+		}
+		return
+	}
+	data, _ := json.Marshal(value)
+	values[path] = string(data)
+}
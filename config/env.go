@@ -0,0 +1,67 @@
+package config
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Prefix of every environment variable read by ServerSentinel
+const envPrefix = "SERVERSENTINEL_"
+
+// applyEnvOverrides replaces the secrets and connection settings of the configuration file by environment variables when they are set :
+//   - SERVERSENTINEL_DB_DRIVER, SERVERSENTINEL_DB_PATH
+//   - SERVERSENTINEL_DB_HOST, SERVERSENTINEL_DB_PORT, SERVERSENTINEL_DB_USER, SERVERSENTINEL_DB_PASSWORD, SERVERSENTINEL_DB_NAME
+//   - SERVERSENTINEL_BOT_<BOT NAME>_TOKEN, ex: SERVERSENTINEL_BOT_MINEOTTERBOT_TOKEN
+//   - SERVERSENTINEL_WEBHOOK_<SERVER TYPE>_URL, ex: SERVERSENTINEL_WEBHOOK_PRIMARY_URL
+func applyEnvOverrides(conf *Config) {
+	if value, ok := lookupEnv("DB_DRIVER"); ok {
+		conf.DB.Driver = value
+	}
+	if value, ok := lookupEnv("DB_PATH"); ok {
+		conf.DB.Path = value
+	}
+	if value, ok := lookupEnv("DB_HOST"); ok {
+		conf.DB.Host = value
+	}
+	if value, ok := lookupEnv("DB_PORT"); ok {
+		if port, err := strconv.Atoi(value); err == nil {
+			conf.DB.Port = port
+		} else {
+			conf.DB.Port = -1 // Reported by the validation
+		}
+	}
+	if value, ok := lookupEnv("DB_USER"); ok {
+		conf.DB.User = value
+	}
+	if value, ok := lookupEnv("DB_PASSWORD"); ok {
+		conf.DB.Password = value
+	}
+	if value, ok := lookupEnv("DB_NAME"); ok {
+		conf.DB.Name = value
+	}
+
+	for botName, bot := range conf.Bots {
+		if value, ok := lookupEnv("BOT_" + envName(botName) + "_TOKEN"); ok {
+			bot.BotToken = value
+			conf.Bots[botName] = bot
+		}
+	}
+
+	for serverType, webhook := range conf.DiscordWebhooks {
+		if value, ok := lookupEnv("WEBHOOK_" + envName(serverType) + "_URL"); ok {
+			webhook.URL = value
+			conf.DiscordWebhooks[serverType] = webhook
+		}
+	}
+}
+
+func lookupEnv(name string) (string, bool) {
+	value, ok := os.LookupEnv(envPrefix + name)
+	return value, ok && value != ""
+}
+
+// envName turns a configuration key into an environment variable name part, ex: "mineotterBot" -> "MINEOTTERBOT"
+func envName(key string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "_", ".", "_", " ", "_").Replace(key))
+}
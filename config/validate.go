@@ -0,0 +1,357 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Corentin-cott/ServeurSentinel/internal/cron"
+	"github.com/Corentin-cott/ServeurSentinel/internal/models"
+)
+
+var discordIDRegex = regexp.MustCompile(`^[0-9]{17,20}$`)
+var colorRegex = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+var serverIDRegex = regexp.MustCompile(`^[0-9]+$`)
+
+// Statistics a leaderboard can rank
+var leaderboardStats = []string{"playtime", "deaths", "mobsKilled", "distance", "blocksMined", "advancements"}
+
+// Validate checks the values of a configuration and returns a message for each problem found
+func Validate(conf Config) []string {
+	var problems []string
+
+	// Database, the settings needed depend on the driver
+	switch conf.DB.Driver {
+	case "mysql":
+		if conf.DB.Host == "" {
+			problems = append(problems, "db.host is missing")
+		}
+		if conf.DB.Port <= 0 || conf.DB.Port > 65535 {
+			problems = append(problems, fmt.Sprintf("db.port must be between 1 and 65535, found %d", conf.DB.Port))
+		}
+		if conf.DB.User == "" {
+			problems = append(problems, "db.user is missing")
+		}
+		if conf.DB.Name == "" {
+			problems = append(problems, "db.name is missing")
+		}
+	case "sqlite":
+		if conf.DB.Path == "" {
+			problems = append(problems, "db.path is missing, it is the database file of the sqlite driver")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("db.driver must be mysql or sqlite, found %q", conf.DB.Driver))
+	}
+
+	// Bots, at least one is needed for the daemon
+	if len(conf.Bots) == 0 {
+		problems = append(problems, "bots is empty, at least one bot must be configured")
+	}
+	anyBotActivated := false
+	for _, botName := range sortedKeys(conf.Bots) {
+		bot := conf.Bots[botName]
+		if !bot.Activated {
+			continue
+		}
+		anyBotActivated = true
+		if bot.BotToken == "" || strings.HasPrefix(bot.BotToken, "#") {
+			problems = append(problems, fmt.Sprintf("bots.%s.botToken is missing while the bot is activated (or set %sBOT_%s_TOKEN)", botName, envPrefix, envName(botName)))
+		}
+	}
+
+	// Channels are only needed if a bot can send messages in them
+	if anyBotActivated {
+		channels := []struct {
+			key      string
+			value    string
+			required bool
+		}{
+			{"botAdminChannelID", conf.DiscordChannels.BotAdminChannelID, true},
+			{"serverStatusChannelID", conf.DiscordChannels.ServerStatusChannelID, true},
+			{"minecraftChatChannelID", conf.DiscordChannels.MinecraftChatChannelID, true},
+			{"palworldChatChannelID", conf.DiscordChannels.PalworldChatChannelID, conf.Bots["multiloutreBot"].Activated},
+			{"accountLinkChannelID", conf.DiscordChannels.AccountLinkChannelID, false},
+		}
+		for _, channel := range channels {
+			switch {
+			case channel.value == "" && channel.required:
+				problems = append(problems, fmt.Sprintf("discordChannels.%s is missing", channel.key))
+			case channel.value != "" && !discordIDRegex.MatchString(channel.value):
+				problems = append(problems, fmt.Sprintf("discordChannels.%s must be a Discord channel ID (17 to 20 digits), found %q", channel.key, channel.value))
+			}
+		}
+	}
+
+	// Webhooks
+	for _, serverType := range sortedKeys(conf.DiscordWebhooks) {
+		webhook := conf.DiscordWebhooks[serverType]
+		if webhook.Enabled && !strings.HasPrefix(webhook.URL, "https://") {
+			problems = append(problems, fmt.Sprintf("discordWebhooks.%s.url must be an https URL while the webhook is enabled", serverType))
+		}
+	}
+
+	// Colors
+	colors := map[string]string{
+		"embedColors.good":    conf.EmbedColors.Good,
+		"embedColors.warning": conf.EmbedColors.Warning,
+		"embedColors.error":   conf.EmbedColors.Error,
+	}
+	for _, key := range sortedKeys(colors) {
+		if !colorRegex.MatchString(colors[key]) {
+			problems = append(problems, fmt.Sprintf("%s must be a hex color like #9adfba, found %q", key, colors[key]))
+		}
+	}
+
+	// Time zone, an IANA name like Europe/Paris
+	if _, err := time.LoadLocation(conf.Timezone); err != nil {
+		problems = append(problems, fmt.Sprintf("timezone must be an IANA time zone like Europe/Paris, found %q", conf.Timezone))
+	}
+
+	// Minecraft web services
+	apiURLs := []struct {
+		key   string
+		value string
+	}{
+		{"minecraftAPI.profilesURL", conf.MinecraftAPI.ProfilesURL},
+		{"minecraftAPI.headsURL", conf.MinecraftAPI.HeadsURL},
+	}
+	for _, apiURL := range apiURLs {
+		if !strings.HasPrefix(apiURL.value, "http://") && !strings.HasPrefix(apiURL.value, "https://") {
+			problems = append(problems, fmt.Sprintf("%s must be an http or https URL, found %q", apiURL.key, apiURL.value))
+		}
+	}
+	if ttl, err := time.ParseDuration(conf.MinecraftAPI.UUIDCacheTTL); err != nil || ttl < 0 {
+		problems = append(problems, fmt.Sprintf("minecraftAPI.uuidCacheTTL must be a duration like 168h, found %q", conf.MinecraftAPI.UUIDCacheTTL))
+	}
+
+	// Statistics history
+	keepAll, errAll := time.ParseDuration(conf.StatsHistory.KeepAll)
+	if errAll != nil || keepAll < 0 {
+		problems = append(problems, fmt.Sprintf("statsHistory.keepAll must be a duration like 168h, found %q", conf.StatsHistory.KeepAll))
+	}
+	keepDaily, errDaily := time.ParseDuration(conf.StatsHistory.KeepDaily)
+	if errDaily != nil || keepDaily < 0 {
+		problems = append(problems, fmt.Sprintf("statsHistory.keepDaily must be a duration like 8760h, found %q", conf.StatsHistory.KeepDaily))
+	} else if errAll == nil && keepDaily != 0 && keepDaily < keepAll {
+		problems = append(problems, fmt.Sprintf("statsHistory.keepDaily must be 0 or longer than statsHistory.keepAll, found %q", conf.StatsHistory.KeepDaily))
+	}
+
+	// Leaderboards
+	if conf.Leaderboards.ChannelID != "" && !discordIDRegex.MatchString(conf.Leaderboards.ChannelID) {
+		problems = append(problems, fmt.Sprintf("leaderboards.channelID must be a Discord ID, found %q", conf.Leaderboards.ChannelID))
+	}
+	if conf.Leaderboards.Size < 1 || conf.Leaderboards.Size > 25 {
+		problems = append(problems, fmt.Sprintf("leaderboards.size must be between 1 and 25, found %d", conf.Leaderboards.Size))
+	}
+	for i, board := range conf.Leaderboards.Boards {
+		if !slices.Contains(leaderboardStats, board.Stat) {
+			problems = append(problems, fmt.Sprintf("leaderboards.boards[%d].stat must be one of %s, found %q", i, strings.Join(leaderboardStats, ", "), board.Stat))
+		}
+		if board.ServerID < 0 {
+			problems = append(problems, fmt.Sprintf("leaderboards.boards[%d].serverId cannot be negative, found %d", i, board.ServerID))
+		}
+		if board.Period != "" {
+			if period, err := time.ParseDuration(board.Period); err != nil || period <= 0 {
+				problems = append(problems, fmt.Sprintf("leaderboards.boards[%d].period must be a duration like 168h, found %q", i, board.Period))
+			}
+		}
+	}
+
+	// Activity reports
+	if conf.Report.ChannelID != "" && !discordIDRegex.MatchString(conf.Report.ChannelID) {
+		problems = append(problems, fmt.Sprintf("report.channelID must be a Discord ID, found %q", conf.Report.ChannelID))
+	}
+	period, errPeriod := time.ParseDuration(conf.Report.Period)
+	if errPeriod != nil || period <= 0 {
+		problems = append(problems, fmt.Sprintf("report.period must be a duration like 168h, found %q", conf.Report.Period))
+	}
+	keepEvents, err := time.ParseDuration(conf.Report.KeepEvents)
+	if err != nil || keepEvents < 0 {
+		problems = append(problems, fmt.Sprintf("report.keepEvents must be a duration like 8760h, found %q", conf.Report.KeepEvents))
+	} else if errPeriod == nil && keepEvents != 0 && keepEvents < period {
+		problems = append(problems, fmt.Sprintf("report.keepEvents must be 0 or longer than report.period, found %q", conf.Report.KeepEvents))
+	}
+
+	// Logs
+	if !slices.Contains([]string{"debug", "info", "warn", "error"}, strings.ToLower(conf.Logging.Level)) {
+		problems = append(problems, fmt.Sprintf("logging.level must be debug, info, warn or error, found %q", conf.Logging.Level))
+	}
+	if conf.Logging.Format != "text" && conf.Logging.Format != "json" {
+		problems = append(problems, fmt.Sprintf("logging.format must be text or json, found %q", conf.Logging.Format))
+	}
+
+	// Metrics
+	if conf.Metrics.Listen != "" {
+		if _, port, err := net.SplitHostPort(conf.Metrics.Listen); err != nil || port == "" {
+			problems = append(problems, fmt.Sprintf("metrics.listen must be an address like 127.0.0.1:9464, found %q", conf.Metrics.Listen))
+		}
+	}
+
+	// API
+	if conf.API.Listen != "" {
+		if _, port, err := net.SplitHostPort(conf.API.Listen); err != nil || port == "" {
+			problems = append(problems, fmt.Sprintf("api.listen must be an address like 127.0.0.1:8090, found %q", conf.API.Listen))
+		}
+		if len(conf.API.Tokens) == 0 {
+			problems = append(problems, "api.tokens is empty, nobody could use the API")
+		}
+		// The tokens of the example are left empty, they are only checked once the API is served
+		for _, name := range sortedKeys(conf.API.Tokens) {
+			// The tokens are compared in constant time, but a short one can still be guessed
+			if len(conf.API.Tokens[name].Token) < 16 {
+				problems = append(problems, fmt.Sprintf("api.tokens.%s.token must be at least 16 characters long", name))
+			}
+		}
+	}
+	for _, name := range sortedKeys(conf.API.Tokens) {
+		if !slices.Contains(models.Roles, conf.API.Tokens[name].Role) {
+			problems = append(problems, fmt.Sprintf("api.tokens.%s.role must be one of %s, found %q", name, strings.Join(models.Roles, ", "), conf.API.Tokens[name].Role))
+		}
+	}
+
+	// Intervals
+	if conf.PeriodicEventsMin < 0 {
+		problems = append(problems, fmt.Sprintf("periodicEventsMin cannot be negative, found %d", conf.PeriodicEventsMin))
+	}
+	if conf.Backups.Keep < 0 {
+		problems = append(problems, fmt.Sprintf("backups.keep cannot be negative, found %d", conf.Backups.Keep))
+	}
+	for _, serverID := range sortedKeys(conf.Restarts.Servers) {
+		if !serverIDRegex.MatchString(serverID) {
+			problems = append(problems, fmt.Sprintf("restarts.servers keys must be server IDs, found %q", serverID))
+		}
+		problems = append(problems, validateTask("restarts.servers."+serverID, conf.Restarts.Servers[serverID].SchedulerTaskConfig)...)
+	}
+	for _, name := range sortedKeys(conf.Scheduler.Tasks) {
+		problems = append(problems, validateTask("scheduler.tasks."+name, conf.Scheduler.Tasks[name])...)
+	}
+
+	return problems
+}
+
+// validateTask checks the schedule of a task like the scheduler does when it registers it
+func validateTask(path string, task models.SchedulerTaskConfig) []string {
+	var problems []string
+	if task.Cron != "" && task.Interval != "" {
+		problems = append(problems, fmt.Sprintf("%s cannot have both cron and interval set", path))
+	}
+	if task.Cron != "" {
+		if _, err := cron.ParseCron(task.Cron); err != nil {
+			problems = append(problems, fmt.Sprintf("%s.cron is invalid: %v", path, err))
+		}
+	}
+	if task.Interval != "" {
+		if interval, err := time.ParseDuration(task.Interval); err != nil || interval <= 0 {
+			problems = append(problems, fmt.Sprintf("%s.interval must be a positive duration like 30m, found %q", path, task.Interval))
+		}
+	}
+	if task.Jitter != "" {
+		if jitter, err := time.ParseDuration(task.Jitter); err != nil || jitter < 0 {
+			problems = append(problems, fmt.Sprintf("%s.jitter must be a duration like 1m, found %q", path, task.Jitter))
+		}
+	}
+	if task.Enabled && task.Cron == "" && task.Interval == "" {
+		problems = append(problems, fmt.Sprintf("%s is enabled but has no cron nor interval", path))
+	}
+	return problems
+}
+
+// findUnknownKeys compares the raw JSON keys with the json tags of the Config struct, and returns a message for each unknown key
+func findUnknownKeys(raw map[string]interface{}) []string {
+	var problems []string
+	walkUnknownKeys(raw, reflect.TypeOf(Config{}), "", &problems)
+	sort.Strings(problems)
+	return problems
+}
+
+func walkUnknownKeys(value interface{}, t reflect.Type, path string, problems *[]string) {
+	switch t.Kind() {
+	case reflect.Struct:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return // Type errors are reported by the decoder
+		}
+		fields := jsonFields(t)
+		for key, child := range object {
+			fieldType, ok := lookupField(fields, key)
+			if !ok {
+				*problems = append(*problems, fmt.Sprintf("unknown key %q", joinPath(path, key)))
+				continue
+			}
+			walkUnknownKeys(child, fieldType, joinPath(path, key), problems)
+		}
+	case reflect.Map:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return
+		}
+		for key, child := range object {
+			walkUnknownKeys(child, t.Elem(), joinPath(path, key), problems)
+		}
+	case reflect.Slice:
+		array, ok := value.([]interface{})
+		if !ok {
+			return
+		}
+		for i, child := range array {
+			walkUnknownKeys(child, t.Elem(), fmt.Sprintf("%s[%d]", path, i), problems)
+		}
+	}
+}
+
+// jsonFields returns the JSON keys of a struct with their type, including the fields of embedded structs
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			for key, fieldType := range jsonFields(field.Type) {
+				fields[key] = fieldType
+			}
+			continue
+		}
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
+
+// lookupField finds the field of a JSON key like encoding/json does: the exact key first, then ignoring the case
+func lookupField(fields map[string]reflect.Type, key string) (reflect.Type, bool) {
+	if fieldType, ok := fields[key]; ok {
+		return fieldType, true
+	}
+	for name, fieldType := range fields {
+		if strings.EqualFold(name, key) {
+			return fieldType, true
+		}
+	}
+	return nil, false
+}
+
+func joinPath(path string, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,195 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Corentin-cott/ServeurSentinel/internal/models"
+)
+
+// validConfig returns a configuration without any problem, each test breaks one thing in it
+func validConfig() Config {
+	conf := Config{
+		DB:          models.DatabaseConfig{Driver: "sqlite", Path: "/tmp/serversentinel.db"},
+		Bots:        map[string]models.BotConfig{"mineotterBot": {Activated: false}},
+		EmbedColors: models.EmbedColorsConfig{Good: "#9adfba", Warning: "#f0c040", Error: "#e05050"},
+	}
+	applyDefaults(&conf)
+	return conf
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(conf *Config)
+		want   string // Part of the expected problem, none expected when empty
+	}{
+		{"valid", func(conf *Config) {}, ""},
+		{"unknown driver", func(conf *Config) { conf.DB.Driver = "postgres" }, "db.driver must be mysql or sqlite"},
+		{"sqlite without path", func(conf *Config) { conf.DB.Path = "" }, "db.path is missing"},
+		{"mysql without host", func(conf *Config) {
+			conf.DB = models.DatabaseConfig{Driver: "mysql", Port: 3306, User: "sentinel", Name: "sentinel"}
+		}, "db.host is missing"},
+		{"mysql bad port", func(conf *Config) {
+			conf.DB = models.DatabaseConfig{Driver: "mysql", Host: "localhost", Port: 70000, User: "sentinel", Name: "sentinel"}
+		}, "db.port must be between 1 and 65535"},
+		{"no bot", func(conf *Config) { conf.Bots = nil }, "bots is empty"},
+		{"activated bot without token", func(conf *Config) {
+			conf.Bots["mineotterBot"] = models.BotConfig{Activated: true}
+		}, "bots.mineotterBot.botToken is missing"},
+		{"activated bot without channels", func(conf *Config) {
+			conf.Bots["mineotterBot"] = models.BotConfig{Activated: true, BotToken: "token"}
+		}, "discordChannels.botAdminChannelID is missing"},
+		{"bad channel ID", func(conf *Config) {
+			conf.Bots["mineotterBot"] = models.BotConfig{Activated: true, BotToken: "token"}
+			conf.DiscordChannels = models.DiscordChannels{
+				BotAdminChannelID:      "123",
+				ServerStatusChannelID:  "123456789012345678",
+				MinecraftChatChannelID: "123456789012345678",
+			}
+		}, "discordChannels.botAdminChannelID must be a Discord channel ID"},
+		{"webhook without https", func(conf *Config) {
+			conf.DiscordWebhooks = map[string]models.DiscordWebhookConfig{"minecraft": {Enabled: true, URL: "http://example.com"}}
+		}, "discordWebhooks.minecraft.url must be an https URL"},
+		{"bad color", func(conf *Config) { conf.EmbedColors.Good = "green" }, "embedColors.good must be a hex color"},
+		{"unknown time zone", func(conf *Config) { conf.Timezone = "Mars/Olympus" }, "timezone must be an IANA time zone"},
+		{"bad profiles URL", func(conf *Config) { conf.MinecraftAPI.ProfilesURL = "api.mojang.com" }, "minecraftAPI.profilesURL must be an http or https URL"},
+		{"bad cache TTL", func(conf *Config) { conf.MinecraftAPI.UUIDCacheTTL = "1 week" }, "minecraftAPI.uuidCacheTTL must be a duration"},
+		{"keepDaily shorter than keepAll", func(conf *Config) {
+			conf.StatsHistory.KeepAll = "168h"
+			conf.StatsHistory.KeepDaily = "24h"
+		}, "statsHistory.keepDaily must be 0 or longer"},
+		{"keepDaily forever", func(conf *Config) { conf.StatsHistory.KeepDaily = "0" }, ""},
+		{"leaderboard size", func(conf *Config) { conf.Leaderboards.Size = 30 }, "leaderboards.size must be between 1 and 25"},
+		{"leaderboard stat", func(conf *Config) {
+			conf.Leaderboards.Boards = []models.LeaderboardConfig{{Stat: "jumps"}}
+		}, "leaderboards.boards[0].stat must be one of"},
+		{"leaderboard period", func(conf *Config) {
+			conf.Leaderboards.Boards = []models.LeaderboardConfig{{Stat: "deaths", Period: "-1h"}}
+		}, "leaderboards.boards[0].period must be a duration"},
+		{"report period", func(conf *Config) { conf.Report.Period = "weekly" }, "report.period must be a duration"},
+		{"report keepEvents", func(conf *Config) { conf.Report.KeepEvents = "-1h" }, "report.keepEvents must be a duration"},
+		{"report keepEvents shorter than period", func(conf *Config) { conf.Report.KeepEvents = "24h" }, "report.keepEvents must be 0 or longer than report.period"},
+		{"report keepEvents forever", func(conf *Config) { conf.Report.KeepEvents = "0" }, ""},
+		{"log level", func(conf *Config) { conf.Logging.Level = "verbose" }, "logging.level must be debug, info, warn or error"},
+		{"log level case", func(conf *Config) { conf.Logging.Level = "DEBUG" }, ""},
+		{"log format", func(conf *Config) { conf.Logging.Format = "xml" }, "logging.format must be text or json"},
+		{"metrics address", func(conf *Config) { conf.Metrics.Listen = "9464" }, "metrics.listen must be an address"},
+		{"API without tokens", func(conf *Config) { conf.API.Listen = "127.0.0.1:8090" }, "api.tokens is empty"},
+		{"API short token", func(conf *Config) {
+			conf.API.Listen = "127.0.0.1:8090"
+			conf.API.Tokens = map[string]models.APITokenConfig{"panel": {Token: "short", Role: models.RoleViewer}}
+		}, "api.tokens.panel.token must be at least 16 characters long"},
+		{"empty token without API", func(conf *Config) {
+			conf.API.Tokens = map[string]models.APITokenConfig{"panel": {Role: models.RoleViewer}}
+		}, ""},
+		{"unknown role", func(conf *Config) {
+			conf.API.Tokens = map[string]models.APITokenConfig{"panel": {Role: "owner"}}
+		}, "api.tokens.panel.role must be one of"},
+		{"negative periodicEventsMin", func(conf *Config) { conf.PeriodicEventsMin = -1 }, "periodicEventsMin cannot be negative"},
+		{"negative backups", func(conf *Config) { conf.Backups.Keep = -1 }, "backups.keep cannot be negative"},
+		{"restarts key", func(conf *Config) {
+			conf.Restarts.Servers = map[string]models.ServerRestartConfig{"survie": {}}
+		}, `restarts.servers keys must be server IDs, found "survie"`},
+		{"restart cron", func(conf *Config) {
+			conf.Restarts.Servers = map[string]models.ServerRestartConfig{"5": {SchedulerTaskConfig: models.SchedulerTaskConfig{Enabled: true, Cron: "0 25 * * *"}}}
+		}, "restarts.servers.5.cron is invalid"},
+		{"task cron", func(conf *Config) {
+			conf.Scheduler.Tasks = map[string]models.SchedulerTaskConfig{"backups": {Enabled: true, Cron: "0 5 * *"}}
+		}, "scheduler.tasks.backups.cron is invalid"},
+		{"task interval", func(conf *Config) {
+			conf.Scheduler.Tasks = map[string]models.SchedulerTaskConfig{"serversCheck": {Enabled: true, Interval: "5 minutes"}}
+		}, "scheduler.tasks.serversCheck.interval must be a positive duration"},
+		{"task negative interval", func(conf *Config) {
+			conf.Scheduler.Tasks = map[string]models.SchedulerTaskConfig{"serversCheck": {Enabled: true, Interval: "-5m"}}
+		}, "scheduler.tasks.serversCheck.interval must be a positive duration"},
+		{"task jitter", func(conf *Config) {
+			conf.Scheduler.Tasks = map[string]models.SchedulerTaskConfig{"serversCheck": {Enabled: true, Interval: "5m", Jitter: "-1m"}}
+		}, "scheduler.tasks.serversCheck.jitter must be a duration"},
+		{"task cron and interval", func(conf *Config) {
+			conf.Scheduler.Tasks = map[string]models.SchedulerTaskConfig{"backups": {Enabled: true, Cron: "0 5 * * *", Interval: "24h"}}
+		}, "scheduler.tasks.backups cannot have both cron and interval set"},
+		{"task without schedule", func(conf *Config) {
+			conf.Scheduler.Tasks = map[string]models.SchedulerTaskConfig{"backups": {Enabled: true}}
+		}, "scheduler.tasks.backups is enabled but has no cron nor interval"},
+		{"disabled task without schedule", func(conf *Config) {
+			conf.Scheduler.Tasks = map[string]models.SchedulerTaskConfig{"backups": {Enabled: false}}
+		}, ""},
+		{"valid tasks", func(conf *Config) {
+			conf.Scheduler.Tasks = map[string]models.SchedulerTaskConfig{
+				"backups":      {Enabled: true, Cron: "@daily", Jitter: "5m"},
+				"serversCheck": {Enabled: true, Interval: "1m"},
+			}
+		}, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conf := validConfig()
+			test.change(&conf)
+			problems := Validate(conf)
+			if test.want == "" {
+				if len(problems) > 0 {
+					t.Errorf("Validate() = %q, want no problem", problems)
+				}
+				return
+			}
+			if !slices.ContainsFunc(problems, func(problem string) bool { return strings.Contains(problem, test.want) }) {
+				t.Errorf("Validate() = %q, want a problem containing %q", problems, test.want)
+			}
+		})
+	}
+}
+
+func TestFindUnknownKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want []string
+	}{
+		{"known keys", `{"backups": {"keep": 3}, "timezone": "UTC"}`, nil},
+		{"unknown top level key", `{"backup": {}}`, []string{`unknown key "backup"`}},
+		{"unknown nested key", `{"backups": {"kept": 3}}`, []string{`unknown key "backups.kept"`}},
+		{"case of the key", `{"Backups": {"Keep": 3}, "TIMEZONE": "UTC"}`, nil},
+		{"map keys are free", `{"bots": {"anyBot": {"activated": false}}}`, nil},
+		{"key inside a map", `{"bots": {"anyBot": {"activate": false}}}`, []string{`unknown key "bots.anyBot.activate"`}},
+		{"key inside a slice", `{"leaderboards": {"boards": [{"stat": "deaths"}, {"stats": "deaths"}]}}`, []string{`unknown key "leaderboards.boards[1].stats"`}},
+		{"embedded struct", `{"restarts": {"servers": {"5": {"cron": "0 5 * * *", "maxDelay": "1h"}}}}`, nil},
+		{"wrong type left to the decoder", `{"backups": 3}`, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var raw map[string]interface{}
+			if err := json.Unmarshal([]byte(test.json), &raw); err != nil {
+				t.Fatalf("invalid test JSON: %v", err)
+			}
+			if got := findUnknownKeys(raw); !slices.Equal(got, test.want) {
+				t.Errorf("findUnknownKeys(%s) = %q, want %q", test.json, got, test.want)
+			}
+		})
+	}
+}
+
+func TestReadConfigLocation(t *testing.T) {
+	conf := validConfig()
+	conf.Timezone = "America/New_York"
+	data, err := json.Marshal(conf)
+	if err != nil {
+		t.Fatalf("configuration not encoded: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("configuration not written: %v", err)
+	}
+
+	read, err := ReadConfig(path)
+	if err != nil {
+		t.Fatalf("ReadConfig() failed: %v", err)
+	}
+	if read.location == nil || read.Location().String() != "America/New_York" {
+		t.Errorf("Location() = %v, want America/New_York loaded by ReadConfig", read.Location())
+	}
+}
@@ -0,0 +1,169 @@
+package accounts
+
+// This package contains the ACCOUNT LINKING : a player asks for a code in game with !lier, then sends the code in the link
+// channel on Discord, which binds the game account to the Discord user. The codes are kept in memory
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Corentin-cott/ServeurSentinel/config"
+	"github.com/Corentin-cott/ServeurSentinel/internal/audit"
+	"github.com/Corentin-cott/ServeurSentinel/internal/db"
+	"github.com/Corentin-cott/ServeurSentinel/internal/discord"
+	"github.com/Corentin-cott/ServeurSentinel/internal/logging"
+	"github.com/Corentin-cott/ServeurSentinel/internal/models"
+)
+
+var logger = logging.For("accounts")
+
+// LinkCommand is what a player types in the game chat to get a link code
+const LinkCommand = "!lier"
+
+// LinkCodeTTL is how long a link code can be used
+const LinkCodeTTL = 10 * time.Minute
+
+// How often the link channel is read while codes are waiting
+var linkPollInterval = 5 * time.Second
+
+// Characters of the codes, without the ones that look alike (0/O, 1/I)
+const codeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+const codeLength = 6
+
+// pendingLink is a code given to a player, waiting to be sent on Discord
+type pendingLink struct {
+	playerID   int
+	playerName string
+	expiresAt  time.Time
+}
+
+var (
+	mu      sync.Mutex
+	pending = make(map[string]pendingLink) // code -> player
+)
+
+// IsLinkingEnabled tells if the codes can be used with the given configuration, a link channel is needed
+func IsLinkingEnabled(conf *config.Config) bool {
+	return conf.DiscordChannels.AccountLinkChannelID != ""
+}
+
+// CreateLinkCode gives a new code to a player, the previous code of the player can't be used anymore
+func CreateLinkCode(playerID int, playerName string) (string, error) {
+	code, err := randomCode()
+	if err != nil {
+		return "", fmt.Errorf("ERROR WHILE GENERATING LINK CODE: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for existingCode, link := range pending {
+		if link.playerID == playerID || time.Now().After(link.expiresAt) {
+			delete(pending, existingCode)
+		}
+	}
+	pending[code] = pendingLink{playerID: playerID, playerName: playerName, expiresAt: time.Now().Add(LinkCodeTTL)}
+	return code, nil
+}
+
+// consumeLinkCode returns the player of a code and removes the code, a code works once
+func consumeLinkCode(code string) (pendingLink, bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	link, ok := pending[code]
+	if !ok {
+		return link, false
+	}
+	delete(pending, code)
+	if time.Now().After(link.expiresAt) {
+		return link, false
+	}
+	return link, true
+}
+
+// RedactLinkCodes hides the codes waiting to be used in a console line, so a code that reaches the console somehow is never
+// shown on Discord nor in the live console, where anyone could use it first
+func RedactLinkCodes(line string) string {
+	mu.Lock()
+	defer mu.Unlock()
+	for code := range pending {
+		line = strings.ReplaceAll(line, code, strings.Repeat("*", len(code)))
+	}
+	return line
+}
+
+func countPendingCodes() int {
+	mu.Lock()
+	defer mu.Unlock()
+	return len(pending)
+}
+
+func randomCode() (string, error) {
+	var code strings.Builder
+	for i := 0; i < codeLength; i++ {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(codeAlphabet))))
+		if err != nil {
+			return "", err
+		}
+		code.WriteByte(codeAlphabet[n.Int64()])
+	}
+	return code.String(), nil
+}
+
+// RunDiscordLinks reads the link channel while codes are waiting and links the players whose code is sent, until the context is cancelled
+func RunDiscordLinks(ctx context.Context, players db.PlayerRepository, notifier discord.Notifier) {
+	lastMessageID := ""
+	ticker := time.NewTicker(linkPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if !IsLinkingEnabled(config.Get()) || countPendingCodes() == 0 {
+			continue
+		}
+
+		conf := config.Get()
+		bot := conf.Bots["mineotterBot"]
+		channelID := conf.DiscordChannels.AccountLinkChannelID
+		messages, err := discord.GetDiscordChannelMessages(bot, channelID, lastMessageID)
+		if err != nil {
+			logger.Error("Account link channel not read", logging.Err(err))
+			continue
+		}
+
+		for _, message := range messages {
+			lastMessageID = message.ID
+			if message.AuthorBot {
+				continue
+			}
+			link, ok := consumeLinkCode(strings.ToUpper(strings.TrimSpace(message.Content)))
+			if !ok {
+				continue
+			}
+
+			_, err := players.LinkPlayerToDiscordUser(link.playerID, message.AuthorID, message.AuthorName)
+			audit.Record(audit.Discord(message.AuthorName), models.AuditLinkAccount, 0, audit.Params("player", link.playerName, "discordId", message.AuthorID), err)
+			if err != nil {
+				logger.Error("Player not linked", "player", link.playerName, "discord_user", message.AuthorName, logging.Err(err))
+				printError(notifier.SendEmbed(bot, channelID, "Liaison impossible", "Le compte "+link.playerName+" n'a pas pu être lié, réessaie plus tard.", conf.EmbedColors.Error))
+				continue
+			}
+			logger.Info("Player linked to a Discord user", "player", link.playerName, "discord_user", message.AuthorName)
+			printError(notifier.SendEmbed(bot, channelID, "Compte lié", "<@"+message.AuthorID+"> est maintenant lié au compte "+link.playerName+".", conf.EmbedColors.Good))
+		}
+	}
+}
+
+func printError(err error) {
+	if err != nil {
+		logger.Error("Discord message not sent", logging.Err(err))
+	}
+}
@@ -0,0 +1,230 @@
+package api
+
+// This package contains the HTTP API of the daemon and serves the dashboard, to control the servers without a shell on the
+// host. Every request needs one of the tokens of the configuration, whose role tells what it can do, and the actions go
+// through the control package like the CLI commands
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Corentin-cott/ServeurSentinel/config"
+	"github.com/Corentin-cott/ServeurSentinel/internal/audit"
+	"github.com/Corentin-cott/ServeurSentinel/internal/control"
+	"github.com/Corentin-cott/ServeurSentinel/internal/dashboard"
+	"github.com/Corentin-cott/ServeurSentinel/internal/logging"
+	"github.com/Corentin-cott/ServeurSentinel/internal/models"
+)
+
+var logger = logging.For("api")
+
+// Largest request body accepted, the requests only carry a few fields
+const maxBodySize = 64 << 10
+
+type contextKey int
+
+const callerKey contextKey = iota
+
+// caller is the token a request was authenticated with
+type caller struct {
+	name string
+	role string
+}
+
+func callerOf(r *http.Request) caller {
+	c, _ := r.Context().Value(callerKey).(caller)
+	return c
+}
+
+// TokenName returns the name of the token a request was authenticated with
+func TokenName(r *http.Request) string {
+	return callerOf(r).name
+}
+
+// actor is the token a request was authenticated with, as recorded in the audit log
+func actor(r *http.Request) audit.Actor {
+	return audit.API(TokenName(r))
+}
+
+// Role returns the role of the token a request was authenticated with
+func Role(r *http.Request) string {
+	return callerOf(r).role
+}
+
+// Serve listens on an address like "127.0.0.1:8090" and serves the API until the context is cancelled
+func Serve(ctx context.Context, address string) error {
+	server := &http.Server{Addr: address, Handler: Handler(ctx), ReadHeaderTimeout: 10 * time.Second}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("ERROR WHILE SERVING THE API ON %s: %v", address, err)
+	}
+	return nil
+}
+
+// Handler returns the dashboard and the routes of the API behind the token check. The restarts it starts are cancelled
+// with the context
+func Handler(ctx context.Context) http.Handler {
+	h := &handlers{ctx: ctx}
+	routes := http.NewServeMux()
+	route := func(pattern string, role string, handler http.HandlerFunc) {
+		routes.Handle(pattern, requireRole(role, handler))
+	}
+	route("GET /api/session", models.RoleViewer, h.getSession)
+	route("DELETE /api/session", models.RoleViewer, h.logout)
+	route("GET /api/servers", models.RoleViewer, h.listServers)
+	route("GET /api/servers/{id}", models.RoleViewer, h.getServer)
+	route("GET /api/servers/{id}/console", models.RoleViewer, h.streamConsole)
+	route("GET /api/servers/{id}/backups", models.RoleViewer, h.listBackups)
+	route("GET /api/players/online", models.RoleViewer, h.onlinePlayers)
+	route("GET /api/events", models.RoleViewer, h.recentEvents)
+	route("GET /api/leaderboards", models.RoleViewer, h.listLeaderboards)
+	route("POST /api/servers/{id}/start", models.RoleOperator, h.startServer)
+	route("POST /api/servers/{id}/stop", models.RoleOperator, h.stopServer)
+	route("POST /api/servers/{id}/restart", models.RoleOperator, h.restartServer)
+	route("POST /api/check", models.RoleOperator, h.checkServers)
+	route("POST /api/servers/{id}/command", models.RoleAdmin, h.sendCommand)
+	route("PUT /api/slots/{slot}", models.RoleAdmin, h.assignSlot)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/session", h.login) // The only route without a token, it gives the cookie of the dashboard
+	mux.Handle("/api/", authenticate(routes))
+	mux.Handle("/", dashboard.Handler())
+	return mux
+}
+
+// authenticate rejects the requests without a known token, sent as "Authorization: Bearer <token>" or in the cookie of the
+// dashboard. The tokens are read from the configuration on each request, so a reload adds or revokes them
+func authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, fromCookie := requestToken(r)
+		name, role, ok := findToken(token)
+		if !ok {
+			logger.Warn("Request refused, unknown token", "method", r.Method, "path", r.URL.Path, "remote", r.RemoteAddr)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="serversentinel"`)
+			writeError(w, http.StatusUnauthorized, "missing or unknown token")
+			return
+		}
+		// The browser sends the cookie with the requests of any page, an action must come from the dashboard itself
+		if fromCookie && r.Method != http.MethodGet && r.Method != http.MethodHead && !sameOrigin(r) {
+			logger.Warn("Request refused, it comes from another site", "method", r.Method, "path", r.URL.Path, "origin", r.Header.Get("Origin"))
+			writeError(w, http.StatusForbidden, "cross-site request refused")
+			return
+		}
+		logger.Debug("Request", "method", r.Method, "path", r.URL.Path, "client", name)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), callerKey, caller{name: name, role: role})))
+	})
+}
+
+// requireRole rejects the requests of the tokens whose role is below the given one
+func requireRole(role string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if slices.Index(models.Roles, Role(r)) < slices.Index(models.Roles, role) {
+			logger.Warn("Request refused, role too low", "method", r.Method, "path", r.URL.Path, "client", TokenName(r), "role", Role(r))
+			writeError(w, http.StatusForbidden, "the "+role+" role is needed")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requestToken returns the token of the Authorization header, or else of the cookie of the dashboard
+func requestToken(r *http.Request) (token string, fromCookie bool) {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return token, false
+	}
+	if cookie, err := r.Cookie(sessionCookieName); err == nil {
+		return cookie.Value, true
+	}
+	return "", false
+}
+
+// sameOrigin tells if a request comes from a page of the daemon, the browsers always send Origin with the POST requests
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return r.Header.Get("Sec-Fetch-Site") == "" || r.Header.Get("Sec-Fetch-Site") == "same-origin"
+	}
+	parsed, err := url.Parse(origin)
+	return err == nil && parsed.Host == r.Host
+}
+
+// findToken returns the name and the role of a token of the configuration, every token is compared so the time doesn't
+// tell which matched
+func findToken(token string) (name string, role string, found bool) {
+	tokens := config.Get().API.Tokens
+	names := make([]string, 0, len(tokens))
+	for name := range tokens {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, candidate := range names {
+		expected := tokens[candidate]
+		if expected.Token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(expected.Token)) == 1 {
+			name, role, found = candidate, expected.Role, true
+		}
+	}
+	return name, role, found
+}
+
+// writeJSON writes a value as the JSON body of the response
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		logger.Error("Response not written", logging.Err(err))
+	}
+}
+
+// writeError writes {"error": "..."} with a status
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+// writeMessage writes {"message": "..."}, the answer of the actions
+func writeMessage(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"message": message})
+}
+
+// writeControlError writes the error of an action with the status that matches it
+func writeControlError(w http.ResponseWriter, r *http.Request, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, control.ErrServerNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, control.ErrServerNotRunning), errors.Is(err, control.ErrRestartInProgress):
+		status = http.StatusConflict
+	case errors.Is(err, control.ErrInvalidSlot), errors.Is(err, control.ErrInvalidCommand):
+		status = http.StatusBadRequest
+	default:
+		logger.Error("Request failed", "method", r.Method, "path", r.URL.Path, "client", TokenName(r), logging.Err(err))
+	}
+	writeError(w, status, err.Error())
+}
+
+// readJSON decodes the JSON body of a request, it writes the error and returns false when the body is not valid
+func readJSON(w http.ResponseWriter, r *http.Request, value any) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(value); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+		return false
+	}
+	return true
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Corentin-cott/ServeurSentinel/config"
+	"github.com/Corentin-cott/ServeurSentinel/internal/models"
+)
+
+// setTokens loads a configuration with a token per role, named after the role
+func setTokens(t *testing.T, roles ...string) {
+	t.Helper()
+	tokens := make(map[string]models.APITokenConfig)
+	for _, role := range roles {
+		tokens[role] = models.APITokenConfig{Token: role + "-token", Role: role}
+	}
+	config.Set(config.Config{API: models.APIConfig{Tokens: tokens}})
+	t.Cleanup(func() { config.Set(config.Config{}) })
+}
+
+func TestAuthentication(t *testing.T) {
+	setTokens(t, models.RoleViewer, models.RoleOperator, models.RoleAdmin)
+	handler := Handler(context.Background())
+
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		prepare func(r *http.Request)
+		want    int
+	}{
+		{"missing token", "GET", "/api/session", func(r *http.Request) {}, http.StatusUnauthorized},
+		{"unknown token", "GET", "/api/session", func(r *http.Request) {
+			r.Header.Set("Authorization", "Bearer unknown-token")
+		}, http.StatusUnauthorized},
+		{"not a bearer token", "GET", "/api/session", func(r *http.Request) {
+			r.Header.Set("Authorization", "viewer-token")
+		}, http.StatusUnauthorized},
+		{"known token", "GET", "/api/session", func(r *http.Request) {
+			r.Header.Set("Authorization", "Bearer viewer-token")
+		}, http.StatusOK},
+		{"known cookie", "GET", "/api/session", func(r *http.Request) {
+			r.AddCookie(&http.Cookie{Name: sessionCookieName, Value: "viewer-token"})
+		}, http.StatusOK},
+		{"viewer on an operator route", "POST", "/api/servers/1/stop", func(r *http.Request) {
+			r.Header.Set("Authorization", "Bearer viewer-token")
+		}, http.StatusForbidden},
+		{"viewer on an admin route", "PUT", "/api/slots/primary", func(r *http.Request) {
+			r.Header.Set("Authorization", "Bearer viewer-token")
+		}, http.StatusForbidden},
+		{"operator on an admin route", "POST", "/api/servers/1/command", func(r *http.Request) {
+			r.Header.Set("Authorization", "Bearer operator-token")
+		}, http.StatusForbidden},
+		{"cookie from another origin", "DELETE", "/api/session", func(r *http.Request) {
+			r.AddCookie(&http.Cookie{Name: sessionCookieName, Value: "admin-token"})
+			r.Header.Set("Origin", "https://evil.example")
+		}, http.StatusForbidden},
+		{"cookie from another site without origin", "DELETE", "/api/session", func(r *http.Request) {
+			r.AddCookie(&http.Cookie{Name: sessionCookieName, Value: "admin-token"})
+			r.Header.Set("Sec-Fetch-Site", "cross-site")
+		}, http.StatusForbidden},
+		{"cookie from the dashboard", "DELETE", "/api/session", func(r *http.Request) {
+			r.AddCookie(&http.Cookie{Name: sessionCookieName, Value: "admin-token"})
+			r.Header.Set("Origin", "http://sentinel.local:8090")
+		}, http.StatusOK},
+		// A bearer token is not sent by the browser on its own, the origin doesn't matter
+		{"bearer token from another origin", "DELETE", "/api/session", func(r *http.Request) {
+			r.Header.Set("Authorization", "Bearer admin-token")
+			r.Header.Set("Origin", "https://evil.example")
+		}, http.StatusOK},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest(test.method, "http://sentinel.local:8090"+test.path, nil)
+			test.prepare(request)
+			response := httptest.NewRecorder()
+			handler.ServeHTTP(response, request)
+			if response.Code != test.want {
+				t.Errorf("%s %s = %d, want %d: %s", test.method, test.path, response.Code, test.want, response.Body.String())
+			}
+		})
+	}
+}
+
+func TestTokenRevokedOnReload(t *testing.T) {
+	setTokens(t, models.RoleViewer, models.RoleOperator)
+	handler := Handler(context.Background())
+	get := func() int {
+		request := httptest.NewRequest("GET", "/api/session", nil)
+		request.Header.Set("Authorization", "Bearer operator-token")
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, request)
+		return response.Code
+	}
+
+	if code := get(); code != http.StatusOK {
+		t.Fatalf("GET /api/session = %d before the reload, want 200", code)
+	}
+	setTokens(t, models.RoleViewer)
+	if code := get(); code != http.StatusUnauthorized {
+		t.Errorf("GET /api/session = %d after the token was removed, want 401", code)
+	}
+}
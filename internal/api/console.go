@@ -0,0 +1,103 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Corentin-cott/ServeurSentinel/internal/console"
+	"github.com/Corentin-cott/ServeurSentinel/internal/control"
+)
+
+// How often a comment is sent on an idle console, so the proxies don't close the connection
+var consoleKeepAliveInterval = 30 * time.Second
+
+type consoleLineView struct {
+	Seq      uint64    `json:"seq"`
+	ServerID int       `json:"serverId"`
+	Time     time.Time `json:"time"`
+	Text     string    `json:"text"` // Without the ANSI codes, or HTML with format=html
+	Input    bool      `json:"input,omitempty"`
+}
+
+// GET /api/servers/{id}/console?format=text|html, the live console as Server-Sent Events. The scrollback is sent first,
+// or only the lines after Last-Event-ID when the browser reconnects
+func (h *handlers) streamConsole(w http.ResponseWriter, r *http.Request) {
+	id, ok := serverID(w, r)
+	if !ok {
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "text" && format != "html" {
+		writeError(w, http.StatusBadRequest, "format must be text or html, found "+strconv.Quote(format))
+		return
+	}
+	if _, err := control.GetServer(id); err != nil {
+		writeControlError(w, r, err)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming is not supported")
+		return
+	}
+
+	var afterSeq uint64
+	if lastEventID := r.Header.Get("Last-Event-ID"); lastEventID != "" {
+		afterSeq, _ = strconv.ParseUint(lastEventID, 10, 64)
+	}
+	scrollback, lines, unsubscribe := console.Subscribe(id, afterSeq)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // Nginx would wait for the end of the response otherwise
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "retry: 3000\n\n")
+
+	send := func(line console.Line) error {
+		view := consoleLineView{Seq: line.Seq, ServerID: line.ServerID, Time: line.Time, Text: line.Text(), Input: line.Input}
+		if format == "html" {
+			view.Text = line.HTML()
+		}
+		data, err := json.Marshal(view)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "id: %d\nevent: line\ndata: %s\n\n", line.Seq, data)
+		return err
+	}
+
+	for _, line := range scrollback {
+		if send(line) != nil {
+			return
+		}
+	}
+	flusher.Flush()
+	logger.Debug("Console subscriber connected", "server_id", id, "client", TokenName(r), "scrollback", len(scrollback))
+
+	keepAlive := time.NewTicker(consoleKeepAliveInterval)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-h.ctx.Done():
+			return
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case line, open := <-lines:
+			if !open {
+				return // Too slow, the browser reconnects with Last-Event-ID
+			}
+			if send(line) != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,285 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Corentin-cott/ServeurSentinel/config"
+	"github.com/Corentin-cott/ServeurSentinel/internal/audit"
+	"github.com/Corentin-cott/ServeurSentinel/internal/backup"
+	"github.com/Corentin-cott/ServeurSentinel/internal/control"
+	"github.com/Corentin-cott/ServeurSentinel/internal/leaderboards"
+	"github.com/Corentin-cott/ServeurSentinel/internal/logging"
+	"github.com/Corentin-cott/ServeurSentinel/internal/models"
+	"github.com/Corentin-cott/ServeurSentinel/internal/tmux"
+)
+
+// Events returned when no limit is given, and the most that can be asked
+const (
+	defaultEventsLimit = 100
+	maxEventsLimit     = 1000
+)
+
+type handlers struct {
+	ctx context.Context
+}
+
+type eventView struct {
+	ID         int64     `json:"id"`
+	ServerID   int       `json:"serverId"`
+	Type       string    `json:"type"`
+	PlayerName string    `json:"playerName,omitempty"`
+	Details    string    `json:"details,omitempty"`
+	Date       time.Time `json:"date"`
+}
+
+// serverID reads the {id} of the path, it writes the error and returns false when it is not a number
+func serverID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "the server ID must be a positive number, found "+strconv.Quote(r.PathValue("id")))
+		return 0, false
+	}
+	return id, true
+}
+
+// GET /api/servers
+func (h *handlers) listServers(w http.ResponseWriter, r *http.Request) {
+	statuses, err := control.ListServers()
+	if err != nil {
+		writeControlError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, statuses)
+}
+
+// GET /api/servers/{id}
+func (h *handlers) getServer(w http.ResponseWriter, r *http.Request) {
+	id, ok := serverID(w, r)
+	if !ok {
+		return
+	}
+	server, err := control.GetServer(id)
+	if err != nil {
+		writeControlError(w, r, err)
+		return
+	}
+	status, err := control.GetServerStatus(server)
+	if err != nil {
+		writeControlError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+// POST /api/servers/{id}/start
+func (h *handlers) startServer(w http.ResponseWriter, r *http.Request) {
+	id, ok := serverID(w, r)
+	if !ok {
+		return
+	}
+	message, err := control.StartServer(actor(r), id)
+	if err != nil {
+		writeControlError(w, r, err)
+		return
+	}
+	logger.Info("Server started through the API", "server_id", id, "client", TokenName(r))
+	writeMessage(w, http.StatusOK, message)
+}
+
+// POST /api/servers/{id}/stop
+func (h *handlers) stopServer(w http.ResponseWriter, r *http.Request) {
+	id, ok := serverID(w, r)
+	if !ok {
+		return
+	}
+	if err := control.StopServer(actor(r), id); err != nil {
+		writeControlError(w, r, err)
+		return
+	}
+	logger.Info("Server stopped through the API", "server_id", id, "client", TokenName(r))
+	writeMessage(w, http.StatusOK, "✔ Server stopped, it is started again on the next check while it keeps its slot.")
+}
+
+// POST /api/servers/{id}/restart, the players are warned first so it answers before the restart is done
+func (h *handlers) restartServer(w http.ResponseWriter, r *http.Request) {
+	id, ok := serverID(w, r)
+	if !ok {
+		return
+	}
+	// Checked here so a missing, stopped or restarting server is an error of the request, not of the restart in the
+	// background. The restart checks again, the scheduler may start one in between
+	server, err := control.GetRunningServer(id)
+	if err == nil && control.IsRestarting(id) {
+		err = control.ErrRestartInProgress
+	}
+	if err != nil {
+		audit.Record(actor(r), models.AuditRestartServer, id, "", err)
+		writeControlError(w, r, err)
+		return
+	}
+
+	tokenName := TokenName(r)
+	restartActor := actor(r)
+	go func() {
+		if err := control.RestartServer(h.ctx, restartActor, id); err != nil {
+			logger.Error("Restart asked through the API failed", "server_id", id, "client", tokenName, logging.Err(err))
+		}
+	}()
+	logger.Info("Server restart asked through the API", "server_id", id, "client", tokenName)
+	writeMessage(w, http.StatusAccepted, "Restart of "+server.Nom+" started, the players are warned first.")
+}
+
+// POST /api/servers/{id}/command with {"command": "say Bonjour"}
+func (h *handlers) sendCommand(w http.ResponseWriter, r *http.Request) {
+	id, ok := serverID(w, r)
+	if !ok {
+		return
+	}
+	var body struct {
+		Command string `json:"command"`
+	}
+	if !readJSON(w, r, &body) {
+		return
+	}
+	if err := control.SendCommand(actor(r), id, body.Command); err != nil {
+		writeControlError(w, r, err)
+		return
+	}
+	logger.Info("Console command sent through the API", "server_id", id, "client", TokenName(r))
+	writeMessage(w, http.StatusOK, "✔ Command sent.")
+}
+
+// PUT /api/slots/{slot} with {"serverId": 5}
+func (h *handlers) assignSlot(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		ServerID int `json:"serverId"`
+	}
+	if !readJSON(w, r, &body) {
+		return
+	}
+	slot := r.PathValue("slot")
+	if err := control.AssignSlot(actor(r), slot, body.ServerID); err != nil {
+		writeControlError(w, r, err)
+		return
+	}
+	logger.Info("Slot assigned through the API", "slot", slot, "server_id", body.ServerID, "client", TokenName(r))
+	writeMessage(w, http.StatusOK, "✔ Slot assigned, POST /api/check to start and stop the servers to match.")
+}
+
+// POST /api/check, like "serversentinel check-server"
+func (h *handlers) checkServers(w http.ResponseWriter, r *http.Request) {
+	message, err := tmux.CheckRunningServers(actor(r))
+	if err != nil {
+		writeControlError(w, r, err)
+		return
+	}
+	writeMessage(w, http.StatusOK, message)
+}
+
+type backupView struct {
+	ID        string    `json:"id"`
+	Size      int64     `json:"sizeBytes"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// GET /api/servers/{id}/backups, newest first
+func (h *handlers) listBackups(w http.ResponseWriter, r *http.Request) {
+	id, ok := serverID(w, r)
+	if !ok {
+		return
+	}
+	server, err := control.GetServer(id)
+	if err != nil {
+		writeControlError(w, r, err)
+		return
+	}
+
+	views := []backupView{}
+	if config.Get().Backups.Path == "" {
+		writeJSON(w, http.StatusOK, views) // Backups are not enabled
+		return
+	}
+	backups, err := backup.ListBackups(server)
+	if err != nil {
+		writeControlError(w, r, err)
+		return
+	}
+	for _, b := range backups {
+		views = append(views, backupView{ID: b.ID, Size: b.Size, CreatedAt: b.CreatedAt})
+	}
+	writeJSON(w, http.StatusOK, views)
+}
+
+// GET /api/leaderboards, like "serversentinel leaderboards show"
+func (h *handlers) listLeaderboards(w http.ResponseWriter, r *http.Request) {
+	boards, err := leaderboards.BuildAll(time.Now())
+	if err != nil {
+		writeControlError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, boards)
+}
+
+// GET /api/players/online
+func (h *handlers) onlinePlayers(w http.ResponseWriter, r *http.Request) {
+	online, err := control.OnlinePlayers()
+	if err != nil {
+		writeControlError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, online)
+}
+
+// GET /api/events?since=24h&serverId=5&type=crash&limit=100
+func (h *handlers) recentEvents(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	since := 24 * time.Hour
+	if value := query.Get("since"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 || parsed > control.RecentEventsMaxAge {
+			writeError(w, http.StatusBadRequest, "since must be a duration like 24h, at most "+control.RecentEventsMaxAge.String()+", found "+strconv.Quote(value))
+			return
+		}
+		since = parsed
+	}
+	id := 0
+	if value := query.Get("serverId"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			writeError(w, http.StatusBadRequest, "serverId must be a server ID, found "+strconv.Quote(value))
+			return
+		}
+		id = parsed
+	}
+	limit := defaultEventsLimit
+	if value := query.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 || parsed > maxEventsLimit {
+			writeError(w, http.StatusBadRequest, "limit must be between 1 and "+strconv.Itoa(maxEventsLimit)+", found "+strconv.Quote(value))
+			return
+		}
+		limit = parsed
+	}
+
+	events, err := control.RecentEvents(time.Now().Add(-since), id, query.Get("type"), limit)
+	if err != nil {
+		writeControlError(w, r, err)
+		return
+	}
+	views := make([]eventView, 0, len(events))
+	for _, event := range events {
+		views = append(views, eventView{
+			ID:         event.ID,
+			ServerID:   event.ServerID,
+			Type:       event.Type,
+			PlayerName: event.PlayerName,
+			Details:    event.Details,
+			Date:       event.Date,
+		})
+	}
+	writeJSON(w, http.StatusOK, views)
+}
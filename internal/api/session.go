@@ -0,0 +1,69 @@
+package api
+
+import (
+	"net/http"
+	"time"
+)
+
+// Cookie holding the token in the browser, the dashboard can't send the Authorization header with EventSource
+const sessionCookieName = "serversentinel_token"
+
+// How long the browser keeps the cookie, the token must be pasted again after
+var sessionDuration = 7 * 24 * time.Hour
+
+type sessionView struct {
+	Name string `json:"name"`
+	Role string `json:"role"`
+}
+
+// POST /api/session with {"token": "..."}, sets the cookie of the dashboard
+func (h *handlers) login(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Token string `json:"token"`
+	}
+	if !readJSON(w, r, &body) {
+		return
+	}
+	name, role, ok := findToken(body.Token)
+	if !ok {
+		logger.Warn("Dashboard login refused, unknown token", "remote", r.RemoteAddr)
+		writeError(w, http.StatusUnauthorized, "unknown token")
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    body.Token,
+		Path:     "/",
+		MaxAge:   int(sessionDuration.Seconds()),
+		HttpOnly: true,
+		Secure:   isHTTPS(r),
+		SameSite: http.SameSiteStrictMode,
+	})
+	logger.Info("Dashboard login", "client", name, "role", role, "remote", r.RemoteAddr)
+	writeJSON(w, http.StatusOK, sessionView{Name: name, Role: role})
+}
+
+// GET /api/session, the token in use and its role
+func (h *handlers) getSession(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, sessionView{Name: TokenName(r), Role: Role(r)})
+}
+
+// DELETE /api/session, removes the cookie of the dashboard
+func (h *handlers) logout(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   isHTTPS(r),
+		SameSite: http.SameSiteStrictMode,
+	})
+	writeMessage(w, http.StatusOK, "✔ Logged out.")
+}
+
+// isHTTPS tells if the browser reached the daemon with HTTPS, directly or through a reverse proxy
+func isHTTPS(r *http.Request) bool {
+	return r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https"
+}
@@ -0,0 +1,152 @@
+package audit
+
+// This package contains the AUDIT LOG : every action done on the servers is saved with who did it and how it went, and
+// posted in the admin channel
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+
+	"github.com/Corentin-cott/ServeurSentinel/config"
+	"github.com/Corentin-cott/ServeurSentinel/internal/db"
+	"github.com/Corentin-cott/ServeurSentinel/internal/discord"
+	"github.com/Corentin-cott/ServeurSentinel/internal/logging"
+	"github.com/Corentin-cott/ServeurSentinel/internal/models"
+)
+
+var logger = logging.For("audit")
+
+// Actor is who did an action, Type is one of the models.Actor* constants
+type Actor struct {
+	Type string
+	Name string
+}
+
+// Names of the types of actors in the Discord messages
+var actorTypeNames = map[string]string{
+	models.ActorCLI:       "CLI",
+	models.ActorAPI:       "API",
+	models.ActorDiscord:   "Discord",
+	models.ActorScheduler: "Planificateur",
+}
+
+// CLI is the system user who typed the command, the real one when it goes through sudo
+func CLI() Actor {
+	name := os.Getenv("SUDO_USER")
+	if name == "" {
+		if current, err := user.Current(); err == nil {
+			name = current.Username
+		}
+	}
+	if name == "" {
+		name = "unknown"
+	}
+	return Actor{Type: models.ActorCLI, Name: name}
+}
+
+// API is the token used to call the API or the dashboard, by its name in the configuration
+func API(tokenName string) Actor {
+	return Actor{Type: models.ActorAPI, Name: tokenName}
+}
+
+// Discord is a Discord user, by its name
+func Discord(userName string) Actor {
+	return Actor{Type: models.ActorDiscord, Name: userName}
+}
+
+// Scheduler is a scheduled task, by its name
+func Scheduler(taskName string) Actor {
+	return Actor{Type: models.ActorScheduler, Name: taskName}
+}
+
+// Params formats the parameters of an action as "key=value" pairs, ex: Params("slot", "primary"). Empty values are left out
+// and the values with spaces are quoted
+func Params(keysAndValues ...string) string {
+	pairs := make([]string, 0, len(keysAndValues)/2)
+	for i := 0; i+1 < len(keysAndValues); i += 2 {
+		value := keysAndValues[i+1]
+		if value == "" {
+			continue
+		}
+		if strings.ContainsAny(value, " \t\"") {
+			value = strconv.Quote(value)
+		}
+		pairs = append(pairs, keysAndValues[i]+"="+value)
+	}
+	return strings.Join(pairs, " ")
+}
+
+// Record saves an action in the audit log and posts it in the admin channel. The serverID is 0 when the action is not about
+// a single server, and actionErr is nil when it succeeded. The action already happened, so a failure here is only logged
+func Record(actor Actor, action string, serverID int, parameters string, actionErr error) {
+	entry := models.AuditEntry{
+		ActorType:  actor.Type,
+		Actor:      actor.Name,
+		Action:     action,
+		ServerID:   serverID,
+		Parameters: parameters,
+		Success:    actionErr == nil,
+	}
+	if actionErr != nil {
+		entry.Error = actionErr.Error()
+	}
+
+	entryLogger := logger.With("actor_type", actor.Type, "actor", actor.Name, "action", action, "server_id", serverID, "parameters", parameters)
+	if actionErr != nil {
+		entryLogger.Warn("Failed action recorded in the audit log", logging.Err(actionErr))
+	} else {
+		entryLogger.Info("Action recorded in the audit log")
+	}
+	if err := db.SaveAuditEntry(entry); err != nil {
+		entryLogger.Error("Action not saved in the audit log", logging.Err(err))
+	}
+
+	conf := config.Get()
+	if conf.DiscordChannels.BotAdminChannelID == "" {
+		return
+	}
+	color := conf.EmbedColors.Good
+	if actionErr != nil {
+		color = conf.EmbedColors.Error
+	}
+	discord.QueueDiscordEmbed(conf.Bots["mineotterBot"], conf.DiscordChannels.BotAdminChannelID, "🛡 Journal d'audit", FormatEntry(entry), color)
+}
+
+// FormatEntry describes an entry of the audit log in French, for Discord
+func FormatEntry(entry models.AuditEntry) string {
+	var description strings.Builder
+	fmt.Fprintf(&description, "**Action :** %s\n", entry.Action)
+	fmt.Fprintf(&description, "**Par :** %s (%s)\n", ActorTypeName(entry.ActorType), entry.Actor)
+	if entry.ServerID > 0 {
+		fmt.Fprintf(&description, "**Serveur :** %s\n", ServerLabel(entry.ServerID))
+	}
+	if entry.Parameters != "" {
+		fmt.Fprintf(&description, "**Paramètres :** `%s`\n", strings.ReplaceAll(entry.Parameters, "`", "'"))
+	}
+	if entry.Success {
+		description.WriteString("**Résultat :** ✔ Réussie")
+	} else {
+		fmt.Fprintf(&description, "**Résultat :** ✘ %s", entry.Error)
+	}
+	return description.String()
+}
+
+// ActorTypeName returns the French name of a type of actor, the type itself if it is unknown
+func ActorTypeName(actorType string) string {
+	if name, ok := actorTypeNames[actorType]; ok {
+		return name
+	}
+	return actorType
+}
+
+// ServerLabel returns the name of a server with its ID, only the ID if the server is not found
+func ServerLabel(serverID int) string {
+	server, err := db.GetServerById(serverID)
+	if err != nil || server.Nom == "" {
+		return fmt.Sprintf("#%d", serverID)
+	}
+	return fmt.Sprintf("%s (#%d)", server.Nom, serverID)
+}
@@ -0,0 +1,384 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Corentin-cott/ServeurSentinel/config"
+	"github.com/Corentin-cott/ServeurSentinel/internal/logging"
+	"github.com/Corentin-cott/ServeurSentinel/internal/models"
+)
+
+var logger = logging.For("backup")
+
+// Format of the date inside a backup ID, ex: 20250314-050000. It also parses the milliseconds of the newer IDs
+const backupTimeFormat = "20060102-150405"
+
+// Format of the date of the new backup IDs, with milliseconds so two backups made in the same second don't collide,
+// ex: 20250314-050000.123
+const backupIDFormat = "20060102-150405.000"
+
+// Extension of every backup archive
+const backupExtension = ".tar.gz"
+
+// GetServerBackupDir returns the directory where the backups of a server are stored
+func GetServerBackupDir(server models.Server) (string, error) {
+	if config.Get().Backups.Path == "" {
+		return "", fmt.Errorf("BACKUP PATH IS NOT SET IN THE CONFIGURATION")
+	}
+	return filepath.Join(config.Get().Backups.Path, fmt.Sprint(server.ID)), nil
+}
+
+// getWorldPath returns the path of the world directory of a server
+func getWorldPath(server models.Server) string {
+	return filepath.Join(server.PathServ, server.NomMonde)
+}
+
+// CreateBackup archives the world directory of a server in the backup directory
+func CreateBackup(server models.Server) (models.Backup, error) {
+	worldPath := getWorldPath(server)
+	if info, err := os.Stat(worldPath); err != nil || !info.IsDir() {
+		return models.Backup{}, fmt.Errorf("WORLD DIRECTORY NOT FOUND: %s", worldPath)
+	}
+
+	backupDir, err := GetServerBackupDir(server)
+	if err != nil {
+		return models.Backup{}, err
+	}
+	if err := os.MkdirAll(backupDir, 0755); err != nil {
+		return models.Backup{}, fmt.Errorf("FAILED TO CREATE BACKUP DIRECTORY: %v", err)
+	}
+
+	createdAt := time.Now()
+	backupID := createdAt.Format(backupIDFormat)
+	backupPath := filepath.Join(backupDir, backupID+backupExtension)
+	if _, err := os.Stat(backupPath); err == nil {
+		return models.Backup{}, fmt.Errorf("BACKUP %s ALREADY EXISTS FOR SERVER %s", backupID, server.Nom)
+	}
+
+	// The archive is written to a temporary file first, so an interrupted backup never looks like a valid one
+	tmpPath := backupPath + ".tmp"
+	if err := writeArchive(tmpPath, server.PathServ, server.NomMonde); err != nil {
+		if !errors.Is(err, fs.ErrExist) {
+			os.Remove(tmpPath) // Written by another backup otherwise
+		}
+		return models.Backup{}, err
+	}
+	if err := os.Rename(tmpPath, backupPath); err != nil {
+		os.Remove(tmpPath)
+		return models.Backup{}, fmt.Errorf("FAILED TO MOVE BACKUP ARCHIVE IN PLACE: %v", err)
+	}
+
+	info, err := os.Stat(backupPath)
+	if err != nil {
+		return models.Backup{}, fmt.Errorf("FAILED TO STAT BACKUP ARCHIVE: %v", err)
+	}
+
+	logger.Info("Backup created", "backup", backupID, "server_id", server.ID, "server", server.Nom)
+	return models.Backup{
+		ID:        backupID,
+		ServerID:  server.ID,
+		Path:      backupPath,
+		Size:      info.Size(),
+		CreatedAt: createdAt,
+	}, nil
+}
+
+// writeArchive writes a gzipped tar of baseDir/dirName, with paths relative to baseDir
+func writeArchive(archivePath string, baseDir string, dirName string) error {
+	// O_EXCL makes a second backup with the same ID fail instead of writing in the same file
+	file, err := os.OpenFile(archivePath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("FAILED TO CREATE BACKUP ARCHIVE: %w", err)
+	}
+	defer file.Close()
+
+	gzipWriter := gzip.NewWriter(file)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	err = filepath.Walk(filepath.Join(baseDir, dirName), func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		// Minecraft keeps this file locked while running, it must not be restored anyway
+		if info.Name() == "session.lock" {
+			return nil
+		}
+		if !info.Mode().IsRegular() && !info.IsDir() {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(baseDir, path)
+		if err != nil {
+			return err
+		}
+
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(relPath)
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			return nil
+		}
+		source, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer source.Close()
+		_, err = io.Copy(tarWriter, source)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("FAILED TO ARCHIVE WORLD DIRECTORY: %v", err)
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		return fmt.Errorf("FAILED TO CLOSE TAR ARCHIVE: %v", err)
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return fmt.Errorf("FAILED TO CLOSE GZIP ARCHIVE: %v", err)
+	}
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("FAILED TO SYNC BACKUP ARCHIVE: %v", err)
+	}
+	return nil
+}
+
+// ListBackups returns the backups of a server, newest first
+func ListBackups(server models.Server) ([]models.Backup, error) {
+	backupDir, err := GetServerBackupDir(server)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := os.ReadDir(backupDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []models.Backup{}, nil // No backup has been made yet
+		}
+		return nil, fmt.Errorf("FAILED TO READ BACKUP DIRECTORY: %v", err)
+	}
+
+	backups := make([]models.Backup, 0)
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), backupExtension) {
+			continue
+		}
+		backupID := strings.TrimSuffix(file.Name(), backupExtension)
+		createdAt, err := time.ParseInLocation(backupTimeFormat, backupID, time.Local)
+		if err != nil {
+			continue // Not a file created by ServerSentinel
+		}
+		info, err := file.Info()
+		if err != nil {
+			return nil, fmt.Errorf("FAILED TO STAT BACKUP %s: %v", backupID, err)
+		}
+		backups = append(backups, models.Backup{
+			ID:        backupID,
+			ServerID:  server.ID,
+			Path:      filepath.Join(backupDir, file.Name()),
+			Size:      info.Size(),
+			CreatedAt: createdAt,
+		})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].CreatedAt.After(backups[j].CreatedAt)
+	})
+	return backups, nil
+}
+
+// GetBackup returns a backup of a server by its ID
+func GetBackup(server models.Server, backupID string) (models.Backup, error) {
+	backups, err := ListBackups(server)
+	if err != nil {
+		return models.Backup{}, err
+	}
+	for _, backup := range backups {
+		if backup.ID == backupID {
+			return backup, nil
+		}
+	}
+	return models.Backup{}, fmt.Errorf("BACKUP %s NOT FOUND FOR SERVER %s", backupID, server.Nom)
+}
+
+// PruneBackups removes the oldest backups of a server to only keep the given number, it returns how many were removed
+func PruneBackups(server models.Server, keep int) (int, error) {
+	backups, err := ListBackups(server)
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for i := keep; i < len(backups); i++ {
+		if err := os.Remove(backups[i].Path); err != nil {
+			return removed, fmt.Errorf("FAILED TO REMOVE BACKUP %s: %v", backups[i].ID, err)
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// VerifyBackup reads the whole archive to make sure it is not truncated or corrupted, and that it contains the world
+func VerifyBackup(backup models.Backup, worldName string) error {
+	file, err := os.Open(backup.Path)
+	if err != nil {
+		return fmt.Errorf("FAILED TO OPEN BACKUP ARCHIVE: %v", err)
+	}
+	defer file.Close()
+
+	gzipReader, err := gzip.NewReader(file)
+	if err != nil {
+		return fmt.Errorf("BACKUP %s IS NOT A VALID GZIP ARCHIVE: %v", backup.ID, err)
+	}
+	defer gzipReader.Close()
+
+	worldFound := false
+	tarReader := tar.NewReader(gzipReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("BACKUP %s IS CORRUPTED: %v", backup.ID, err)
+		}
+		if _, err := safeJoin(string(filepath.Separator)+"backup", header.Name); err != nil {
+			return fmt.Errorf("BACKUP %s CONTAINS AN INVALID PATH: %v", backup.ID, err)
+		}
+		if strings.SplitN(header.Name, "/", 2)[0] == worldName {
+			worldFound = true
+		}
+		// Reading the content makes gzip check its CRC at the end of the stream
+		if _, err := io.Copy(io.Discard, tarReader); err != nil {
+			return fmt.Errorf("BACKUP %s IS CORRUPTED: %v", backup.ID, err)
+		}
+	}
+
+	if !worldFound {
+		return fmt.Errorf("BACKUP %s DOES NOT CONTAIN THE WORLD %s", backup.ID, worldName)
+	}
+	return nil
+}
+
+// RestoreBackup replaces the world of a server with a backup, the current world is moved aside and its new path is returned
+func RestoreBackup(server models.Server, backupID string) (string, error) {
+	backup, err := GetBackup(server, backupID)
+	if err != nil {
+		return "", err
+	}
+
+	if err := VerifyBackup(backup, server.NomMonde); err != nil {
+		return "", err
+	}
+
+	// Extract next to the world first, so a failed extraction leaves the current world untouched
+	extractDir := filepath.Join(server.PathServ, ".restore-"+backup.ID)
+	if err := os.RemoveAll(extractDir); err != nil {
+		return "", fmt.Errorf("FAILED TO CLEAN RESTORE DIRECTORY: %v", err)
+	}
+	if err := extractArchive(backup.Path, extractDir); err != nil {
+		os.RemoveAll(extractDir)
+		return "", err
+	}
+
+	worldPath := getWorldPath(server)
+	asidePath := ""
+	if _, err := os.Stat(worldPath); err == nil {
+		asidePath = worldPath + ".before-restore-" + time.Now().Format(backupTimeFormat)
+		if err := os.Rename(worldPath, asidePath); err != nil {
+			os.RemoveAll(extractDir)
+			return "", fmt.Errorf("FAILED TO MOVE CURRENT WORLD ASIDE: %v", err)
+		}
+	}
+
+	if err := os.Rename(filepath.Join(extractDir, server.NomMonde), worldPath); err != nil {
+		// Put the current world back where it was
+		if asidePath != "" {
+			os.Rename(asidePath, worldPath)
+		}
+		os.RemoveAll(extractDir)
+		return "", fmt.Errorf("FAILED TO MOVE RESTORED WORLD IN PLACE: %v", err)
+	}
+	os.RemoveAll(extractDir)
+
+	logger.Info("Backup restored", "backup", backup.ID, "server_id", server.ID, "server", server.Nom)
+	return asidePath, nil
+}
+
+// extractArchive extracts a gzipped tar archive inside destDir
+func extractArchive(archivePath string, destDir string) error {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return fmt.Errorf("FAILED TO OPEN BACKUP ARCHIVE: %v", err)
+	}
+	defer file.Close()
+
+	gzipReader, err := gzip.NewReader(file)
+	if err != nil {
+		return fmt.Errorf("FAILED TO READ GZIP ARCHIVE: %v", err)
+	}
+	defer gzipReader.Close()
+
+	tarReader := tar.NewReader(gzipReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("FAILED TO READ TAR ARCHIVE: %v", err)
+		}
+
+		target, err := safeJoin(destDir, header.Name)
+		if err != nil {
+			return err
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return fmt.Errorf("FAILED TO CREATE DIRECTORY %s: %v", target, err)
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return fmt.Errorf("FAILED TO CREATE DIRECTORY %s: %v", filepath.Dir(target), err)
+			}
+			out, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, os.FileMode(header.Mode).Perm())
+			if err != nil {
+				return fmt.Errorf("FAILED TO CREATE FILE %s: %v", target, err)
+			}
+			if _, err := io.Copy(out, tarReader); err != nil {
+				out.Close()
+				return fmt.Errorf("FAILED TO EXTRACT FILE %s: %v", target, err)
+			}
+			if err := out.Close(); err != nil {
+				return fmt.Errorf("FAILED TO CLOSE FILE %s: %v", target, err)
+			}
+		}
+	}
+}
+
+// safeJoin joins an archive entry name to a directory, refusing entries that would escape it
+func safeJoin(dir string, name string) (string, error) {
+	target := filepath.Join(dir, name)
+	relPath, err := filepath.Rel(dir, target)
+	if err != nil || relPath == ".." || strings.HasPrefix(relPath, ".."+string(filepath.Separator)) || filepath.IsAbs(name) {
+		return "", fmt.Errorf("ARCHIVE ENTRY %s IS OUTSIDE OF THE DESTINATION", name)
+	}
+	return target, nil
+}
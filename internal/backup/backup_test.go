@@ -0,0 +1,287 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Corentin-cott/ServeurSentinel/config"
+	"github.com/Corentin-cott/ServeurSentinel/internal/models"
+)
+
+// archiveEntry is a file of a test archive, a directory when its name ends with "/"
+type archiveEntry struct {
+	name    string
+	content string
+}
+
+// writeTestArchive writes a gzipped tar with the given entries and returns its path
+func writeTestArchive(t *testing.T, entries []archiveEntry) string {
+	t.Helper()
+	var buffer bytes.Buffer
+	gzipWriter := gzip.NewWriter(&buffer)
+	tarWriter := tar.NewWriter(gzipWriter)
+	for _, entry := range entries {
+		header := &tar.Header{Name: entry.name, Mode: 0644, Typeflag: tar.TypeReg, Size: int64(len(entry.content))}
+		if strings.HasSuffix(entry.name, "/") {
+			header = &tar.Header{Name: entry.name, Mode: 0755, Typeflag: tar.TypeDir}
+		}
+		if err := tarWriter.WriteHeader(header); err != nil {
+			t.Fatalf("archive entry %s not written: %v", entry.name, err)
+		}
+		if _, err := tarWriter.Write([]byte(entry.content)); err != nil {
+			t.Fatalf("archive entry %s not written: %v", entry.name, err)
+		}
+	}
+	if err := tarWriter.Close(); err != nil {
+		t.Fatalf("archive not closed: %v", err)
+	}
+	if err := gzipWriter.Close(); err != nil {
+		t.Fatalf("archive not closed: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "20250314-050000"+backupExtension)
+	if err := os.WriteFile(path, buffer.Bytes(), 0644); err != nil {
+		t.Fatalf("archive not written: %v", err)
+	}
+	return path
+}
+
+// setupServer creates a server with a world and uses a temporary backup directory
+func setupServer(t *testing.T) models.Server {
+	t.Helper()
+	dir := t.TempDir()
+	config.Set(config.Config{Backups: models.BackupConfig{Path: filepath.Join(dir, "backups")}})
+	t.Cleanup(func() { config.Set(config.Config{}) })
+
+	server := models.Server{ID: 5, Nom: "survie", PathServ: filepath.Join(dir, "server"), NomMonde: "world"}
+	writeFile(t, filepath.Join(server.PathServ, "world", "level.dat"), "original")
+	writeFile(t, filepath.Join(server.PathServ, "world", "region", "r.0.0.mca"), "region")
+	writeFile(t, filepath.Join(server.PathServ, "world", "session.lock"), "lock")
+	return server
+}
+
+func writeFile(t *testing.T, path string, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("directory of %s not created: %v", path, err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("%s not written: %v", path, err)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%s not read: %v", path, err)
+	}
+	return string(content)
+}
+
+func TestSafeJoin(t *testing.T) {
+	dir := filepath.Join(string(filepath.Separator)+"srv", "restore")
+	tests := []struct {
+		name    string
+		entry   string
+		want    string
+		wantErr bool
+	}{
+		{"file", "world/level.dat", filepath.Join(dir, "world", "level.dat"), false},
+		{"directory", "world/", filepath.Join(dir, "world"), false},
+		{"dot inside", "world/./region/../level.dat", filepath.Join(dir, "world", "level.dat"), false},
+		{"name starting with dots", "..world/level.dat", filepath.Join(dir, "..world", "level.dat"), false},
+		{"parent", "..", "", true},
+		{"escaping", "../../etc/passwd", "", true},
+		{"escaping through a directory", "world/../../other", "", true},
+		{"absolute", "/etc/passwd", "", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := safeJoin(dir, test.entry)
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("safeJoin(%q) = %q, want an error", test.entry, got)
+				}
+				return
+			}
+			if err != nil || got != test.want {
+				t.Errorf("safeJoin(%q) = %q, %v, want %q", test.entry, got, err, test.want)
+			}
+		})
+	}
+}
+
+func TestVerifyBackup(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []archiveEntry
+		wantErr string
+	}{
+		{"valid", []archiveEntry{{"world/", ""}, {"world/level.dat", "data"}}, ""},
+		{"world without directory entry", []archiveEntry{{"world/level.dat", "data"}}, ""},
+		{"other world", []archiveEntry{{"world_nether/level.dat", "data"}}, "DOES NOT CONTAIN THE WORLD"},
+		{"empty", nil, "DOES NOT CONTAIN THE WORLD"},
+		{"escaping entry", []archiveEntry{{"world/level.dat", "data"}, {"../../etc/cron.d/evil", "x"}}, "CONTAINS AN INVALID PATH"},
+		{"absolute entry", []archiveEntry{{"/world/level.dat", "data"}}, "CONTAINS AN INVALID PATH"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			backup := models.Backup{ID: "20250314-050000", Path: writeTestArchive(t, test.entries)}
+			err := VerifyBackup(backup, "world")
+			if test.wantErr == "" {
+				if err != nil {
+					t.Errorf("VerifyBackup() failed: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("VerifyBackup() = %v, want an error containing %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyBackupDamagedFiles(t *testing.T) {
+	valid := writeTestArchive(t, []archiveEntry{{"world/level.dat", strings.Repeat("data", 10000)}})
+	content, err := os.ReadFile(valid)
+	if err != nil {
+		t.Fatalf("archive not read: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		content []byte
+		wantErr string
+	}{
+		{"not gzip", []byte("not an archive"), "IS NOT A VALID GZIP ARCHIVE"},
+		{"truncated", content[:len(content)/2], "IS CORRUPTED"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "damaged"+backupExtension)
+			if err := os.WriteFile(path, test.content, 0644); err != nil {
+				t.Fatalf("archive not written: %v", err)
+			}
+			err := VerifyBackup(models.Backup{ID: "damaged", Path: path}, "world")
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("VerifyBackup() = %v, want an error containing %q", err, test.wantErr)
+			}
+		})
+	}
+
+	if err := VerifyBackup(models.Backup{ID: "missing", Path: filepath.Join(t.TempDir(), "missing.tar.gz")}, "world"); err == nil {
+		t.Errorf("VerifyBackup() of a missing file succeeded, want an error")
+	}
+}
+
+func TestRestoreBackup(t *testing.T) {
+	server := setupServer(t)
+	backup, err := CreateBackup(server)
+	if err != nil {
+		t.Fatalf("CreateBackup() failed: %v", err)
+	}
+
+	// The world changes after the backup
+	worldPath := filepath.Join(server.PathServ, "world")
+	writeFile(t, filepath.Join(worldPath, "level.dat"), "changed")
+	writeFile(t, filepath.Join(worldPath, "new.dat"), "new")
+
+	asidePath, err := RestoreBackup(server, backup.ID)
+	if err != nil {
+		t.Fatalf("RestoreBackup() failed: %v", err)
+	}
+
+	if got := readFile(t, filepath.Join(worldPath, "level.dat")); got != "original" {
+		t.Errorf("restored level.dat = %q, want %q", got, "original")
+	}
+	if got := readFile(t, filepath.Join(worldPath, "region", "r.0.0.mca")); got != "region" {
+		t.Errorf("restored r.0.0.mca = %q, want %q", got, "region")
+	}
+	if _, err := os.Stat(filepath.Join(worldPath, "new.dat")); !os.IsNotExist(err) {
+		t.Errorf("new.dat is still in the restored world")
+	}
+	if _, err := os.Stat(filepath.Join(worldPath, "session.lock")); !os.IsNotExist(err) {
+		t.Errorf("session.lock was archived and restored")
+	}
+	if got := readFile(t, filepath.Join(asidePath, "level.dat")); got != "changed" {
+		t.Errorf("level.dat moved aside = %q, want %q", got, "changed")
+	}
+	if _, err := os.Stat(filepath.Join(server.PathServ, ".restore-"+backup.ID)); !os.IsNotExist(err) {
+		t.Errorf("the restore directory was not removed")
+	}
+}
+
+func TestRestoreBackupErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		backupID string
+		archive  []archiveEntry
+		wantErr  string
+	}{
+		{"unknown backup", "20240101-000000", nil, "NOT FOUND"},
+		{"other world", "20250314-050000", []archiveEntry{{"world_nether/level.dat", "data"}}, "DOES NOT CONTAIN THE WORLD"},
+		{"escaping entry", "20250314-050000", []archiveEntry{{"world/level.dat", "data"}, {"../evil", "x"}}, "CONTAINS AN INVALID PATH"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := setupServer(t)
+			backupDir, err := GetServerBackupDir(server)
+			if err != nil {
+				t.Fatalf("GetServerBackupDir() failed: %v", err)
+			}
+			if test.archive != nil {
+				content, err := os.ReadFile(writeTestArchive(t, test.archive))
+				if err != nil {
+					t.Fatalf("archive not read: %v", err)
+				}
+				writeFile(t, filepath.Join(backupDir, "20250314-050000"+backupExtension), string(content))
+			}
+
+			_, err = RestoreBackup(server, test.backupID)
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("RestoreBackup() = %v, want an error containing %q", err, test.wantErr)
+			}
+			// The current world is left untouched
+			if got := readFile(t, filepath.Join(server.PathServ, "world", "level.dat")); got != "original" {
+				t.Errorf("level.dat = %q after a failed restore, want %q", got, "original")
+			}
+		})
+	}
+}
+
+func TestListBackups(t *testing.T) {
+	server := setupServer(t)
+	backupDir, err := GetServerBackupDir(server)
+	if err != nil {
+		t.Fatalf("GetServerBackupDir() failed: %v", err)
+	}
+	for _, name := range []string{
+		"20250314-050000.tar.gz",     // Before the milliseconds
+		"20250314-050000.250.tar.gz", // Same second, later
+		"20250313-050000.tar.gz",
+		"20250315-050000.tar.gz.tmp", // Being written
+		"notes.txt",
+	} {
+		writeFile(t, filepath.Join(backupDir, name), "x")
+	}
+
+	backups, err := ListBackups(server)
+	if err != nil {
+		t.Fatalf("ListBackups() failed: %v", err)
+	}
+	want := []string{"20250314-050000.250", "20250314-050000", "20250313-050000"}
+	if len(backups) != len(want) {
+		t.Fatalf("ListBackups() returned %d backups, want %d", len(backups), len(want))
+	}
+	for i, backup := range backups {
+		if backup.ID != want[i] {
+			t.Errorf("backup %d = %s, want %s", i, backup.ID, want[i])
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package console
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Matches the ANSI escape sequences, only the SGR ones (ending with "m") change the style
+var ansiSequenceRegex = regexp.MustCompile(`\x1b\[([0-9;]*)([A-Za-z])`)
+
+// The 16 standard colors of the terminals, ex: what Minecraft uses for the levels of its logs
+var ansiColors = [16]string{
+	"#000000", "#aa0000", "#00aa00", "#aa5500", "#0000aa", "#aa00aa", "#00aaaa", "#aaaaaa",
+	"#555555", "#ff5555", "#55ff55", "#ffff55", "#5555ff", "#ff55ff", "#55ffff", "#ffffff",
+}
+
+// ansiStyle is the style set by the SGR codes read so far
+type ansiStyle struct {
+	bold, italic, underline bool
+	foreground, background  string // CSS colors, empty for the default one
+}
+
+func (s ansiStyle) css() string {
+	var rules []string
+	if s.bold {
+		rules = append(rules, "font-weight:bold")
+	}
+	if s.italic {
+		rules = append(rules, "font-style:italic")
+	}
+	if s.underline {
+		rules = append(rules, "text-decoration:underline")
+	}
+	if s.foreground != "" {
+		rules = append(rules, "color:"+s.foreground)
+	}
+	if s.background != "" {
+		rules = append(rules, "background-color:"+s.background)
+	}
+	return strings.Join(rules, ";")
+}
+
+// ANSIToHTML escapes a line for HTML and turns its ANSI colors and styles into <span style="..."> elements
+func ANSIToHTML(line string) string {
+	var builder strings.Builder
+	var style ansiStyle
+	open := false
+
+	writeText := func(text string) {
+		if text == "" {
+			return
+		}
+		if css := style.css(); css != "" && !open {
+			builder.WriteString(`<span style="` + css + `">`)
+			open = true
+		}
+		builder.WriteString(html.EscapeString(text))
+	}
+
+	position := 0
+	for _, match := range ansiSequenceRegex.FindAllStringSubmatchIndex(line, -1) {
+		writeText(line[position:match[0]])
+		position = match[1]
+		if line[match[4]:match[5]] != "m" {
+			continue // Cursor moves and the like mean nothing in a web page
+		}
+		next := applySGR(style, line[match[2]:match[3]])
+		if next != style && open {
+			builder.WriteString("</span>")
+			open = false
+		}
+		style = next
+	}
+	writeText(line[position:])
+	if open {
+		builder.WriteString("</span>")
+	}
+	return builder.String()
+}
+
+// applySGR returns the style after the codes of a SGR sequence, ex: "1;31" for bold red
+func applySGR(style ansiStyle, parameters string) ansiStyle {
+	if parameters == "" {
+		return ansiStyle{}
+	}
+	codes := strings.Split(parameters, ";")
+	for i := 0; i < len(codes); i++ {
+		code, err := strconv.Atoi(codes[i])
+		if err != nil {
+			continue
+		}
+		switch {
+		case code == 0:
+			style = ansiStyle{}
+		case code == 1:
+			style.bold = true
+		case code == 3:
+			style.italic = true
+		case code == 4:
+			style.underline = true
+		case code == 22:
+			style.bold = false
+		case code == 23:
+			style.italic = false
+		case code == 24:
+			style.underline = false
+		case code >= 30 && code <= 37:
+			style.foreground = ansiColors[code-30]
+		case code >= 90 && code <= 97:
+			style.foreground = ansiColors[code-90+8]
+		case code == 39:
+			style.foreground = ""
+		case code >= 40 && code <= 47:
+			style.background = ansiColors[code-40]
+		case code >= 100 && code <= 107:
+			style.background = ansiColors[code-100+8]
+		case code == 49:
+			style.background = ""
+		case code == 38 || code == 48:
+			// Extended colors, "38;5;n" from the 256 colors palette or "38;2;r;g;b"
+			color, used := extendedColor(codes[i+1:])
+			i += used
+			if code == 38 {
+				style.foreground = color
+			} else {
+				style.background = color
+			}
+		}
+	}
+	return style
+}
+
+// extendedColor reads the color after a 38 or 48 code, it returns the CSS color and how many codes it used
+func extendedColor(codes []string) (string, int) {
+	numbers := make([]int, 0, 4)
+	for _, code := range codes {
+		n, err := strconv.Atoi(code)
+		if err != nil || n < 0 || n > 255 {
+			break
+		}
+		numbers = append(numbers, n)
+	}
+
+	switch {
+	case len(numbers) >= 2 && numbers[0] == 5:
+		return palette256(numbers[1]), 2
+	case len(numbers) >= 4 && numbers[0] == 2:
+		return fmt.Sprintf("#%02x%02x%02x", numbers[1], numbers[2], numbers[3]), 4
+	}
+	return "", len(numbers)
+}
+
+// palette256 returns a color of the 256 colors palette: the 16 standard ones, a 6x6x6 cube, then 24 grays
+func palette256(n int) string {
+	switch {
+	case n < 16:
+		return ansiColors[n]
+	case n < 232:
+		n -= 16
+		level := func(v int) int {
+			if v == 0 {
+				return 0
+			}
+			return 55 + v*40
+		}
+		return fmt.Sprintf("#%02x%02x%02x", level(n/36), level(n/6%6), level(n%6))
+	}
+	gray := 8 + (n-232)*10
+	return fmt.Sprintf("#%02x%02x%02x", gray, gray, gray)
+}
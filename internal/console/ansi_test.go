@@ -0,0 +1,34 @@
+package console
+
+import "testing"
+
+func TestANSIToHTML(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want string
+	}{
+		{"plain text", "Done (3.2s)!", "Done (3.2s)!"},
+		{"escaped", `<Steve> a & "b"`, "&lt;Steve&gt; a &amp; &#34;b&#34;"},
+		{"color", "\x1b[31mError\x1b[0m", `<span style="color:#aa0000">Error</span>`},
+		{"bright color", "\x1b[92mOK", `<span style="color:#55ff55">OK</span>`},
+		{"bold and color", "\x1b[1;33mWarn\x1b[m done", `<span style="font-weight:bold;color:#aa5500">Warn</span> done`},
+		{"style change", "\x1b[31ma\x1b[32mb", `<span style="color:#aa0000">a</span><span style="color:#00aa00">b</span>`},
+		{"same style again", "\x1b[31ma\x1b[31mb", `<span style="color:#aa0000">ab</span>`},
+		{"background", "\x1b[44mx", `<span style="background-color:#0000aa">x</span>`},
+		{"default color", "\x1b[1;31ma\x1b[39mb", `<span style="font-weight:bold;color:#aa0000">a</span><span style="font-weight:bold">b</span>`},
+		{"256 colors", "\x1b[38;5;196mx", `<span style="color:#ff0000">x</span>`},
+		{"256 colors gray", "\x1b[48;5;232mx", `<span style="background-color:#080808">x</span>`},
+		{"true color", "\x1b[38;2;1;2;3mx", `<span style="color:#010203">x</span>`},
+		{"cursor move dropped", "\x1b[2Ka\x1b[1Gb", "ab"},
+		{"only a reset", "\x1b[0m", ""},
+		{"style without text", "a\x1b[31m", "a"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := ANSIToHTML(test.line); got != test.want {
+				t.Errorf("ANSIToHTML(%q) = %q, want %q", test.line, got, test.want)
+			}
+		})
+	}
+}
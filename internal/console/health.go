@@ -0,0 +1,77 @@
+package console
+
+// This file contains the HEALTH of the log listeners, used by the systemd watchdog
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// listenerHealth is the last sign of life of a log listener
+type listenerHealth struct {
+	lastSeen time.Time
+	err      error // Set when the listener stopped because of an error
+}
+
+var (
+	healthMutex sync.Mutex
+	listeners   = make(map[string]*listenerHealth) // log file path -> health
+)
+
+// A listener is considered stuck when it didn't loop for this long, it loops at least every 100ms when idle
+var listenerStuckAfter = 30 * time.Second
+
+// touchListener records that a listener is alive
+func touchListener(logFilePath string) {
+	healthMutex.Lock()
+	defer healthMutex.Unlock()
+	listeners[logFilePath] = &listenerHealth{lastSeen: time.Now()}
+}
+
+// stopListener records that a listener stopped, with the error that stopped it or nil if it was asked to stop
+func stopListener(logFilePath string, err error) {
+	healthMutex.Lock()
+	defer healthMutex.Unlock()
+	if err == nil {
+		delete(listeners, logFilePath)
+		return
+	}
+	listeners[logFilePath] = &listenerHealth{lastSeen: time.Now(), err: err}
+}
+
+// CheckListenersHealth returns an error if a log listener stopped because of an error or is stuck
+func CheckListenersHealth() error {
+	healthMutex.Lock()
+	defer healthMutex.Unlock()
+
+	var problems []string
+	for logFilePath, health := range listeners {
+		switch {
+		case health.err != nil:
+			problems = append(problems, fmt.Sprintf("%s stopped (%v)", logFilePath, health.err))
+		case time.Since(health.lastSeen) > listenerStuckAfter:
+			problems = append(problems, fmt.Sprintf("%s stuck since %s", logFilePath, health.lastSeen.Format("15:04:05")))
+		}
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("LOG LISTENERS UNHEALTHY: %s", strings.Join(problems, ", "))
+	}
+	return nil
+}
+
+// CountListeners returns how many log listeners are running
+func CountListeners() int {
+	healthMutex.Lock()
+	defer healthMutex.Unlock()
+	count := 0
+	for _, health := range listeners {
+		if health.err == nil {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,80 @@
+package console
+
+// This file contains the CHECKPOINTS of the log listeners, so a restarted daemon continues reading where it stopped
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/Corentin-cott/ServeurSentinel/config"
+)
+
+// Name of the checkpoint file inside the state directory
+const offsetsFileName = "log_offsets.json"
+
+var (
+	offsetsMutex sync.Mutex
+	offsets      = make(map[string]int64) // log file path -> offset of the next line to read
+)
+
+func getOffsetsFilePath() string {
+	return filepath.Join(config.Get().StatePath, offsetsFileName)
+}
+
+// LoadOffsets reads the checkpoint file, a missing file simply means every listener starts at the end of its log file
+func LoadOffsets() error {
+	offsetsMutex.Lock()
+	defer offsetsMutex.Unlock()
+
+	data, err := os.ReadFile(getOffsetsFilePath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("ERROR WHILE READING LOG OFFSETS: %v", err)
+	}
+	if err := json.Unmarshal(data, &offsets); err != nil {
+		return fmt.Errorf("ERROR WHILE DECODING LOG OFFSETS: %v", err)
+	}
+	return nil
+}
+
+// SaveOffsets writes the checkpoint file
+func SaveOffsets() error {
+	offsetsMutex.Lock()
+	data, err := json.MarshalIndent(offsets, "", "  ")
+	offsetsMutex.Unlock()
+	if err != nil {
+		return fmt.Errorf("ERROR WHILE ENCODING LOG OFFSETS: %v", err)
+	}
+
+	if err := os.MkdirAll(config.Get().StatePath, 0750); err != nil {
+		return fmt.Errorf("ERROR WHILE CREATING STATE DIRECTORY: %v", err)
+	}
+
+	// Written next to the real file then renamed, so a crash never leaves a half-written checkpoint
+	tmpPath := getOffsetsFilePath() + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0640); err != nil {
+		return fmt.Errorf("ERROR WHILE WRITING LOG OFFSETS: %v", err)
+	}
+	if err := os.Rename(tmpPath, getOffsetsFilePath()); err != nil {
+		return fmt.Errorf("ERROR WHILE WRITING LOG OFFSETS: %v", err)
+	}
+	return nil
+}
+
+func getOffset(logFilePath string) (int64, bool) {
+	offsetsMutex.Lock()
+	defer offsetsMutex.Unlock()
+	offset, ok := offsets[logFilePath]
+	return offset, ok
+}
+
+func setOffset(logFilePath string, offset int64) {
+	offsetsMutex.Lock()
+	defer offsetsMutex.Unlock()
+	offsets[logFilePath] = offset
+}
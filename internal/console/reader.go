@@ -2,99 +2,171 @@ package console
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"io"
-	"log"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/Corentin-cott/ServeurSentinel/internal/accounts"
 	"github.com/Corentin-cott/ServeurSentinel/internal/db"
+	"github.com/Corentin-cott/ServeurSentinel/internal/discord"
+	"github.com/Corentin-cott/ServeurSentinel/internal/logging"
+	"github.com/Corentin-cott/ServeurSentinel/internal/metrics"
 	"github.com/Corentin-cott/ServeurSentinel/internal/models"
 	"github.com/Corentin-cott/ServeurSentinel/internal/triggers"
 )
 
-// StartFileLogListener starts listening to a log file in real time
-func StartFileLogListener(logFilePath string, triggersVar []models.Trigger) error {
+var logger = logging.For("console")
+
+// The triggers checked against every line, replaced as a whole when the configuration is reloaded
+var activeTriggers atomic.Pointer[[]models.Trigger]
+
+// SetTriggers replaces the triggers used by every log listener
+func SetTriggers(triggersList []models.Trigger) {
+	activeTriggers.Store(&triggersList)
+}
+
+func getTriggers() []models.Trigger {
+	triggersList := activeTriggers.Load()
+	if triggersList == nil {
+		return nil
+	}
+	return *triggersList
+}
+
+// StartFileLogListener starts listening to a log file in real time, until the context is cancelled
+func StartFileLogListener(ctx context.Context, logFilePath string) error {
 	file, err := os.Open(logFilePath)
 	if err != nil {
 		return fmt.Errorf("ERROR WHILE OPENING LOG FILE NAMED %s : %v", logFilePath, err)
 	}
 	defer file.Close()
+	fileLogger := logger.With("file", logFilePath)
 
-	// Position the cursor at the end of the file
-	if _, err := file.Seek(0, io.SeekEnd); err != nil {
+	// Continue from the last checkpoint if the file wasn't truncated since, else position the cursor at the end of the file
+	offset, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
 		return fmt.Errorf("ERROR WHILE SEEKING TO THE END OF THE FILE NAMED %s : %v", logFilePath, err)
 	}
+	if checkpoint, ok := getOffset(logFilePath); ok && checkpoint <= offset {
+		fileLogger.Info("Resuming the log file from its checkpoint", "bytes_behind", offset-checkpoint)
+		if offset, err = file.Seek(checkpoint, io.SeekStart); err != nil {
+			return fmt.Errorf("ERROR WHILE SEEKING TO THE CHECKPOINT OF THE FILE NAMED %s : %v", logFilePath, err)
+		}
+	}
+	setOffset(logFilePath, offset)
+
+	// We check if logFilePath ends with 1.log or 2.log. If 1.log, it's primary server. If 2.log, it's secondary server.
+	var serverType string
+	var getServerID func() int
+	if strings.HasSuffix(logFilePath, "1.log") {
+		serverType, getServerID = "primary", db.GetPrimaryServerId
+	} else if strings.HasSuffix(logFilePath, "2.log") {
+		serverType, getServerID = "secondary", db.GetSecondaryServerId
+	} else if strings.HasSuffix(logFilePath, "3.log") {
+		serverType, getServerID = "partner", db.GetPartenariatServerId
+	} else {
+		fileLogger.Error("Server type unknown, the log file must end with 1.log, 2.log or 3.log")
+		return nil
+	}
+
+	// The server is resolved once, then again only when the servers or the slots changed
+	serversGeneration := db.ServersGeneration()
+	serverID := getServerID()
+	fileName := filepath.Base(logFilePath)
 
-	fmt.Printf("✔ Started listening to log file %s with %d triggers.\n", logFilePath, len(triggersVar))
+	fileLogger.Info("Listening to the log file", "server_type", serverType, "server_id", serverID, "triggers", len(getTriggers()))
 
 	// Read the file line by line
 	reader := bufio.NewReader(file)
+	pending := "" // Beginning of a line that is still being written
 	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+		}
+		touchListener(logFilePath)
+
 		line, err := reader.ReadString('\n') // Define the delimiter as '\n' is the line break character
 		if err != nil {
-			if err.Error() == "EOF" { // If the end of the file is reached, wait for 100ms and continue
-				time.Sleep(100 * time.Millisecond)
+			if err == io.EOF { // If the end of the file is reached, wait for 100ms and continue
+				pending += line
+				select {
+				case <-ctx.Done():
+					return nil
+				case <-time.After(100 * time.Millisecond):
+				}
 				continue
 			}
 			return fmt.Errorf("ERROR WHILE READING LOG FILE NAMED %s : %v", logFilePath, err)
 		}
-
-		// We check if logFilePath ends with 1.log or 2.log. If 1.log, it's primary server. If 2.log, it's secondary server.
-		var serverType string
-		if strings.HasSuffix(logFilePath, "1.log") {
-			serverType = "primary"
-		} else if strings.HasSuffix(logFilePath, "2.log") {
-			serverType = "secondary"
-		} else if strings.HasSuffix(logFilePath, "3.log") {
-			serverType = "partner"
-		} else {
-			fmt.Println("✘ Error while determining server type, is the log file name correct?")
-			return nil
+		line = pending + line
+		pending = ""
+		offset += int64(len(line))
+		metrics.LinesRead.Inc(fileName)
+
+		if generation := db.ServersGeneration(); generation != serversGeneration {
+			serversGeneration = generation
+			serverID = getServerID()
 		}
 
-		// Depending on the server type, we set the serverID
-		var serverID int
-		if serverType == "primary" {
-			serverID = db.GetPrimaryServerId()
-		} else if serverType == "secondary" {
-			serverID = db.GetSecondaryServerId()
-		} else if serverType == "partner" {
-			serverID = db.GetPartenariatServerId()
-		}
-
-		// We send the log in the appropriate channel by webhook
-		err = triggers.SendToDiscordWebhook(serverType, line)
-		if err != nil {
-			fmt.Println("✘ Error while sending log to Discord webhook: " + err.Error())
-		}
+		line = forwardLine(serverID, serverType, line)
 
 		// Remove leading and trailing whitespaces
 		line = removeANSIcodes(strings.TrimSpace(line))
 		if line != "" {
-			for _, trigger := range triggersVar {
+			for _, trigger := range getTriggers() {
 				if trigger.Condition(line) {
+					metrics.TriggerMatches.Inc(trigger.Name)
 					trigger.Action(line, serverID)
 				}
 			}
 		}
+		setOffset(logFilePath, offset)
 	}
 }
 
-// Function to process all log files in a directory
-func ProcessLogFiles(logDirPath string, triggersList []models.Trigger) {
+// forwardLine sends a line to the live console and to the webhook of the server type, with the pending link codes hidden.
+// It returns the line as forwarded
+func forwardLine(serverID int, serverType string, line string) string {
+	line = accounts.RedactLinkCodes(line)
+
+	// The live console of the API, with its colors
+	Publish(serverID, line)
+
+	// We send the log in the appropriate channel by webhook
+	discord.Queue("log line to the "+serverType+" webhook", func() error {
+		return triggers.SendToDiscordWebhook(serverType, line)
+	})
+	return line
+}
+
+// How often the log offsets are saved while running, they are also saved when the listeners stop
+var offsetsCheckpointInterval = time.Minute
+
+// Function to process all log files in a directory, it returns when the context is cancelled or every listener stopped
+func ProcessLogFiles(ctx context.Context, logDirPath string, triggersList []models.Trigger) error {
+	SetTriggers(triggersList)
+
 	logFiles, err := filepath.Glob(filepath.Join(logDirPath, "*.log"))
 	if err != nil {
-		log.Fatalf("✘ FATAL ERROR WHEN GETTING LOG FILES: %v", err)
+		return fmt.Errorf("ERROR WHEN GETTING LOG FILES: %v", err)
 	}
 
 	if len(logFiles) == 0 {
-		log.Println("✘ No log files found in the directory, did you forget to redirect the logs to the folder?")
-		return
+		logger.Error("No log files found, did you forget to redirect the logs of the servers to the folder?", "directory", logDirPath)
+		return nil
+	}
+
+	if err := LoadOffsets(); err != nil {
+		logger.Warn("Log offsets not loaded, every log file will be read from its end", logging.Err(err))
 	}
 
 	// Create a wait group
@@ -109,15 +181,35 @@ func ProcessLogFiles(logDirPath string, triggersList []models.Trigger) {
 		wg.Add(1)
 		go func(file string) {
 			defer wg.Done()
-			err := StartFileLogListener(file, triggersList)
+			err := StartFileLogListener(ctx, file)
+			stopListener(file, err)
 			if err != nil {
-				log.Printf("✘ Error with file %s: %v\n", file, err)
+				logger.Error("Log listener stopped", "file", file, logging.Err(err))
 			}
 		}(logFile)
 	}
 
-	// Wait for all goroutines to finish
-	wg.Wait()
+	// Save the offsets regularly, so even a crash doesn't lose much
+	listenersDone := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(listenersDone)
+	}()
+	ticker := time.NewTicker(offsetsCheckpointInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := SaveOffsets(); err != nil {
+				logger.Error("Log offsets not saved", logging.Err(err))
+			}
+		case <-listenersDone:
+			if err := SaveOffsets(); err != nil {
+				return err
+			}
+			return nil
+		}
+	}
 }
 
 func removeANSIcodes(line string) string {
@@ -0,0 +1,59 @@
+package console
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Corentin-cott/ServeurSentinel/config"
+	"github.com/Corentin-cott/ServeurSentinel/internal/accounts"
+	"github.com/Corentin-cott/ServeurSentinel/internal/models"
+)
+
+func TestForwardLineHidesLinkCodes(t *testing.T) {
+	var mutex sync.Mutex
+	var bodies []string
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mutex.Lock()
+		bodies = append(bodies, string(body))
+		mutex.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer webhook.Close()
+	config.Set(config.Config{DiscordWebhooks: map[string]models.DiscordWebhookConfig{"primary": {URL: webhook.URL}}})
+	t.Cleanup(func() { config.Set(config.Config{}) })
+
+	code, err := accounts.CreateLinkCode(1, "Steve")
+	if err != nil {
+		t.Fatalf("CreateLinkCode() failed: %v", err)
+	}
+
+	// The outbox isn't running, the webhook is sent before forwardLine returns
+	const serverID = 42
+	forwardLine(serverID, "primary", "[12:00:00] [Server thread/INFO]: You whisper to Steve: Ton code de liaison est "+code+".")
+
+	scrollback, _, unsubscribe := Subscribe(serverID, 0)
+	unsubscribe()
+	if len(scrollback) != 1 {
+		t.Fatalf("%d lines in the console, want 1", len(scrollback))
+	}
+	if strings.Contains(scrollback[0].Raw, code) {
+		t.Errorf("console line %q contains the link code", scrollback[0].Raw)
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(bodies) != 1 {
+		t.Fatalf("%d webhook requests, want 1", len(bodies))
+	}
+	if strings.Contains(bodies[0], code) {
+		t.Errorf("webhook body %q contains the link code", bodies[0])
+	}
+	if !strings.Contains(bodies[0], "You whisper to Steve") {
+		t.Errorf("webhook body %q, want the rest of the line", bodies[0])
+	}
+}
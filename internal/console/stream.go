@@ -0,0 +1,121 @@
+package console
+
+// This file keeps the last lines of each server console and sends the new ones to the subscribers, ex: the live console of
+// the API. A subscriber that can't keep up is disconnected instead of slowing down the log listeners
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// Lines kept per server, sent to a new subscriber before the live lines
+var scrollbackSize = 500
+
+// Lines waiting for a subscriber before it is disconnected
+const subscriberBuffer = 256
+
+// Line is a line of a server console
+type Line struct {
+	Seq      uint64    `json:"seq"` // Increases with each line of all the servers, a subscriber resumes after the last one it got
+	ServerID int       `json:"serverId"`
+	Time     time.Time `json:"time"`
+	Raw      string    `json:"-"`               // As written by the server, with its ANSI codes
+	Input    bool      `json:"input,omitempty"` // A command typed in the console through the daemon
+}
+
+// Text returns the line without its ANSI codes
+func (l Line) Text() string {
+	return removeANSIcodes(l.Raw)
+}
+
+// HTML returns the line escaped for HTML, with its ANSI colors and styles as <span> elements
+func (l Line) HTML() string {
+	return ANSIToHTML(l.Raw)
+}
+
+type serverStream struct {
+	scrollback  []Line // Ring buffer, next is the oldest line once it is full
+	next        int
+	subscribers map[chan Line]struct{}
+}
+
+var (
+	streamsMutex sync.Mutex
+	streams      = make(map[int]*serverStream)
+	lastSeq      uint64
+)
+
+func getStream(serverID int) *serverStream {
+	stream, ok := streams[serverID]
+	if !ok {
+		stream = &serverStream{subscribers: make(map[chan Line]struct{})}
+		streams[serverID] = stream
+	}
+	return stream
+}
+
+// Publish adds a line to the console of a server and sends it to its subscribers
+func Publish(serverID int, raw string) {
+	publish(serverID, raw, false)
+}
+
+// PublishInput adds a command typed through the daemon to the console of a server, the servers don't write them
+func PublishInput(serverID int, command string) {
+	publish(serverID, "> "+command, true)
+}
+
+func publish(serverID int, raw string, input bool) {
+	raw = strings.TrimRight(raw, "\r\n")
+
+	streamsMutex.Lock()
+	defer streamsMutex.Unlock()
+	lastSeq++
+	line := Line{Seq: lastSeq, ServerID: serverID, Time: time.Now(), Raw: raw, Input: input}
+
+	stream := getStream(serverID)
+	if len(stream.scrollback) < scrollbackSize {
+		stream.scrollback = append(stream.scrollback, line)
+	} else {
+		stream.scrollback[stream.next] = line
+		stream.next = (stream.next + 1) % len(stream.scrollback)
+	}
+
+	for subscriber := range stream.subscribers {
+		select {
+		case subscriber <- line:
+		default:
+			// Closed so the subscriber reconnects and resumes from the scrollback
+			delete(stream.subscribers, subscriber)
+			close(subscriber)
+			logger.Debug("Console subscriber too slow, disconnected", "server_id", serverID)
+		}
+	}
+}
+
+// Subscribe returns the lines of the scrollback of a server after a sequence number, 0 for all of them, and a channel of
+// the next lines. The channel is closed when the subscriber is too slow, unsubscribe must be called when done
+func Subscribe(serverID int, afterSeq uint64) (scrollback []Line, lines <-chan Line, unsubscribe func()) {
+	streamsMutex.Lock()
+	defer streamsMutex.Unlock()
+	stream := getStream(serverID)
+
+	for i := range stream.scrollback {
+		line := stream.scrollback[(stream.next+i)%len(stream.scrollback)]
+		if line.Seq > afterSeq {
+			scrollback = append(scrollback, line)
+		}
+	}
+
+	channel := make(chan Line, subscriberBuffer)
+	stream.subscribers[channel] = struct{}{}
+	unsubscribe = func() {
+		streamsMutex.Lock()
+		defer streamsMutex.Unlock()
+		if _, ok := stream.subscribers[channel]; ok {
+			delete(stream.subscribers, channel)
+			close(channel)
+		}
+	}
+	return scrollback, channel, unsubscribe
+}
@@ -0,0 +1,272 @@
+package control
+
+// This package contains the ACTIONS on the servers, shared by the CLI commands and the API so they always behave the same.
+// Every action is recorded in the audit log under the actor given by the caller, failed or not
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Corentin-cott/ServeurSentinel/internal/audit"
+	"github.com/Corentin-cott/ServeurSentinel/internal/console"
+	"github.com/Corentin-cott/ServeurSentinel/internal/db"
+	periodic "github.com/Corentin-cott/ServeurSentinel/internal/events"
+	"github.com/Corentin-cott/ServeurSentinel/internal/logging"
+	"github.com/Corentin-cott/ServeurSentinel/internal/models"
+	"github.com/Corentin-cott/ServeurSentinel/internal/presence"
+	"github.com/Corentin-cott/ServeurSentinel/internal/tmux"
+)
+
+var logger = logging.For("control")
+
+// The slots a server can be assigned to, each one has its tmux session and its log file
+const (
+	SlotPrimary   = "primary"
+	SlotSecondary = "secondary"
+	SlotPartner   = "partner"
+)
+
+// Slots lists the slots in the order of their sessions
+var Slots = []string{SlotPrimary, SlotSecondary, SlotPartner}
+
+var (
+	ErrServerNotFound    = errors.New("SERVER NOT FOUND")        // No server has the given ID
+	ErrServerNotRunning  = errors.New("SERVER IS NOT RUNNING")   // The action needs a running server
+	ErrInvalidSlot       = errors.New("INVALID SLOT")            // Not one of Slots
+	ErrInvalidCommand    = errors.New("INVALID CONSOLE COMMAND") // Empty, or more than one line
+	ErrRestartInProgress = periodic.ErrRestartInProgress         // The server is already restarting
+)
+
+// ServerStatus is a server with its slot and what is seen in its console
+type ServerStatus struct {
+	ID            int      `json:"id"`
+	Name          string   `json:"name"`
+	Game          string   `json:"game"`
+	Version       string   `json:"version"`
+	Modpack       string   `json:"modpack"`
+	Slot          string   `json:"slot,omitempty"` // Empty when the server is not supposed to run
+	Running       bool     `json:"running"`
+	PlayersOnline int      `json:"playersOnline"`
+	Players       []string `json:"players"`
+}
+
+// GetServer returns a server by its ID, ErrServerNotFound if there is none
+func GetServer(serverID int) (models.Server, error) {
+	servers, err := db.GetAllServers()
+	if err != nil {
+		return models.Server{}, err
+	}
+	for _, server := range servers {
+		if server.ID == serverID {
+			return server, nil
+		}
+	}
+	return models.Server{}, fmt.Errorf("%w: %d", ErrServerNotFound, serverID)
+}
+
+// GetRunningServer returns a server by its ID, ErrServerNotRunning if its tmux session is not open
+func GetRunningServer(serverID int) (models.Server, error) {
+	server, err := GetServer(serverID)
+	if err != nil {
+		return server, err
+	}
+	running, err := tmux.IsServerRunning(server.Nom)
+	if err != nil {
+		return server, err
+	}
+	if !running {
+		return server, fmt.Errorf("%w: %s", ErrServerNotRunning, server.Nom)
+	}
+	return server, nil
+}
+
+// ServerSlot returns the slot a server is assigned to, empty if none
+func ServerSlot(serverID int) string {
+	switch serverID {
+	case db.GetPrimaryServerId():
+		return SlotPrimary
+	case db.GetSecondaryServerId():
+		return SlotSecondary
+	case db.GetPartenariatServerId():
+		return SlotPartner
+	}
+	return ""
+}
+
+// GetServerStatus returns the status of a server, its players come from the console so they are only known by the daemon
+func GetServerStatus(server models.Server) (ServerStatus, error) {
+	running, err := tmux.IsServerRunning(server.Nom)
+	if err != nil {
+		return ServerStatus{}, err
+	}
+	players := presence.GetOnlinePlayers(server.ID)
+	return ServerStatus{
+		ID:            server.ID,
+		Name:          server.Nom,
+		Game:          server.Jeu,
+		Version:       server.Version,
+		Modpack:       server.Modpack,
+		Slot:          ServerSlot(server.ID),
+		Running:       running,
+		PlayersOnline: len(players),
+		Players:       players,
+	}, nil
+}
+
+// ListServers returns the status of every server, sorted by ID
+func ListServers() ([]ServerStatus, error) {
+	servers, err := db.GetAllServers()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(servers, func(i, j int) bool { return servers[i].ID < servers[j].ID })
+
+	statuses := make([]ServerStatus, 0, len(servers))
+	for _, server := range servers {
+		status, err := GetServerStatus(server)
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// StartServer starts a server, it is first assigned to the secondary slot if it has none. The returned message tells what
+// was started or stopped to match the slots
+func StartServer(actor audit.Actor, serverID int) (message string, err error) {
+	defer func() { audit.Record(actor, models.AuditStartServer, serverID, "", err) }()
+
+	server, err := GetServer(serverID)
+	if err != nil {
+		return "", err
+	}
+
+	// A server without a slot is not supposed to run, it takes the secondary slot. A server with one is started below
+	if ServerSlot(server.ID) == "" {
+		err := db.SetSecondaryServerId(server.ID)
+		audit.Record(actor, models.AuditAssignSlot, server.ID, audit.Params("slot", SlotSecondary), err)
+		if err != nil {
+			return "", fmt.Errorf("ERROR WHILE SETTING SECONDARY SERVER ID: %v", err)
+		}
+		logger.Info("Server assigned to the secondary slot", "server_id", server.ID, "server", server.Nom)
+	}
+
+	return tmux.CheckRunningServers(actor)
+}
+
+// StopServer stops a server. It keeps its slot, so the next check of the running servers starts it again
+func StopServer(actor audit.Actor, serverID int) (err error) {
+	defer func() { audit.Record(actor, models.AuditStopServer, serverID, "", err) }()
+
+	server, err := GetRunningServer(serverID)
+	if err != nil {
+		return err
+	}
+	return tmux.StopServerTmux(server.Nom)
+}
+
+// IsRestarting tells if a restart of the server is running, asked by anyone
+func IsRestarting(serverID int) bool {
+	return periodic.IsRestarting(serverID)
+}
+
+// RestartServer restarts a running server after warning its players, it returns once the server is started again. The
+// restart itself is recorded by the restart task, ErrRestartInProgress if another restart of the server runs
+func RestartServer(ctx context.Context, actor audit.Actor, serverID int) error {
+	if _, err := GetRunningServer(serverID); err != nil {
+		audit.Record(actor, models.AuditRestartServer, serverID, "", err)
+		return err
+	}
+	return periodic.RestartServerNow(ctx, actor, serverID)
+}
+
+// AssignSlot assigns a server to a slot. The servers are started and stopped to match on the next check of the running servers
+func AssignSlot(actor audit.Actor, slot string, serverID int) (err error) {
+	defer func() { audit.Record(actor, models.AuditAssignSlot, serverID, audit.Params("slot", slot), err) }()
+
+	server, err := GetServer(serverID)
+	if err != nil {
+		return err
+	}
+
+	switch slot {
+	case SlotPrimary:
+		err = db.SetPrimaryServerId(server.ID)
+	case SlotSecondary:
+		err = db.SetSecondaryServerId(server.ID)
+	case SlotPartner:
+		err = db.SetPartenariatServerId(server.ID)
+	default:
+		return fmt.Errorf("%w %q, EXPECTED %s", ErrInvalidSlot, slot, strings.Join(Slots, ", "))
+	}
+	if err != nil {
+		return fmt.Errorf("ERROR WHILE ASSIGNING %s TO THE %s SLOT: %v", server.Nom, strings.ToUpper(slot), err)
+	}
+	logger.Info("Server assigned to a slot", "server_id", server.ID, "server", server.Nom, "slot", slot)
+	return nil
+}
+
+// SendCommand types a command in the console of a running server, ex: "say Bonjour"
+func SendCommand(actor audit.Actor, serverID int, command string) (err error) {
+	command = strings.TrimSpace(command)
+	defer func() { audit.Record(actor, models.AuditSendCommand, serverID, audit.Params("command", command), err) }()
+
+	if command == "" {
+		return fmt.Errorf("%w: THE COMMAND IS EMPTY", ErrInvalidCommand)
+	}
+	if strings.ContainsAny(command, "\r\n") {
+		return fmt.Errorf("%w: THE COMMAND MUST BE A SINGLE LINE", ErrInvalidCommand)
+	}
+
+	server, err := GetRunningServer(serverID)
+	if err != nil {
+		return err
+	}
+	if err := tmux.SendCommandToServer(server.Nom, command); err != nil {
+		return err
+	}
+	console.PublishInput(server.ID, command)
+	logger.Info("Command sent to the console", "server_id", server.ID, "server", server.Nom, "command", command)
+	return nil
+}
+
+// ServerPlayers is the players connected to a server
+type ServerPlayers struct {
+	ServerID   int      `json:"serverId"`
+	ServerName string   `json:"serverName"`
+	Players    []string `json:"players"`
+}
+
+// OnlinePlayers returns the players connected to each server that has some, sorted by server ID
+func OnlinePlayers() ([]ServerPlayers, error) {
+	servers, err := db.GetAllServers()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(servers, func(i, j int) bool { return servers[i].ID < servers[j].ID })
+
+	online := []ServerPlayers{}
+	for _, server := range servers {
+		if players := presence.GetOnlinePlayers(server.ID); len(players) > 0 {
+			online = append(online, ServerPlayers{ServerID: server.ID, ServerName: server.Nom, Players: players})
+		}
+	}
+	return online, nil
+}
+
+// RecentEventsMaxAge is how far back RecentEvents looks, an older since is moved to it
+const RecentEventsMaxAge = 90 * 24 * time.Hour
+
+// RecentEvents returns the events of the servers since a date, the most recent first. A serverID of 0 means all the servers,
+// an empty eventType all the types, ex: models.EventServerCrashed, and a limit of 0 means no limit
+func RecentEvents(since time.Time, serverID int, eventType string, limit int) ([]models.ServerEvent, error) {
+	if oldest := time.Now().Add(-RecentEventsMaxAge); since.Before(oldest) {
+		since = oldest
+	}
+	return db.FindServerEvents(db.EventFilter{Since: since.UTC(), ServerID: serverID, Type: eventType, Limit: limit})
+}
@@ -0,0 +1,151 @@
+package cron
+
+// This package contains the parser of the CRON EXPRESSIONS of the scheduler, kept apart so the configuration can check them
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule returns the next time a task must run after a given time
+type Schedule interface {
+	Next(after time.Time) time.Time
+}
+
+// cronSchedule runs a task following a standard 5 fields cron expression : minute hour day-of-month month day-of-week
+type cronSchedule struct {
+	minutes     map[int]bool
+	hours       map[int]bool
+	daysOfMonth map[int]bool
+	months      map[int]bool
+	daysOfWeek  map[int]bool
+	// Like in cron, if both day fields are restricted, a day matching either of them is valid
+	domRestricted bool
+	dowRestricted bool
+}
+
+// ParseCron parses a cron expression, ex: "0 5 * * *" for every day at 05:00, or "*/30 * * * 1-5" every 30 minutes on weekdays
+func ParseCron(expression string) (Schedule, error) {
+	aliases := map[string]string{
+		"@hourly":  "0 * * * *",
+		"@daily":   "0 0 * * *",
+		"@weekly":  "0 0 * * 0",
+		"@monthly": "0 0 1 * *",
+	}
+	if alias, ok := aliases[strings.TrimSpace(expression)]; ok {
+		expression = alias
+	}
+
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("INVALID CRON EXPRESSION %q: EXPECTED 5 FIELDS, FOUND %d", expression, len(fields))
+	}
+
+	var schedule cronSchedule
+	var err error
+	if schedule.minutes, err = parseCronField(fields[0], 0, 59); err != nil {
+		return nil, fmt.Errorf("INVALID CRON MINUTE FIELD %q: %v", fields[0], err)
+	}
+	if schedule.hours, err = parseCronField(fields[1], 0, 23); err != nil {
+		return nil, fmt.Errorf("INVALID CRON HOUR FIELD %q: %v", fields[1], err)
+	}
+	if schedule.daysOfMonth, err = parseCronField(fields[2], 1, 31); err != nil {
+		return nil, fmt.Errorf("INVALID CRON DAY OF MONTH FIELD %q: %v", fields[2], err)
+	}
+	if schedule.months, err = parseCronField(fields[3], 1, 12); err != nil {
+		return nil, fmt.Errorf("INVALID CRON MONTH FIELD %q: %v", fields[3], err)
+	}
+	if schedule.daysOfWeek, err = parseCronField(fields[4], 0, 7); err != nil {
+		return nil, fmt.Errorf("INVALID CRON DAY OF WEEK FIELD %q: %v", fields[4], err)
+	}
+	// 7 is sunday too
+	if schedule.daysOfWeek[7] {
+		schedule.daysOfWeek[0] = true
+	}
+	schedule.domRestricted = fields[2] != "*"
+	schedule.dowRestricted = fields[4] != "*"
+
+	return schedule, nil
+}
+
+// parseCronField parses a comma separated list of values, ranges and steps ("*", "5", "1-5", "*/15", "0-30/10")
+func parseCronField(field string, min int, max int) (map[int]bool, error) {
+	values := make(map[int]bool)
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if rangePart, stepPart, found := strings.Cut(part, "/"); found {
+			var err error
+			step, err = strconv.Atoi(stepPart)
+			if err != nil || step <= 0 {
+				return nil, fmt.Errorf("INVALID STEP %q", stepPart)
+			}
+			part = rangePart
+		}
+
+		start, end := min, max
+		if part != "*" {
+			startPart, endPart, isRange := strings.Cut(part, "-")
+			var err error
+			start, err = strconv.Atoi(startPart)
+			if err != nil {
+				return nil, fmt.Errorf("INVALID VALUE %q", startPart)
+			}
+			end = start
+			if isRange {
+				end, err = strconv.Atoi(endPart)
+				if err != nil {
+					return nil, fmt.Errorf("INVALID VALUE %q", endPart)
+				}
+			} else if step > 1 {
+				end = max // "5/15" means from 5 to the max every 15
+			}
+		}
+
+		if start < min || end > max || start > end {
+			return nil, fmt.Errorf("VALUE OUT OF RANGE %d-%d", min, max)
+		}
+		for value := start; value <= end; value += step {
+			values[value] = true
+		}
+	}
+	return values, nil
+}
+
+// Next returns the first minute strictly after the given time that matches the cron expression
+func (s cronSchedule) Next(after time.Time) time.Time {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+
+	// A matching minute always exists within 5 years (29th of february on a given weekday), past that the expression is impossible
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if !s.months[int(t.Month())] {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.hours[t.Hour()] {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.minutes[t.Minute()] {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (s cronSchedule) dayMatches(t time.Time) bool {
+	domMatch := s.daysOfMonth[t.Day()]
+	dowMatch := s.daysOfWeek[int(t.Weekday())]
+	if s.domRestricted && s.dowRestricted {
+		return domMatch || dowMatch
+	}
+	return domMatch && dowMatch
+}
@@ -0,0 +1,114 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+	}{
+		{"empty", ""},
+		{"too few fields", "0 5 * *"},
+		{"too many fields", "0 5 * * * *"},
+		{"minute out of range", "60 * * * *"},
+		{"hour out of range", "0 24 * * *"},
+		{"day of month zero", "0 0 0 * *"},
+		{"month out of range", "0 0 1 13 *"},
+		{"day of week out of range", "0 0 * * 8"},
+		{"reversed range", "30-10 * * * *"},
+		{"zero step", "*/0 * * * *"},
+		{"negative step", "*/-5 * * * *"},
+		{"not a number", "a * * * *"},
+		{"unknown alias", "@yearly"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := ParseCron(test.expression); err == nil {
+				t.Errorf("ParseCron(%q) succeeded, want an error", test.expression)
+			}
+		})
+	}
+}
+
+func TestParseCronFields(t *testing.T) {
+	tests := []struct {
+		name  string
+		field string
+		min   int
+		max   int
+		want  []int
+	}{
+		{"single value", "5", 0, 59, []int{5}},
+		{"list", "1,15,30", 0, 59, []int{1, 15, 30}},
+		{"range", "1-5", 0, 6, []int{1, 2, 3, 4, 5}},
+		{"every step", "*/15", 0, 59, []int{0, 15, 30, 45}},
+		{"range with step", "0-30/10", 0, 59, []int{0, 10, 20, 30}},
+		{"start with step", "5/20", 0, 59, []int{5, 25, 45}},
+		{"list of ranges", "1-2,10-11", 1, 31, []int{1, 2, 10, 11}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			values, err := parseCronField(test.field, test.min, test.max)
+			if err != nil {
+				t.Fatalf("parseCronField(%q) failed: %v", test.field, err)
+			}
+			if len(values) != len(test.want) {
+				t.Fatalf("parseCronField(%q) = %v, want %v", test.field, values, test.want)
+			}
+			for _, value := range test.want {
+				if !values[value] {
+					t.Errorf("parseCronField(%q) = %v, want %v", test.field, values, test.want)
+				}
+			}
+		})
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Fatalf("Europe/Paris not found: %v", err)
+	}
+	date := func(year int, month time.Month, day int, hour int, minute int) time.Time {
+		return time.Date(year, month, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name       string
+		expression string
+		after      time.Time
+		want       time.Time
+	}{
+		{"every day later the same day", "0 5 * * *", date(2025, 3, 14, 4, 30), date(2025, 3, 14, 5, 0)},
+		{"every day the next day", "0 5 * * *", date(2025, 3, 14, 5, 0), date(2025, 3, 15, 5, 0)},
+		{"seconds are ignored", "* * * * *", date(2025, 3, 14, 4, 30).Add(30 * time.Second), date(2025, 3, 14, 4, 31)},
+		{"every 30 minutes on weekdays", "*/30 * * * 1-5", date(2025, 3, 14, 23, 45), date(2025, 3, 17, 0, 0)}, // Friday to Monday
+		{"next month", "0 0 1 * *", date(2025, 1, 31, 12, 0), date(2025, 2, 1, 0, 0)},
+		{"next year", "0 0 1 1 *", date(2025, 6, 1, 0, 0), date(2026, 1, 1, 0, 0)},
+		{"29th of february", "0 0 29 2 *", date(2025, 3, 1, 0, 0), date(2028, 2, 29, 0, 0)},
+		{"7 is sunday", "0 12 * * 7", date(2025, 3, 14, 0, 0), date(2025, 3, 16, 12, 0)},
+		{"either day field", "0 0 13 * 5", date(2025, 3, 10, 0, 0), date(2025, 3, 13, 0, 0)}, // The 13th comes before friday
+		{"hourly alias", "@hourly", date(2025, 3, 14, 4, 30), date(2025, 3, 14, 5, 0)},
+		{"weekly alias", "@weekly", date(2025, 3, 14, 4, 30), date(2025, 3, 16, 0, 0)},
+		{"impossible date", "0 0 31 2 *", date(2025, 1, 1, 0, 0), time.Time{}},
+		{
+			"time zone of the given time", "0 5 * * *",
+			time.Date(2025, 3, 14, 6, 0, 0, 0, paris),
+			time.Date(2025, 3, 15, 5, 0, 0, 0, paris),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			schedule, err := ParseCron(test.expression)
+			if err != nil {
+				t.Fatalf("ParseCron(%q) failed: %v", test.expression, err)
+			}
+			if got := schedule.Next(test.after); !got.Equal(test.want) {
+				t.Errorf("Next(%v) = %v, want %v", test.after, got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package dashboard
+
+// This package contains the web DASHBOARD, embedded in the binary. It is a single page that reads and controls the servers
+// through the API, the token is pasted once and kept in a cookie
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+//go:embed static
+var staticFiles embed.FS
+
+// Handler serves the files of the dashboard, the page itself holds no data so it needs no token
+func Handler() http.Handler {
+	root, err := fs.Sub(staticFiles, "static")
+	if err != nil {
+		panic(err) // The directory is embedded, it can't be missing
+	}
+	files := http.FileServerFS(root)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The console lines are HTML made by the daemon, but a script from elsewhere must never run in the page
+		w.Header().Set("Content-Security-Policy", "default-src 'self'; style-src 'self' 'unsafe-inline'; img-src 'self' https:; frame-ancestors 'none'")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Referrer-Policy", "no-referrer")
+		files.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,321 @@
+"use strict";
+
+// The dashboard of Serveur Sentinel, everything goes through the API with the cookie set at login
+
+const roles = ["viewer", "operator", "admin"];
+const roleNames = { viewer: "Lecteur", operator: "Opérateur", admin: "Administrateur" };
+const slotNames = { primary: "principal", secondary: "secondaire", partner: "partenaire" };
+
+const serversRefreshInterval = 10 * 1000;
+const leaderboardsRefreshInterval = 5 * 60 * 1000;
+const consoleMaxLines = 1000;
+
+let session = null;
+let selectedServerId = null;
+let consoleSource = null;
+const timers = [];
+
+const $ = (id) => document.getElementById(id);
+
+// api calls the API, it returns the decoded JSON or throws an Error with the message of the daemon
+async function api(method, path, body) {
+  const options = { method, credentials: "same-origin", headers: {} };
+  if (body !== undefined) {
+    options.headers["Content-Type"] = "application/json";
+    options.body = JSON.stringify(body);
+  }
+  const response = await fetch(path, options);
+  const data = await response.json().catch(() => ({}));
+  if (response.status === 401 && path !== "/api/session") {
+    showLogin();
+  }
+  if (!response.ok) {
+    throw new Error(data.error || response.statusText);
+  }
+  return data;
+}
+
+function notify(message, isError) {
+  const notice = $("notice");
+  notice.textContent = message;
+  notice.classList.toggle("error", !!isError);
+  notice.hidden = false;
+}
+
+function can(role) {
+  return session !== null && roles.indexOf(session.role) >= roles.indexOf(role);
+}
+
+// applyRoles hides the buttons of the actions the token can't do
+function applyRoles(root) {
+  root.querySelectorAll("[data-role]").forEach((element) => {
+    element.hidden = !can(element.dataset.role);
+  });
+}
+
+function formatDate(value) {
+  return new Date(value).toLocaleString("fr-FR", { dateStyle: "short", timeStyle: "short" });
+}
+
+function formatSize(bytes) {
+  const units = ["o", "Ko", "Mo", "Go", "To"];
+  let unit = 0;
+  while (bytes >= 1024 && unit < units.length - 1) {
+    bytes /= 1024;
+    unit++;
+  }
+  return bytes.toFixed(unit === 0 ? 0 : 1) + " " + units[unit];
+}
+
+function listItems(list, items, emptyText) {
+  list.replaceChildren();
+  if (items.length === 0) {
+    const li = document.createElement("li");
+    li.className = "empty";
+    li.textContent = emptyText;
+    list.append(li);
+    return;
+  }
+  for (const text of items) {
+    const li = document.createElement("li");
+    li.textContent = text;
+    list.append(li);
+  }
+}
+
+// Session
+
+function showLogin() {
+  session = null;
+  timers.splice(0).forEach(clearInterval);
+  closeConsole();
+  $("app").hidden = true;
+  $("session").hidden = true;
+  $("login").hidden = false;
+}
+
+async function start(newSession) {
+  session = newSession;
+  $("login").hidden = true;
+  $("notice").hidden = true;
+  $("session-name").textContent = session.name;
+  $("session-role").textContent = roleNames[session.role] || session.role;
+  $("session").hidden = false;
+  $("app").hidden = false;
+  applyRoles(document);
+
+  await Promise.all([loadServers(), loadLeaderboards()]);
+  timers.push(setInterval(loadServers, serversRefreshInterval));
+  timers.push(setInterval(loadLeaderboards, leaderboardsRefreshInterval));
+}
+
+$("login-form").addEventListener("submit", async (event) => {
+  event.preventDefault();
+  try {
+    const newSession = await api("POST", "/api/session", { token: $("login-token").value });
+    $("login-token").value = "";
+    await start(newSession);
+  } catch (error) {
+    notify("Connexion refusée : " + error.message, true);
+  }
+});
+
+$("logout").addEventListener("click", async () => {
+  await api("DELETE", "/api/session").catch(() => {});
+  showLogin();
+});
+
+// Servers
+
+async function loadServers() {
+  let servers;
+  try {
+    servers = await api("GET", "/api/servers");
+  } catch (error) {
+    notify("Serveurs non chargés : " + error.message, true);
+    return;
+  }
+
+  const container = $("servers");
+  container.replaceChildren();
+  for (const server of servers) {
+    container.append(renderServer(server));
+  }
+}
+
+function renderServer(server) {
+  const card = $("server-template").content.firstElementChild.cloneNode(true);
+  card.dataset.serverId = server.id;
+  card.classList.toggle("selected", server.id === selectedServerId);
+  card.querySelector(".state").classList.toggle("running", server.running);
+  card.querySelector(".state").title = server.running ? "En marche" : "Arrêté";
+  card.querySelector(".name").textContent = server.name;
+
+  const meta = [server.game, server.version, server.modpack].filter(Boolean);
+  meta.push(server.slot ? "emplacement " + slotNames[server.slot] : "sans emplacement");
+  card.querySelector(".meta").textContent = meta.join(" · ");
+  card.querySelector(".players").textContent = server.playersOnline > 0
+    ? server.playersOnline + " en ligne : " + server.players.join(", ")
+    : "Personne en ligne";
+
+  applyRoles(card);
+  card.querySelector(".start").disabled = server.running;
+  card.querySelector(".stop").disabled = !server.running;
+  card.querySelector(".restart").disabled = !server.running;
+
+  card.querySelector(".show").addEventListener("click", () => selectServer(server));
+  card.querySelector(".start").addEventListener("click", () => action(server, "start", "Démarrer"));
+  card.querySelector(".stop").addEventListener("click", () => action(server, "stop", "Arrêter"));
+  card.querySelector(".restart").addEventListener("click", () => action(server, "restart", "Redémarrer"));
+  card.querySelector(".slot").addEventListener("change", (event) => assignSlot(server, event.target));
+  return card;
+}
+
+async function action(server, name, label) {
+  if (!confirm(label + " " + server.name + " ?")) {
+    return;
+  }
+  try {
+    const result = await api("POST", "/api/servers/" + server.id + "/" + name);
+    notify(result.message);
+  } catch (error) {
+    notify(label + " " + server.name + " a échoué : " + error.message, true);
+  }
+  loadServers();
+}
+
+async function assignSlot(server, select) {
+  const slot = select.value;
+  select.value = "";
+  if (!slot || !confirm("Mettre " + server.name + " en emplacement " + slotNames[slot] + " ? Les serveurs seront démarrés et arrêtés en conséquence.")) {
+    return;
+  }
+  try {
+    await api("PUT", "/api/slots/" + slot, { serverId: server.id });
+    const result = await api("POST", "/api/check");
+    notify(result.message);
+  } catch (error) {
+    notify("Changement d'emplacement échoué : " + error.message, true);
+  }
+  loadServers();
+}
+
+// Details of a server: live console, crashes and backups
+
+function closeConsole() {
+  if (consoleSource !== null) {
+    consoleSource.close();
+    consoleSource = null;
+  }
+}
+
+function selectServer(server) {
+  selectedServerId = server.id;
+  document.querySelectorAll(".server").forEach((card) => {
+    card.classList.toggle("selected", Number(card.dataset.serverId) === server.id);
+  });
+  $("details-title").textContent = server.name;
+  $("details").hidden = false;
+
+  const consoleElement = $("console");
+  consoleElement.replaceChildren();
+  closeConsole();
+  // The browser reconnects by itself with Last-Event-ID, so no line is missed or shown twice
+  consoleSource = new EventSource("/api/servers/" + server.id + "/console?format=html");
+  consoleSource.addEventListener("line", (event) => {
+    const line = JSON.parse(event.data);
+    const atBottom = consoleElement.scrollTop + consoleElement.clientHeight >= consoleElement.scrollHeight - 5;
+    const div = document.createElement("div");
+    if (line.input) {
+      div.className = "input";
+    }
+    div.innerHTML = line.text; // Escaped by the daemon, only its color spans are HTML
+    consoleElement.append(div);
+    while (consoleElement.childElementCount > consoleMaxLines) {
+      consoleElement.firstElementChild.remove();
+    }
+    if (atBottom) {
+      consoleElement.scrollTop = consoleElement.scrollHeight;
+    }
+  });
+
+  loadCrashes(server.id);
+  loadBackups(server.id);
+}
+
+async function loadCrashes(serverId) {
+  try {
+    const events = await api("GET", "/api/events?serverId=" + serverId + "&type=crash&since=720h&limit=20");
+    listItems($("crashes"), events.map((event) => formatDate(event.date) + (event.details ? " : " + event.details : "")), "Aucun crash ces 30 derniers jours.");
+  } catch (error) {
+    listItems($("crashes"), [], "Crashs non chargés : " + error.message);
+  }
+}
+
+async function loadBackups(serverId) {
+  try {
+    const backups = await api("GET", "/api/servers/" + serverId + "/backups");
+    listItems($("backups"), backups.map((backup) => formatDate(backup.createdAt) + " · " + formatSize(backup.sizeBytes)), "Aucune sauvegarde.");
+  } catch (error) {
+    listItems($("backups"), [], "Sauvegardes non chargées : " + error.message);
+  }
+}
+
+$("command-form").addEventListener("submit", async (event) => {
+  event.preventDefault();
+  if (selectedServerId === null) {
+    return;
+  }
+  try {
+    await api("POST", "/api/servers/" + selectedServerId + "/command", { command: $("command").value });
+    $("command").value = "";
+  } catch (error) {
+    notify("Commande non envoyée : " + error.message, true);
+  }
+});
+
+// Leaderboards
+
+async function loadLeaderboards() {
+  let boards;
+  try {
+    boards = await api("GET", "/api/leaderboards");
+  } catch (error) {
+    notify("Classements non chargés : " + error.message, true);
+    return;
+  }
+
+  const container = $("leaderboards");
+  container.replaceChildren();
+  if (boards.length === 0) {
+    const p = document.createElement("p");
+    p.className = "meta";
+    p.textContent = "Aucun classement dans la configuration.";
+    container.append(p);
+  }
+  for (const board of boards) {
+    const card = document.createElement("article");
+    card.className = "card";
+    const title = document.createElement("h3");
+    title.textContent = board.title;
+    const ranking = document.createElement("ol");
+    ranking.className = "ranking";
+    for (const entry of board.entries) {
+      const li = document.createElement("li");
+      li.textContent = entry.name + " : " + entry.formatted;
+      ranking.append(li);
+    }
+    if (board.entries.length === 0) {
+      const p = document.createElement("p");
+      p.className = "meta";
+      p.textContent = "Personne n'est encore classé.";
+      card.append(title, p);
+    } else {
+      card.append(title, ranking);
+    }
+    container.append(card);
+  }
+}
+
+// Start with the cookie of a previous visit, or ask for the token
+api("GET", "/api/session").then(start).catch(showLogin);
@@ -0,0 +1,128 @@
+package db
+
+// This file contains the AUDIT LOG, the actions done on the servers by the CLI, the API, Discord or the scheduler : who did
+// what, on which server, and how it went
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Corentin-cott/ServeurSentinel/internal/models"
+)
+
+/* -----------------------------------------------------
+Table journal_audit {
+  id BIGINT [pk, increment]
+  date DATETIME [not null]
+  acteur_type VARCHAR(20) [not null]
+  acteur VARCHAR(255) [not null]
+  action VARCHAR(50) [not null]
+  serveur_id INT [ref: > serveurs.id, null]
+  parametres TEXT [null]
+  succes BOOLEAN [not null]
+  erreur TEXT [null]
+}
+----------------------------------------------------- */
+
+// AuditFilter selects the entries of the audit log, the empty fields select everything
+type AuditFilter struct {
+	Since     time.Time
+	ServerID  int
+	ActorType string
+	Actor     string
+	Action    string
+	Limit     int // 0 means no limit
+}
+
+// SaveAuditEntry saves an action in the audit log, at the current date
+func (r *SQLRepository) SaveAuditEntry(entry models.AuditEntry) error {
+	query := "INSERT INTO journal_audit (date, acteur_type, acteur, action, serveur_id, parametres, succes, erreur) VALUES (?, ?, ?, ?, ?, ?, ?, ?)"
+	serverID := sql.NullInt64{Int64: int64(entry.ServerID), Valid: entry.ServerID > 0}
+	_, err := r.db.Exec(query, r.dialect.timeArg(utcNow()), entry.ActorType, entry.Actor, entry.Action, serverID,
+		nullString(entry.Parameters), entry.Success, nullString(entry.Error))
+	if err != nil {
+		return fmt.Errorf("FAILED TO SAVE AUDIT ENTRY: %v", err)
+	}
+	return nil
+}
+
+// GetAuditEntries returns the entries of the audit log matching the filter, the most recent first
+func (r *SQLRepository) GetAuditEntries(filter AuditFilter) ([]models.AuditEntry, error) {
+	var conditions []string
+	var args []any
+	if !filter.Since.IsZero() {
+		conditions = append(conditions, "date >= ?")
+		args = append(args, r.dialect.timeArg(filter.Since))
+	}
+	if filter.ServerID > 0 {
+		conditions = append(conditions, "serveur_id = ?")
+		args = append(args, filter.ServerID)
+	}
+	if filter.ActorType != "" {
+		conditions = append(conditions, "acteur_type = ?")
+		args = append(args, filter.ActorType)
+	}
+	if filter.Actor != "" {
+		conditions = append(conditions, "acteur = ?")
+		args = append(args, filter.Actor)
+	}
+	if filter.Action != "" {
+		conditions = append(conditions, "action = ?")
+		args = append(args, filter.Action)
+	}
+
+	query := "SELECT id, date, acteur_type, acteur, action, serveur_id, parametres, succes, erreur FROM journal_audit"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY date DESC, id DESC"
+	if filter.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", filter.Limit)
+	}
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("FAILED TO GET AUDIT ENTRIES: %v", err)
+	}
+	defer rows.Close()
+
+	var entries []models.AuditEntry
+	for rows.Next() {
+		var entry models.AuditEntry
+		var date nullTime
+		var serverID sql.NullInt64
+		var parameters, actionError sql.NullString
+		err := rows.Scan(&entry.ID, &date, &entry.ActorType, &entry.Actor, &entry.Action, &serverID, &parameters, &entry.Success, &actionError)
+		if err != nil {
+			return nil, fmt.Errorf("FAILED TO SCAN AUDIT ENTRY: %v", err)
+		}
+		entry.Date = date.Time
+		entry.ServerID = int(serverID.Int64)
+		entry.Parameters = parameters.String
+		entry.Error = actionError.String
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// matches tells if an entry is selected by the filter, for the repositories that can't query
+func (filter AuditFilter) matches(entry models.AuditEntry) bool {
+	return !entry.Date.Before(filter.Since) &&
+		(filter.ServerID <= 0 || entry.ServerID == filter.ServerID) &&
+		(filter.ActorType == "" || entry.ActorType == filter.ActorType) &&
+		(filter.Actor == "" || entry.Actor == filter.Actor) &&
+		(filter.Action == "" || entry.Action == filter.Action)
+}
+
+// sortAuditEntries sorts the entries the most recent first, like the SQL query
+func sortAuditEntries(entries []models.AuditEntry) {
+	slices.SortStableFunc(entries, func(a, b models.AuditEntry) int {
+		if c := b.Date.Compare(a.Date); c != 0 {
+			return c
+		}
+		return int(b.ID - a.ID)
+	})
+}
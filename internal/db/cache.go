@@ -0,0 +1,272 @@
+package db
+
+// This file contains the SERVER CACHE, the servers and the primary, secondary and partner slots kept in memory by the daemon
+// so reading a log line never waits for the database. The CLI runs in another process, it invalidates the cache of the
+// daemon by touching a marker file in the state directory
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Corentin-cott/ServeurSentinel/config"
+	"github.com/Corentin-cott/ServeurSentinel/internal/logging"
+	"github.com/Corentin-cott/ServeurSentinel/internal/models"
+)
+
+// Name of the marker file inside the state directory, touched when the servers or the slots change
+const serversChangedFileName = "servers.changed"
+
+// How often the cache reloads the servers even if nothing was marked as changed, ex: a server edited in the database by hand
+var serverCacheRefreshInterval = 5 * time.Minute
+
+// How often the cache looks at the marker file
+var serverCacheCheckInterval = time.Second
+
+// CachedRepository is a Repository whose servers and slots are read from memory, the rest is read from the wrapped repository
+type CachedRepository struct {
+	Repository
+
+	mu          sync.RWMutex
+	loaded      bool
+	servers     map[int]models.Server
+	primaryID   int
+	secondaryID int
+	partnerID   int
+	attemptedAt time.Time // Last refresh, even a failed one, so a database outage is retried on the refresh interval
+
+	generation atomic.Uint64 // Incremented each time the servers or the slots change
+}
+
+// NewCachedRepository wraps a repository, the servers are loaded on the first read
+func NewCachedRepository(repo Repository) *CachedRepository {
+	return &CachedRepository{Repository: repo}
+}
+
+// getServersChangedFilePath returns the path of the marker file, empty without a configuration so nothing is written in the
+// working directory
+func getServersChangedFilePath() string {
+	if config.Get().StatePath == "" {
+		return ""
+	}
+	return filepath.Join(config.Get().StatePath, serversChangedFileName)
+}
+
+// MarkServersChanged tells the daemon to reload its servers, the CLI calls it after changing a server or a slot
+func MarkServersChanged() {
+	path := getServersChangedFilePath()
+	if path == "" {
+		return
+	}
+	now := time.Now()
+	if err := os.Chtimes(path, now, now); err == nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		logger.Error("Servers not marked as changed", logging.Err(err))
+		return
+	}
+	if err := os.WriteFile(path, nil, 0640); err != nil {
+		logger.Error("Servers not marked as changed", logging.Err(err))
+	}
+}
+
+// Refresh reloads the servers and the slots from the database. On error the previous values are kept
+func (r *CachedRepository) Refresh() error {
+	r.mu.Lock()
+	r.attemptedAt = time.Now()
+	r.mu.Unlock()
+
+	servers, err := r.Repository.GetAllServers()
+	if err != nil {
+		return fmt.Errorf("FAILED TO REFRESH THE SERVER CACHE: %v", err)
+	}
+	serversByID := make(map[int]models.Server, len(servers))
+	for _, server := range servers {
+		serversByID[server.ID] = server
+	}
+	primaryID := r.Repository.GetPrimaryServerId()
+	secondaryID := r.Repository.GetSecondaryServerId()
+	partnerID := r.Repository.GetPartenariatServerId()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	changed := !r.loaded || !reflect.DeepEqual(r.servers, serversByID) ||
+		r.primaryID != primaryID || r.secondaryID != secondaryID || r.partnerID != partnerID
+	r.servers = serversByID
+	r.primaryID, r.secondaryID, r.partnerID = primaryID, secondaryID, partnerID
+	r.loaded = true
+	if changed {
+		r.generation.Add(1)
+	}
+	return nil
+}
+
+// Invalidate makes the next read reload the servers and the slots
+func (r *CachedRepository) Invalidate() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.loaded = false
+}
+
+// Generation changes each time the servers or the slots change, so a reader can keep what it resolved until then
+func (r *CachedRepository) Generation() uint64 {
+	return r.generation.Load()
+}
+
+// RunRefresh reloads the cache on an interval and when the marker file is touched, until the context is cancelled
+func (r *CachedRepository) RunRefresh(ctx context.Context) {
+	var lastMarked time.Time
+	if info, err := os.Stat(getServersChangedFilePath()); err == nil {
+		lastMarked = info.ModTime()
+	}
+
+	ticker := time.NewTicker(serverCacheCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		marked := false
+		if info, err := os.Stat(getServersChangedFilePath()); err == nil && !info.ModTime().Equal(lastMarked) {
+			lastMarked = info.ModTime()
+			marked = true
+		}
+
+		r.mu.RLock()
+		stale := time.Since(r.attemptedAt) >= serverCacheRefreshInterval
+		r.mu.RUnlock()
+
+		if marked || stale {
+			if err := r.Refresh(); err != nil {
+				logger.Warn("Servers not refreshed, the cached ones are kept", logging.Err(err))
+			}
+		}
+	}
+}
+
+// ensureLoaded loads the cache on the first read and after an invalidation
+func (r *CachedRepository) ensureLoaded() error {
+	r.mu.RLock()
+	loaded := r.loaded
+	r.mu.RUnlock()
+	if loaded {
+		return nil
+	}
+	return r.Refresh()
+}
+
+func (r *CachedRepository) filterServers(keep func(models.Server) bool) ([]models.Server, error) {
+	if err := r.ensureLoaded(); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var servers []models.Server
+	for _, server := range r.servers {
+		if keep(server) {
+			servers = append(servers, server)
+		}
+	}
+	sort.Slice(servers, func(i, j int) bool { return servers[i].ID < servers[j].ID })
+	return servers, nil
+}
+
+func (r *CachedRepository) GetAllServers() ([]models.Server, error) {
+	return r.filterServers(func(models.Server) bool { return true })
+}
+
+func (r *CachedRepository) GetAllMinecraftServers() ([]models.Server, error) {
+	return r.filterServers(func(server models.Server) bool { return server.Jeu == "Minecraft" })
+}
+
+// slot returns a cached slot, -1 if the cache can't be loaded like the repository does when the query fails
+func (r *CachedRepository) slot(get func() int) int {
+	if err := r.ensureLoaded(); err != nil {
+		logger.Error("Servers not loaded", logging.Err(err))
+		return -1
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return get()
+}
+
+func (r *CachedRepository) GetPrimaryServerId() int {
+	return r.slot(func() int { return r.primaryID })
+}
+
+func (r *CachedRepository) GetSecondaryServerId() int {
+	return r.slot(func() int { return r.secondaryID })
+}
+
+func (r *CachedRepository) GetPartenariatServerId() int {
+	return r.slot(func() int { return r.partnerID })
+}
+
+// The setters write to the database, then the cache is reloaded on the next read
+
+func (r *CachedRepository) SetPrimaryServerId(serverID int) error {
+	defer r.Invalidate()
+	return r.Repository.SetPrimaryServerId(serverID)
+}
+
+func (r *CachedRepository) SetSecondaryServerId(serverID int) error {
+	defer r.Invalidate()
+	return r.Repository.SetSecondaryServerId(serverID)
+}
+
+func (r *CachedRepository) SetPartenariatServerId(serverID int) error {
+	defer r.Invalidate()
+	return r.Repository.SetPartenariatServerId(serverID)
+}
+
+func (r *CachedRepository) GetServerById(serverID int) (models.Server, error) {
+	if err := r.ensureLoaded(); err != nil {
+		return models.Server{}, err
+	}
+	r.mu.RLock()
+	server, ok := r.servers[serverID]
+	r.mu.RUnlock()
+	if !ok {
+		return server, fmt.Errorf("SERVER NOT FOUND: %d", serverID)
+	}
+	return server, nil
+}
+
+func (r *CachedRepository) GetServerByName(serverName string) (models.Server, error) {
+	servers, err := r.filterServers(func(server models.Server) bool { return server.Nom == serverName })
+	if err != nil {
+		return models.Server{}, err
+	}
+	if len(servers) == 0 {
+		return models.Server{}, fmt.Errorf("SERVER NOT FOUND: %s", serverName)
+	}
+	return servers[0], nil
+}
+
+func (r *CachedRepository) GetServerNameById(serverID int) (string, error) {
+	server, err := r.GetServerById(serverID)
+	return server.Nom, err
+}
+
+func (r *CachedRepository) GetServerGameById(serverID int) (string, error) {
+	server, err := r.GetServerById(serverID)
+	if err != nil {
+		return "", fmt.Errorf("GAME NOT FOUND FOR SERVER ID: %d", serverID)
+	}
+	return server.Jeu, nil
+}
+
+func (r *CachedRepository) GetServerColorByName(serverName string) (string, error) {
+	server, err := r.GetServerByName(serverName)
+	return server.EmbedColor, err
+}
@@ -0,0 +1,43 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Corentin-cott/ServeurSentinel/internal/models"
+)
+
+// failingServersRepository is a memory repository whose servers can't be read, like a database that is down
+type failingServersRepository struct {
+	*MemoryRepository
+	reads atomic.Int32
+}
+
+func (r *failingServersRepository) GetAllServers() ([]models.Server, error) {
+	r.reads.Add(1)
+	return nil, errors.New("database is down")
+}
+
+func TestRunRefreshWaitsAfterFailure(t *testing.T) {
+	previousCheck, previousRefresh := serverCacheCheckInterval, serverCacheRefreshInterval
+	serverCacheCheckInterval, serverCacheRefreshInterval = 5*time.Millisecond, time.Hour
+	t.Cleanup(func() { serverCacheCheckInterval, serverCacheRefreshInterval = previousCheck, previousRefresh })
+
+	repo := &failingServersRepository{MemoryRepository: NewMemoryRepository()}
+	cache := NewCachedRepository(repo)
+	if err := cache.Refresh(); err == nil {
+		t.Fatal("Refresh() succeeded on a failing repository")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	cache.RunRefresh(ctx)
+
+	// The failed refresh counts as one, the next one waits for the refresh interval
+	if reads := repo.reads.Load(); reads != 1 {
+		t.Errorf("servers read %d times, want 1", reads)
+	}
+}
@@ -4,44 +4,21 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
-	"github.com/Corentin-cott/ServeurSentinel/config"
+	"github.com/Corentin-cott/ServeurSentinel/internal/logging"
 	"github.com/Corentin-cott/ServeurSentinel/internal/models"
 	"github.com/Corentin-cott/ServeurSentinel/internal/services"
-	_ "github.com/go-sql-driver/mysql"
 )
 
-// ConnectToDatabase initialises the connection to the MySQL database
-func ConnectToDatabase() error {
-	// Load the database configuration
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s",
-		config.AppConfig.DB.User,
-		config.AppConfig.DB.Password,
-		config.AppConfig.DB.Host,
-		config.AppConfig.DB.Port,
-		config.AppConfig.DB.Name,
-	)
-
-	fmt.Println("Here is the conexion string : ", dsn)
-
-	// Try to connect to the database
-	var err error
-	db, err = sql.Open("mysql", dsn)
-	if err != nil {
-		return fmt.Errorf("ERROR OPENING DATABASE: %v", err)
-	}
-
-	// Test the connection
-	if err := db.Ping(); err != nil {
-		return fmt.Errorf("ERROR WHILE PINGING DATABASE WITH CONNECTION STRING: (%v) ! ERROR: %v", dsn, err)
-	}
-
-	fmt.Println("✔ Successfully connected to the database.")
-	return nil
+// SQLRepository is the Repository stored in a SQL database, the SQL specific to the database is in its dialect
+type SQLRepository struct {
+	db      timedDB
+	dialect dialect
 }
 
-var db *sql.DB
+// The tables below are a reminder, the schema is defined by the migrations in the migrations folder
 
 /* -----------------------------------------------------
 Table serveurs {
@@ -60,10 +37,27 @@ Table serveurs {
 }
 ----------------------------------------------------- */
 
+// Columns of the serveurs table, in the order of scanServer
+const serverColumns = "id, nom, jeu, version, modpack, modpack_url, nom_monde, embed_color, path_serv, start_script, actif, global"
+
+// rowScanner is either a *sql.Row or a *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanServer reads a row selected with serverColumns
+func scanServer(row rowScanner) (models.Server, error) {
+	var serv models.Server
+	var modpackURL sql.NullString
+	err := row.Scan(&serv.ID, &serv.Nom, &serv.Jeu, &serv.Version, &serv.Modpack, &modpackURL, &serv.NomMonde, &serv.EmbedColor, &serv.PathServ, &serv.StartScript, &serv.Actif, &serv.Global)
+	serv.ModpackURL = modpackURL.String
+	return serv, err
+}
+
 // GetAllServers returns all the servers from the database
-func GetAllServers() ([]models.Server, error) {
-	query := "SELECT * FROM serveurs"
-	rows, err := db.Query(query)
+func (r *SQLRepository) GetAllServers() ([]models.Server, error) {
+	query := "SELECT " + serverColumns + " FROM serveurs"
+	rows, err := r.db.Query(query)
 	if err != nil {
 		return nil, fmt.Errorf("FAILED TO GET SERVERS: %v", err)
 	}
@@ -71,8 +65,8 @@ func GetAllServers() ([]models.Server, error) {
 
 	var servers []models.Server
 	for rows.Next() {
-		var serv models.Server
-		if err := rows.Scan(&serv.ID, &serv.Nom, &serv.Jeu, &serv.Version, &serv.Modpack, &serv.ModpackURL, &serv.NomMonde, &serv.EmbedColor, &serv.PathServ, &serv.StartScript, &serv.Actif, &serv.Global); err != nil {
+		serv, err := scanServer(rows)
+		if err != nil {
 			return nil, fmt.Errorf("FAILED TO SCAN SERVER: %v", err)
 		}
 		servers = append(servers, serv)
@@ -82,9 +76,9 @@ func GetAllServers() ([]models.Server, error) {
 }
 
 // GetAllMineCraftServers returns all the Minecraft servers from the database
-func GetAllMinecraftServers() ([]models.Server, error) {
-	query := "SELECT * FROM serveurs WHERE jeu = 'Minecraft'"
-	rows, err := db.Query(query)
+func (r *SQLRepository) GetAllMinecraftServers() ([]models.Server, error) {
+	query := "SELECT " + serverColumns + " FROM serveurs WHERE jeu = 'Minecraft'"
+	rows, err := r.db.Query(query)
 	if err != nil {
 		return nil, fmt.Errorf("FAILED TO GET MINECRAFT SERVERS: %v", err)
 	}
@@ -92,8 +86,8 @@ func GetAllMinecraftServers() ([]models.Server, error) {
 
 	var servers []models.Server
 	for rows.Next() {
-		var serv models.Server
-		if err := rows.Scan(&serv.ID, &serv.Nom, &serv.Jeu, &serv.Version, &serv.Modpack, &serv.ModpackURL, &serv.NomMonde, &serv.EmbedColor, &serv.PathServ, &serv.StartScript, &serv.Actif, &serv.Global); err != nil {
+		serv, err := scanServer(rows)
+		if err != nil {
 			return nil, fmt.Errorf("FAILED TO SCAN MINECRAFT SERVER: %v", err)
 		}
 		servers = append(servers, serv)
@@ -103,13 +97,13 @@ func GetAllMinecraftServers() ([]models.Server, error) {
 }
 
 // Getter to get the primary server
-func GetPrimaryServerId() int {
+func (r *SQLRepository) GetPrimaryServerId() int {
 	query := "SELECT id_serv_primaire FROM serveurs_parameters"
 	var serverID int
 
-	err := db.QueryRow(query).Scan(&serverID)
+	err := r.db.QueryRow(query).Scan(&serverID)
 	if err != nil {
-		fmt.Println("FAILED TO GET PRIMARY SERVER:", err)
+		logger.Error("Primary server not found", logging.Err(err))
 		return -1
 	}
 
@@ -117,13 +111,13 @@ func GetPrimaryServerId() int {
 }
 
 // Getter to get the secondary server
-func GetSecondaryServerId() int {
+func (r *SQLRepository) GetSecondaryServerId() int {
 	query := "SELECT id_serv_secondaire FROM serveurs_parameters"
 	var serverID int
 
-	err := db.QueryRow(query).Scan(&serverID)
+	err := r.db.QueryRow(query).Scan(&serverID)
 	if err != nil {
-		fmt.Println("FAILED TO GET SECONDARY SERVER:", err)
+		logger.Error("Secondary server not found", logging.Err(err))
 		return -1
 	}
 
@@ -131,13 +125,13 @@ func GetSecondaryServerId() int {
 }
 
 // Getter to get the event/partenariat server
-func GetPartenariatServerId() int {
+func (r *SQLRepository) GetPartenariatServerId() int {
 	query := "SELECT id_serv_partenaire FROM serveurs_parameters"
 	var serverID int
 
-	err := db.QueryRow(query).Scan(&serverID)
+	err := r.db.QueryRow(query).Scan(&serverID)
 	if err != nil {
-		fmt.Println("FAILED TO GET PARTENARIAT SERVER:", err)
+		logger.Error("Partner server not found", logging.Err(err))
 		return -1
 	}
 
@@ -145,9 +139,9 @@ func GetPartenariatServerId() int {
 }
 
 // Setter to set the primary server
-func SetPrimaryServerId(serverID int) error {
+func (r *SQLRepository) SetPrimaryServerId(serverID int) error {
 	query := "UPDATE serveurs_parameters SET id_serv_primaire = ?"
-	_, err := db.Exec(query, serverID)
+	_, err := r.db.Exec(query, serverID)
 	if err != nil {
 		return fmt.Errorf("FAILED TO SET PRIMARY SERVER: %v", err)
 	}
@@ -156,9 +150,9 @@ func SetPrimaryServerId(serverID int) error {
 }
 
 // Setter to set the secondary server
-func SetSecondaryServerId(serverID int) error {
+func (r *SQLRepository) SetSecondaryServerId(serverID int) error {
 	query := "UPDATE serveurs_parameters SET id_serv_secondaire = ?"
-	_, err := db.Exec(query, serverID)
+	_, err := r.db.Exec(query, serverID)
 	if err != nil {
 		return fmt.Errorf("FAILED TO SET SECONDARY SERVER: %v", err)
 	}
@@ -167,9 +161,9 @@ func SetSecondaryServerId(serverID int) error {
 }
 
 // Setter to set the event/partenariat server
-func SetPartenariatServerId(serverID int) error {
+func (r *SQLRepository) SetPartenariatServerId(serverID int) error {
 	query := "UPDATE serveurs_parameters SET id_serv_partenaire = ?"
-	_, err := db.Exec(query, serverID)
+	_, err := r.db.Exec(query, serverID)
 	if err != nil {
 		return fmt.Errorf("FAILED TO SET PARTENARIAT SERVER: %v", err)
 	}
@@ -178,11 +172,10 @@ func SetPartenariatServerId(serverID int) error {
 }
 
 // Getter to get all the server informations
-func GetServerById(serverID int) (models.Server, error) {
-	query := "SELECT * FROM serveurs WHERE id = ?"
-	var serv models.Server
+func (r *SQLRepository) GetServerById(serverID int) (models.Server, error) {
+	query := "SELECT " + serverColumns + " FROM serveurs WHERE id = ?"
 
-	err := db.QueryRow(query, serverID).Scan(&serv.ID, &serv.Nom, &serv.Jeu, &serv.Version, &serv.Modpack, &serv.ModpackURL, &serv.NomMonde, &serv.EmbedColor, &serv.PathServ, &serv.StartScript, &serv.Actif, &serv.Global)
+	serv, err := scanServer(r.db.QueryRow(query, serverID))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return serv, fmt.Errorf("SERVER NOT FOUND: %d", serverID)
@@ -194,11 +187,10 @@ func GetServerById(serverID int) (models.Server, error) {
 }
 
 // Getter to get the server by the server name
-func GetServerByName(serverName string) (models.Server, error) {
-	query := "SELECT * FROM serveurs WHERE nom = ?"
-	var serv models.Server
+func (r *SQLRepository) GetServerByName(serverName string) (models.Server, error) {
+	query := "SELECT " + serverColumns + " FROM serveurs WHERE nom = ?"
 
-	err := db.QueryRow(query, serverName).Scan(&serv.ID, &serv.Nom, &serv.Jeu, &serv.Version, &serv.Modpack, &serv.ModpackURL, &serv.NomMonde, &serv.EmbedColor, &serv.PathServ, &serv.StartScript, &serv.Actif, &serv.Global)
+	serv, err := scanServer(r.db.QueryRow(query, serverName))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return serv, fmt.Errorf("SERVER NOT FOUND: %s", serverName)
@@ -210,11 +202,11 @@ func GetServerByName(serverName string) (models.Server, error) {
 }
 
 // Getter to get the server name by the server id
-func GetServerNameById(serverID int) (string, error) {
+func (r *SQLRepository) GetServerNameById(serverID int) (string, error) {
 	query := "SELECT nom FROM serveurs WHERE id = ?"
 	var serverName string
 
-	err := db.QueryRow(query, serverID).Scan(&serverName)
+	err := r.db.QueryRow(query, serverID).Scan(&serverName)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return "", fmt.Errorf("SERVER NOT FOUND: %d", serverID)
@@ -226,11 +218,11 @@ func GetServerNameById(serverID int) (string, error) {
 }
 
 // Getter to get the server game by the server ID
-func GetServerGameById(serverID int) (string, error) {
+func (r *SQLRepository) GetServerGameById(serverID int) (string, error) {
 	query := "SELECT jeu FROM serveurs WHERE id = ?"
 	var jeu string
 
-	err := db.QueryRow(query, serverID).Scan(&jeu)
+	err := r.db.QueryRow(query, serverID).Scan(&jeu)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return "", fmt.Errorf("GAME NOT FOUND FOR SERVER ID: %d", serverID)
@@ -242,11 +234,11 @@ func GetServerGameById(serverID int) (string, error) {
 }
 
 // Getter to get the server color by the server id
-func GetServerColorByName(serverName string) (string, error) {
+func (r *SQLRepository) GetServerColorByName(serverName string) (string, error) {
 	query := "SELECT embed_color FROM serveurs WHERE nom = ?"
 	var serverColor string
 
-	err := db.QueryRow(query, serverName).Scan(&serverColor)
+	err := r.db.QueryRow(query, serverName).Scan(&serverColor)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return "", fmt.Errorf("SERVER NOT FOUND: %s", serverName)
@@ -261,24 +253,51 @@ func GetServerColorByName(serverName string) (string, error) {
 Table joueurs_connections_log {
     id INT [pk, increment]
     serveur_id INT [ref: > serveurs.id, not null]
-    joueurs_id INT [ref: > joueurs.id, null]
+    joueur_id INT [ref: > joueurs.id, null]
     date DATETIME
 }
 ----------------------------------------------------- */
 
 // SaveConnectionLog saves a connection log for a player
-func SaveConnectionLog(playerID int, serverID int) error {
+func (r *SQLRepository) SaveConnectionLog(playerID int, serverID int) error {
 	query := "INSERT INTO joueurs_connections_log (serveur_id, joueur_id, date) VALUES (?, ?, ?)"
-	_, err := db.Exec(query, serverID, playerID, GetGoodDatetime())
+	_, err := r.db.Exec(query, serverID, playerID, r.dialect.timeArg(utcNow()))
 	if err != nil {
 		return fmt.Errorf("FAILED TO SAVE CONNECTION LOG: %v", err)
 	}
 
-	fmt.Println("Connection log saved successfully for player ID", playerID)
+	logger.Debug("Connection saved", "player_id", playerID, "server_id", serverID)
 
 	return nil
 }
 
+// GetConnectionLogs returns the connections of the players to all the servers between two dates, the oldest first
+func (r *SQLRepository) GetConnectionLogs(from time.Time, to time.Time) ([]models.PlayerConnection, error) {
+	query := "SELECT joueur_id, serveur_id, date FROM joueurs_connections_log WHERE date >= ? AND date < ? ORDER BY date, id"
+	rows, err := r.db.Query(query, r.dialect.timeArg(from), r.dialect.timeArg(to))
+	if err != nil {
+		return nil, fmt.Errorf("FAILED TO GET CONNECTION LOGS: %v", err)
+	}
+	defer rows.Close()
+
+	var connections []models.PlayerConnection
+	for rows.Next() {
+		var connection models.PlayerConnection
+		var playerID sql.NullInt64
+		var date nullTime
+		if err := rows.Scan(&playerID, &connection.ServerID, &date); err != nil {
+			return nil, fmt.Errorf("FAILED TO SCAN CONNECTION LOG: %v", err)
+		}
+		if !playerID.Valid {
+			continue // Connections saved before the players had an ID
+		}
+		connection.PlayerID = int(playerID.Int64)
+		connection.Date = date.Time
+		connections = append(connections, connection)
+	}
+	return connections, rows.Err()
+}
+
 /* -----------------------------------------------------
 Table joueurs {
     id INT [pk, increment]
@@ -290,15 +309,93 @@ Table joueurs {
 }
 ----------------------------------------------------- */
 
+// Columns of the joueurs table, in the order of scanPlayer
+const playerColumns = "id, utilisateur_id, jeu, compte_id, premiere_co, derniere_co"
+
+// scanPlayer reads a row selected with playerColumns, a player without user account has UtilisateurID set to -1
+func scanPlayer(row rowScanner) (models.Player, error) {
+	var player models.Player
+	var utilisateurID sql.NullInt64
+	var premiereCo, derniereCo nullTime
+	err := row.Scan(&player.ID, &utilisateurID, &player.Jeu, &player.CompteID, &premiereCo, &derniereCo)
+	if utilisateurID.Valid {
+		player.UtilisateurID = int(utilisateurID.Int64)
+	} else {
+		player.UtilisateurID = -1
+	}
+	player.PremiereCo = premiereCo.Time
+	player.DerniereCo = derniereCo.Time
+	return player, err
+}
+
 // GetAllPlayers returns all the players from the database
-func GetAllPlayers() ([]models.Player, error) {
-	return nil, nil // TODO
+func (r *SQLRepository) GetAllPlayers() ([]models.Player, error) {
+	return r.FindPlayers(PlayerFilter{})
+}
+
+// PlayerFilter selects players, the empty fields don't filter
+type PlayerFilter struct {
+	Jeu            string    // Game of the player
+	ServerID       int       // Server the player connected to or has statistics on, 0 for any server
+	SeenSince      time.Time // Last connection at or after this date
+	FirstSeenSince time.Time // First connection at or after this date
+	Search         string    // Part of the account ID or of a name of the player
+}
+
+// likeEscaper escapes the wildcards of a LIKE pattern, so a search matches its text literally. The escape character is
+// "!" rather than a backslash, which MySQL and SQLite don't read the same way in string literals
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+// FindPlayers returns the players matching a filter, the last seen first
+func (r *SQLRepository) FindPlayers(filter PlayerFilter) ([]models.Player, error) {
+	query := "SELECT " + playerColumns + " FROM joueurs j WHERE 1 = 1"
+	var args []any
+	if filter.Jeu != "" {
+		query += " AND j.jeu = ?"
+		args = append(args, filter.Jeu)
+	}
+	if filter.ServerID != 0 {
+		query += " AND (EXISTS (SELECT 1 FROM joueurs_connections_log c WHERE c.joueur_id = j.id AND c.serveur_id = ?)" +
+			" OR EXISTS (SELECT 1 FROM joueurs_stats s WHERE s.compte_id = j.compte_id AND s.serveur_id = ?))"
+		args = append(args, filter.ServerID, filter.ServerID)
+	}
+	if !filter.SeenSince.IsZero() {
+		query += " AND j.derniere_co >= ?"
+		args = append(args, r.dialect.timeArg(filter.SeenSince))
+	}
+	if !filter.FirstSeenSince.IsZero() {
+		query += " AND j.premiere_co >= ?"
+		args = append(args, r.dialect.timeArg(filter.FirstSeenSince))
+	}
+	if filter.Search != "" {
+		query += " AND (j.compte_id LIKE ? ESCAPE '!' OR EXISTS (SELECT 1 FROM joueurs_pseudos p WHERE p.joueur_id = j.id AND p.pseudo LIKE ? ESCAPE '!'))"
+		pattern := "%" + likeEscaper.Replace(filter.Search) + "%"
+		args = append(args, pattern, pattern)
+	}
+	query += " ORDER BY j.derniere_co DESC, j.id"
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("FAILED TO FIND PLAYERS: %v", err)
+	}
+	defer rows.Close()
+
+	var players []models.Player
+	for rows.Next() {
+		player, err := scanPlayer(rows)
+		if err != nil {
+			return nil, fmt.Errorf("FAILED TO SCAN PLAYER: %v", err)
+		}
+		players = append(players, player)
+	}
+
+	return players, rows.Err()
 }
 
 // GetAllMinecraftPlayers returns all the Minecraft players from the database
-func GetAllMinecraftPlayers() ([]models.Player, error) {
-	query := "SELECT * FROM joueurs WHERE jeu = 'Minecraft'"
-	rows, err := db.Query(query)
+func (r *SQLRepository) GetAllMinecraftPlayers() ([]models.Player, error) {
+	query := "SELECT " + playerColumns + " FROM joueurs WHERE jeu = 'Minecraft'"
+	rows, err := r.db.Query(query)
 	if err != nil {
 		return nil, fmt.Errorf("FAILED TO GET MINECRAFT PLAYERS: %v", err)
 	}
@@ -306,20 +403,11 @@ func GetAllMinecraftPlayers() ([]models.Player, error) {
 
 	var players []models.Player
 	for rows.Next() {
-		var player models.Player
-		var utilisateurID sql.NullInt64 // Use sql.NullInt64 to handle NULL values
-
-		if err := rows.Scan(&player.ID, &utilisateurID, &player.Jeu, &player.CompteID, &player.PremiereCo, &player.DerniereCo); err != nil {
+		player, err := scanPlayer(rows)
+		if err != nil {
 			return nil, fmt.Errorf("FAILED TO SCAN MINECRAFT PLAYER: %v", err)
 		}
 
-		// If the utilisateurID is NULL, set it to 0
-		if utilisateurID.Valid {
-			player.UtilisateurID = int(utilisateurID.Int64)
-		} else {
-			player.UtilisateurID = 0
-		}
-
 		players = append(players, player)
 	}
 
@@ -327,31 +415,36 @@ func GetAllMinecraftPlayers() ([]models.Player, error) {
 }
 
 // CheckAndInsertPlayer checks if a player exists in the database and inserts it if it doesn't
-func CheckAndInsertPlayerWithPlayerName(playerName string, serverID int, timeConf string) (int, error) {
-	getPlayerUUID, err := GetPlayerAccountIdByPlayerName(playerName, "Minecraft")
+func (r *SQLRepository) CheckAndInsertPlayerWithPlayerName(playerName string, serverID int, timeConf string) (int, error) {
+	server, err := r.GetServerById(serverID)
+	if err != nil {
+		return -1, fmt.Errorf("FAILED TO GET SERVER: %v", err)
+	}
+
+	getPlayerUUID, err := GetPlayerAccountIdByPlayerName(playerName, server)
 	if err != nil {
 		return -1, fmt.Errorf("FAILED TO GET PLAYER UUID BY PLAYER NAME: %v", err)
 	}
 
-	return CheckAndInsertPlayerWithPlayerUUID(getPlayerUUID, serverID, timeConf)
+	return r.CheckAndInsertPlayerWithPlayerUUID(getPlayerUUID, serverID, timeConf)
 }
 
 // InsertPlayer inserts a player in the database. if utilisateurID is -1, then null is inserted
-func InsertPlayer(utilisateurID int, jeu string, compteID string, premiereCo time.Time, derniereCo time.Time) (int, error) {
+func (r *SQLRepository) InsertPlayer(utilisateurID int, jeu string, compteID string, premiereCo time.Time, derniereCo time.Time) (int, error) {
 	var insertQuery string
 	var err error
 	if utilisateurID == -1 {
 		insertQuery = "INSERT INTO joueurs (utilisateur_id, jeu, compte_id, premiere_co, derniere_co) VALUES (null, ?, ?, ?, ?)"
-		_, err = db.Exec(insertQuery, jeu, compteID, premiereCo, derniereCo)
+		_, err = r.db.Exec(insertQuery, jeu, compteID, r.dialect.timeArg(premiereCo), r.dialect.timeArg(derniereCo))
 	} else {
 		insertQuery = "INSERT INTO joueurs (utilisateur_id, jeu, compte_id, premiere_co, derniere_co) VALUES (?, ?, ?, ?, ?)"
-		_, err = db.Exec(insertQuery, utilisateurID, jeu, compteID, premiereCo, derniereCo)
+		_, err = r.db.Exec(insertQuery, utilisateurID, jeu, compteID, r.dialect.timeArg(premiereCo), r.dialect.timeArg(derniereCo))
 	}
 	if err != nil {
 		return -1, fmt.Errorf("FAILED TO INSERT PLAYER: %v", err)
 	}
 
-	playerID, err := GetPlayerIdByAccountId(compteID)
+	playerID, err := r.GetPlayerIdByAccountId(compteID)
 	if err != nil {
 		return -1, fmt.Errorf("FAILED TO GET PLAYER ID: %v", err)
 	}
@@ -360,12 +453,12 @@ func InsertPlayer(utilisateurID int, jeu string, compteID string, premiereCo tim
 }
 
 // CheckAndInsertPlayerWithPlayerUUID checks if a player exists in the database and inserts it if it doesn't
-func CheckAndInsertPlayerWithPlayerUUID(playerUUID string, serverID int, timeConf string) (int, error) {
+func (r *SQLRepository) CheckAndInsertPlayerWithPlayerUUID(playerUUID string, serverID int, timeConf string) (int, error) {
 	var datetime time.Time
 	if timeConf == "now" {
-		datetime = GetGoodDatetime()
+		datetime = utcNow()
 	} else {
-		datetime, _ = time.Parse("2006-01-02 15:04:05", "2000-01-01 00:00:00") // Default value if we don't know the time
+		datetime = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC) // Default value if we don't know the time
 	}
 
 	// Check that playerUUID is not empty
@@ -374,21 +467,21 @@ func CheckAndInsertPlayerWithPlayerUUID(playerUUID string, serverID int, timeCon
 	}
 
 	// Get server game
-	jeu, err := GetServerGameById(serverID)
+	jeu, err := r.GetServerGameById(serverID)
 	if err != nil {
 		return -1, fmt.Errorf("FAILED TO GET SERVER GAME: %v", err)
 	}
 
 	// Check if the player already exists
-	playerID, _ := GetPlayerIdByAccountId(playerUUID)
+	playerID, _ := r.GetPlayerIdByAccountId(playerUUID)
 	if playerID != -1 {
-		fmt.Printf("Player already exists with ID (this is not a problem) %d\n", playerID)
+		logger.Debug("Player already saved", "player_id", playerID)
 		return playerID, nil // Player already exists, return its ID
 	}
 
 	// If the player does not exist, insert it
-	fmt.Println("Player does not exist. Inserting new player:", playerUUID)
-	playerID, err = InsertPlayer(-1, jeu, playerUUID, datetime, datetime)
+	logger.Info("New player saved", "player_uuid", playerUUID, "server_id", serverID)
+	playerID, err = r.InsertPlayer(-1, jeu, playerUUID, datetime, datetime)
 	if err != nil {
 		return -1, fmt.Errorf("ERROR: %v", err)
 	}
@@ -397,14 +490,14 @@ func CheckAndInsertPlayerWithPlayerUUID(playerUUID string, serverID int, timeCon
 }
 
 // UpdatePlayerLastConnection updates the last connection date of a player
-func UpdatePlayerLastConnection(playerID int) error {
+func (r *SQLRepository) UpdatePlayerLastConnection(playerID int) error {
 	if playerID == -1 {
 		return fmt.Errorf("PLAYER ID IS -1, CANNOT UPDATE LAST CONNECTION")
 	}
 
-	fmt.Println("Updating last connection for player ID", playerID)
-	updateQuery := "UPDATE joueurs SET derniere_co = NOW() WHERE id = ?"
-	_, err := db.Exec(updateQuery, playerID)
+	logger.Debug("Last connection updated", "player_id", playerID)
+	updateQuery := "UPDATE joueurs SET derniere_co = ? WHERE id = ?"
+	_, err := r.db.Exec(updateQuery, r.dialect.timeArg(utcNow()), playerID)
 	if err != nil {
 		return fmt.Errorf("FAILED TO UPDATE LAST CONNECTION: %v", err)
 	}
@@ -413,11 +506,10 @@ func UpdatePlayerLastConnection(playerID int) error {
 }
 
 // GetPlayerById returns a player from the database by its ID
-func GetPlayerById(playerID int) (models.Player, error) {
-	query := "SELECT * FROM joueurs WHERE id = ?"
-	var player models.Player
+func (r *SQLRepository) GetPlayerById(playerID int) (models.Player, error) {
+	query := "SELECT " + playerColumns + " FROM joueurs WHERE id = ?"
 
-	err := db.QueryRow(query, playerID).Scan(&player.ID, &player.UtilisateurID, &player.Jeu, &player.CompteID, &player.PremiereCo, &player.DerniereCo)
+	player, err := scanPlayer(r.db.QueryRow(query, playerID))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return player, fmt.Errorf("PLAYER NOT FOUND: %d", playerID)
@@ -429,30 +521,10 @@ func GetPlayerById(playerID int) (models.Player, error) {
 }
 
 // GetPlayerByUUID returns a player from the database by its UUID
-func GetPlayerByUUID(playerUUID string) (models.Player, error) {
-	query := `
-        SELECT id, utilisateur_id, jeu, compte_id, premiere_co, derniere_co 
-        FROM joueurs 
-        WHERE compte_id = ?`
-
-	var player models.Player
-	var utilisateurID sql.NullInt64
-
-	err := db.QueryRow(query, playerUUID).Scan(
-		&player.ID,
-		&utilisateurID,
-		&player.Jeu,
-		&player.CompteID,
-		&player.PremiereCo,
-		&player.DerniereCo,
-	)
-
-	if utilisateurID.Valid {
-		player.UtilisateurID = int(utilisateurID.Int64)
-	} else {
-		player.UtilisateurID = -1 // Set to -1 if NULL
-	}
+func (r *SQLRepository) GetPlayerByUUID(playerUUID string) (models.Player, error) {
+	query := "SELECT " + playerColumns + " FROM joueurs WHERE compte_id = ?"
 
+	player, err := scanPlayer(r.db.QueryRow(query, playerUUID))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return player, fmt.Errorf("player not found: %s", playerUUID)
@@ -464,11 +536,11 @@ func GetPlayerByUUID(playerUUID string) (models.Player, error) {
 }
 
 // Getter to get the player ID by the account ID
-func GetPlayerIdByAccountId(accountId any) (int, error) {
+func (r *SQLRepository) GetPlayerIdByAccountId(accountId any) (int, error) {
 	query := "SELECT id FROM joueurs WHERE compte_id = ?"
 	var playerID int
 
-	err := db.QueryRow(query, accountId).Scan(&playerID)
+	err := r.db.QueryRow(query, accountId).Scan(&playerID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return -1, fmt.Errorf("PLAYER ISN'T IN THE DATABASE")
@@ -476,30 +548,126 @@ func GetPlayerIdByAccountId(accountId any) (int, error) {
 		return -1, fmt.Errorf("FAILED TO GET PLAYER ID: %v", err)
 	}
 
-	strPlayerID := fmt.Sprintf("%d", playerID)
-	fmt.Println("Player ID retrieved successfully : "+strPlayerID+" for account ID : ", accountId)
+	logger.Debug("Player found", "player_id", playerID, "player_uuid", accountId)
 	return playerID, nil
 }
 
-// Getter to get the player account ID by the player name
-func GetPlayerAccountIdByPlayerName(playerName string, jeu string) (string, error) {
-	if jeu == "" {
+// Getter to get the player account ID by the player name, on the server the player was seen on
+func GetPlayerAccountIdByPlayerName(playerName string, server models.Server) (string, error) {
+	if server.Jeu == "" {
 		return "", fmt.Errorf("GAME NOT FOUND")
 	}
 
-	switch jeu {
+	switch server.Jeu {
 	case "Minecraft":
-		return services.GetMinecraftPlayerUUID(playerName)
+		return services.ResolveMinecraftPlayerUUID(playerName, server)
 	default:
-		return "", fmt.Errorf("UNKNOWN GAME: %s", jeu)
+		return "", fmt.Errorf("UNKNOWN GAME: %s", server.Jeu)
 	}
 }
 
+/* -----------------------------------------------------
+Table joueurs_pseudos {
+    id INT [pk, increment]
+    joueur_id INT [ref: > joueurs.id, not null]
+    pseudo VARCHAR(255) [not null]
+    premiere_vue DATETIME [not null]
+    derniere_vue DATETIME [not null]
+}
+
+Table utilisateurs_discord {
+    id INT [pk, increment]
+    discord_id VARCHAR(20) [unique, not null]
+    pseudo_discord VARCHAR(255)
+}
+----------------------------------------------------- */
+
+// SavePlayerName records the name a player is seen with, the first time and the last time
+func (r *SQLRepository) SavePlayerName(playerID int, playerName string) error {
+	if playerID == -1 {
+		return fmt.Errorf("PLAYER ID IS -1, CANNOT SAVE PLAYER NAME")
+	}
+
+	now := r.dialect.timeArg(utcNow())
+	query := "INSERT INTO joueurs_pseudos (joueur_id, pseudo, premiere_vue, derniere_vue) VALUES (?, ?, ?, ?) " +
+		r.dialect.upsert([]string{"joueur_id", "pseudo"}, []string{"derniere_vue"})
+	_, err := r.db.Exec(query, playerID, playerName, now, now)
+	if err != nil {
+		return fmt.Errorf("FAILED TO SAVE PLAYER NAME: %v", err)
+	}
+
+	return nil
+}
+
+// GetPlayerNames returns the names of a player, the last one seen first
+func (r *SQLRepository) GetPlayerNames(playerID int) ([]models.PlayerName, error) {
+	query := "SELECT pseudo, premiere_vue, derniere_vue FROM joueurs_pseudos WHERE joueur_id = ? ORDER BY derniere_vue DESC"
+	rows, err := r.db.Query(query, playerID)
+	if err != nil {
+		return nil, fmt.Errorf("FAILED TO GET PLAYER NAMES: %v", err)
+	}
+	defer rows.Close()
+
+	var names []models.PlayerName
+	for rows.Next() {
+		var name models.PlayerName
+		var premiereVue, derniereVue nullTime
+		if err := rows.Scan(&name.Pseudo, &premiereVue, &derniereVue); err != nil {
+			return nil, fmt.Errorf("FAILED TO SCAN PLAYER NAME: %v", err)
+		}
+		name.PremiereVue = premiereVue.Time
+		name.DerniereVue = derniereVue.Time
+		names = append(names, name)
+	}
+
+	return names, rows.Err()
+}
+
+// LinkPlayerToDiscordUser binds a player to a Discord user, the user is created if needed. It returns the user ID
+func (r *SQLRepository) LinkPlayerToDiscordUser(playerID int, discordID string, discordName string) (int, error) {
+	query := "INSERT INTO utilisateurs_discord (discord_id, pseudo_discord) VALUES (?, ?) " +
+		r.dialect.upsert([]string{"discord_id"}, []string{"pseudo_discord"})
+	if _, err := r.db.Exec(query, discordID, discordName); err != nil {
+		return -1, fmt.Errorf("FAILED TO SAVE DISCORD USER: %v", err)
+	}
+
+	var utilisateurID int
+	err := r.db.QueryRow("SELECT id FROM utilisateurs_discord WHERE discord_id = ? ORDER BY id LIMIT 1", discordID).Scan(&utilisateurID)
+	if err != nil {
+		return -1, fmt.Errorf("FAILED TO GET DISCORD USER: %v", err)
+	}
+
+	result, err := r.db.Exec("UPDATE joueurs SET utilisateur_id = ? WHERE id = ?", utilisateurID, playerID)
+	if err != nil {
+		return -1, fmt.Errorf("FAILED TO LINK PLAYER: %v", err)
+	}
+	if count, _ := result.RowsAffected(); count == 0 {
+		return -1, fmt.Errorf("PLAYER NOT FOUND: %d", playerID)
+	}
+
+	return utilisateurID, nil
+}
+
+// GetDiscordUser returns a Discord user linked to players
+func (r *SQLRepository) GetDiscordUser(utilisateurID int) (models.DiscordUser, error) {
+	user := models.DiscordUser{ID: utilisateurID}
+	var pseudo sql.NullString
+	err := r.db.QueryRow("SELECT discord_id, pseudo_discord FROM utilisateurs_discord WHERE id = ?", utilisateurID).Scan(&user.DiscordID, &pseudo)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return user, fmt.Errorf("DISCORD USER NOT FOUND: %d", utilisateurID)
+		}
+		return user, fmt.Errorf("FAILED TO GET DISCORD USER: %v", err)
+	}
+	user.Pseudo = pseudo.String
+	return user, nil
+}
+
 /* -----------------------------------------------------
 Table joueurs_stats {
   id INT [pk, increment]
   serveur_id INT [ref: > serveurs.id, not null]
-  compte_id INT [ref: > joueurs.compte_id, not null]
+  compte_id VARCHAR(255) [ref: > joueurs.compte_id, not null]
   tmps_jeux BIGINT [default: 0]
   nb_mort INT [default: 0]
   nb_kills INT [default: 0]
@@ -515,17 +683,26 @@ Table joueurs_stats {
   item_broken JSON
   achievement JSON
   dern_enregistrment DATETIME [not null]
+  item_used JSON
+  dist_details JSON
 }
 ----------------------------------------------------- */
 
+// Columns of joueurs_stats replaced when the statistics of a player are saved again
+var statsUpdatedColumns = []string{
+	"tmps_jeux", "nb_mort", "nb_kills", "nb_playerkill", "mob_killed", "nb_blocs_detr", "nb_blocs_pose", "dist_total",
+	"dist_pieds", "dist_elytres", "dist_vol", "item_crafted", "item_broken", "achievement", "dern_enregistrment",
+	"item_used", "dist_details",
+}
+
 // CheckMinecraftPlayerGameStatisticsExists checks if the game statistics of a Minecraft player already exists
-func CheckMinecraftPlayerGameStatisticsExists(playerUUID string, serverID int) bool {
+func (r *SQLRepository) CheckMinecraftPlayerGameStatisticsExists(playerUUID string, serverID int) bool {
 	query := "SELECT COUNT(*) FROM joueurs_stats WHERE compte_id = ? AND serveur_id = ?"
 	var count int
 
-	err := db.QueryRow(query, playerUUID, serverID).Scan(&count)
+	err := r.db.QueryRow(query, playerUUID, serverID).Scan(&count)
 	if err != nil {
-		fmt.Println("FAILED TO CHECK IF PLAYER STATISTICS EXISTS:", err)
+		logger.Error("Player statistics not checked", "player_uuid", playerUUID, "server_id", serverID, logging.Err(err))
 		return false
 	}
 
@@ -533,31 +710,16 @@ func CheckMinecraftPlayerGameStatisticsExists(playerUUID string, serverID int) b
 }
 
 // SaveMinecraftPlayerGameStatistics saves the game statistics of a Minecraft player
-func SaveMinecraftPlayerGameStatistics(serverID int, playerUUID string, playerStats models.MinecraftPlayerGameStatistics) error {
+func (r *SQLRepository) SaveMinecraftPlayerGameStatistics(serverID int, playerUUID string, playerStats models.MinecraftPlayerGameStatistics) error {
 	// Prepare the SQL query
 	query := `
 		INSERT INTO joueurs_stats (
 			serveur_id, compte_id, tmps_jeux, nb_mort, nb_kills, nb_playerkill,
 			mob_killed, nb_blocs_detr, nb_blocs_pose, dist_total, dist_pieds,
-			dist_elytres, dist_vol, item_crafted, item_broken, achievement, dern_enregistrment
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-		ON DUPLICATE KEY UPDATE
-			tmps_jeux = VALUES(tmps_jeux),
-			nb_mort = VALUES(nb_mort),
-			nb_kills = VALUES(nb_kills),
-			nb_playerkill = VALUES(nb_playerkill),
-			mob_killed = VALUES(mob_killed),
-			nb_blocs_detr = VALUES(nb_blocs_detr),
-			nb_blocs_pose = VALUES(nb_blocs_pose),
-			dist_total = VALUES(dist_total),
-			dist_pieds = VALUES(dist_pieds),
-			dist_elytres = VALUES(dist_elytres),
-			dist_vol = VALUES(dist_vol),
-			item_crafted = VALUES(item_crafted),
-			item_broken = VALUES(item_broken),
-			achievement = VALUES(achievement),
-			dern_enregistrment = VALUES(dern_enregistrment)
-	`
+			dist_elytres, dist_vol, item_crafted, item_broken, achievement, dern_enregistrment,
+			item_used, dist_details
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		` + r.dialect.upsert([]string{"serveur_id", "compte_id"}, statsUpdatedColumns)
 
 	// Convert JSON fields
 	mobKilledJSON, err := json.Marshal(playerStats.MobsKilled)
@@ -580,14 +742,25 @@ func SaveMinecraftPlayerGameStatistics(serverID int, playerUUID string, playerSt
 		return fmt.Errorf("failed to marshal achievement: %v", err)
 	}
 
+	itemsUsedJSON, err := json.Marshal(playerStats.ItemsUsed)
+	if err != nil {
+		return fmt.Errorf("failed to marshal item_used: %v", err)
+	}
+
+	distancesJSON, err := json.Marshal(playerStats.Distances)
+	if err != nil {
+		return fmt.Errorf("failed to marshal dist_details: %v", err)
+	}
+
 	// Execute the query with all the necessary values
-	_, err = db.Exec(query,
+	_, err = r.db.Exec(query,
 		serverID, playerUUID, playerStats.TimePlayed,
 		playerStats.Deaths, playerStats.Kills, playerStats.PlayerKills,
 		mobKilledJSON, playerStats.BlocksDestroyed, playerStats.BlocksPlaced,
 		playerStats.TotalDistance, playerStats.DistanceByFoot, playerStats.DistanceByElytra,
 		playerStats.DistanceByFlight, itemsCraftedJSON, itemsBrokenJSON,
-		achievementsJSON, GetGoodDatetime(),
+		achievementsJSON, r.dialect.timeArg(utcNow()),
+		itemsUsedJSON, distancesJSON,
 	)
 
 	if err != nil {
@@ -597,8 +770,52 @@ func SaveMinecraftPlayerGameStatistics(serverID int, playerUUID string, playerSt
 	return nil
 }
 
+// GetMinecraftPlayerGameStatistics returns the game statistics of a Minecraft player on every server, by server ID
+func (r *SQLRepository) GetMinecraftPlayerGameStatistics(playerUUID string) ([]models.MinecraftPlayerGameStatistics, error) {
+	query := `
+		SELECT
+			id, serveur_id, tmps_jeux, nb_mort, nb_kills, nb_playerkill,
+			mob_killed, nb_blocs_detr, nb_blocs_pose, dist_total, dist_pieds,
+			dist_elytres, dist_vol, item_crafted, item_broken, achievement, dern_enregistrment,
+			item_used, dist_details
+		FROM joueurs_stats WHERE compte_id = ? ORDER BY serveur_id
+	`
+	rows, err := r.db.Query(query, playerUUID)
+	if err != nil {
+		return nil, fmt.Errorf("FAILED TO GET PLAYER STATISTICS: %v", err)
+	}
+	defer rows.Close()
+
+	var allStats []models.MinecraftPlayerGameStatistics
+	for rows.Next() {
+		var stats models.MinecraftPlayerGameStatistics
+		var mobKilled, itemsCrafted, itemsBroken, achievements, itemsUsed, distances []byte
+		var lastRecorded nullTime
+		err := rows.Scan(
+			&stats.ID, &stats.ServerID, &stats.TimePlayed, &stats.Deaths, &stats.Kills, &stats.PlayerKills,
+			&mobKilled, &stats.BlocksDestroyed, &stats.BlocksPlaced, &stats.TotalDistance, &stats.DistanceByFoot,
+			&stats.DistanceByElytra, &stats.DistanceByFlight, &itemsCrafted, &itemsBroken, &achievements, &lastRecorded,
+			&itemsUsed, &distances,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("FAILED TO SCAN PLAYER STATISTICS: %v", err)
+		}
+		// The JSON columns can be NULL, a broken one is left empty rather than hiding the other statistics
+		json.Unmarshal(mobKilled, &stats.MobsKilled)
+		json.Unmarshal(itemsCrafted, &stats.ItemsCrafted)
+		json.Unmarshal(itemsBroken, &stats.ItemsBroken)
+		json.Unmarshal(achievements, &stats.Achievements)
+		json.Unmarshal(itemsUsed, &stats.ItemsUsed)
+		json.Unmarshal(distances, &stats.Distances)
+		stats.LastRecordedTime = lastRecorded.Time
+		allStats = append(allStats, stats)
+	}
+
+	return allStats, rows.Err()
+}
+
 // UpdateMinecraftPlayerGameStatistics updates the game statistics of a Minecraft player
-func UpdateMinecraftPlayerGameStatistics(serverID int, playerUUID string, playerStats models.MinecraftPlayerGameStatistics) error {
+func (r *SQLRepository) UpdateMinecraftPlayerGameStatistics(serverID int, playerUUID string, playerStats models.MinecraftPlayerGameStatistics) error {
 	// Prepare the SQL query
 	query := `
 		UPDATE joueurs_stats SET
@@ -616,7 +833,9 @@ func UpdateMinecraftPlayerGameStatistics(serverID int, playerUUID string, player
 			item_crafted = ?,
 			item_broken = ?,
 			achievement = ?,
-			dern_enregistrment = ?
+			dern_enregistrment = ?,
+			item_used = ?,
+			dist_details = ?
 		WHERE compte_id = ? AND serveur_id = ?
 	`
 
@@ -641,13 +860,24 @@ func UpdateMinecraftPlayerGameStatistics(serverID int, playerUUID string, player
 		return fmt.Errorf("failed to marshal achievement: %v", err)
 	}
 
+	itemsUsedJSON, err := json.Marshal(playerStats.ItemsUsed)
+	if err != nil {
+		return fmt.Errorf("failed to marshal item_used: %v", err)
+	}
+
+	distancesJSON, err := json.Marshal(playerStats.Distances)
+	if err != nil {
+		return fmt.Errorf("failed to marshal dist_details: %v", err)
+	}
+
 	// Execute the query with all the necessary values
-	_, err = db.Exec(query,
+	_, err = r.db.Exec(query,
 		playerStats.TimePlayed, playerStats.Deaths, playerStats.Kills, playerStats.PlayerKills,
 		mobKilledJSON, playerStats.BlocksDestroyed, playerStats.BlocksPlaced,
 		playerStats.TotalDistance, playerStats.DistanceByFoot, playerStats.DistanceByElytra,
 		playerStats.DistanceByFlight, itemsCraftedJSON, itemsBrokenJSON,
-		achievementsJSON, GetGoodDatetime(),
+		achievementsJSON, r.dialect.timeArg(utcNow()),
+		itemsUsedJSON, distancesJSON,
 		playerUUID, serverID,
 	)
 
@@ -657,8 +887,3 @@ func UpdateMinecraftPlayerGameStatistics(serverID int, playerUUID string, player
 
 	return nil
 }
-
-func GetGoodDatetime() time.Time {
-	// Time of now + 1 hour. Not good practice, but it's a quick fix. Again. Yeah.
-	return time.Now().Add(time.Hour)
-}
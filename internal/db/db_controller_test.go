@@ -0,0 +1,54 @@
+package db
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestFindPlayersSearch(t *testing.T) {
+	r := openTestSQLite(t)
+	if _, err := r.Migrate(); err != nil {
+		t.Fatalf("Migrate() failed: %v", err)
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	names := map[string]string{"a": "Steve_42", "b": "Steve142", "c": "100%Alex", "d": "Alex!"}
+	ids := map[int]string{}
+	for accountID, name := range names {
+		id, err := r.InsertPlayer(-1, "Minecraft", accountID, now, now)
+		if err != nil {
+			t.Fatalf("InsertPlayer() failed: %v", err)
+		}
+		if err := r.SavePlayerName(id, name); err != nil {
+			t.Fatalf("SavePlayerName() failed: %v", err)
+		}
+		ids[id] = name
+	}
+
+	// The wildcards and the escape character of LIKE are searched as they are
+	tests := []struct {
+		search string
+		want   []string
+	}{
+		{"Steve", []string{"Steve142", "Steve_42"}},
+		{"_", []string{"Steve_42"}},
+		{"%", []string{"100%Alex"}},
+		{"!", []string{"Alex!"}},
+		{"e_4", []string{"Steve_42"}},
+	}
+	for _, test := range tests {
+		players, err := r.FindPlayers(PlayerFilter{Search: test.search})
+		if err != nil {
+			t.Fatalf("FindPlayers(%q) failed: %v", test.search, err)
+		}
+		var found []string
+		for _, player := range players {
+			found = append(found, ids[player.ID])
+		}
+		slices.Sort(found)
+		if !slices.Equal(found, test.want) {
+			t.Errorf("FindPlayers(%q) = %v, want %v", test.search, found, test.want)
+		}
+	}
+}
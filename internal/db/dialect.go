@@ -0,0 +1,128 @@
+package db
+
+// This file contains the DIALECTS, what differs between the SQL databases we support
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Corentin-cott/ServeurSentinel/internal/models"
+)
+
+// dialect is the SQL specific to a database
+type dialect interface {
+	// Name of the database/sql driver
+	driverName() string
+	// Connection string of the database
+	dsn(conf models.DatabaseConfig) string
+	// Connection string with the password hidden, for the logs
+	safeDSN(conf models.DatabaseConfig) string
+	// Folder of the migrations in the embedded files
+	migrationsDir() string
+	// End of an INSERT statement updating the given columns when the unique key already exists
+	upsert(conflictColumns []string, updateColumns []string) string
+	// Value of a date argument, dates are always stored in UTC
+	timeArg(t time.Time) any
+}
+
+// getDialect returns the dialect of a driver
+func getDialect(driver string) (dialect, error) {
+	switch driver {
+	case "", "mysql":
+		return mysqlDialect{}, nil
+	case "sqlite":
+		return sqliteDialect{}, nil
+	default:
+		return nil, fmt.Errorf("UNKNOWN DATABASE DRIVER: %s", driver)
+	}
+}
+
+// mysqlDialect is the dialect of MySQL and MariaDB
+type mysqlDialect struct{}
+
+func (mysqlDialect) driverName() string { return "mysql" }
+
+// The DATETIME columns have no time zone, the driver reads and writes them as UTC
+func (mysqlDialect) dsn(conf models.DatabaseConfig) string {
+	return fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?parseTime=true&loc=UTC", conf.User, conf.Password, conf.Host, conf.Port, conf.Name)
+}
+
+func (mysqlDialect) safeDSN(conf models.DatabaseConfig) string {
+	return fmt.Sprintf("%s:***@tcp(%s:%d)/%s?parseTime=true&loc=UTC", conf.User, conf.Host, conf.Port, conf.Name)
+}
+
+func (mysqlDialect) migrationsDir() string { return "migrations/mysql" }
+
+func (mysqlDialect) upsert(conflictColumns []string, updateColumns []string) string {
+	assignments := make([]string, len(updateColumns))
+	for i, column := range updateColumns {
+		assignments[i] = column + " = VALUES(" + column + ")"
+	}
+	return "ON DUPLICATE KEY UPDATE " + strings.Join(assignments, ", ")
+}
+
+func (mysqlDialect) timeArg(t time.Time) any { return t.UTC() }
+
+// sqliteDialect is the dialect of SQLite, through the pure Go driver
+type sqliteDialect struct{}
+
+func (sqliteDialect) driverName() string { return "sqlite" }
+
+// The busy timeout makes a writer wait for the other instead of failing, WAL lets the CLI read while the daemon writes
+func (sqliteDialect) dsn(conf models.DatabaseConfig) string {
+	return "file:" + conf.Path + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)"
+}
+
+func (d sqliteDialect) safeDSN(conf models.DatabaseConfig) string { return d.dsn(conf) }
+
+func (sqliteDialect) migrationsDir() string { return "migrations/sqlite" }
+
+func (sqliteDialect) upsert(conflictColumns []string, updateColumns []string) string {
+	assignments := make([]string, len(updateColumns))
+	for i, column := range updateColumns {
+		assignments[i] = column + " = excluded." + column
+	}
+	return "ON CONFLICT(" + strings.Join(conflictColumns, ", ") + ") DO UPDATE SET " + strings.Join(assignments, ", ")
+}
+
+// SQLite has no date type, dates are stored as text the way the MySQL driver sends them, so both sort and read the same
+func (sqliteDialect) timeArg(t time.Time) any { return t.UTC().Format("2006-01-02 15:04:05") }
+
+// nullTime reads a date column, as a time.Time from MySQL or as text from SQLite. Invalid if the column is NULL
+type nullTime struct {
+	Time  time.Time
+	Valid bool
+}
+
+func (t *nullTime) Scan(value any) error {
+	switch v := value.(type) {
+	case nil:
+		t.Time, t.Valid = time.Time{}, false
+		return nil
+	case time.Time:
+		t.Time, t.Valid = v.UTC(), true
+		return nil
+	case string:
+		return t.parse(v)
+	case []byte:
+		return t.parse(string(v))
+	default:
+		return fmt.Errorf("CANNOT READ A DATE FROM %T", value)
+	}
+}
+
+func (t *nullTime) parse(value string) error {
+	for _, layout := range []string{"2006-01-02 15:04:05", time.RFC3339Nano} {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			t.Time, t.Valid = parsed.UTC(), true
+			return nil
+		}
+	}
+	return fmt.Errorf("CANNOT READ A DATE FROM %q", value)
+}
+
+// utcNow is the current date as stored in the database
+func utcNow() time.Time {
+	return time.Now().UTC()
+}
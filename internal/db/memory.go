@@ -0,0 +1,649 @@
+package db
+
+// This file contains the MEMORY repository, a Repository kept in memory for the tests. It behaves like the SQL one,
+// including the -1 returned when a server or a player is missing
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Corentin-cott/ServeurSentinel/internal/models"
+)
+
+// MemoryRepository is a Repository kept in memory, nothing is saved
+type MemoryRepository struct {
+	mu sync.Mutex
+
+	Servers     map[int]models.Server
+	PrimaryID   int
+	SecondaryID int
+	PartnerID   int
+
+	Players     map[int]models.Player
+	Connections []MemoryConnection
+	Stats       map[string]models.MinecraftPlayerGameStatistics // "<server ID>/<player UUID>" -> statistics
+	Names       map[int][]models.PlayerName                     // player ID -> names, in the order they were first seen
+	Users       map[int]models.DiscordUser                      // user ID -> Discord user
+	Snapshots   []models.MinecraftStatsSnapshot                 // In the order they were saved
+	Events      []models.ServerEvent                            // In the order they were saved
+	Audit       []models.AuditEntry                             // In the order they were saved
+
+	// Account IDs of the players by name, used instead of the Mojang API. A missing name is its own account ID
+	AccountIDs map[string]string
+
+	nextPlayerID int
+	nextUserID   int
+}
+
+// MemoryConnection is a connection saved by the MemoryRepository
+type MemoryConnection struct {
+	PlayerID int
+	ServerID int
+	Date     time.Time
+}
+
+// NewMemoryRepository creates an empty MemoryRepository, without primary, secondary nor partner server
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{
+		Servers:      make(map[int]models.Server),
+		PrimaryID:    -1,
+		SecondaryID:  -1,
+		PartnerID:    -1,
+		Players:      make(map[int]models.Player),
+		Stats:        make(map[string]models.MinecraftPlayerGameStatistics),
+		Names:        make(map[int][]models.PlayerName),
+		Users:        make(map[int]models.DiscordUser),
+		AccountIDs:   make(map[string]string),
+		nextPlayerID: 1,
+		nextUserID:   1,
+	}
+}
+
+// AddServer adds a server, the ID of the given server is kept
+func (r *MemoryRepository) AddServer(server models.Server) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Servers[server.ID] = server
+}
+
+func (r *MemoryRepository) GetAllServers() ([]models.Server, error) {
+	return r.filterServers(func(models.Server) bool { return true }), nil
+}
+
+func (r *MemoryRepository) GetAllMinecraftServers() ([]models.Server, error) {
+	return r.filterServers(func(server models.Server) bool { return server.Jeu == "Minecraft" }), nil
+}
+
+func (r *MemoryRepository) filterServers(keep func(models.Server) bool) []models.Server {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var servers []models.Server
+	for _, server := range r.Servers {
+		if keep(server) {
+			servers = append(servers, server)
+		}
+	}
+	sort.Slice(servers, func(i, j int) bool { return servers[i].ID < servers[j].ID })
+	return servers
+}
+
+func (r *MemoryRepository) GetPrimaryServerId() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.PrimaryID
+}
+
+func (r *MemoryRepository) GetSecondaryServerId() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.SecondaryID
+}
+
+func (r *MemoryRepository) GetPartenariatServerId() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.PartnerID
+}
+
+func (r *MemoryRepository) SetPrimaryServerId(serverID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.PrimaryID = serverID
+	return nil
+}
+
+func (r *MemoryRepository) SetSecondaryServerId(serverID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.SecondaryID = serverID
+	return nil
+}
+
+func (r *MemoryRepository) SetPartenariatServerId(serverID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.PartnerID = serverID
+	return nil
+}
+
+func (r *MemoryRepository) GetServerById(serverID int) (models.Server, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	server, ok := r.Servers[serverID]
+	if !ok {
+		return server, fmt.Errorf("SERVER NOT FOUND: %d", serverID)
+	}
+	return server, nil
+}
+
+func (r *MemoryRepository) GetServerByName(serverName string) (models.Server, error) {
+	servers := r.filterServers(func(server models.Server) bool { return server.Nom == serverName })
+	if len(servers) == 0 {
+		return models.Server{}, fmt.Errorf("SERVER NOT FOUND: %s", serverName)
+	}
+	return servers[0], nil
+}
+
+func (r *MemoryRepository) GetServerNameById(serverID int) (string, error) {
+	server, err := r.GetServerById(serverID)
+	return server.Nom, err
+}
+
+func (r *MemoryRepository) GetServerGameById(serverID int) (string, error) {
+	server, err := r.GetServerById(serverID)
+	if err != nil {
+		return "", fmt.Errorf("GAME NOT FOUND FOR SERVER ID: %d", serverID)
+	}
+	return server.Jeu, nil
+}
+
+func (r *MemoryRepository) GetServerColorByName(serverName string) (string, error) {
+	server, err := r.GetServerByName(serverName)
+	return server.EmbedColor, err
+}
+
+func (r *MemoryRepository) SaveConnectionLog(playerID int, serverID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Connections = append(r.Connections, MemoryConnection{PlayerID: playerID, ServerID: serverID, Date: utcNow()})
+	return nil
+}
+
+func (r *MemoryRepository) GetAllPlayers() ([]models.Player, error) {
+	return r.filterPlayers(func(models.Player) bool { return true }), nil
+}
+
+func (r *MemoryRepository) GetAllMinecraftPlayers() ([]models.Player, error) {
+	return r.filterPlayers(func(player models.Player) bool { return player.Jeu == "Minecraft" }), nil
+}
+
+func (r *MemoryRepository) filterPlayers(keep func(models.Player) bool) []models.Player {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var players []models.Player
+	for _, player := range r.Players {
+		if keep(player) {
+			players = append(players, player)
+		}
+	}
+	sort.Slice(players, func(i, j int) bool { return players[i].ID < players[j].ID })
+	return players
+}
+
+func (r *MemoryRepository) CheckAndInsertPlayerWithPlayerName(playerName string, serverID int, timeConf string) (int, error) {
+	r.mu.Lock()
+	accountID, ok := r.AccountIDs[playerName]
+	r.mu.Unlock()
+	if !ok {
+		accountID = playerName
+	}
+	return r.CheckAndInsertPlayerWithPlayerUUID(accountID, serverID, timeConf)
+}
+
+func (r *MemoryRepository) InsertPlayer(utilisateurID int, jeu string, compteID string, premiereCo time.Time, derniereCo time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, player := range r.Players {
+		if player.CompteID == compteID {
+			return -1, fmt.Errorf("FAILED TO INSERT PLAYER: DUPLICATE ACCOUNT ID %s", compteID)
+		}
+	}
+
+	player := models.Player{
+		ID:            r.nextPlayerID,
+		UtilisateurID: utilisateurID,
+		Jeu:           jeu,
+		CompteID:      compteID,
+		PremiereCo:    premiereCo.UTC(),
+		DerniereCo:    derniereCo.UTC(),
+	}
+	r.Players[player.ID] = player
+	r.nextPlayerID++
+	return player.ID, nil
+}
+
+func (r *MemoryRepository) CheckAndInsertPlayerWithPlayerUUID(playerUUID string, serverID int, timeConf string) (int, error) {
+	if playerUUID == "" || playerUUID == "null" {
+		return -1, fmt.Errorf("PLAYER UUID IS EMPTY")
+	}
+	jeu, err := r.GetServerGameById(serverID)
+	if err != nil {
+		return -1, fmt.Errorf("FAILED TO GET SERVER GAME: %v", err)
+	}
+	if playerID, err := r.GetPlayerIdByAccountId(playerUUID); err == nil {
+		return playerID, nil
+	}
+
+	datetime := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	if timeConf == "now" {
+		datetime = utcNow()
+	}
+	return r.InsertPlayer(-1, jeu, playerUUID, datetime, datetime)
+}
+
+func (r *MemoryRepository) UpdatePlayerLastConnection(playerID int) error {
+	if playerID == -1 {
+		return fmt.Errorf("PLAYER ID IS -1, CANNOT UPDATE LAST CONNECTION")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	player, ok := r.Players[playerID]
+	if !ok {
+		return nil // Like an UPDATE matching no row
+	}
+	player.DerniereCo = utcNow()
+	r.Players[playerID] = player
+	return nil
+}
+
+func (r *MemoryRepository) GetPlayerById(playerID int) (models.Player, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	player, ok := r.Players[playerID]
+	if !ok {
+		return player, fmt.Errorf("PLAYER NOT FOUND: %d", playerID)
+	}
+	return player, nil
+}
+
+func (r *MemoryRepository) GetPlayerByUUID(playerUUID string) (models.Player, error) {
+	players := r.filterPlayers(func(player models.Player) bool { return player.CompteID == playerUUID })
+	if len(players) == 0 {
+		return models.Player{UtilisateurID: -1}, fmt.Errorf("player not found: %s", playerUUID)
+	}
+	return players[0], nil
+}
+
+func (r *MemoryRepository) GetPlayerIdByAccountId(accountId any) (int, error) {
+	accountID := fmt.Sprint(accountId)
+	players := r.filterPlayers(func(player models.Player) bool { return player.CompteID == accountID })
+	if len(players) == 0 {
+		return -1, fmt.Errorf("PLAYER ISN'T IN THE DATABASE")
+	}
+	return players[0].ID, nil
+}
+
+func (r *MemoryRepository) SavePlayerName(playerID int, playerName string) error {
+	if playerID == -1 {
+		return fmt.Errorf("PLAYER ID IS -1, CANNOT SAVE PLAYER NAME")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := utcNow()
+	for i, name := range r.Names[playerID] {
+		if name.Pseudo == playerName {
+			r.Names[playerID][i].DerniereVue = now
+			return nil
+		}
+	}
+	r.Names[playerID] = append(r.Names[playerID], models.PlayerName{Pseudo: playerName, PremiereVue: now, DerniereVue: now})
+	return nil
+}
+
+func (r *MemoryRepository) GetPlayerNames(playerID int) ([]models.PlayerName, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	names := append([]models.PlayerName(nil), r.Names[playerID]...)
+	sort.SliceStable(names, func(i, j int) bool { return names[i].DerniereVue.After(names[j].DerniereVue) })
+	return names, nil
+}
+
+func (r *MemoryRepository) LinkPlayerToDiscordUser(playerID int, discordID string, discordName string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	player, ok := r.Players[playerID]
+	if !ok {
+		return -1, fmt.Errorf("PLAYER NOT FOUND: %d", playerID)
+	}
+	utilisateurID := -1
+	for _, user := range r.Users {
+		if user.DiscordID == discordID {
+			utilisateurID = user.ID
+		}
+	}
+	if utilisateurID == -1 {
+		utilisateurID = r.nextUserID
+		r.nextUserID++
+	}
+	r.Users[utilisateurID] = models.DiscordUser{ID: utilisateurID, DiscordID: discordID, Pseudo: discordName}
+	player.UtilisateurID = utilisateurID
+	r.Players[playerID] = player
+	return utilisateurID, nil
+}
+
+func (r *MemoryRepository) FindPlayers(filter PlayerFilter) ([]models.Player, error) {
+	r.mu.Lock()
+	serverPlayers := make(map[int]bool)
+	for _, connection := range r.Connections {
+		if connection.ServerID == filter.ServerID {
+			serverPlayers[connection.PlayerID] = true
+		}
+	}
+	statsAccounts := make(map[string]bool)
+	for key, stats := range r.Stats {
+		if stats.ServerID == filter.ServerID {
+			_, playerUUID, _ := strings.Cut(key, "/")
+			statsAccounts[playerUUID] = true
+		}
+	}
+	search := strings.ToLower(filter.Search)
+	names := make(map[int][]models.PlayerName, len(r.Names))
+	for playerID, playerNames := range r.Names {
+		names[playerID] = playerNames
+	}
+	r.mu.Unlock()
+
+	players := r.filterPlayers(func(player models.Player) bool {
+		if filter.Jeu != "" && player.Jeu != filter.Jeu {
+			return false
+		}
+		if filter.ServerID != 0 && !serverPlayers[player.ID] && !statsAccounts[player.CompteID] {
+			return false
+		}
+		if !filter.SeenSince.IsZero() && player.DerniereCo.Before(filter.SeenSince) {
+			return false
+		}
+		if !filter.FirstSeenSince.IsZero() && player.PremiereCo.Before(filter.FirstSeenSince) {
+			return false
+		}
+		if search != "" && !strings.Contains(strings.ToLower(player.CompteID), search) {
+			found := false
+			for _, name := range names[player.ID] {
+				found = found || strings.Contains(strings.ToLower(name.Pseudo), search)
+			}
+			return found
+		}
+		return true
+	})
+	sort.SliceStable(players, func(i, j int) bool { return players[i].DerniereCo.After(players[j].DerniereCo) })
+	return players, nil
+}
+
+func (r *MemoryRepository) GetDiscordUser(utilisateurID int) (models.DiscordUser, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.Users[utilisateurID]
+	if !ok {
+		return models.DiscordUser{ID: utilisateurID}, fmt.Errorf("DISCORD USER NOT FOUND: %d", utilisateurID)
+	}
+	return user, nil
+}
+
+func memoryStatsKey(serverID int, playerUUID string) string {
+	return fmt.Sprintf("%d/%s", serverID, playerUUID)
+}
+
+func (r *MemoryRepository) CheckMinecraftPlayerGameStatisticsExists(playerUUID string, serverID int) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.Stats[memoryStatsKey(serverID, playerUUID)]
+	return ok
+}
+
+func (r *MemoryRepository) SaveMinecraftPlayerGameStatistics(serverID int, playerUUID string, playerStats models.MinecraftPlayerGameStatistics) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	playerStats.ServerID = serverID
+	playerStats.LastRecordedTime = utcNow()
+	r.Stats[memoryStatsKey(serverID, playerUUID)] = playerStats
+	return nil
+}
+
+func (r *MemoryRepository) GetMinecraftPlayerGameStatistics(playerUUID string) ([]models.MinecraftPlayerGameStatistics, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var allStats []models.MinecraftPlayerGameStatistics
+	for key, stats := range r.Stats {
+		if key == memoryStatsKey(stats.ServerID, playerUUID) {
+			allStats = append(allStats, stats)
+		}
+	}
+	sort.Slice(allStats, func(i, j int) bool { return allStats[i].ServerID < allStats[j].ServerID })
+	return allStats, nil
+}
+
+func (r *MemoryRepository) UpdateMinecraftPlayerGameStatistics(serverID int, playerUUID string, playerStats models.MinecraftPlayerGameStatistics) error {
+	if !r.CheckMinecraftPlayerGameStatisticsExists(playerUUID, serverID) {
+		return nil // Like an UPDATE matching no row
+	}
+	return r.SaveMinecraftPlayerGameStatistics(serverID, playerUUID, playerStats)
+}
+
+func (r *MemoryRepository) SaveMinecraftStatsSnapshot(serverID int, playerUUID string, playerStats models.MinecraftPlayerGameStatistics) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Snapshots = append(r.Snapshots, models.MinecraftStatsSnapshot{
+		ServerID: serverID, PlayerUUID: playerUUID, RecordedAt: utcNow(), MinecraftStatsCounters: statsCounters(playerStats),
+	})
+	return nil
+}
+
+func (r *MemoryRepository) lastSnapshotsAt(serverID int, at time.Time) []models.MinecraftStatsSnapshot {
+	var snapshots []models.MinecraftStatsSnapshot
+	for _, snapshot := range r.Snapshots {
+		if (serverID == 0 || snapshot.ServerID == serverID) && !snapshot.RecordedAt.After(at) {
+			snapshots = append(snapshots, snapshot)
+		}
+	}
+	return lastSnapshots(snapshots)
+}
+
+func (r *MemoryRepository) GetMinecraftStatsDeltas(serverID int, from time.Time, to time.Time) ([]models.MinecraftStatsDelta, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return computeDeltas(r.lastSnapshotsAt(serverID, from), r.lastSnapshotsAt(serverID, to), from), nil
+}
+
+func (r *MemoryRepository) PruneMinecraftStatsSnapshots(keepAllSince time.Time, keepDailySince time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// The index of a snapshot is its ID
+	var old []prunableSnapshot
+	for i, snapshot := range r.Snapshots {
+		if snapshot.RecordedAt.Before(keepAllSince) {
+			old = append(old, prunableSnapshot{id: int64(i), key: snapshotKey(snapshot), recordedAt: snapshot.RecordedAt})
+		}
+	}
+	sort.SliceStable(old, func(i, j int) bool {
+		if old[i].key != old[j].key {
+			return old[i].key < old[j].key
+		}
+		return old[i].recordedAt.Before(old[j].recordedAt)
+	})
+
+	deleted := make(map[int64]bool)
+	for _, id := range snapshotsToPrune(old, keepDailySince) {
+		deleted[id] = true
+	}
+	kept := r.Snapshots[:0]
+	for i, snapshot := range r.Snapshots {
+		if !deleted[int64(i)] {
+			kept = append(kept, snapshot)
+		}
+	}
+	r.Snapshots = kept
+	return len(deleted), nil
+}
+
+func (r *MemoryRepository) GetConnectionLogs(from time.Time, to time.Time) ([]models.PlayerConnection, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var connections []models.PlayerConnection
+	for _, connection := range r.Connections {
+		if !connection.Date.Before(from) && connection.Date.Before(to) {
+			connections = append(connections, models.PlayerConnection{PlayerID: connection.PlayerID, ServerID: connection.ServerID, Date: connection.Date})
+		}
+	}
+	sort.SliceStable(connections, func(i, j int) bool { return connections[i].Date.Before(connections[j].Date) })
+	return connections, nil
+}
+
+func (r *MemoryRepository) SaveServerEvent(serverID int, eventType string, playerName string, details string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// The IDs keep growing once old events are pruned
+	id := int64(1)
+	if len(r.Events) > 0 {
+		id = r.Events[len(r.Events)-1].ID + 1
+	}
+	r.Events = append(r.Events, models.ServerEvent{
+		ID: id, ServerID: serverID, Type: eventType, PlayerName: playerName, Details: details, Date: utcNow(),
+	})
+	return nil
+}
+
+func (r *MemoryRepository) GetServerEvents(from time.Time, to time.Time) ([]models.ServerEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var events []models.ServerEvent
+	for _, event := range r.Events {
+		if !event.Date.Before(from) && event.Date.Before(to) {
+			events = append(events, event)
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Date.Before(events[j].Date) })
+	return events, nil
+}
+
+func (r *MemoryRepository) FindServerEvents(filter EventFilter) ([]models.ServerEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var events []models.ServerEvent
+	for _, event := range r.Events {
+		if filter.matches(event) {
+			events = append(events, event)
+		}
+	}
+	slices.SortStableFunc(events, func(a, b models.ServerEvent) int {
+		if c := b.Date.Compare(a.Date); c != 0 {
+			return c
+		}
+		return int(b.ID - a.ID)
+	})
+	if filter.Limit > 0 && len(events) > filter.Limit {
+		events = events[:filter.Limit]
+	}
+	return events, nil
+}
+
+func (r *MemoryRepository) GetLastServerStates(before time.Time) ([]models.ServerEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	last := make(map[int]models.ServerEvent)
+	for _, event := range r.Events {
+		if slices.Contains(serverStateEvents, event.Type) && event.Date.Before(before) {
+			last[event.ServerID] = event
+		}
+	}
+	events := make([]models.ServerEvent, 0, len(last))
+	for _, event := range last {
+		events = append(events, event)
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].ServerID < events[j].ServerID })
+	return events, nil
+}
+
+func (r *MemoryRepository) GetLastPlayerEvents(before time.Time) ([]models.ServerEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	last := make(map[string]int) // "<server ID>/<player name>" -> index of the event
+	for i, event := range r.Events {
+		if (event.Type == models.EventPlayerJoined || event.Type == models.EventPlayerLeft) && event.Date.Before(before) {
+			last[fmt.Sprintf("%d/%s", event.ServerID, event.PlayerName)] = i
+		}
+	}
+	events := make([]models.ServerEvent, 0, len(last))
+	for _, i := range last {
+		events = append(events, r.Events[i])
+	}
+	sort.Slice(events, func(i, j int) bool {
+		if events[i].ServerID != events[j].ServerID {
+			return events[i].ServerID < events[j].ServerID
+		}
+		return events[i].ID < events[j].ID
+	})
+	return events, nil
+}
+
+func (r *MemoryRepository) PruneServerEvents(before time.Time) (int, error) {
+	states, _ := r.GetLastServerStates(before)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	kept := r.Events[:0]
+	for _, event := range r.Events {
+		if !event.Date.Before(before) || slices.ContainsFunc(states, func(state models.ServerEvent) bool { return state.ID == event.ID }) {
+			kept = append(kept, event)
+		}
+	}
+	deleted := len(r.Events) - len(kept)
+	r.Events = kept
+	return deleted, nil
+}
+
+func (r *MemoryRepository) SaveAuditEntry(entry models.AuditEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry.ID = int64(len(r.Audit) + 1)
+	entry.Date = utcNow()
+	r.Audit = append(r.Audit, entry)
+	return nil
+}
+
+func (r *MemoryRepository) GetAuditEntries(filter AuditFilter) ([]models.AuditEntry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var entries []models.AuditEntry
+	for _, entry := range r.Audit {
+		if filter.matches(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	sortAuditEntries(entries)
+	if filter.Limit > 0 && len(entries) > filter.Limit {
+		entries = entries[:filter.Limit]
+	}
+	return entries, nil
+}
+
+// The memory repository has no schema, it is always up to date
+
+func (r *MemoryRepository) GetMigrationsStatus() ([]MigrationState, error) { return nil, nil }
+
+func (r *MemoryRepository) CountPendingMigrations() (int, error) { return 0, nil }
+
+func (r *MemoryRepository) Migrate() ([]Migration, error) { return nil, nil }
+
+func (r *MemoryRepository) Rollback(steps int) ([]Migration, error) { return nil, nil }
+
+func (r *MemoryRepository) Close() error { return nil }
+
+// Compile time checks that the repositories are complete
+var (
+	_ Repository = (*SQLRepository)(nil)
+	_ Repository = (*MemoryRepository)(nil)
+	_ Repository = (*CachedRepository)(nil)
+)
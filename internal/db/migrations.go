@@ -0,0 +1,262 @@
+package db
+
+// This file contains the SCHEMA MIGRATIONS, SQL files embedded in the binary and applied in order of version.
+// A migration is two files, "0001_name.up.sql" to apply it and "0001_name.down.sql" to roll it back, in the folder of
+// each database: the schema is the same, but written in the SQL of the database. A migration without a down file can't be
+// rolled back, like 0001 which adopts the tables of the existing databases
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed migrations
+var migrationFiles embed.FS
+
+// Migration is a version of the schema
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationState is a migration and when it was applied
+type MigrationState struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// LoadMigrations returns the embedded migrations of a folder, sorted by version
+func LoadMigrations(migrationsDir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, migrationsDir)
+	if err != nil {
+		return nil, fmt.Errorf("ERROR WHILE READING MIGRATIONS: %v", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		fileName := entry.Name()
+		var direction string
+		switch {
+		case strings.HasSuffix(fileName, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(fileName, ".down.sql"):
+			direction = "down"
+		default:
+			continue
+		}
+
+		versionString, name, found := strings.Cut(strings.TrimSuffix(fileName, "."+direction+".sql"), "_")
+		version, err := strconv.Atoi(versionString)
+		if !found || err != nil || version <= 0 {
+			return nil, fmt.Errorf("INVALID MIGRATION FILE NAME %s, EXPECTED 0001_name.up.sql", fileName)
+		}
+
+		content, err := migrationFiles.ReadFile(path.Join(migrationsDir, fileName))
+		if err != nil {
+			return nil, fmt.Errorf("ERROR WHILE READING MIGRATION %s: %v", fileName, err)
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: name}
+			byVersion[version] = migration
+		} else if migration.Name != name {
+			return nil, fmt.Errorf("MIGRATION %d HAS TWO NAMES: %s AND %s", version, migration.Name, name)
+		}
+		if direction == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("MIGRATION %d_%s HAS NO UP FILE", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// ensureMigrationsTable creates the table remembering the applied migrations
+func (r *SQLRepository) ensureMigrationsTable() error {
+	_, err := r.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INT NOT NULL PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		applied_at DATETIME NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("ERROR WHILE CREATING THE SCHEMA_MIGRATIONS TABLE: %v", err)
+	}
+	return nil
+}
+
+// getAppliedMigrations returns when each applied migration was applied, by version
+func (r *SQLRepository) getAppliedMigrations() (map[int]time.Time, error) {
+	if err := r.ensureMigrationsTable(); err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query("SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("FAILED TO GET APPLIED MIGRATIONS: %v", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt nullTime
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("FAILED TO SCAN APPLIED MIGRATION: %v", err)
+		}
+		applied[version] = appliedAt.Time
+	}
+	return applied, rows.Err()
+}
+
+// GetMigrationsStatus returns every migration, applied or pending
+func (r *SQLRepository) GetMigrationsStatus() ([]MigrationState, error) {
+	migrations, err := LoadMigrations(r.dialect.migrationsDir())
+	if err != nil {
+		return nil, err
+	}
+	applied, err := r.getAppliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	states := make([]MigrationState, 0, len(migrations))
+	for _, migration := range migrations {
+		appliedAt, ok := applied[migration.Version]
+		states = append(states, MigrationState{Migration: migration, Applied: ok, AppliedAt: appliedAt})
+	}
+	return states, nil
+}
+
+// CountPendingMigrations returns how many migrations are not applied yet
+func (r *SQLRepository) CountPendingMigrations() (int, error) {
+	states, err := r.GetMigrationsStatus()
+	if err != nil {
+		return 0, err
+	}
+	pending := 0
+	for _, state := range states {
+		if !state.Applied {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+// Migrate applies the pending migrations in order, it stops at the first one that fails
+func (r *SQLRepository) Migrate() ([]Migration, error) {
+	states, err := r.GetMigrationsStatus()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, state := range states {
+		if state.Applied {
+			continue
+		}
+		if err := r.runMigration(state.Migration, state.Up); err != nil {
+			return done, err
+		}
+		_, err := r.db.Exec("INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)",
+			state.Version, state.Name, r.dialect.timeArg(utcNow()))
+		if err != nil {
+			return done, fmt.Errorf("FAILED TO RECORD MIGRATION %d_%s: %v", state.Version, state.Name, err)
+		}
+		done = append(done, state.Migration)
+	}
+	return done, nil
+}
+
+// Rollback rolls back the last applied migrations, steps is how many
+func (r *SQLRepository) Rollback(steps int) ([]Migration, error) {
+	states, err := r.GetMigrationsStatus()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for i := len(states) - 1; i >= 0 && len(done) < steps; i-- {
+		state := states[i]
+		if !state.Applied {
+			continue
+		}
+		if state.Down == "" {
+			return done, fmt.Errorf("MIGRATION %d_%s CAN'T BE ROLLED BACK, IT HAS NO DOWN FILE", state.Version, state.Name)
+		}
+		if err := r.runMigration(state.Migration, state.Down); err != nil {
+			return done, err
+		}
+		if _, err := r.db.Exec("DELETE FROM schema_migrations WHERE version = ?", state.Version); err != nil {
+			return done, fmt.Errorf("FAILED TO FORGET MIGRATION %d_%s: %v", state.Version, state.Name, err)
+		}
+		done = append(done, state.Migration)
+	}
+	return done, nil
+}
+
+// runMigration runs the statements of a migration file one by one. MySQL commits the schema changes immediately, so a
+// migration failing halfway must be fixed by hand, keep them small. They all go through the same connection, so a statement
+// can use the variables and prepared statements of the previous ones
+func (r *SQLRepository) runMigration(migration Migration, script string) error {
+	conn, err := r.db.Conn(context.Background())
+	if err != nil {
+		return fmt.Errorf("ERROR WHILE GETTING A CONNECTION FOR MIGRATION %d_%s: %v", migration.Version, migration.Name, err)
+	}
+	defer conn.Close()
+
+	for _, statement := range splitStatements(script) {
+		start := time.Now()
+		_, err := conn.ExecContext(context.Background(), statement)
+		observeQuery(statement, start)
+		if err != nil {
+			return fmt.Errorf("MIGRATION %d_%s FAILED ON STATEMENT %q: %v", migration.Version, migration.Name, firstLine(statement), err)
+		}
+	}
+	return nil
+}
+
+// splitStatements splits a SQL script on the semicolons ending a line, and removes the comment lines
+func splitStatements(script string) []string {
+	var statements []string
+	var current strings.Builder
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		current.WriteString(line + "\n")
+		if strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, strings.TrimSuffix(strings.TrimSpace(current.String()), ";"))
+			current.Reset()
+		}
+	}
+	if strings.TrimSpace(current.String()) != "" {
+		statements = append(statements, strings.TrimSpace(current.String()))
+	}
+	return statements
+}
+
+func firstLine(statement string) string {
+	line, _, _ := strings.Cut(statement, "\n")
+	return line
+}
@@ -0,0 +1,126 @@
+-- Tables created before the migrations existed, "IF NOT EXISTS" lets existing databases adopt this migration as is.
+-- It has no down file : rolling it back would drop the tables of production, with their data
+
+CREATE TABLE IF NOT EXISTS serveurs (
+    id INT NOT NULL AUTO_INCREMENT,
+    nom VARCHAR(255) NOT NULL,
+    jeu VARCHAR(255) NOT NULL,
+    version VARCHAR(20) NOT NULL,
+    modpack VARCHAR(255) DEFAULT 'Vanilla',
+    modpack_url VARCHAR(255) NULL,
+    nom_monde VARCHAR(255) DEFAULT 'world',
+    embed_color VARCHAR(7) DEFAULT '#000000',
+    path_serv TEXT NOT NULL,
+    start_script VARCHAR(255) NOT NULL,
+    actif BOOLEAN NOT NULL DEFAULT FALSE,
+    global BOOLEAN NOT NULL DEFAULT TRUE,
+    PRIMARY KEY (id)
+);
+
+CREATE TABLE IF NOT EXISTS serveurs_parameters (
+    id INT NOT NULL AUTO_INCREMENT,
+    id_serv_primaire INT NULL,
+    id_serv_secondaire INT NULL,
+    id_serv_partenaire INT NULL,
+    PRIMARY KEY (id)
+);
+
+-- The parameters are a single row, updated by the setters
+INSERT INTO serveurs_parameters (id_serv_primaire, id_serv_secondaire, id_serv_partenaire)
+SELECT NULL, NULL, NULL FROM DUAL WHERE NOT EXISTS (SELECT 1 FROM serveurs_parameters);
+
+CREATE TABLE IF NOT EXISTS joueurs (
+    id INT NOT NULL AUTO_INCREMENT,
+    utilisateur_id INT NULL,
+    jeu VARCHAR(255) NOT NULL,
+    compte_id VARCHAR(255) NOT NULL,
+    premiere_co DATETIME NULL,
+    derniere_co DATETIME NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY joueurs_compte_id (compte_id)
+);
+
+CREATE TABLE IF NOT EXISTS joueurs_connections_log (
+    id INT NOT NULL AUTO_INCREMENT,
+    serveur_id INT NOT NULL,
+    joueur_id INT NULL,
+    date DATETIME NULL,
+    PRIMARY KEY (id),
+    KEY joueurs_connections_log_serveur (serveur_id),
+    KEY joueurs_connections_log_joueur (joueur_id)
+);
+
+CREATE TABLE IF NOT EXISTS joueurs_stats (
+    id INT NOT NULL AUTO_INCREMENT,
+    serveur_id INT NOT NULL,
+    compte_id VARCHAR(255) NOT NULL,
+    tmps_jeux BIGINT DEFAULT 0,
+    nb_mort INT DEFAULT 0,
+    nb_kills INT DEFAULT 0,
+    nb_playerkill INT DEFAULT 0,
+    mob_killed JSON,
+    nb_blocs_detr INT DEFAULT 0,
+    nb_blocs_pose INT DEFAULT 0,
+    dist_total INT DEFAULT 0,
+    dist_pieds INT DEFAULT 0,
+    dist_elytres INT DEFAULT 0,
+    dist_vol INT DEFAULT 0,
+    item_crafted JSON,
+    item_broken JSON,
+    achievement JSON,
+    dern_enregistrment DATETIME NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY joueurs_stats_serveur_compte (serveur_id, compte_id)
+);
+
+-- The adopted tables may lack the unique keys the upserts need, and hold the duplicated rows they let in. The duplicates are
+-- merged, then the keys are added if missing
+
+-- The players of a same account are merged into the oldest row, their connections follow it
+UPDATE joueurs j
+JOIN (
+    SELECT compte_id, MIN(id) AS id, MIN(premiere_co) AS premiere_co, MAX(derniere_co) AS derniere_co, MAX(utilisateur_id) AS utilisateur_id
+    FROM joueurs GROUP BY compte_id HAVING COUNT(*) > 1
+) d ON j.id = d.id
+SET j.premiere_co = d.premiere_co, j.derniere_co = d.derniere_co, j.utilisateur_id = COALESCE(j.utilisateur_id, d.utilisateur_id);
+
+UPDATE joueurs_connections_log l
+JOIN joueurs j ON l.joueur_id = j.id
+JOIN (SELECT compte_id, MIN(id) AS id FROM joueurs GROUP BY compte_id HAVING COUNT(*) > 1) d ON j.compte_id = d.compte_id
+SET l.joueur_id = d.id
+WHERE j.id <> d.id;
+
+DELETE j FROM joueurs j
+JOIN joueurs k ON k.compte_id = j.compte_id AND k.id < j.id;
+
+-- The statistics only grow, the last saved ones of a player on a server are kept
+DELETE s FROM joueurs_stats s
+JOIN joueurs_stats k ON k.serveur_id = s.serveur_id AND k.compte_id = s.compte_id
+    AND (k.dern_enregistrment > s.dern_enregistrment OR (k.dern_enregistrment = s.dern_enregistrment AND k.id > s.id));
+
+-- MySQL has no "ADD UNIQUE KEY IF NOT EXISTS", the key is added by a statement prepared only when it is missing
+SET @key_exists = (
+    SELECT COUNT(*) FROM information_schema.statistics
+    WHERE table_schema = DATABASE() AND table_name = 'joueurs' AND index_name = 'joueurs_compte_id'
+);
+
+SET @statement = IF(@key_exists = 0, 'ALTER TABLE joueurs ADD UNIQUE KEY joueurs_compte_id (compte_id)', 'DO 0');
+
+PREPARE add_unique_key FROM @statement;
+
+EXECUTE add_unique_key;
+
+DEALLOCATE PREPARE add_unique_key;
+
+SET @key_exists = (
+    SELECT COUNT(*) FROM information_schema.statistics
+    WHERE table_schema = DATABASE() AND table_name = 'joueurs_stats' AND index_name = 'joueurs_stats_serveur_compte'
+);
+
+SET @statement = IF(@key_exists = 0, 'ALTER TABLE joueurs_stats ADD UNIQUE KEY joueurs_stats_serveur_compte (serveur_id, compte_id)', 'DO 0');
+
+PREPARE add_unique_key FROM @statement;
+
+EXECUTE add_unique_key;
+
+DEALLOCATE PREPARE add_unique_key;
@@ -0,0 +1,12 @@
+UPDATE joueurs SET derniere_co = COALESCE(CONVERT_TZ(derniere_co, '+00:00', @@global.time_zone), derniere_co)
+WHERE derniere_co <> premiere_co AND derniere_co <> '2000-01-01 00:00:00';
+
+UPDATE joueurs SET premiere_co = premiere_co + INTERVAL 1 HOUR
+WHERE premiere_co <> '2000-01-01 00:00:00';
+
+UPDATE joueurs SET derniere_co = derniere_co + INTERVAL 1 HOUR
+WHERE derniere_co = premiere_co - INTERVAL 1 HOUR AND premiere_co <> '2000-01-01 00:00:00';
+
+UPDATE joueurs_stats SET dern_enregistrment = dern_enregistrment + INTERVAL 1 HOUR;
+
+UPDATE joueurs_connections_log SET date = date + INTERVAL 1 HOUR WHERE date IS NOT NULL;
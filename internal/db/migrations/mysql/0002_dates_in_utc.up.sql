@@ -0,0 +1,19 @@
+-- Dates used to be written one hour ahead of UTC (the old GetGoodDatetime), they are now written in UTC.
+-- premiere_co and derniere_co were inserted that way, so derniere_co is shifted back like premiere_co while it still
+-- equals it. 2000-01-01 is the "unknown" date, it was never shifted.
+-- derniere_co was then updated with NOW(), in the time zone of the MySQL session. The daemon never set it, so these dates
+-- are converted from the global time zone of the server, assuming it didn't change since they were written. With a named
+-- time zone, the time zone tables of MySQL must be loaded : CONVERT_TZ returns NULL otherwise and the dates are kept
+
+UPDATE joueurs_connections_log SET date = date - INTERVAL 1 HOUR WHERE date IS NOT NULL;
+
+UPDATE joueurs_stats SET dern_enregistrment = dern_enregistrment - INTERVAL 1 HOUR;
+
+UPDATE joueurs SET derniere_co = COALESCE(CONVERT_TZ(derniere_co, @@global.time_zone, '+00:00'), derniere_co)
+WHERE derniere_co <> premiere_co AND derniere_co <> '2000-01-01 00:00:00';
+
+UPDATE joueurs SET derniere_co = derniere_co - INTERVAL 1 HOUR
+WHERE derniere_co = premiere_co AND premiere_co <> '2000-01-01 00:00:00';
+
+UPDATE joueurs SET premiere_co = premiere_co - INTERVAL 1 HOUR
+WHERE premiere_co <> '2000-01-01 00:00:00';
@@ -0,0 +1,2 @@
+-- utilisateurs_discord is kept, the Discord bots may use it
+DROP TABLE IF EXISTS joueurs_pseudos;
@@ -0,0 +1,51 @@
+-- utilisateurs_discord may already exist, it is shared with the Discord bots. When they created it, "IF NOT EXISTS" keeps
+-- it without the unique key on discord_id that the links need: the duplicated users are merged into the oldest, then the
+-- key is added if missing
+
+CREATE TABLE IF NOT EXISTS utilisateurs_discord (
+    id INT NOT NULL AUTO_INCREMENT,
+    discord_id VARCHAR(20) NOT NULL,
+    pseudo_discord VARCHAR(255) NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY utilisateurs_discord_discord_id (discord_id)
+);
+
+UPDATE utilisateurs_discord u
+JOIN (
+    SELECT discord_id, MIN(id) AS id, MAX(pseudo_discord) AS pseudo_discord
+    FROM utilisateurs_discord GROUP BY discord_id HAVING COUNT(*) > 1
+) d ON u.id = d.id
+SET u.pseudo_discord = COALESCE(u.pseudo_discord, d.pseudo_discord);
+
+UPDATE joueurs j
+JOIN utilisateurs_discord u ON j.utilisateur_id = u.id
+JOIN (SELECT discord_id, MIN(id) AS id FROM utilisateurs_discord GROUP BY discord_id HAVING COUNT(*) > 1) d ON u.discord_id = d.discord_id
+SET j.utilisateur_id = d.id
+WHERE u.id <> d.id;
+
+DELETE u FROM utilisateurs_discord u
+JOIN utilisateurs_discord k ON k.discord_id = u.discord_id AND k.id < u.id;
+
+-- MySQL has no "ADD UNIQUE KEY IF NOT EXISTS", the key is added by a statement prepared only when it is missing
+SET @key_exists = (
+    SELECT COUNT(*) FROM information_schema.statistics
+    WHERE table_schema = DATABASE() AND table_name = 'utilisateurs_discord' AND index_name = 'utilisateurs_discord_discord_id'
+);
+
+SET @statement = IF(@key_exists = 0, 'ALTER TABLE utilisateurs_discord ADD UNIQUE KEY utilisateurs_discord_discord_id (discord_id)', 'DO 0');
+
+PREPARE add_unique_key FROM @statement;
+
+EXECUTE add_unique_key;
+
+DEALLOCATE PREPARE add_unique_key;
+
+CREATE TABLE IF NOT EXISTS joueurs_pseudos (
+    id INT NOT NULL AUTO_INCREMENT,
+    joueur_id INT NOT NULL,
+    pseudo VARCHAR(255) NOT NULL,
+    premiere_vue DATETIME NOT NULL,
+    derniere_vue DATETIME NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY joueurs_pseudos_joueur_pseudo (joueur_id, pseudo)
+);
@@ -0,0 +1,3 @@
+ALTER TABLE joueurs_stats ADD COLUMN item_used JSON NULL;
+
+ALTER TABLE joueurs_stats ADD COLUMN dist_details JSON NULL;
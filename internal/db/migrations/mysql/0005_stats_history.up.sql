@@ -0,0 +1,28 @@
+CREATE TABLE IF NOT EXISTS joueurs_stats_historique (
+    id BIGINT NOT NULL AUTO_INCREMENT,
+    serveur_id INT NOT NULL,
+    compte_id VARCHAR(255) NOT NULL,
+    date DATETIME NOT NULL,
+    tmps_jeux BIGINT DEFAULT 0,
+    nb_mort INT DEFAULT 0,
+    nb_kills INT DEFAULT 0,
+    nb_playerkill INT DEFAULT 0,
+    nb_blocs_detr INT DEFAULT 0,
+    nb_blocs_pose INT DEFAULT 0,
+    dist_total BIGINT DEFAULT 0,
+    nb_achievements INT DEFAULT 0,
+    PRIMARY KEY (id),
+    KEY joueurs_stats_historique_joueur (serveur_id, compte_id, date),
+    KEY joueurs_stats_historique_date (date)
+);
+
+-- The statistics already saved are the first snapshot, so the first deltas don't count everything since the beginning
+INSERT INTO joueurs_stats_historique (
+    serveur_id, compte_id, date, tmps_jeux, nb_mort, nb_kills, nb_playerkill,
+    nb_blocs_detr, nb_blocs_pose, dist_total, nb_achievements
+)
+SELECT
+    serveur_id, compte_id, dern_enregistrment, COALESCE(tmps_jeux, 0), COALESCE(nb_mort, 0), COALESCE(nb_kills, 0),
+    COALESCE(nb_playerkill, 0), COALESCE(nb_blocs_detr, 0), COALESCE(nb_blocs_pose, 0), COALESCE(dist_total, 0),
+    COALESCE(JSON_LENGTH(achievement), 0)
+FROM joueurs_stats;
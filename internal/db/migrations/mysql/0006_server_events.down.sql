@@ -0,0 +1 @@
+DROP TABLE IF EXISTS evenements_serveurs;
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS evenements_serveurs (
+    id BIGINT NOT NULL AUTO_INCREMENT,
+    serveur_id INT NOT NULL,
+    type VARCHAR(20) NOT NULL,
+    pseudo VARCHAR(255) NULL,
+    details TEXT NULL,
+    date DATETIME NOT NULL,
+    PRIMARY KEY (id),
+    KEY evenements_serveurs_date (date),
+    KEY evenements_serveurs_serveur_type (serveur_id, type, date)
+);
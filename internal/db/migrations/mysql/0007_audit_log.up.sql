@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS journal_audit (
+    id BIGINT NOT NULL AUTO_INCREMENT,
+    date DATETIME NOT NULL,
+    acteur_type VARCHAR(20) NOT NULL,
+    acteur VARCHAR(255) NOT NULL,
+    action VARCHAR(50) NOT NULL,
+    serveur_id INT NULL,
+    parametres TEXT NULL,
+    succes BOOLEAN NOT NULL,
+    erreur TEXT NULL,
+    PRIMARY KEY (id),
+    KEY journal_audit_date (date),
+    KEY journal_audit_serveur (serveur_id, date)
+);
@@ -0,0 +1,70 @@
+-- Same schema as the MySQL migration, in SQLite types. Dates are TEXT so the driver returns them as written, like MySQL.
+-- It has no down file, like the MySQL one. The SQLite databases are always created by it, so they have nothing to merge
+
+CREATE TABLE IF NOT EXISTS serveurs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    nom TEXT NOT NULL,
+    jeu TEXT NOT NULL,
+    version TEXT NOT NULL,
+    modpack TEXT DEFAULT 'Vanilla',
+    modpack_url TEXT NULL,
+    nom_monde TEXT DEFAULT 'world',
+    embed_color TEXT DEFAULT '#000000',
+    path_serv TEXT NOT NULL,
+    start_script TEXT NOT NULL,
+    actif BOOLEAN NOT NULL DEFAULT 0,
+    global BOOLEAN NOT NULL DEFAULT 1
+);
+
+CREATE TABLE IF NOT EXISTS serveurs_parameters (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    id_serv_primaire INTEGER NULL,
+    id_serv_secondaire INTEGER NULL,
+    id_serv_partenaire INTEGER NULL
+);
+
+-- The parameters are a single row, updated by the setters
+INSERT INTO serveurs_parameters (id_serv_primaire, id_serv_secondaire, id_serv_partenaire)
+SELECT NULL, NULL, NULL WHERE NOT EXISTS (SELECT 1 FROM serveurs_parameters);
+
+CREATE TABLE IF NOT EXISTS joueurs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    utilisateur_id INTEGER NULL,
+    jeu TEXT NOT NULL,
+    compte_id TEXT NOT NULL UNIQUE,
+    premiere_co TEXT NULL,
+    derniere_co TEXT NULL
+);
+
+CREATE TABLE IF NOT EXISTS joueurs_connections_log (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    serveur_id INTEGER NOT NULL,
+    joueur_id INTEGER NULL,
+    date TEXT NULL
+);
+
+CREATE INDEX IF NOT EXISTS joueurs_connections_log_serveur ON joueurs_connections_log (serveur_id);
+
+CREATE INDEX IF NOT EXISTS joueurs_connections_log_joueur ON joueurs_connections_log (joueur_id);
+
+CREATE TABLE IF NOT EXISTS joueurs_stats (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    serveur_id INTEGER NOT NULL,
+    compte_id TEXT NOT NULL,
+    tmps_jeux INTEGER DEFAULT 0,
+    nb_mort INTEGER DEFAULT 0,
+    nb_kills INTEGER DEFAULT 0,
+    nb_playerkill INTEGER DEFAULT 0,
+    mob_killed TEXT,
+    nb_blocs_detr INTEGER DEFAULT 0,
+    nb_blocs_pose INTEGER DEFAULT 0,
+    dist_total INTEGER DEFAULT 0,
+    dist_pieds INTEGER DEFAULT 0,
+    dist_elytres INTEGER DEFAULT 0,
+    dist_vol INTEGER DEFAULT 0,
+    item_crafted TEXT,
+    item_broken TEXT,
+    achievement TEXT,
+    dern_enregistrment TEXT NOT NULL,
+    UNIQUE (serveur_id, compte_id)
+);
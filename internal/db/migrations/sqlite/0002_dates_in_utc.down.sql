@@ -0,0 +1,12 @@
+UPDATE joueurs SET derniere_co = datetime(derniere_co, 'localtime')
+WHERE derniere_co <> premiere_co AND derniere_co <> '2000-01-01 00:00:00';
+
+UPDATE joueurs SET premiere_co = datetime(premiere_co, '+1 hour')
+WHERE premiere_co <> '2000-01-01 00:00:00';
+
+UPDATE joueurs SET derniere_co = datetime(derniere_co, '+1 hour')
+WHERE derniere_co = datetime(premiere_co, '-1 hour') AND premiere_co <> '2000-01-01 00:00:00';
+
+UPDATE joueurs_stats SET dern_enregistrment = datetime(dern_enregistrment, '+1 hour');
+
+UPDATE joueurs_connections_log SET date = datetime(date, '+1 hour') WHERE date IS NOT NULL;
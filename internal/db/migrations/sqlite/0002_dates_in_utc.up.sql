@@ -0,0 +1,16 @@
+-- Same as the MySQL migration : the dates written one hour ahead of UTC are shifted back, they are now written in UTC.
+-- derniere_co was updated with datetime('now', 'localtime'), so once it differs from premiere_co it is converted from the
+-- local time of the machine, assuming its time zone didn't change since
+
+UPDATE joueurs_connections_log SET date = datetime(date, '-1 hour') WHERE date IS NOT NULL;
+
+UPDATE joueurs_stats SET dern_enregistrment = datetime(dern_enregistrment, '-1 hour');
+
+UPDATE joueurs SET derniere_co = datetime(derniere_co, 'utc')
+WHERE derniere_co <> premiere_co AND derniere_co <> '2000-01-01 00:00:00';
+
+UPDATE joueurs SET derniere_co = datetime(derniere_co, '-1 hour')
+WHERE derniere_co = premiere_co AND premiere_co <> '2000-01-01 00:00:00';
+
+UPDATE joueurs SET premiere_co = datetime(premiere_co, '-1 hour')
+WHERE premiere_co <> '2000-01-01 00:00:00';
@@ -0,0 +1,2 @@
+-- utilisateurs_discord is kept, like in the MySQL migration
+DROP TABLE IF EXISTS joueurs_pseudos;
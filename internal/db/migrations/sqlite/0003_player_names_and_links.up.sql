@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS utilisateurs_discord (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    discord_id TEXT NOT NULL UNIQUE,
+    pseudo_discord TEXT NULL
+);
+
+CREATE TABLE IF NOT EXISTS joueurs_pseudos (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    joueur_id INTEGER NOT NULL,
+    pseudo TEXT NOT NULL,
+    premiere_vue TEXT NOT NULL,
+    derniere_vue TEXT NOT NULL,
+    UNIQUE (joueur_id, pseudo)
+);
@@ -0,0 +1,3 @@
+ALTER TABLE joueurs_stats DROP COLUMN dist_details;
+
+ALTER TABLE joueurs_stats DROP COLUMN item_used;
@@ -0,0 +1,3 @@
+ALTER TABLE joueurs_stats ADD COLUMN item_used TEXT NULL;
+
+ALTER TABLE joueurs_stats ADD COLUMN dist_details TEXT NULL;
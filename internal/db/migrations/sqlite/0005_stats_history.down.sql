@@ -0,0 +1 @@
+DROP TABLE IF EXISTS joueurs_stats_historique;
@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS joueurs_stats_historique (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    serveur_id INTEGER NOT NULL,
+    compte_id TEXT NOT NULL,
+    date TEXT NOT NULL,
+    tmps_jeux INTEGER DEFAULT 0,
+    nb_mort INTEGER DEFAULT 0,
+    nb_kills INTEGER DEFAULT 0,
+    nb_playerkill INTEGER DEFAULT 0,
+    nb_blocs_detr INTEGER DEFAULT 0,
+    nb_blocs_pose INTEGER DEFAULT 0,
+    dist_total INTEGER DEFAULT 0,
+    nb_achievements INTEGER DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS joueurs_stats_historique_joueur ON joueurs_stats_historique (serveur_id, compte_id, date);
+
+CREATE INDEX IF NOT EXISTS joueurs_stats_historique_date ON joueurs_stats_historique (date);
+
+-- The statistics already saved are the first snapshot, like in the MySQL migration
+INSERT INTO joueurs_stats_historique (
+    serveur_id, compte_id, date, tmps_jeux, nb_mort, nb_kills, nb_playerkill,
+    nb_blocs_detr, nb_blocs_pose, dist_total, nb_achievements
+)
+SELECT
+    serveur_id, compte_id, dern_enregistrment, COALESCE(tmps_jeux, 0), COALESCE(nb_mort, 0), COALESCE(nb_kills, 0),
+    COALESCE(nb_playerkill, 0), COALESCE(nb_blocs_detr, 0), COALESCE(nb_blocs_pose, 0), COALESCE(dist_total, 0),
+    CASE WHEN json_valid(CAST(achievement AS TEXT)) THEN (SELECT COUNT(*) FROM json_each(CAST(joueurs_stats.achievement AS TEXT))) ELSE 0 END
+FROM joueurs_stats;
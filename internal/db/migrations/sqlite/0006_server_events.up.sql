@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS evenements_serveurs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    serveur_id INTEGER NOT NULL,
+    type TEXT NOT NULL,
+    pseudo TEXT NULL,
+    details TEXT NULL,
+    date TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS evenements_serveurs_date ON evenements_serveurs (date);
+
+CREATE INDEX IF NOT EXISTS evenements_serveurs_serveur_type ON evenements_serveurs (serveur_id, type, date);
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS journal_audit (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    date TEXT NOT NULL,
+    acteur_type TEXT NOT NULL,
+    acteur TEXT NOT NULL,
+    action TEXT NOT NULL,
+    serveur_id INTEGER NULL,
+    parametres TEXT NULL,
+    succes BOOLEAN NOT NULL,
+    erreur TEXT NULL
+);
+
+CREATE INDEX IF NOT EXISTS journal_audit_date ON journal_audit (date);
+
+CREATE INDEX IF NOT EXISTS journal_audit_serveur ON journal_audit (serveur_id, date);
@@ -0,0 +1,169 @@
+package db
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Corentin-cott/ServeurSentinel/internal/models"
+)
+
+// openTestSQLite opens an empty SQLite database in a temporary directory
+func openTestSQLite(t *testing.T) *SQLRepository {
+	t.Helper()
+	r, err := OpenRepository(models.DatabaseConfig{Driver: "sqlite", Path: filepath.Join(t.TempDir(), "sentinel.db")})
+	if err != nil {
+		t.Fatalf("OpenRepository() failed: %v", err)
+	}
+	t.Cleanup(func() { r.Close() })
+	return r
+}
+
+func hasTable(t *testing.T, r *SQLRepository, table string) bool {
+	t.Helper()
+	var count int
+	if err := r.db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", table).Scan(&count); err != nil {
+		t.Fatalf("table %s not looked up: %v", table, err)
+	}
+	return count > 0
+}
+
+func versions(migrations []Migration) []int {
+	result := make([]int, 0, len(migrations))
+	for _, migration := range migrations {
+		result = append(result, migration.Version)
+	}
+	return result
+}
+
+func TestLoadMigrations(t *testing.T) {
+	mysqlMigrations, err := LoadMigrations("migrations/mysql")
+	if err != nil {
+		t.Fatalf("LoadMigrations(mysql) failed: %v", err)
+	}
+	sqliteMigrations, err := LoadMigrations("migrations/sqlite")
+	if err != nil {
+		t.Fatalf("LoadMigrations(sqlite) failed: %v", err)
+	}
+
+	if len(mysqlMigrations) != len(sqliteMigrations) {
+		t.Fatalf("%d MySQL migrations but %d SQLite ones", len(mysqlMigrations), len(sqliteMigrations))
+	}
+	for i, migration := range mysqlMigrations {
+		if migration.Version != i+1 {
+			t.Errorf("migration %d has version %d, the versions must follow each other", i, migration.Version)
+		}
+		if sqliteMigrations[i].Version != migration.Version || sqliteMigrations[i].Name != migration.Name {
+			t.Errorf("MySQL migration %d_%s but SQLite migration %d_%s", migration.Version, migration.Name, sqliteMigrations[i].Version, sqliteMigrations[i].Name)
+		}
+		// Only the adoption of the existing tables can't be rolled back
+		for _, m := range []Migration{migration, sqliteMigrations[i]} {
+			if hasDown := m.Down != ""; hasDown != (m.Version != 1) {
+				t.Errorf("migration %d_%s has a down file: %v", m.Version, m.Name, hasDown)
+			}
+		}
+	}
+}
+
+func TestSQLiteMigrations(t *testing.T) {
+	r := openTestSQLite(t)
+	migrations, err := LoadMigrations("migrations/sqlite")
+	if err != nil {
+		t.Fatalf("LoadMigrations() failed: %v", err)
+	}
+
+	done, err := r.Migrate()
+	if err != nil {
+		t.Fatalf("Migrate() failed: %v", err)
+	}
+	if !slices.Equal(versions(done), versions(migrations)) {
+		t.Fatalf("Migrate() applied %v, want %v", versions(done), versions(migrations))
+	}
+	if pending, err := r.CountPendingMigrations(); err != nil || pending != 0 {
+		t.Errorf("CountPendingMigrations() = %d, %v after Migrate(), want 0", pending, err)
+	}
+	if done, err := r.Migrate(); err != nil || len(done) != 0 {
+		t.Errorf("second Migrate() = %v, %v, want nothing to do", versions(done), err)
+	}
+
+	// The tables created by each migration disappear with its rollback, from the last one to the second one
+	tests := []struct {
+		version int
+		tables  []string
+	}{
+		{7, []string{"journal_audit"}},
+		{6, []string{"evenements_serveurs"}},
+		{5, []string{"joueurs_stats_historique"}},
+		{4, nil},
+		{3, []string{"joueurs_pseudos"}}, // utilisateurs_discord is kept on purpose
+		{2, nil},
+	}
+	if len(tests) != len(migrations)-1 {
+		t.Fatalf("%d rollbacks tested for %d migrations, a new migration needs its case", len(tests), len(migrations))
+	}
+	for _, test := range tests {
+		for _, table := range test.tables {
+			if !hasTable(t, r, table) {
+				t.Errorf("table %s missing before the rollback of migration %d", table, test.version)
+			}
+		}
+		done, err := r.Rollback(1)
+		if err != nil {
+			t.Fatalf("Rollback() of migration %d failed: %v", test.version, err)
+		}
+		if !slices.Equal(versions(done), []int{test.version}) {
+			t.Fatalf("Rollback() rolled back %v, want [%d]", versions(done), test.version)
+		}
+		for _, table := range test.tables {
+			if hasTable(t, r, table) {
+				t.Errorf("table %s still there after the rollback of migration %d", table, test.version)
+			}
+		}
+	}
+
+	// The initial schema stays
+	done, err = r.Rollback(1)
+	if err == nil || !strings.Contains(err.Error(), "CAN'T BE ROLLED BACK") || len(done) != 0 {
+		t.Errorf("Rollback() of migration 1 = %v, %v, want an error", versions(done), err)
+	}
+	for _, table := range []string{"serveurs", "joueurs", "joueurs_stats"} {
+		if !hasTable(t, r, table) {
+			t.Errorf("table %s removed by a refused rollback", table)
+		}
+	}
+
+	// Everything can be applied again
+	done, err = r.Migrate()
+	if err != nil {
+		t.Fatalf("Migrate() after the rollbacks failed: %v", err)
+	}
+	if !slices.Equal(versions(done), versions(migrations[1:])) {
+		t.Errorf("Migrate() after the rollbacks applied %v, want %v", versions(done), versions(migrations[1:]))
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   []string
+	}{
+		{"empty", "", nil},
+		{"only comments", "-- The keys are kept\n  -- on purpose\n", nil},
+		{"one statement", "DROP TABLE a;", []string{"DROP TABLE a"}},
+		{"without last semicolon", "DROP TABLE a;\nDROP TABLE b", []string{"DROP TABLE a", "DROP TABLE b"}},
+		{
+			"statement on several lines with comments", "-- Table a\nCREATE TABLE a (\n  id INT, -- The ID\n  name TEXT\n);\n\nDROP TABLE b;",
+			[]string{"CREATE TABLE a (\n  id INT, -- The ID\n  name TEXT\n)", "DROP TABLE b"},
+		},
+		{"semicolon inside a line", "SET @a = 'x;y', @b = 1;", []string{"SET @a = 'x;y', @b = 1"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := splitStatements(test.script); !slices.Equal(got, test.want) {
+				t.Errorf("splitStatements(%q) = %q, want %q", test.script, got, test.want)
+			}
+		})
+	}
+}
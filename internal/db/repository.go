@@ -0,0 +1,342 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Corentin-cott/ServeurSentinel/config"
+	"github.com/Corentin-cott/ServeurSentinel/internal/logging"
+	"github.com/Corentin-cott/ServeurSentinel/internal/models"
+	_ "github.com/go-sql-driver/mysql"
+	_ "modernc.org/sqlite"
+)
+
+var logger = logging.For("db")
+
+// ServerRepository is where the game servers and the servers selected as primary, secondary and partner are stored
+type ServerRepository interface {
+	GetAllServers() ([]models.Server, error)
+	GetAllMinecraftServers() ([]models.Server, error)
+	GetPrimaryServerId() int
+	GetSecondaryServerId() int
+	GetPartenariatServerId() int
+	SetPrimaryServerId(serverID int) error
+	SetSecondaryServerId(serverID int) error
+	SetPartenariatServerId(serverID int) error
+	GetServerById(serverID int) (models.Server, error)
+	GetServerByName(serverName string) (models.Server, error)
+	GetServerNameById(serverID int) (string, error)
+	GetServerGameById(serverID int) (string, error)
+	GetServerColorByName(serverName string) (string, error)
+}
+
+// PlayerRepository is where the players and their connections are stored
+type PlayerRepository interface {
+	SaveConnectionLog(playerID int, serverID int) error
+	GetAllPlayers() ([]models.Player, error)
+	GetAllMinecraftPlayers() ([]models.Player, error)
+	CheckAndInsertPlayerWithPlayerName(playerName string, serverID int, timeConf string) (int, error)
+	InsertPlayer(utilisateurID int, jeu string, compteID string, premiereCo time.Time, derniereCo time.Time) (int, error)
+	CheckAndInsertPlayerWithPlayerUUID(playerUUID string, serverID int, timeConf string) (int, error)
+	UpdatePlayerLastConnection(playerID int) error
+	GetPlayerById(playerID int) (models.Player, error)
+	GetPlayerByUUID(playerUUID string) (models.Player, error)
+	GetPlayerIdByAccountId(accountId any) (int, error)
+	SavePlayerName(playerID int, playerName string) error
+	GetPlayerNames(playerID int) ([]models.PlayerName, error)
+	LinkPlayerToDiscordUser(playerID int, discordID string, discordName string) (int, error)
+	FindPlayers(filter PlayerFilter) ([]models.Player, error)
+	GetConnectionLogs(from time.Time, to time.Time) ([]models.PlayerConnection, error)
+	GetDiscordUser(utilisateurID int) (models.DiscordUser, error)
+}
+
+// StatsRepository is where the game statistics of the players are stored
+type StatsRepository interface {
+	CheckMinecraftPlayerGameStatisticsExists(playerUUID string, serverID int) bool
+	SaveMinecraftPlayerGameStatistics(serverID int, playerUUID string, playerStats models.MinecraftPlayerGameStatistics) error
+	UpdateMinecraftPlayerGameStatistics(serverID int, playerUUID string, playerStats models.MinecraftPlayerGameStatistics) error
+	GetMinecraftPlayerGameStatistics(playerUUID string) ([]models.MinecraftPlayerGameStatistics, error)
+	SaveMinecraftStatsSnapshot(serverID int, playerUUID string, playerStats models.MinecraftPlayerGameStatistics) error
+	GetMinecraftStatsDeltas(serverID int, from time.Time, to time.Time) ([]models.MinecraftStatsDelta, error)
+	PruneMinecraftStatsSnapshots(keepAllSince time.Time, keepDailySince time.Time) (int, error)
+}
+
+// EventRepository is where the events read in the consoles of the servers are stored
+type EventRepository interface {
+	SaveServerEvent(serverID int, eventType string, playerName string, details string) error
+	GetServerEvents(from time.Time, to time.Time) ([]models.ServerEvent, error)
+	FindServerEvents(filter EventFilter) ([]models.ServerEvent, error)
+	GetLastServerStates(before time.Time) ([]models.ServerEvent, error)
+	GetLastPlayerEvents(before time.Time) ([]models.ServerEvent, error)
+	PruneServerEvents(before time.Time) (int, error)
+}
+
+// AuditRepository is where the actions done on the servers are stored
+type AuditRepository interface {
+	SaveAuditEntry(entry models.AuditEntry) error
+	GetAuditEntries(filter AuditFilter) ([]models.AuditEntry, error)
+}
+
+// Repository is a whole database, with its schema
+type Repository interface {
+	ServerRepository
+	PlayerRepository
+	StatsRepository
+	EventRepository
+	AuditRepository
+
+	GetMigrationsStatus() ([]MigrationState, error)
+	CountPendingMigrations() (int, error)
+	Migrate() ([]Migration, error)
+	Rollback(steps int) ([]Migration, error)
+
+	Close() error
+}
+
+// The repository used by the package functions, set by ConnectToDatabase
+var repo Repository
+
+// OpenRepository connects to the database of the configuration, with the driver it selects
+func OpenRepository(conf models.DatabaseConfig) (*SQLRepository, error) {
+	d, err := getDialect(conf.Driver)
+	if err != nil {
+		return nil, err
+	}
+
+	if conf.Driver == "sqlite" {
+		if err := os.MkdirAll(filepath.Dir(conf.Path), 0750); err != nil {
+			return nil, fmt.Errorf("ERROR WHILE CREATING THE DATABASE DIRECTORY: %v", err)
+		}
+	}
+
+	logger.Debug("Connecting to the database", "driver", d.driverName(), "address", d.safeDSN(conf))
+
+	database, err := sql.Open(d.driverName(), d.dsn(conf))
+	if err != nil {
+		return nil, fmt.Errorf("ERROR OPENING DATABASE: %v", err)
+	}
+
+	// SQLite allows a single writer, one connection avoids "database is locked" errors between our own goroutines
+	if conf.Driver == "sqlite" {
+		database.SetMaxOpenConns(1)
+	}
+
+	if err := database.Ping(); err != nil {
+		database.Close()
+		return nil, fmt.Errorf("ERROR WHILE PINGING DATABASE WITH CONNECTION STRING: (%v) ! ERROR: %v", d.safeDSN(conf), err)
+	}
+
+	return &SQLRepository{db: timedDB{database}, dialect: d}, nil
+}
+
+// Close closes the connections to the database, it waits for the queries in progress
+func (r *SQLRepository) Close() error {
+	if err := r.db.Close(); err != nil {
+		return fmt.Errorf("ERROR WHILE CLOSING DATABASE: %v", err)
+	}
+	return nil
+}
+
+// ConnectToDatabase initialises the connection to the database of the configuration
+func ConnectToDatabase() error {
+	r, err := OpenRepository(config.Get().DB)
+	if err != nil {
+		return err
+	}
+	repo = r
+
+	logger.Info("Connected to the database", "driver", config.Get().DB.Driver)
+	return nil
+}
+
+// SetRepository replaces the repository used by the package functions
+func SetRepository(r Repository) {
+	repo = r
+}
+
+// GetRepository returns the repository used by the package functions, nil before ConnectToDatabase
+func GetRepository() Repository {
+	return repo
+}
+
+// CloseDatabase closes the connections to the database, it waits for the queries in progress
+func CloseDatabase() error {
+	if repo == nil {
+		return nil
+	}
+	return repo.Close()
+}
+
+// The functions below use the repository set by ConnectToDatabase, see the Repository methods
+
+func GetAllServers() ([]models.Server, error) { return repo.GetAllServers() }
+
+func GetAllMinecraftServers() ([]models.Server, error) { return repo.GetAllMinecraftServers() }
+
+func GetPrimaryServerId() int { return repo.GetPrimaryServerId() }
+
+func GetSecondaryServerId() int { return repo.GetSecondaryServerId() }
+
+func GetPartenariatServerId() int { return repo.GetPartenariatServerId() }
+
+// The slot setters also mark the servers as changed, the daemon may be another process
+
+func SetPrimaryServerId(serverID int) error { return markIfDone(repo.SetPrimaryServerId(serverID)) }
+
+func SetSecondaryServerId(serverID int) error { return markIfDone(repo.SetSecondaryServerId(serverID)) }
+
+func SetPartenariatServerId(serverID int) error {
+	return markIfDone(repo.SetPartenariatServerId(serverID))
+}
+
+func markIfDone(err error) error {
+	if err == nil {
+		MarkServersChanged()
+	}
+	return err
+}
+
+// ServersGeneration changes each time the cached servers or slots change, it is always 0 without a CachedRepository
+func ServersGeneration() uint64 {
+	if cached, ok := repo.(*CachedRepository); ok {
+		return cached.Generation()
+	}
+	return 0
+}
+
+func GetServerById(serverID int) (models.Server, error) { return repo.GetServerById(serverID) }
+
+func GetServerByName(serverName string) (models.Server, error) {
+	return repo.GetServerByName(serverName)
+}
+
+func GetServerNameById(serverID int) (string, error) { return repo.GetServerNameById(serverID) }
+
+func GetServerGameById(serverID int) (string, error) { return repo.GetServerGameById(serverID) }
+
+func GetServerColorByName(serverName string) (string, error) {
+	return repo.GetServerColorByName(serverName)
+}
+
+func SaveConnectionLog(playerID int, serverID int) error {
+	return repo.SaveConnectionLog(playerID, serverID)
+}
+
+func GetAllPlayers() ([]models.Player, error) { return repo.GetAllPlayers() }
+
+func GetAllMinecraftPlayers() ([]models.Player, error) { return repo.GetAllMinecraftPlayers() }
+
+func CheckAndInsertPlayerWithPlayerName(playerName string, serverID int, timeConf string) (int, error) {
+	return repo.CheckAndInsertPlayerWithPlayerName(playerName, serverID, timeConf)
+}
+
+func InsertPlayer(utilisateurID int, jeu string, compteID string, premiereCo time.Time, derniereCo time.Time) (int, error) {
+	return repo.InsertPlayer(utilisateurID, jeu, compteID, premiereCo, derniereCo)
+}
+
+func CheckAndInsertPlayerWithPlayerUUID(playerUUID string, serverID int, timeConf string) (int, error) {
+	return repo.CheckAndInsertPlayerWithPlayerUUID(playerUUID, serverID, timeConf)
+}
+
+func UpdatePlayerLastConnection(playerID int) error { return repo.UpdatePlayerLastConnection(playerID) }
+
+func GetPlayerById(playerID int) (models.Player, error) { return repo.GetPlayerById(playerID) }
+
+func GetPlayerByUUID(playerUUID string) (models.Player, error) {
+	return repo.GetPlayerByUUID(playerUUID)
+}
+
+func GetPlayerIdByAccountId(accountId any) (int, error) {
+	return repo.GetPlayerIdByAccountId(accountId)
+}
+
+func SavePlayerName(playerID int, playerName string) error {
+	return repo.SavePlayerName(playerID, playerName)
+}
+
+func GetPlayerNames(playerID int) ([]models.PlayerName, error) { return repo.GetPlayerNames(playerID) }
+
+func LinkPlayerToDiscordUser(playerID int, discordID string, discordName string) (int, error) {
+	return repo.LinkPlayerToDiscordUser(playerID, discordID, discordName)
+}
+
+func FindPlayers(filter PlayerFilter) ([]models.Player, error) { return repo.FindPlayers(filter) }
+
+func GetDiscordUser(utilisateurID int) (models.DiscordUser, error) {
+	return repo.GetDiscordUser(utilisateurID)
+}
+
+func GetConnectionLogs(from time.Time, to time.Time) ([]models.PlayerConnection, error) {
+	return repo.GetConnectionLogs(from, to)
+}
+
+func SaveServerEvent(serverID int, eventType string, playerName string, details string) error {
+	return repo.SaveServerEvent(serverID, eventType, playerName, details)
+}
+
+func GetServerEvents(from time.Time, to time.Time) ([]models.ServerEvent, error) {
+	return repo.GetServerEvents(from, to)
+}
+
+func FindServerEvents(filter EventFilter) ([]models.ServerEvent, error) {
+	return repo.FindServerEvents(filter)
+}
+
+func GetLastServerStates(before time.Time) ([]models.ServerEvent, error) {
+	return repo.GetLastServerStates(before)
+}
+
+func GetLastPlayerEvents(before time.Time) ([]models.ServerEvent, error) {
+	return repo.GetLastPlayerEvents(before)
+}
+
+func PruneServerEvents(before time.Time) (int, error) {
+	return repo.PruneServerEvents(before)
+}
+
+func SaveAuditEntry(entry models.AuditEntry) error {
+	return repo.SaveAuditEntry(entry)
+}
+
+func GetAuditEntries(filter AuditFilter) ([]models.AuditEntry, error) {
+	return repo.GetAuditEntries(filter)
+}
+
+func GetMinecraftPlayerGameStatistics(playerUUID string) ([]models.MinecraftPlayerGameStatistics, error) {
+	return repo.GetMinecraftPlayerGameStatistics(playerUUID)
+}
+
+func CheckMinecraftPlayerGameStatisticsExists(playerUUID string, serverID int) bool {
+	return repo.CheckMinecraftPlayerGameStatisticsExists(playerUUID, serverID)
+}
+
+func SaveMinecraftPlayerGameStatistics(serverID int, playerUUID string, playerStats models.MinecraftPlayerGameStatistics) error {
+	return repo.SaveMinecraftPlayerGameStatistics(serverID, playerUUID, playerStats)
+}
+
+func UpdateMinecraftPlayerGameStatistics(serverID int, playerUUID string, playerStats models.MinecraftPlayerGameStatistics) error {
+	return repo.UpdateMinecraftPlayerGameStatistics(serverID, playerUUID, playerStats)
+}
+
+func SaveMinecraftStatsSnapshot(serverID int, playerUUID string, playerStats models.MinecraftPlayerGameStatistics) error {
+	return repo.SaveMinecraftStatsSnapshot(serverID, playerUUID, playerStats)
+}
+
+func GetMinecraftStatsDeltas(serverID int, from time.Time, to time.Time) ([]models.MinecraftStatsDelta, error) {
+	return repo.GetMinecraftStatsDeltas(serverID, from, to)
+}
+
+func PruneMinecraftStatsSnapshots(keepAllSince time.Time, keepDailySince time.Time) (int, error) {
+	return repo.PruneMinecraftStatsSnapshots(keepAllSince, keepDailySince)
+}
+
+func GetMigrationsStatus() ([]MigrationState, error) { return repo.GetMigrationsStatus() }
+
+func CountPendingMigrations() (int, error) { return repo.CountPendingMigrations() }
+
+func Migrate() ([]Migration, error) { return repo.Migrate() }
+
+func Rollback(steps int) ([]Migration, error) { return repo.Rollback(steps) }
@@ -0,0 +1,169 @@
+package db
+
+// This file contains the SERVER EVENTS, what the triggers read in the consoles of the servers : starts, stops, crashes,
+// connections, messages, deaths and advancements. The reports are built on them
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Corentin-cott/ServeurSentinel/internal/models"
+)
+
+/* -----------------------------------------------------
+Table evenements_serveurs {
+  id BIGINT [pk, increment]
+  serveur_id INT [ref: > serveurs.id, not null]
+  type VARCHAR(20) [not null]
+  pseudo VARCHAR(255) [null]
+  details TEXT [null]
+  date DATETIME [not null]
+}
+----------------------------------------------------- */
+
+// EventFilter selects the server events, the empty fields select everything
+type EventFilter struct {
+	Since    time.Time
+	ServerID int
+	Type     string // Ex: models.EventServerCrashed
+	Limit    int    // 0 means no limit
+}
+
+// matches tells if an event is selected by the filter, for the repositories that can't query
+func (filter EventFilter) matches(event models.ServerEvent) bool {
+	return !event.Date.Before(filter.Since) &&
+		(filter.ServerID <= 0 || event.ServerID == filter.ServerID) &&
+		(filter.Type == "" || event.Type == filter.Type)
+}
+
+// The events telling if a server is running
+var serverStateEvents = []string{models.EventServerStarted, models.EventServerStopped, models.EventServerCrashed}
+
+// SaveServerEvent saves an event of a server, at the current date
+func (r *SQLRepository) SaveServerEvent(serverID int, eventType string, playerName string, details string) error {
+	query := "INSERT INTO evenements_serveurs (serveur_id, type, pseudo, details, date) VALUES (?, ?, ?, ?, ?)"
+	_, err := r.db.Exec(query, serverID, eventType, nullString(playerName), nullString(details), r.dialect.timeArg(utcNow()))
+	if err != nil {
+		return fmt.Errorf("FAILED TO SAVE SERVER EVENT: %v", err)
+	}
+	return nil
+}
+
+// GetServerEvents returns the events of all the servers between two dates, the oldest first
+func (r *SQLRepository) GetServerEvents(from time.Time, to time.Time) ([]models.ServerEvent, error) {
+	query := `
+		SELECT id, serveur_id, type, pseudo, details, date FROM evenements_serveurs
+		WHERE date >= ? AND date < ? ORDER BY date, id
+	`
+	return r.queryServerEvents(query, r.dialect.timeArg(from), r.dialect.timeArg(to))
+}
+
+// FindServerEvents returns the server events matching the filter, the most recent first
+func (r *SQLRepository) FindServerEvents(filter EventFilter) ([]models.ServerEvent, error) {
+	var conditions []string
+	var args []any
+	if !filter.Since.IsZero() {
+		conditions = append(conditions, "date >= ?")
+		args = append(args, r.dialect.timeArg(filter.Since))
+	}
+	if filter.ServerID > 0 {
+		conditions = append(conditions, "serveur_id = ?")
+		args = append(args, filter.ServerID)
+	}
+	if filter.Type != "" {
+		conditions = append(conditions, "type = ?")
+		args = append(args, filter.Type)
+	}
+
+	query := "SELECT id, serveur_id, type, pseudo, details, date FROM evenements_serveurs"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY date DESC, id DESC"
+	if filter.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", filter.Limit)
+	}
+	return r.queryServerEvents(query, args...)
+}
+
+// GetLastServerStates returns the last start, stop or crash of each server before a date, to know if it was running
+func (r *SQLRepository) GetLastServerStates(before time.Time) ([]models.ServerEvent, error) {
+	query := `
+		SELECT e.id, e.serveur_id, e.type, e.pseudo, e.details, e.date FROM evenements_serveurs e
+		JOIN (
+			SELECT serveur_id, MAX(id) AS id FROM evenements_serveurs
+			WHERE type IN (?, ?, ?) AND date < ?
+			GROUP BY serveur_id
+		) l ON e.id = l.id
+		ORDER BY e.serveur_id
+	`
+	return r.queryServerEvents(query, serverStateEvents[0], serverStateEvents[1], serverStateEvents[2], r.dialect.timeArg(before))
+}
+
+// GetLastPlayerEvents returns the last connection or disconnection of each player on each server before a date, to know
+// who was connected
+func (r *SQLRepository) GetLastPlayerEvents(before time.Time) ([]models.ServerEvent, error) {
+	query := `
+		SELECT e.id, e.serveur_id, e.type, e.pseudo, e.details, e.date FROM evenements_serveurs e
+		JOIN (
+			SELECT serveur_id, pseudo, MAX(id) AS id FROM evenements_serveurs
+			WHERE type IN (?, ?) AND date < ?
+			GROUP BY serveur_id, pseudo
+		) l ON e.id = l.id
+		ORDER BY e.serveur_id, e.id
+	`
+	return r.queryServerEvents(query, models.EventPlayerJoined, models.EventPlayerLeft, r.dialect.timeArg(before))
+}
+
+// PruneServerEvents deletes the events older than a date, except the last start, stop or crash of each server that still
+// tells if it is running. Returns how many events were deleted
+func (r *SQLRepository) PruneServerEvents(before time.Time) (int, error) {
+	states, err := r.GetLastServerStates(before)
+	if err != nil {
+		return 0, err
+	}
+
+	query := "DELETE FROM evenements_serveurs WHERE date < ?"
+	args := []any{r.dialect.timeArg(before)}
+	if len(states) > 0 {
+		query += " AND id NOT IN (" + strings.TrimSuffix(strings.Repeat("?, ", len(states)), ", ") + ")"
+		for _, state := range states {
+			args = append(args, state.ID)
+		}
+	}
+	result, err := r.db.Exec(query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("FAILED TO DELETE OLD SERVER EVENTS: %v", err)
+	}
+	deleted, _ := result.RowsAffected()
+	return int(deleted), nil
+}
+
+func (r *SQLRepository) queryServerEvents(query string, args ...any) ([]models.ServerEvent, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("FAILED TO GET SERVER EVENTS: %v", err)
+	}
+	defer rows.Close()
+
+	var events []models.ServerEvent
+	for rows.Next() {
+		var event models.ServerEvent
+		var playerName, details sql.NullString
+		var date nullTime
+		if err := rows.Scan(&event.ID, &event.ServerID, &event.Type, &playerName, &details, &date); err != nil {
+			return nil, fmt.Errorf("FAILED TO SCAN SERVER EVENT: %v", err)
+		}
+		event.PlayerName = playerName.String
+		event.Details = details.String
+		event.Date = date.Time
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+func nullString(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
@@ -0,0 +1,151 @@
+package db
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Corentin-cott/ServeurSentinel/internal/models"
+)
+
+func TestPruneServerEvents(t *testing.T) {
+	r := openTestSQLite(t)
+	if _, err := r.Migrate(); err != nil {
+		t.Fatalf("Migrate() failed: %v", err)
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	old := now.Add(-48 * time.Hour)
+	events := []struct {
+		serverID  int
+		eventType string
+		date      time.Time
+	}{
+		{1, models.EventServerStarted, old.Add(-time.Hour)}, // 1: replaced by the stop of server 1
+		{1, models.EventPlayerJoined, old},                  // 2
+		{1, models.EventServerStopped, old},                 // 3: last state of server 1
+		{2, models.EventServerStarted, old},                 // 4: last state of server 2 before the cutoff
+		{2, models.EventServerCrashed, now},                 // 5
+		{1, models.EventPlayerJoined, now},                  // 6
+	}
+	for _, event := range events {
+		_, err := r.db.Exec("INSERT INTO evenements_serveurs (serveur_id, type, date) VALUES (?, ?, ?)",
+			event.serverID, event.eventType, r.dialect.timeArg(event.date))
+		if err != nil {
+			t.Fatalf("inserting event failed: %v", err)
+		}
+	}
+
+	deleted, err := r.PruneServerEvents(now.Add(-24 * time.Hour))
+	if err != nil {
+		t.Fatalf("PruneServerEvents() failed: %v", err)
+	}
+	if deleted != 2 {
+		t.Errorf("PruneServerEvents() deleted %d events, want 2", deleted)
+	}
+
+	kept, err := r.GetServerEvents(time.Time{}, now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("GetServerEvents() failed: %v", err)
+	}
+	var ids []int64
+	for _, event := range kept {
+		ids = append(ids, event.ID)
+	}
+	slices.Sort(ids)
+	if want := []int64{3, 4, 5, 6}; !slices.Equal(ids, want) {
+		t.Errorf("events kept = %v, want %v", ids, want)
+	}
+}
+
+func TestFindServerEvents(t *testing.T) {
+	r := openTestSQLite(t)
+	if _, err := r.Migrate(); err != nil {
+		t.Fatalf("Migrate() failed: %v", err)
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	events := []struct {
+		serverID  int
+		eventType string
+		date      time.Time
+	}{
+		{1, models.EventServerCrashed, now.Add(-48 * time.Hour)}, // 1
+		{1, models.EventServerCrashed, now.Add(-2 * time.Hour)},  // 2
+		{2, models.EventServerCrashed, now.Add(-time.Hour)},      // 3
+		{1, models.EventPlayerJoined, now.Add(-time.Hour)},       // 4
+		{1, models.EventServerCrashed, now.Add(-time.Hour)},      // 5
+	}
+	for _, event := range events {
+		_, err := r.db.Exec("INSERT INTO evenements_serveurs (serveur_id, type, date) VALUES (?, ?, ?)",
+			event.serverID, event.eventType, r.dialect.timeArg(event.date))
+		if err != nil {
+			t.Fatalf("inserting event failed: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter EventFilter
+		want   []int64
+	}{
+		{"everything", EventFilter{}, []int64{5, 4, 3, 2, 1}},
+		{"since", EventFilter{Since: now.Add(-24 * time.Hour)}, []int64{5, 4, 3, 2}},
+		{"server and type", EventFilter{ServerID: 1, Type: models.EventServerCrashed}, []int64{5, 2, 1}},
+		{"limit", EventFilter{Type: models.EventServerCrashed, Limit: 2}, []int64{5, 3}},
+	}
+	for _, test := range tests {
+		found, err := r.FindServerEvents(test.filter)
+		if err != nil {
+			t.Fatalf("%s: FindServerEvents() failed: %v", test.name, err)
+		}
+		var ids []int64
+		for _, event := range found {
+			ids = append(ids, event.ID)
+		}
+		if !slices.Equal(ids, test.want) {
+			t.Errorf("%s: FindServerEvents() = %v, want %v", test.name, ids, test.want)
+		}
+	}
+}
+
+func TestGetLastPlayerEvents(t *testing.T) {
+	r := openTestSQLite(t)
+	if _, err := r.Migrate(); err != nil {
+		t.Fatalf("Migrate() failed: %v", err)
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	events := []struct {
+		serverID   int
+		eventType  string
+		playerName string
+		date       time.Time
+	}{
+		{1, models.EventPlayerJoined, "Steve", now.Add(-3 * time.Hour)}, // 1
+		{1, models.EventPlayerJoined, "Alex", now.Add(-3 * time.Hour)},  // 2
+		{1, models.EventPlayerLeft, "Steve", now.Add(-2 * time.Hour)},   // 3: last of Steve on server 1
+		{2, models.EventPlayerJoined, "Steve", now.Add(-2 * time.Hour)}, // 4: last of Steve on server 2
+		{1, models.EventPlayerMessage, "Alex", now.Add(-time.Hour)},     // 5
+		{1, models.EventPlayerLeft, "Alex", now},                        // 6: after the date
+	}
+	for _, event := range events {
+		_, err := r.db.Exec("INSERT INTO evenements_serveurs (serveur_id, type, pseudo, date) VALUES (?, ?, ?, ?)",
+			event.serverID, event.eventType, event.playerName, r.dialect.timeArg(event.date))
+		if err != nil {
+			t.Fatalf("inserting event failed: %v", err)
+		}
+	}
+
+	found, err := r.GetLastPlayerEvents(now.Add(-time.Minute))
+	if err != nil {
+		t.Fatalf("GetLastPlayerEvents() failed: %v", err)
+	}
+	var ids []int64
+	for _, event := range found {
+		ids = append(ids, event.ID)
+	}
+	if want := []int64{2, 3, 4}; !slices.Equal(ids, want) {
+		t.Errorf("GetLastPlayerEvents() = %v, want %v", ids, want)
+	}
+}
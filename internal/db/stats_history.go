@@ -0,0 +1,272 @@
+package db
+
+// This file contains the HISTORY of the statistics : a snapshot of the counters of each player is added on each statistics
+// update, the old ones are downsampled, and the deltas tell how much the counters grew during a period
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Corentin-cott/ServeurSentinel/internal/models"
+)
+
+/* -----------------------------------------------------
+Table joueurs_stats_historique {
+  id BIGINT [pk, increment]
+  serveur_id INT [ref: > serveurs.id, not null]
+  compte_id VARCHAR(255) [ref: > joueurs.compte_id, not null]
+  date DATETIME [not null]
+  tmps_jeux BIGINT [default: 0]
+  nb_mort INT [default: 0]
+  nb_kills INT [default: 0]
+  nb_playerkill INT [default: 0]
+  nb_blocs_detr INT [default: 0]
+  nb_blocs_pose INT [default: 0]
+  dist_total BIGINT [default: 0]
+  nb_achievements INT [default: 0]
+}
+----------------------------------------------------- */
+
+// How many snapshots are deleted by a single query
+const pruneBatchSize = 500
+
+// statsCounters takes the counters kept in the history from the statistics of a player
+func statsCounters(stats models.MinecraftPlayerGameStatistics) models.MinecraftStatsCounters {
+	return models.MinecraftStatsCounters{
+		TimePlayed:      stats.TimePlayed,
+		Deaths:          stats.Deaths,
+		Kills:           stats.Kills,
+		PlayerKills:     stats.PlayerKills,
+		BlocksDestroyed: stats.BlocksDestroyed,
+		BlocksPlaced:    stats.BlocksPlaced,
+		TotalDistance:   stats.TotalDistance,
+		Advancements:    len(stats.Achievements),
+	}
+}
+
+// subtractCounters is end - start, a counter can't go down so a negative one is 0
+func subtractCounters(end models.MinecraftStatsCounters, start models.MinecraftStatsCounters) models.MinecraftStatsCounters {
+	positive := func(value int) int { return max(value, 0) }
+	return models.MinecraftStatsCounters{
+		TimePlayed:      positive(end.TimePlayed - start.TimePlayed),
+		Deaths:          positive(end.Deaths - start.Deaths),
+		Kills:           positive(end.Kills - start.Kills),
+		PlayerKills:     positive(end.PlayerKills - start.PlayerKills),
+		BlocksDestroyed: positive(end.BlocksDestroyed - start.BlocksDestroyed),
+		BlocksPlaced:    positive(end.BlocksPlaced - start.BlocksPlaced),
+		TotalDistance:   positive(end.TotalDistance - start.TotalDistance),
+		Advancements:    positive(end.Advancements - start.Advancements),
+	}
+}
+
+func snapshotKey(snapshot models.MinecraftStatsSnapshot) string {
+	return fmt.Sprintf("%d/%s", snapshot.ServerID, snapshot.PlayerUUID)
+}
+
+// computeDeltas compares the last snapshots at the end of a period with the last ones at its start. A player without
+// snapshot at the start was first seen during the period, a play time that went down means the world was reset : in both
+// cases the whole counters were earned during the period. The most active players come first
+func computeDeltas(startSnapshots []models.MinecraftStatsSnapshot, endSnapshots []models.MinecraftStatsSnapshot, from time.Time) []models.MinecraftStatsDelta {
+	starts := make(map[string]models.MinecraftStatsSnapshot, len(startSnapshots))
+	for _, snapshot := range startSnapshots {
+		starts[snapshotKey(snapshot)] = snapshot
+	}
+
+	deltas := make([]models.MinecraftStatsDelta, 0)
+	for _, end := range endSnapshots {
+		if !end.RecordedAt.After(from) {
+			continue // Nothing recorded during the period
+		}
+		delta := models.MinecraftStatsDelta{ServerID: end.ServerID, PlayerUUID: end.PlayerUUID, To: end.RecordedAt}
+		start, ok := starts[snapshotKey(end)]
+		if ok && end.TimePlayed >= start.TimePlayed {
+			delta.From = start.RecordedAt
+			delta.MinecraftStatsCounters = subtractCounters(end.MinecraftStatsCounters, start.MinecraftStatsCounters)
+		} else {
+			delta.MinecraftStatsCounters = end.MinecraftStatsCounters
+		}
+		deltas = append(deltas, delta)
+	}
+
+	sort.SliceStable(deltas, func(i, j int) bool {
+		if deltas[i].TimePlayed != deltas[j].TimePlayed {
+			return deltas[i].TimePlayed > deltas[j].TimePlayed
+		}
+		if deltas[i].ServerID != deltas[j].ServerID {
+			return deltas[i].ServerID < deltas[j].ServerID
+		}
+		return deltas[i].PlayerUUID < deltas[j].PlayerUUID
+	})
+	return deltas
+}
+
+// lastSnapshots keeps the last snapshot of each player on each server
+func lastSnapshots(snapshots []models.MinecraftStatsSnapshot) []models.MinecraftStatsSnapshot {
+	last := make(map[string]models.MinecraftStatsSnapshot)
+	for _, snapshot := range snapshots {
+		key := snapshotKey(snapshot)
+		if current, ok := last[key]; !ok || !snapshot.RecordedAt.Before(current.RecordedAt) {
+			last[key] = snapshot
+		}
+	}
+	result := make([]models.MinecraftStatsSnapshot, 0, len(last))
+	for _, snapshot := range last {
+		result = append(result, snapshot)
+	}
+	return result
+}
+
+// prunableSnapshot is a snapshot older than the ones kept whole
+type prunableSnapshot struct {
+	id         int64
+	key        string
+	recordedAt time.Time
+}
+
+// snapshotsToPrune chooses the snapshots to delete among the ones sorted by player, server and date : those older than
+// keepDailySince (unless it is zero) and all but the last of each day. The last one of each player is always kept, it is
+// the start of the next deltas
+func snapshotsToPrune(snapshots []prunableSnapshot, keepDailySince time.Time) []int64 {
+	var ids []int64
+	for i, snapshot := range snapshots {
+		isLastOfPlayer := i == len(snapshots)-1 || snapshots[i+1].key != snapshot.key
+		if isLastOfPlayer {
+			continue
+		}
+		if !keepDailySince.IsZero() && snapshot.recordedAt.Before(keepDailySince) {
+			ids = append(ids, snapshot.id)
+			continue
+		}
+		if snapshots[i+1].recordedAt.UTC().Format(time.DateOnly) == snapshot.recordedAt.UTC().Format(time.DateOnly) {
+			ids = append(ids, snapshot.id)
+		}
+	}
+	return ids
+}
+
+// SaveMinecraftStatsSnapshot adds the counters of a Minecraft player to the history
+func (r *SQLRepository) SaveMinecraftStatsSnapshot(serverID int, playerUUID string, playerStats models.MinecraftPlayerGameStatistics) error {
+	counters := statsCounters(playerStats)
+	_, err := r.db.Exec(`
+		INSERT INTO joueurs_stats_historique (
+			serveur_id, compte_id, date, tmps_jeux, nb_mort, nb_kills, nb_playerkill,
+			nb_blocs_detr, nb_blocs_pose, dist_total, nb_achievements
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		serverID, playerUUID, r.dialect.timeArg(utcNow()), counters.TimePlayed, counters.Deaths, counters.Kills,
+		counters.PlayerKills, counters.BlocksDestroyed, counters.BlocksPlaced, counters.TotalDistance, counters.Advancements,
+	)
+	if err != nil {
+		return fmt.Errorf("FAILED TO SAVE PLAYER STATISTICS SNAPSHOT: %v", err)
+	}
+	return nil
+}
+
+// getLastSnapshotsAt returns the last snapshot of each player at a date, on a server or on all of them with 0
+func (r *SQLRepository) getLastSnapshotsAt(serverID int, at time.Time) ([]models.MinecraftStatsSnapshot, error) {
+	serverFilter := ""
+	args := []any{r.dialect.timeArg(at)}
+	if serverID != 0 {
+		serverFilter = " AND serveur_id = ?"
+		args = append(args, serverID)
+	}
+	query := `
+		SELECT
+			h.serveur_id, h.compte_id, h.date, h.tmps_jeux, h.nb_mort, h.nb_kills, h.nb_playerkill,
+			h.nb_blocs_detr, h.nb_blocs_pose, h.dist_total, h.nb_achievements
+		FROM joueurs_stats_historique h
+		JOIN (
+			SELECT serveur_id, compte_id, MAX(date) AS date FROM joueurs_stats_historique
+			WHERE date <= ?` + serverFilter + `
+			GROUP BY serveur_id, compte_id
+		) l ON h.serveur_id = l.serveur_id AND h.compte_id = l.compte_id AND h.date = l.date
+	`
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("FAILED TO GET PLAYER STATISTICS SNAPSHOTS: %v", err)
+	}
+	defer rows.Close()
+
+	var snapshots []models.MinecraftStatsSnapshot
+	for rows.Next() {
+		var snapshot models.MinecraftStatsSnapshot
+		var recordedAt nullTime
+		err := rows.Scan(
+			&snapshot.ServerID, &snapshot.PlayerUUID, &recordedAt, &snapshot.TimePlayed, &snapshot.Deaths, &snapshot.Kills,
+			&snapshot.PlayerKills, &snapshot.BlocksDestroyed, &snapshot.BlocksPlaced, &snapshot.TotalDistance, &snapshot.Advancements,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("FAILED TO SCAN PLAYER STATISTICS SNAPSHOT: %v", err)
+		}
+		snapshot.RecordedAt = recordedAt.Time
+		snapshots = append(snapshots, snapshot)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("FAILED TO GET PLAYER STATISTICS SNAPSHOTS: %v", err)
+	}
+	// Two snapshots of a player can have the same date
+	return lastSnapshots(snapshots), nil
+}
+
+// GetMinecraftStatsDeltas returns how much the counters of each player grew between two dates, on a server or on all of
+// them with 0. The players without snapshot during the period are left out
+func (r *SQLRepository) GetMinecraftStatsDeltas(serverID int, from time.Time, to time.Time) ([]models.MinecraftStatsDelta, error) {
+	startSnapshots, err := r.getLastSnapshotsAt(serverID, from)
+	if err != nil {
+		return nil, err
+	}
+	endSnapshots, err := r.getLastSnapshotsAt(serverID, to)
+	if err != nil {
+		return nil, err
+	}
+	return computeDeltas(startSnapshots, endSnapshots, from), nil
+}
+
+// PruneMinecraftStatsSnapshots downsamples the snapshots older than keepAllSince to one per day, and deletes the ones
+// older than keepDailySince unless it is zero. Returns how many snapshots were deleted
+func (r *SQLRepository) PruneMinecraftStatsSnapshots(keepAllSince time.Time, keepDailySince time.Time) (int, error) {
+	rows, err := r.db.Query(`
+		SELECT id, serveur_id, compte_id, date FROM joueurs_stats_historique
+		WHERE date < ? ORDER BY serveur_id, compte_id, date, id
+	`, r.dialect.timeArg(keepAllSince))
+	if err != nil {
+		return 0, fmt.Errorf("FAILED TO GET OLD PLAYER STATISTICS SNAPSHOTS: %v", err)
+	}
+	var snapshots []prunableSnapshot
+	for rows.Next() {
+		var snapshot prunableSnapshot
+		var serverID int
+		var playerUUID string
+		var recordedAt nullTime
+		if err := rows.Scan(&snapshot.id, &serverID, &playerUUID, &recordedAt); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("FAILED TO SCAN PLAYER STATISTICS SNAPSHOT: %v", err)
+		}
+		snapshot.key = fmt.Sprintf("%d/%s", serverID, playerUUID)
+		snapshot.recordedAt = recordedAt.Time
+		snapshots = append(snapshots, snapshot)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("FAILED TO GET OLD PLAYER STATISTICS SNAPSHOTS: %v", err)
+	}
+
+	ids := snapshotsToPrune(snapshots, keepDailySince)
+	deleted := 0
+	for start := 0; start < len(ids); start += pruneBatchSize {
+		batch := ids[start:min(start+pruneBatchSize, len(ids))]
+		args := make([]any, len(batch))
+		for i, id := range batch {
+			args[i] = id
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(batch)), ", ")
+		result, err := r.db.Exec("DELETE FROM joueurs_stats_historique WHERE id IN ("+placeholders+")", args...)
+		if err != nil {
+			return deleted, fmt.Errorf("FAILED TO DELETE OLD PLAYER STATISTICS SNAPSHOTS: %v", err)
+		}
+		count, _ := result.RowsAffected()
+		deleted += int(count)
+	}
+	return deleted, nil
+}
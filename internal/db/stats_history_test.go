@@ -0,0 +1,169 @@
+package db
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Corentin-cott/ServeurSentinel/internal/models"
+)
+
+func TestComputeDeltas(t *testing.T) {
+	from := time.Date(2025, 3, 7, 0, 0, 0, 0, time.UTC)
+	before := from.Add(-time.Hour)
+	during := from.Add(48 * time.Hour)
+	snapshot := func(serverID int, uuid string, at time.Time, timePlayed int, deaths int) models.MinecraftStatsSnapshot {
+		return models.MinecraftStatsSnapshot{
+			ServerID: serverID, PlayerUUID: uuid, RecordedAt: at,
+			MinecraftStatsCounters: models.MinecraftStatsCounters{TimePlayed: timePlayed, Deaths: deaths},
+		}
+	}
+	delta := func(serverID int, uuid string, start time.Time, end time.Time, timePlayed int, deaths int) models.MinecraftStatsDelta {
+		return models.MinecraftStatsDelta{
+			ServerID: serverID, PlayerUUID: uuid, From: start, To: end,
+			MinecraftStatsCounters: models.MinecraftStatsCounters{TimePlayed: timePlayed, Deaths: deaths},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		starts []models.MinecraftStatsSnapshot
+		ends   []models.MinecraftStatsSnapshot
+		want   []models.MinecraftStatsDelta
+	}{
+		{"nothing", nil, nil, []models.MinecraftStatsDelta{}},
+		{
+			"grown counters",
+			[]models.MinecraftStatsSnapshot{snapshot(1, "a", before, 100, 2)},
+			[]models.MinecraftStatsSnapshot{snapshot(1, "a", during, 250, 5)},
+			[]models.MinecraftStatsDelta{delta(1, "a", before, during, 150, 3)},
+		},
+		{
+			"no snapshot during the period",
+			[]models.MinecraftStatsSnapshot{snapshot(1, "a", before, 100, 2)},
+			[]models.MinecraftStatsSnapshot{snapshot(1, "a", before, 100, 2)},
+			[]models.MinecraftStatsDelta{},
+		},
+		{
+			"snapshot exactly at the start",
+			nil,
+			[]models.MinecraftStatsSnapshot{snapshot(1, "a", from, 100, 2)},
+			[]models.MinecraftStatsDelta{},
+		},
+		{
+			"first seen during the period",
+			nil,
+			[]models.MinecraftStatsSnapshot{snapshot(1, "a", during, 250, 5)},
+			[]models.MinecraftStatsDelta{delta(1, "a", time.Time{}, during, 250, 5)},
+		},
+		{
+			"world reset",
+			[]models.MinecraftStatsSnapshot{snapshot(1, "a", before, 1000, 9)},
+			[]models.MinecraftStatsSnapshot{snapshot(1, "a", during, 40, 1)},
+			[]models.MinecraftStatsDelta{delta(1, "a", time.Time{}, during, 40, 1)},
+		},
+		{
+			"counter going down alone",
+			[]models.MinecraftStatsSnapshot{snapshot(1, "a", before, 100, 9)},
+			[]models.MinecraftStatsSnapshot{snapshot(1, "a", during, 150, 4)},
+			[]models.MinecraftStatsDelta{delta(1, "a", before, during, 50, 0)},
+		},
+		{
+			"same player on two servers",
+			[]models.MinecraftStatsSnapshot{snapshot(1, "a", before, 100, 0), snapshot(2, "a", before, 500, 0)},
+			[]models.MinecraftStatsSnapshot{snapshot(1, "a", during, 200, 0), snapshot(2, "a", during, 550, 0)},
+			[]models.MinecraftStatsDelta{delta(1, "a", before, during, 100, 0), delta(2, "a", before, during, 50, 0)},
+		},
+		{
+			"most active first, then by server and player",
+			nil,
+			[]models.MinecraftStatsSnapshot{
+				snapshot(2, "b", during, 10, 0), snapshot(1, "c", during, 10, 0),
+				snapshot(1, "b", during, 10, 0), snapshot(1, "a", during, 99, 0),
+			},
+			[]models.MinecraftStatsDelta{
+				delta(1, "a", time.Time{}, during, 99, 0), delta(1, "b", time.Time{}, during, 10, 0),
+				delta(1, "c", time.Time{}, during, 10, 0), delta(2, "b", time.Time{}, during, 10, 0),
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := computeDeltas(test.starts, test.ends, from); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("computeDeltas() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSnapshotsToPrune(t *testing.T) {
+	keepDailySince := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	day := func(month time.Month, day int, hour int) time.Time {
+		return time.Date(2025, month, day, hour, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name           string
+		snapshots      []prunableSnapshot
+		keepDailySince time.Time
+		want           []int64
+	}{
+		{"nothing", nil, keepDailySince, nil},
+		{
+			"last of the player kept even when too old",
+			[]prunableSnapshot{{1, "1/a", time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)}},
+			keepDailySince, nil,
+		},
+		{
+			"last of each day kept",
+			[]prunableSnapshot{
+				{1, "1/a", day(3, 1, 8)}, {2, "1/a", day(3, 1, 12)}, {3, "1/a", day(3, 1, 20)},
+				{4, "1/a", day(3, 2, 8)}, {5, "1/a", day(3, 2, 9)},
+			},
+			keepDailySince, []int64{1, 2, 4},
+		},
+		{
+			"older than keepDaily",
+			[]prunableSnapshot{
+				{1, "1/a", time.Date(2024, 12, 30, 8, 0, 0, 0, time.UTC)},
+				{2, "1/a", time.Date(2024, 12, 31, 8, 0, 0, 0, time.UTC)},
+				{3, "1/a", day(1, 1, 8)},
+			},
+			keepDailySince, []int64{1, 2},
+		},
+		{
+			"keepDaily forever",
+			[]prunableSnapshot{
+				{1, "1/a", time.Date(2024, 12, 30, 8, 0, 0, 0, time.UTC)},
+				{2, "1/a", time.Date(2024, 12, 31, 8, 0, 0, 0, time.UTC)},
+				{3, "1/a", time.Date(2024, 12, 31, 9, 0, 0, 0, time.UTC)},
+			},
+			time.Time{}, []int64{2},
+		},
+		{
+			"players apart",
+			[]prunableSnapshot{
+				{1, "1/a", day(3, 1, 8)}, {2, "1/a", day(3, 1, 9)},
+				{3, "1/b", day(3, 1, 10)},
+				{4, "2/a", day(3, 1, 8)}, {5, "2/a", day(3, 1, 9)},
+			},
+			keepDailySince, []int64{1, 4},
+		},
+		{
+			"days in UTC",
+			[]prunableSnapshot{
+				{1, "1/a", time.Date(2025, 3, 1, 23, 30, 0, 0, time.FixedZone("UTC-2", -2*3600))}, // 2nd of march in UTC
+				{2, "1/a", day(3, 2, 8)},
+			},
+			keepDailySince, []int64{1},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := snapshotsToPrune(test.snapshots, test.keepDailySince); !slices.Equal(got, test.want) {
+				t.Errorf("snapshotsToPrune() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
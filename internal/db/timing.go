@@ -0,0 +1,40 @@
+package db
+
+// This file contains the TIMING of the database queries, for the metrics
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/Corentin-cott/ServeurSentinel/internal/metrics"
+)
+
+// timedDB is a database whose queries are timed, by type of statement
+type timedDB struct {
+	*sql.DB
+}
+
+func (t timedDB) Exec(query string, args ...any) (sql.Result, error) {
+	defer observeQuery(query, time.Now())
+	return t.DB.Exec(query, args...)
+}
+
+func (t timedDB) Query(query string, args ...any) (*sql.Rows, error) {
+	defer observeQuery(query, time.Now())
+	return t.DB.Query(query, args...)
+}
+
+func (t timedDB) QueryRow(query string, args ...any) *sql.Row {
+	defer observeQuery(query, time.Now())
+	return t.DB.QueryRow(query, args...)
+}
+
+// observeQuery records the duration of a query, labelled with its first keyword, ex: "select"
+func observeQuery(query string, start time.Time) {
+	operation := "other"
+	if fields := strings.Fields(query); len(fields) > 0 {
+		operation = strings.ToLower(fields[0])
+	}
+	metrics.DBQueryDuration.ObserveDuration(time.Since(start), operation)
+}
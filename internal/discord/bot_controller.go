@@ -3,16 +3,22 @@ package discord
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/Corentin-cott/ServeurSentinel/internal/metrics"
 	"github.com/Corentin-cott/ServeurSentinel/internal/models"
 )
 
+// HTTPClient sends every request to Discord, its timeout keeps a hung request from blocking the outbox and the shutdown
+var HTTPClient = &http.Client{Timeout: 10 * time.Second}
+
 // SendDiscordMessage() sends a message to a Discord channel
 func SendDiscordMessage(bot models.BotConfig, channelID string, message string) error {
 	if !bot.Activated {
@@ -55,8 +61,9 @@ func SendDiscordMessage(bot models.BotConfig, channelID string, message string)
 	req.Header.Set("Content-Type", "application/json")
 
 	// Finally, send the request
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	start := time.Now()
+	resp, err := HTTPClient.Do(req)
+	metrics.ObserveDiscordRequest(start, resp, err)
 	if err != nil {
 		return fmt.Errorf("ERROR WHILE SENDING MESSAGE TO DISCORD: %v", err)
 	}
@@ -120,8 +127,9 @@ func SendDiscordEmbed(bot models.BotConfig, channelID string, title string, desc
 	req.Header.Set("Authorization", "Bot "+botToken)
 	req.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	start := time.Now()
+	resp, err := HTTPClient.Do(req)
+	metrics.ObserveDiscordRequest(start, resp, err)
 	if err != nil {
 		return fmt.Errorf("ERROR WHILE SENDING EMBED TO DISCORD: %v", err)
 	}
@@ -141,22 +149,53 @@ func SendDiscordEmbedWithModel(bot models.BotConfig, channelID string, embed mod
 		// fmt.Println("Bot " + bot.BotToken + " is not activated")
 		return nil // If the bot is not activated, we don't send the message
 	}
+	_, err := PostDiscordEmbed(bot, channelID, embed)
+	return err
+}
+
+// ErrDiscordMessageNotFound is returned when editing a message that was deleted
+var ErrDiscordMessageNotFound = errors.New("DISCORD MESSAGE NOT FOUND")
+
+// PostDiscordEmbed sends an embed and returns the ID of the message, so it can be edited later
+func PostDiscordEmbed(bot models.BotConfig, channelID string, embed models.EmbedConfig) (string, error) {
+	apiURL := fmt.Sprintf("https://discord.com/api/v10/channels/%s/messages", channelID)
+	body, err := sendEmbedRequest(bot, channelID, "POST", apiURL, embed)
+	if err != nil {
+		return "", err
+	}
+	var message struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(body, &message); err != nil {
+		return "", fmt.Errorf("ERROR WHILE DECODING DISCORD MESSAGE: %v", err)
+	}
+	return message.ID, nil
+}
+
+// EditDiscordEmbed replaces the embed of a message sent by the bot, ErrDiscordMessageNotFound if it was deleted
+func EditDiscordEmbed(bot models.BotConfig, channelID string, messageID string, embed models.EmbedConfig) error {
+	apiURL := fmt.Sprintf("https://discord.com/api/v10/channels/%s/messages/%s", channelID, messageID)
+	_, err := sendEmbedRequest(bot, channelID, "PATCH", apiURL, embed)
+	return err
+}
 
+// sendEmbedRequest sends an embed to the Discord API and returns the body of the response
+func sendEmbedRequest(bot models.BotConfig, channelID string, method string, apiURL string, embed models.EmbedConfig) ([]byte, error) {
 	// Check required parameters
 	botToken := bot.BotToken
 	switch {
 	case botToken == "" && channelID == "":
-		return fmt.Errorf("ERROR: BOT TOKEN AND CHANNEL ID NOT SET")
+		return nil, fmt.Errorf("ERROR: BOT TOKEN AND CHANNEL ID NOT SET")
 	case botToken == "":
-		return fmt.Errorf("ERROR: BOT TOKEN NOT SET")
+		return nil, fmt.Errorf("ERROR: BOT TOKEN NOT SET")
 	case channelID == "":
-		return fmt.Errorf("ERROR: CHANNEL ID NOT SET")
+		return nil, fmt.Errorf("ERROR: CHANNEL ID NOT SET")
 	}
 
 	// Convert hex color to integer
 	colorInt, err := strconv.ParseInt(strings.TrimPrefix(embed.Color, "#"), 16, 32)
 	if err != nil {
-		return fmt.Errorf("ERROR: INVALID COLOR FORMAT: %v", err)
+		return nil, fmt.Errorf("ERROR: INVALID COLOR FORMAT: %v", err)
 	}
 
 	// Timestamp
@@ -196,31 +235,100 @@ func SendDiscordEmbedWithModel(bot models.BotConfig, channelID string, embed mod
 
 	payloadBytes, err := json.Marshal(payload)
 	if err != nil {
-		return fmt.Errorf("ERROR WHILE SERIALIZING DISCORD EMBED: %v", err)
+		return nil, fmt.Errorf("ERROR WHILE SERIALIZING DISCORD EMBED: %v", err)
 	}
 
 	// Create and send the request
-	apiURL := fmt.Sprintf("https://discord.com/api/v10/channels/%s/messages", channelID)
-	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(payloadBytes))
+	req, err := http.NewRequest(method, apiURL, bytes.NewBuffer(payloadBytes))
 	if err != nil {
-		return fmt.Errorf("ERROR WHILE CREATING REQUEST TO DISCORD: %v", err)
+		return nil, fmt.Errorf("ERROR WHILE CREATING REQUEST TO DISCORD: %v", err)
 	}
 
 	req.Header.Set("Authorization", "Bot "+botToken)
 	req.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	start := time.Now()
+	resp, err := HTTPClient.Do(req)
+	metrics.ObserveDiscordRequest(start, resp, err)
 	if err != nil {
-		return fmt.Errorf("ERROR WHILE SENDING EMBED TO DISCORD : %v", err)
+		return nil, fmt.Errorf("ERROR WHILE SENDING EMBED TO DISCORD : %v", err)
 	}
 	defer resp.Body.Close()
 
 	// Check response
 	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode == http.StatusNotFound && method == "PATCH" {
+		return nil, ErrDiscordMessageNotFound
+	}
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
-		return fmt.Errorf("ERROR WHILE SENDING EMBED TO DISCORD, RESPONSE STATUS: %v, RESPONSE BODY: %s", resp.Status, string(body))
+		return nil, fmt.Errorf("ERROR WHILE SENDING EMBED TO DISCORD, RESPONSE STATUS: %v, RESPONSE BODY: %s", resp.Status, string(body))
 	}
 
-	return nil
+	return body, nil
+}
+
+// GetDiscordChannelMessages reads the messages of a channel sent after a message ID, the oldest first. Without ID the last 50 are read
+func GetDiscordChannelMessages(bot models.BotConfig, channelID string, afterID string) ([]models.DiscordMessage, error) {
+	if !bot.Activated {
+		return nil, nil // If the bot is not activated, there is nothing to read
+	}
+	if bot.BotToken == "" || channelID == "" {
+		return nil, fmt.Errorf("ERROR: BOT TOKEN OR CHANNEL ID NOT SET")
+	}
+
+	apiURL := fmt.Sprintf("https://discord.com/api/v10/channels/%s/messages?limit=50", channelID)
+	if afterID != "" {
+		apiURL += "&after=" + afterID
+	}
+	req, err := http.NewRequest("GET", apiURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("ERROR WHILE CREATING REQUEST TO DISCORD: %v", err)
+	}
+	req.Header.Set("Authorization", "Bot "+bot.BotToken)
+
+	start := time.Now()
+	resp, err := HTTPClient.Do(req)
+	metrics.ObserveDiscordRequest(start, resp, err)
+	if err != nil {
+		return nil, fmt.Errorf("ERROR WHILE READING MESSAGES FROM DISCORD: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ERROR WHILE READING MESSAGES FROM DISCORD, RESPONSE STATUS: %v, RESPONSE BODY: %s", resp.Status, string(body))
+	}
+
+	var rawMessages []struct {
+		ID      string `json:"id"`
+		Content string `json:"content"`
+		Author  struct {
+			ID       string `json:"id"`
+			Username string `json:"username"`
+			Bot      bool   `json:"bot"`
+		} `json:"author"`
+	}
+	if err := json.Unmarshal(body, &rawMessages); err != nil {
+		return nil, fmt.Errorf("ERROR WHILE DECODING DISCORD MESSAGES: %v", err)
+	}
+
+	messages := make([]models.DiscordMessage, 0, len(rawMessages))
+	for _, raw := range rawMessages {
+		messages = append(messages, models.DiscordMessage{
+			ID:         raw.ID,
+			Content:    raw.Content,
+			AuthorID:   raw.Author.ID,
+			AuthorName: raw.Author.Username,
+			AuthorBot:  raw.Author.Bot,
+		})
+	}
+
+	// Discord sends the newest first, the IDs are snowflakes so a longer ID is a newer message
+	sort.Slice(messages, func(i, j int) bool {
+		if len(messages[i].ID) != len(messages[j].ID) {
+			return len(messages[i].ID) < len(messages[j].ID)
+		}
+		return messages[i].ID < messages[j].ID
+	})
+	return messages, nil
 }
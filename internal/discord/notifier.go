@@ -0,0 +1,71 @@
+package discord
+
+// This file contains the NOTIFIERS, what the triggers and the tasks use to send their embeds without knowing how
+
+import (
+	"sync"
+
+	"github.com/Corentin-cott/ServeurSentinel/internal/models"
+)
+
+// Notifier sends embeds with a bot in a channel, see SendDiscordEmbed and SendDiscordEmbedWithModel
+type Notifier interface {
+	SendEmbed(bot models.BotConfig, channelID string, title string, description string, color string) error
+	SendEmbedWithModel(bot models.BotConfig, channelID string, embed models.EmbedConfig) error
+}
+
+// DirectNotifier sends the embeds right away, the caller waits for Discord
+type DirectNotifier struct{}
+
+func (DirectNotifier) SendEmbed(bot models.BotConfig, channelID string, title string, description string, color string) error {
+	return SendDiscordEmbed(bot, channelID, title, description, color)
+}
+
+func (DirectNotifier) SendEmbedWithModel(bot models.BotConfig, channelID string, embed models.EmbedConfig) error {
+	return SendDiscordEmbedWithModel(bot, channelID, embed)
+}
+
+// OutboxNotifier queues the embeds in the outbox, errors are printed by the outbox
+type OutboxNotifier struct{}
+
+func (OutboxNotifier) SendEmbed(bot models.BotConfig, channelID string, title string, description string, color string) error {
+	QueueDiscordEmbed(bot, channelID, title, description, color)
+	return nil
+}
+
+func (OutboxNotifier) SendEmbedWithModel(bot models.BotConfig, channelID string, embed models.EmbedConfig) error {
+	QueueDiscordEmbedWithModel(bot, channelID, embed)
+	return nil
+}
+
+// SentEmbed is an embed received by a RecordingNotifier, the simple embeds only have a title, a description and a color
+type SentEmbed struct {
+	Bot       models.BotConfig
+	ChannelID string
+	Embed     models.EmbedConfig
+}
+
+// RecordingNotifier keeps the embeds instead of sending them, for the tests
+type RecordingNotifier struct {
+	mu   sync.Mutex
+	sent []SentEmbed
+	Err  error // Returned by SendEmbed when set
+}
+
+func (n *RecordingNotifier) SendEmbed(bot models.BotConfig, channelID string, title string, description string, color string) error {
+	return n.SendEmbedWithModel(bot, channelID, models.EmbedConfig{Title: title, Description: description, Color: color})
+}
+
+func (n *RecordingNotifier) SendEmbedWithModel(bot models.BotConfig, channelID string, embed models.EmbedConfig) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.sent = append(n.sent, SentEmbed{Bot: bot, ChannelID: channelID, Embed: embed})
+	return n.Err
+}
+
+// Sent returns the embeds received so far, in order
+func (n *RecordingNotifier) Sent() []SentEmbed {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]SentEmbed(nil), n.sent...)
+}
@@ -0,0 +1,89 @@
+package discord
+
+// This file contains the OUTBOX, a queue of messages sent in the background so reading the server logs is never slowed down by Discord
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/Corentin-cott/ServeurSentinel/internal/logging"
+	"github.com/Corentin-cott/ServeurSentinel/internal/models"
+)
+
+var logger = logging.For("discord")
+
+// outboxMessage is a message waiting to be sent
+type outboxMessage struct {
+	description string
+	send        func() error
+}
+
+// Size of the queue, when full the messages are sent directly by the caller
+const outboxSize = 512
+
+var outbox = make(chan outboxMessage, outboxSize)
+var outboxRunning atomic.Bool
+
+// Queue adds a message to the outbox. If the outbox is not running (CLI commands), the message is sent right away
+func Queue(description string, send func() error) {
+	if outboxRunning.Load() {
+		select {
+		case outbox <- outboxMessage{description: description, send: send}:
+			return
+		default:
+			logger.Warn("Discord outbox is full, the message is sent directly", "message", description)
+		}
+	}
+	sendOutboxMessage(outboxMessage{description: description, send: send})
+}
+
+// QueueDiscordEmbed queues an embed, see SendDiscordEmbed
+func QueueDiscordEmbed(bot models.BotConfig, channelID string, title string, description string, color string) {
+	Queue("embed \""+title+"\"", func() error {
+		return SendDiscordEmbed(bot, channelID, title, description, color)
+	})
+}
+
+// QueueDiscordEmbedWithModel queues an embed, see SendDiscordEmbedWithModel
+func QueueDiscordEmbedWithModel(bot models.BotConfig, channelID string, embed models.EmbedConfig) {
+	Queue("embed \""+embed.Title+"\"", func() error {
+		return SendDiscordEmbedWithModel(bot, channelID, embed)
+	})
+}
+
+// RunOutbox sends the queued messages one by one, in order, until the context is cancelled
+func RunOutbox(ctx context.Context) {
+	outboxRunning.Store(true)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case message := <-outbox:
+			sendOutboxMessage(message)
+		}
+	}
+}
+
+// FlushOutbox sends the messages still in the queue, it gives up after the timeout and returns how many messages were dropped
+func FlushOutbox(timeout time.Duration) int {
+	outboxRunning.Store(false)
+	deadline := time.Now().Add(timeout)
+	for {
+		select {
+		case message := <-outbox:
+			if time.Now().After(deadline) {
+				return len(outbox) + 1
+			}
+			sendOutboxMessage(message)
+		default:
+			return 0
+		}
+	}
+}
+
+func sendOutboxMessage(message outboxMessage) {
+	if err := message.send(); err != nil {
+		logger.Error("Discord message not sent", "message", message.description, logging.Err(err))
+	}
+}
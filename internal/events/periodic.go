@@ -1,42 +1,44 @@
 package periodic
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"time"
 
 	"github.com/Corentin-cott/ServeurSentinel/config"
+	"github.com/Corentin-cott/ServeurSentinel/internal/audit"
+	"github.com/Corentin-cott/ServeurSentinel/internal/backup"
 	"github.com/Corentin-cott/ServeurSentinel/internal/db"
 	"github.com/Corentin-cott/ServeurSentinel/internal/discord"
+	"github.com/Corentin-cott/ServeurSentinel/internal/leaderboards"
+	"github.com/Corentin-cott/ServeurSentinel/internal/logging"
+	"github.com/Corentin-cott/ServeurSentinel/internal/models"
+	"github.com/Corentin-cott/ServeurSentinel/internal/reports"
 	"github.com/Corentin-cott/ServeurSentinel/internal/services"
 	"github.com/Corentin-cott/ServeurSentinel/internal/tmux"
 )
 
-// Var for the colors of the Discord embeds
-var goodColor = "#9adfba"
-var mehColor = "#ff8c00"
-var badColor = "#ff0000"
-
-// Task to run periodically
-func Task() {
-	fmt.Println("♟ Periodic task executed at", time.Now().Format("02/01/2006 15:04:05"))
-}
+// Colors of the Discord embeds, taken from the configuration
+func goodColor() string { return config.Get().EmbedColors.Good }
+func mehColor() string  { return config.Get().EmbedColors.Warning }
+func badColor() string  { return config.Get().EmbedColors.Error }
 
 // Task : Server check
-func TaskServerCheck() {
+func TaskServerCheck(ctx context.Context) error {
 	// Check if the right tmux servers are running
-	color := goodColor
-	message, err := tmux.CheckRunningServers()
-	if err != nil {
+	color := goodColor()
+	message, checkErr := tmux.CheckRunningServers(audit.Scheduler(TaskNameServersCheck))
+	if checkErr != nil {
 		// If an error occurs, we change the color to red
-		color = badColor
-		fmt.Println(err)
+		color = badColor()
+		logger.Error("Servers not checked", logging.Err(checkErr))
 	} else {
 		// If the message contains "✘", we change the color to orange (cause it means a server wasn't supposed to be running)
 		if message[:3] == "✘" {
-			color = mehColor
+			color = mehColor()
 		}
-		fmt.Println("♟ Actions : " + message)
+		logger.Info("Servers checked", "result", message)
 	}
 
 	// Replace the "✔" and "✘" emojis with "\n✔" and "\n✘" for a better display in the Discord embed
@@ -44,7 +46,7 @@ func TaskServerCheck() {
 	message = strings.ReplaceAll(message, "✘", "\n✘")
 	tmuxSessions, err := tmux.GetTmuxSessions()
 	if err != nil {
-		fmt.Println(err)
+		logger.Error("Tmux sessions not listed", logging.Err(err))
 	} else {
 		// For each opened tmux session, we add \n- before the name
 		message += "\n\nCurently opened serveurs:"
@@ -54,28 +56,54 @@ func TaskServerCheck() {
 		message += fmt.Sprintf("\n%d opened sessions.", len(tmuxSessions))
 	}
 
-	err = discord.SendDiscordEmbed(config.AppConfig.Bots["mineotterBot"], config.AppConfig.DiscordChannels.ServerStatusChannelID, "♟ Serveur periodic check", message, color)
+	err = discord.SendDiscordEmbed(config.Get().Bots["mineotterBot"], config.Get().DiscordChannels.ServerStatusChannelID, "♟ Serveur periodic check", message, color)
+	if err != nil {
+		logger.Error("Discord message not sent", logging.Err(err))
+	}
+	return checkErr
+}
+
+// Task : Server events older than the configuration says deleted, the reports don't need them anymore
+func TaskPruneEvents(ctx context.Context) error {
+	keepEvents, err := time.ParseDuration(config.Get().Report.KeepEvents)
+	if err != nil {
+		return fmt.Errorf("INVALID SERVER EVENTS DURATION: %v", err)
+	}
+	if keepEvents == 0 {
+		return nil
+	}
+
+	deleted, err := db.PruneServerEvents(time.Now().UTC().Add(-keepEvents))
 	if err != nil {
-		fmt.Println(err)
+		return err
+	}
+	if deleted > 0 {
+		logger.Info("Old server events removed", "events", deleted)
 	}
+	return nil
 }
 
 // Task : Minecraft statistics update
-func TaskMinecraftStatsUpdate() {
-	fmt.Println("Saving Minecraft players game statistics...")
+func TaskMinecraftStatsUpdate(ctx context.Context) error {
+	logger.Info("Saving the Minecraft statistics of the players")
 	serverList, err := db.GetAllMinecraftServers()
 	if err != nil {
-		fmt.Println("✘ Error while getting the Minecraft servers list " + err.Error())
+		return fmt.Errorf("ERROR WHILE GETTING THE MINECRAFT SERVERS LIST: %v", err)
 	}
 
 	nbPlayerSaves := 0
 	nbPlayerSavesFailed := 0
 	serverThatFailedSavesList := make([]string, 0)
 	for _, server := range serverList {
-		fmt.Println("*-*-*-*-*-*-*-*-*-*-*-*-*-*-*-*-* Server " + server.Nom + " *-*-*-*-*-*-*-*-*-*-*-*-*-*-*-*-*")
+		// Stop between two servers if the daemon is shutting down, the next run will save the rest
+		if ctx.Err() != nil {
+			return fmt.Errorf("MINECRAFT STATISTICS UPDATE INTERRUPTED: %v", ctx.Err())
+		}
+		serverLogger := logger.With("server_id", server.ID, "server", server.Nom)
+		serverLogger.Debug("Saving the Minecraft statistics of a server")
 		playerUUIDList, err := services.GetMinecraftPlayerServerUUIDSaves(server)
 		if err != nil {
-			fmt.Println("✘ Error while getting the Minecraft players list " + err.Error())
+			serverLogger.Error("Minecraft players list not read", logging.Err(err))
 			serverThatFailedSavesList = append(serverThatFailedSavesList, server.Nom)
 		}
 
@@ -84,31 +112,31 @@ func TaskMinecraftStatsUpdate() {
 
 			isValid, err := services.IsValidMinecraftUUID(playerUUID)
 			if err != nil {
-				fmt.Println("✘ Error while validating Minecraft UUID:", err)
+				serverLogger.Error("Minecraft UUID not validated", "player_uuid", playerUUID, logging.Err(err))
 				continue
 			}
 			if !isValid {
-				fmt.Println("✘ Invalid Minecraft UUID : " + playerUUID + " for server " + server.Nom)
+				serverLogger.Warn("Invalid Minecraft UUID", "player_uuid", playerUUID)
 				nbPlayerSavesFailed++
 
 				// Since the UUID is invalid, we skip the player and continue to the next one
 				continue
 			}
 
-			fmt.Println("------------------------ Player " + playerUUID + " ------------------------")
+			playerLogger := serverLogger.With("player_uuid", playerUUID)
 			_, err = db.CheckAndInsertPlayerWithPlayerUUID(playerUUID, 1, "nil") // 1 is the ID of the server "La Vanilla", wich will put Minecraft as the game. Not a good practice, but it's a quick fix cause i'm tired.
 			if err != nil {
-				fmt.Println("✘ Error while checking or inserting the player " + playerUUID + " " + err.Error())
+				playerLogger.Error("Player not saved", logging.Err(err))
 				nbPlayerSavesFailed++
-				return
+				return err
 			}
 
 			player, err := db.GetPlayerByUUID(playerUUID)
 			if player.UtilisateurID == -1 {
-				fmt.Println("Player " + playerUUID + " doesn't have a user account linked. This is not a problem.")
+				playerLogger.Debug("Player not linked to a user account")
 			}
 			if err != nil {
-				fmt.Println(err)
+				playerLogger.Error("Player not found", logging.Err(err))
 			}
 
 			playerID := player.ID
@@ -116,86 +144,222 @@ func TaskMinecraftStatsUpdate() {
 
 			_, _, playerStats, error := services.GetMinecraftPlayerGameStatistics(playerID, playerUUID, server)
 			if error != nil {
-				fmt.Println(error)
+				playerLogger.Error("Minecraft statistics not read", logging.Err(error))
 			}
 
 			if db.CheckMinecraftPlayerGameStatisticsExists(playerUUID, server.ID) {
-				fmt.Println("Player " + playerUUID + " statistics already exists, they will be updated.")
+				playerLogger.Debug("Updating the Minecraft statistics")
 				err := db.UpdateMinecraftPlayerGameStatistics(server.ID, playerUUID, playerStats)
 				if err != nil {
 					nbPlayerSavesFailed++
-					fmt.Println(err)
+					playerLogger.Error("Minecraft statistics not updated", logging.Err(err))
 				}
 			} else {
-				fmt.Println("Player " + playerUUID + " statistics doesn't exist, they will be created.")
+				playerLogger.Debug("Creating the Minecraft statistics")
 				err := db.SaveMinecraftPlayerGameStatistics(server.ID, playerUUID, playerStats)
 				if err != nil {
 					nbPlayerSavesFailed++
-					fmt.Println(err)
+					playerLogger.Error("Minecraft statistics not saved", logging.Err(err))
 				}
 			}
 
-			if err != nil {
-				fmt.Println(err)
+			// The history only gets the statistics actually read, an empty snapshot would look like a world reset
+			if error == nil {
+				if err := db.SaveMinecraftStatsSnapshot(server.ID, playerUUID, playerStats); err != nil {
+					playerLogger.Error("Statistics snapshot not saved", logging.Err(err))
+				}
 			}
 		}
 	}
-	fmt.Println("*-*-*-*-*-*-*-*-* ✔ Minecraft players stats are saved *-*-*-*-*-*-*-*-*")
+	logger.Info("Minecraft statistics saved", "players", nbPlayerSaves, "failed", nbPlayerSavesFailed)
+
+	if err := pruneStatsHistory(); err != nil {
+		logger.Error("Statistics history not pruned", logging.Err(err))
+	}
 
 	var serverThatFailedSavesListString string
 	var color string
 	if len(serverThatFailedSavesList) > 0 {
 		serverThatFailedSavesListString = "✘ Servers that failed to give player saves list : " + strings.Join(serverThatFailedSavesList, ", ")
-		color = badColor
+		color = badColor()
 	} else {
 		serverThatFailedSavesListString = "✔ No server failed to give player saves list."
-		color = goodColor
+		color = goodColor()
 	}
 
 	if nbPlayerSavesFailed > 0 {
-		color = mehColor
+		color = mehColor()
 	}
 
-	err = discord.SendDiscordEmbed(config.AppConfig.Bots["mineotterBot"], config.AppConfig.DiscordChannels.ServerStatusChannelID,
+	err = discord.SendDiscordEmbed(config.Get().Bots["mineotterBot"], config.Get().DiscordChannels.ServerStatusChannelID,
 		"♟ Minecraft statistics update", "Minecraft players stats are saved.\n\n"+
 			fmt.Sprint(nbPlayerSaves)+" players stats updated from "+fmt.Sprint(len(serverList))+" servers.\n"+
 			"Failed to save stats for "+fmt.Sprint(nbPlayerSavesFailed)+" players.\n\n"+
 			serverThatFailedSavesListString, color)
 
 	if err != nil {
-		fmt.Println("✘ Error while sending the Discord message " + err.Error())
+		logger.Error("Discord message not sent", logging.Err(err))
 	}
+	return nil
 }
 
-// Start the periodic task
-func StartPeriodicTask(PeriodicEventsMin int) error {
-	if PeriodicEventsMin <= 0 {
-		return fmt.Errorf("ERROR: PERIODIC EVENTS MINUTES MUST BE GREATER THAN 0, CURRENTLY %d", PeriodicEventsMin)
+// pruneStatsHistory downsamples the old snapshots of the statistics, as long as the configuration says
+func pruneStatsHistory() error {
+	history := config.Get().StatsHistory
+	keepAll, err := time.ParseDuration(history.KeepAll)
+	if err != nil {
+		return fmt.Errorf("INVALID STATISTICS HISTORY DURATION: %v", err)
+	}
+	keepDaily, err := time.ParseDuration(history.KeepDaily)
+	if err != nil {
+		return fmt.Errorf("INVALID STATISTICS HISTORY DURATION: %v", err)
 	}
 
-	interval := time.Duration(PeriodicEventsMin) * time.Minute
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
+	now := time.Now()
+	keepDailySince := time.Time{}
+	if keepDaily > 0 {
+		keepDailySince = now.Add(-keepDaily)
+	}
+	deleted, err := db.PruneMinecraftStatsSnapshots(now.Add(-keepAll), keepDailySince)
+	if err != nil {
+		return err
+	}
+	if deleted > 0 {
+		logger.Info("Old statistics snapshots removed", "snapshots", deleted)
+	}
+	return nil
+}
 
-	for range ticker.C {
-		// Execute the periodic task : Log the time and send a message to Discord
-		Task()
-		discord.SendDiscordEmbed(config.AppConfig.Bots["mineotterBot"], config.AppConfig.DiscordChannels.ServerStatusChannelID, "♟ "+time.Now().Format("02/01/2006 15:04:05"), "Periodic task executed.", goodColor)
+// Task : World backups of the servers that are supposed to be running
+func TaskBackups(ctx context.Context) error {
+	serverIDs := []int{db.GetPrimaryServerId(), db.GetSecondaryServerId(), db.GetPartenariatServerId()}
 
-		// Check if the right tmux servers are running
-		if config.AppConfig.PeriodicEvents.ServersCheckEnabled {
-			TaskServerCheck()
-		} else {
-			fmt.Println("♟ Server check is disabled.")
+	var report strings.Builder
+	color := goodColor()
+	done := make(map[int]bool)
+	for _, serverID := range serverIDs {
+		if serverID == -1 || done[serverID] {
+			continue
 		}
+		// A backup in progress is finished, but no new one is started if the daemon is shutting down
+		if ctx.Err() != nil {
+			fmt.Fprintf(&report, "✘ Server %d : backup cancelled, the daemon is shutting down\n", serverID)
+			color = badColor()
+			break
+		}
+		done[serverID] = true
 
-		// Get the minecraft player game statistics
-		if config.AppConfig.PeriodicEvents.MinecraftStatsEnabled {
-			TaskMinecraftStatsUpdate()
-		} else {
-			fmt.Println("♟ Minecraft statistics update is disabled.")
+		server, err := db.GetServerById(serverID)
+		if err != nil {
+			fmt.Fprintf(&report, "✘ Server %d : %v\n", serverID, err)
+			color = badColor()
+			continue
+		}
+
+		resumeWorldSaves := pauseWorldSaves(ctx, server)
+		b, err := backup.CreateBackup(server)
+		resumeWorldSaves()
+		if err != nil {
+			fmt.Fprintf(&report, "✘ %s : %v\n", server.Nom, err)
+			color = badColor()
+			continue
+		}
+		fmt.Fprintf(&report, "✔ %s : %s\n", server.Nom, b.ID)
+
+		if config.Get().Backups.Keep > 0 {
+			removed, err := backup.PruneBackups(server, config.Get().Backups.Keep)
+			if err != nil {
+				fmt.Fprintf(&report, "✘ %s : %v\n", server.Nom, err)
+				color = mehColor()
+			} else if removed > 0 {
+				fmt.Fprintf(&report, "  %d old backups removed\n", removed)
+			}
 		}
 	}
 
+	err := discord.SendDiscordEmbed(config.Get().Bots["mineotterBot"], config.Get().DiscordChannels.ServerStatusChannelID, "♟ Sauvegardes des mondes", report.String(), color)
+	if err != nil {
+		logger.Error("Discord message not sent", logging.Err(err))
+	}
+	if color == badColor() {
+		return fmt.Errorf("SOME BACKUPS FAILED:\n%s", report.String())
+	}
 	return nil
 }
+
+// How long a Minecraft server is given to write its world before it is archived
+var worldSaveDelay = 10 * time.Second
+
+// pauseWorldSaves stops a running Minecraft server from writing its world and gives it the time to flush it, so the archive
+// is consistent. The returned function turns the saves back on, it must be called once the archive is written
+func pauseWorldSaves(ctx context.Context, server models.Server) func() {
+	if server.Jeu != "Minecraft" {
+		return func() {}
+	}
+	if isRunning, err := tmux.IsServerRunning(server.Nom); err != nil || !isRunning {
+		return func() {}
+	}
+	serverLogger := logger.With("server_id", server.ID, "server", server.Nom)
+
+	resume := func() {
+		if err := tmux.SendCommandToServer(server.Nom, "save-on"); err != nil {
+			serverLogger.Error("World saves not turned back on after the backup", logging.Err(err))
+		}
+	}
+	if err := tmux.SendCommandToServer(server.Nom, "save-off"); err != nil {
+		serverLogger.Error("World saves not turned off before the backup", logging.Err(err))
+		return resume
+	}
+	if err := tmux.SendCommandToServer(server.Nom, "save-all flush"); err != nil {
+		serverLogger.Error("World not saved before the backup", logging.Err(err))
+		return resume
+	}
+	// A shutdown doesn't wait for the whole delay, the world is archived as it is
+	select {
+	case <-ctx.Done():
+	case <-time.After(worldSaveDelay):
+	}
+	return resume
+}
+
+// Task : Leaderboards posted or updated on Discord
+func TaskLeaderboards(ctx context.Context) error {
+	return leaderboards.PublishAll()
+}
+
+// Task : Activity report of the period, posted on Discord and exported
+func TaskWeeklyReport(ctx context.Context) error {
+	return reports.Publish()
+}
+
+// Names of the scheduled tasks, also used as keys in the "scheduler.tasks" configuration
+const (
+	TaskNameServersCheck   = "serversCheck"
+	TaskNameMinecraftStats = "minecraftStats"
+	TaskNameBackups        = "backups"
+	TaskNameLeaderboards   = "leaderboards"
+	TaskNameWeeklyReport   = "weeklyReport"
+	TaskNamePruneEvents    = "pruneEvents"
+)
+
+// RegisterTasks registers every periodic task in the scheduler with its configuration
+func RegisterTasks(scheduler *Scheduler, conf *config.Config) error {
+	tasks := []struct {
+		name string
+		run  func(ctx context.Context) error
+	}{
+		{TaskNameServersCheck, TaskServerCheck},
+		{TaskNameMinecraftStats, TaskMinecraftStatsUpdate},
+		{TaskNameBackups, TaskBackups},
+		{TaskNameLeaderboards, TaskLeaderboards},
+		{TaskNameWeeklyReport, TaskWeeklyReport},
+		{TaskNamePruneEvents, TaskPruneEvents},
+	}
+
+	for _, task := range tasks {
+		if err := scheduler.Register(task.name, GetTaskConfig(conf, task.name), task.run); err != nil {
+			return err
+		}
+	}
+	return RegisterRestartTasks(scheduler, conf)
+}
@@ -0,0 +1,299 @@
+package periodic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Corentin-cott/ServeurSentinel/config"
+	"github.com/Corentin-cott/ServeurSentinel/internal/audit"
+	"github.com/Corentin-cott/ServeurSentinel/internal/db"
+	"github.com/Corentin-cott/ServeurSentinel/internal/discord"
+	"github.com/Corentin-cott/ServeurSentinel/internal/logging"
+	"github.com/Corentin-cott/ServeurSentinel/internal/models"
+	"github.com/Corentin-cott/ServeurSentinel/internal/presence"
+	"github.com/Corentin-cott/ServeurSentinel/internal/tmux"
+)
+
+// Warnings sent before a restart when none are configured
+var defaultRestartWarnings = []string{"10m", "5m", "1m", "10s"}
+
+// How often we check if the players left when a restart is delayed
+var restartDelayCheckInterval = time.Minute
+
+// How long we wait for the tmux session of a server to close before giving up on its restart
+var sessionCloseTimeout = 30 * time.Second
+
+// Prefix of the restart tasks names, followed by the server ID
+const taskNameRestartPrefix = "restart-"
+
+// ErrRestartInProgress is returned when a server is restarted while it already is, ex: by its task and by an operator
+var ErrRestartInProgress = errors.New("SERVER IS ALREADY RESTARTING")
+
+var restarting sync.Map // Server ID -> true while a restart runs, whoever asked for it
+
+// IsRestarting tells if a restart of the server is running
+func IsRestarting(serverID int) bool {
+	_, ok := restarting.Load(serverID)
+	return ok
+}
+
+// RegisterRestartTasks registers a restart task for every server with a restart schedule
+func RegisterRestartTasks(scheduler *Scheduler, conf *config.Config) error {
+	warnings, err := parseRestartWarnings(conf.Restarts.Warnings)
+	if err != nil {
+		return err
+	}
+
+	for serverIDString, restartConf := range conf.Restarts.Servers {
+		serverID, err := strconv.Atoi(serverIDString)
+		if err != nil {
+			return fmt.Errorf("INVALID SERVER ID %q IN RESTARTS CONFIGURATION", serverIDString)
+		}
+
+		switch restartConf.WhenPlayersOnline {
+		case "", "restart", "skip", "delay":
+		default:
+			return fmt.Errorf("INVALID WHEN PLAYERS ONLINE VALUE %q FOR SERVER %d, EXPECTED restart, skip OR delay", restartConf.WhenPlayersOnline, serverID)
+		}
+		maxDelay := time.Hour
+		if restartConf.MaxDelay != "" {
+			maxDelay, err = time.ParseDuration(restartConf.MaxDelay)
+			if err != nil || maxDelay < 0 {
+				return fmt.Errorf("INVALID MAX DELAY %q FOR SERVER %d", restartConf.MaxDelay, serverID)
+			}
+		}
+
+		whenPlayersOnline := restartConf.WhenPlayersOnline
+		taskName := taskNameRestartPrefix + serverIDString
+		err = scheduler.Register(taskName, restartConf.SchedulerTaskConfig, func(ctx context.Context) error {
+			return TaskRestartServer(ctx, audit.Scheduler(taskName), serverID, whenPlayersOnline, maxDelay, warnings)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RestartServerNow restarts a server right away with the configured warnings, asked by an operator so the players online
+// don't delay it
+func RestartServerNow(ctx context.Context, actor audit.Actor, serverID int) error {
+	warnings, err := parseRestartWarnings(config.Get().Restarts.Warnings)
+	if err != nil {
+		return err
+	}
+	return TaskRestartServer(ctx, actor, serverID, "restart", 0, warnings)
+}
+
+// parseRestartWarnings parses the warnings durations and sorts them from the earliest to the latest
+func parseRestartWarnings(warningStrings []string) ([]time.Duration, error) {
+	if len(warningStrings) == 0 {
+		warningStrings = defaultRestartWarnings
+	}
+
+	warnings := make([]time.Duration, 0, len(warningStrings))
+	for _, warningString := range warningStrings {
+		warning, err := time.ParseDuration(warningString)
+		if err != nil || warning <= 0 {
+			return nil, fmt.Errorf("INVALID RESTART WARNING %q", warningString)
+		}
+		warnings = append(warnings, warning)
+	}
+	sort.Slice(warnings, func(i, j int) bool { return warnings[i] > warnings[j] })
+	return warnings, nil
+}
+
+// Task : Restart a server, warning the players in game before. The restart is recorded in the audit log under the given actor,
+// unless it is skipped
+func TaskRestartServer(ctx context.Context, actor audit.Actor, serverID int, whenPlayersOnline string, maxDelay time.Duration, warnings []time.Duration) (err error) {
+	skipped := false
+	defer func() {
+		if !skipped {
+			audit.Record(actor, models.AuditRestartServer, serverID, audit.Params("whenPlayersOnline", whenPlayersOnline), err)
+		}
+	}()
+
+	if _, already := restarting.LoadOrStore(serverID, true); already {
+		return ErrRestartInProgress
+	}
+	defer restarting.Delete(serverID)
+
+	server, err := db.GetServerById(serverID)
+	if err != nil {
+		return fmt.Errorf("ERROR WHILE GETTING SERVER %d FOR RESTART: %v", serverID, err)
+	}
+
+	isRunning, err := tmux.IsServerRunning(server.Nom)
+	if err != nil {
+		return fmt.Errorf("ERROR WHILE CHECKING IF %s IS RUNNING: %v", server.Nom, err)
+	}
+	if !isRunning {
+		logger.Info("Restart skipped, the server is not running", "server_id", serverID, "server", server.Nom)
+		skipped = true
+		return nil
+	}
+
+	// Decide what to do if players are connected
+	if presence.CountOnlinePlayers(serverID) > 0 {
+		switch whenPlayersOnline {
+		case "skip":
+			sendRestartNotice("Redémarrage de "+server.Nom+" annulé, des joueurs sont connectés : "+strings.Join(presence.GetOnlinePlayers(serverID), ", "), mehColor())
+			skipped = true
+			return nil
+		case "delay":
+			if !waitForPlayersToLeave(ctx, serverID, maxDelay) {
+				if ctx.Err() != nil {
+					logger.Info("Restart cancelled, the daemon is shutting down", "server_id", serverID, "server", server.Nom)
+					return ctx.Err()
+				}
+				logger.Info("Players are still online, restarting anyway", "server_id", serverID, "server", server.Nom, "waited", maxDelay.String())
+			}
+		}
+	}
+
+	// Staged warnings, only Minecraft servers have a console we can talk to
+	if server.Jeu == "Minecraft" {
+		for i, warning := range warnings {
+			err := tmux.SendCommandToServer(server.Nom, "say Redémarrage du serveur dans "+formatWarningDuration(warning)+" !")
+			if err != nil {
+				logger.Error("Players not warned of the restart", "server_id", serverID, "server", server.Nom, logging.Err(err))
+			}
+
+			next := time.Duration(0)
+			if i+1 < len(warnings) {
+				next = warnings[i+1]
+			}
+			if !sleepContext(ctx, warning-next) {
+				cancelRestart(server.Nom)
+				return ctx.Err()
+			}
+		}
+
+		// Make sure the world is written on disk before stopping
+		if err := tmux.SendCommandToServer(server.Nom, "save-all"); err != nil {
+			logger.Error("World not saved before the restart", "server_id", serverID, "server", server.Nom, logging.Err(err))
+		}
+		if !sleepContext(ctx, 5*time.Second) {
+			cancelRestart(server.Nom)
+			return ctx.Err()
+		}
+	}
+
+	// From here the stop is never interrupted, only the wait for the session to close is. The server keeps its slot, so
+	// the check of the running servers starts it again if the restart doesn't
+
+	sessionID, err := tmux.GetSessionIDForServer(server.ID)
+	if err != nil {
+		return fmt.Errorf("ERROR WHILE GETTING SESSION ID OF %s: %v", server.Nom, err)
+	}
+
+	startedAt := time.Now()
+	err = tmux.StopServerTmux(server.Nom)
+	if err != nil {
+		sendRestartNotice("Échec de l'arrêt de "+server.Nom+" : "+err.Error(), badColor())
+		return fmt.Errorf("ERROR WHILE STOPPING %s FOR RESTART: %v", server.Nom, err)
+	}
+
+	// Wait for the session to be closed before starting it again, two sessions of the same server would share its files
+	if err := waitForSessionClosed(ctx, server.Nom); err != nil {
+		sendRestartNotice("Échec du redémarrage de "+server.Nom+" : "+err.Error(), badColor())
+		return fmt.Errorf("ERROR WHILE WAITING FOR %s TO STOP FOR RESTART: %v", server.Nom, err)
+	}
+
+	err = tmux.StartServerTmux(sessionID, server)
+	if err != nil {
+		sendRestartNotice("Échec du redémarrage de "+server.Nom+" : "+err.Error(), badColor())
+		return fmt.Errorf("ERROR WHILE STARTING %s FOR RESTART: %v", server.Nom, err)
+	}
+
+	sendRestartNotice(server.Nom+" a été redémarré ("+time.Since(startedAt).Round(time.Second).String()+").", goodColor())
+	return nil
+}
+
+// waitForSessionClosed waits until the tmux session of a server is closed, it fails if it is still open after the
+// session close timeout or if the context is cancelled first
+func waitForSessionClosed(ctx context.Context, serverName string) error {
+	deadline := time.Now().Add(sessionCloseTimeout)
+	for {
+		isRunning, err := tmux.IsServerRunning(serverName)
+		if err == nil && !isRunning {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			if err != nil {
+				return fmt.Errorf("SESSION STATE UNKNOWN AFTER %s: %v", sessionCloseTimeout, err)
+			}
+			return fmt.Errorf("SESSION STILL OPEN AFTER %s", sessionCloseTimeout)
+		}
+		if !sleepContext(ctx, time.Second) {
+			return ctx.Err()
+		}
+	}
+}
+
+// waitForPlayersToLeave waits until nobody is connected to the server, it returns false if the max delay is reached first
+func waitForPlayersToLeave(ctx context.Context, serverID int, maxDelay time.Duration) bool {
+	deadline := time.Now().Add(maxDelay)
+	for presence.CountOnlinePlayers(serverID) > 0 {
+		if time.Now().After(deadline) {
+			return false
+		}
+		if !sleepContext(ctx, restartDelayCheckInterval) {
+			return false
+		}
+	}
+	return true
+}
+
+// sleepContext waits for the given duration, it returns false if the context is cancelled first
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// cancelRestart tells the players that the announced restart won't happen
+func cancelRestart(serverName string) {
+	if err := tmux.SendCommandToServer(serverName, "say Redémarrage annulé."); err != nil {
+		logger.Error("Players not warned of the cancelled restart", "server", serverName, logging.Err(err))
+	}
+	logger.Info("Restart cancelled, the daemon is shutting down", "server", serverName)
+}
+
+// formatWarningDuration formats a warning duration in French, ex: "5 minutes" or "10 secondes"
+func formatWarningDuration(d time.Duration) string {
+	switch {
+	case d >= time.Minute && d%time.Minute == 0:
+		minutes := int(d / time.Minute)
+		if minutes == 1 {
+			return "1 minute"
+		}
+		return strconv.Itoa(minutes) + " minutes"
+	default:
+		seconds := int(d.Round(time.Second) / time.Second)
+		if seconds <= 1 {
+			return "1 seconde"
+		}
+		return strconv.Itoa(seconds) + " secondes"
+	}
+}
+
+// sendRestartNotice sends the result of a restart in the server status channel
+func sendRestartNotice(message string, color string) {
+	logger.Info(message)
+	err := discord.SendDiscordEmbed(config.Get().Bots["mineotterBot"], config.Get().DiscordChannels.ServerStatusChannelID, "♟ Redémarrage planifié", message, color)
+	if err != nil {
+		logger.Error("Discord message not sent", logging.Err(err))
+	}
+}
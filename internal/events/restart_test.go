@@ -0,0 +1,36 @@
+package periodic
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Corentin-cott/ServeurSentinel/internal/audit"
+	"github.com/Corentin-cott/ServeurSentinel/internal/db"
+	"github.com/Corentin-cott/ServeurSentinel/internal/models"
+)
+
+func TestTaskRestartServerAlreadyRestarting(t *testing.T) {
+	repository := db.NewMemoryRepository()
+	db.SetRepository(repository)
+	t.Cleanup(func() { db.SetRepository(nil) })
+
+	// A restart asked by an operator runs, the scheduled one is refused before warning the players
+	const serverID = 5
+	restarting.Store(serverID, true)
+	t.Cleanup(func() { restarting.Delete(serverID) })
+	if !IsRestarting(serverID) {
+		t.Fatalf("IsRestarting(%d) = false, want true", serverID)
+	}
+
+	err := TaskRestartServer(context.Background(), audit.Scheduler("restart-5"), serverID, "restart", 0, nil)
+	if !errors.Is(err, ErrRestartInProgress) {
+		t.Fatalf("TaskRestartServer() = %v, want ErrRestartInProgress", err)
+	}
+	if !IsRestarting(serverID) {
+		t.Errorf("the refused restart ended the running one")
+	}
+	if len(repository.Audit) != 1 || repository.Audit[0].Action != models.AuditRestartServer || repository.Audit[0].Success {
+		t.Errorf("audit = %+v, want the refused restart", repository.Audit)
+	}
+}
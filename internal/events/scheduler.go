@@ -0,0 +1,375 @@
+package periodic
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Corentin-cott/ServeurSentinel/config"
+	"github.com/Corentin-cott/ServeurSentinel/internal/cron"
+	"github.com/Corentin-cott/ServeurSentinel/internal/logging"
+	"github.com/Corentin-cott/ServeurSentinel/internal/metrics"
+	"github.com/Corentin-cott/ServeurSentinel/internal/models"
+)
+
+var logger = logging.For("scheduler")
+
+// intervalSchedule runs a task every fixed duration
+type intervalSchedule struct {
+	interval time.Duration
+}
+
+func (s intervalSchedule) Next(after time.Time) time.Time {
+	return after.Add(s.interval)
+}
+
+// ScheduledTask is a task registered in the scheduler
+type ScheduledTask struct {
+	Name     string
+	Enabled  bool
+	Schedule cron.Schedule
+	Jitter   time.Duration
+	Run      func(ctx context.Context) error
+
+	// The state is shared with the task of the same name when the scheduler is reloaded
+	state *taskState
+}
+
+// taskState is the runtime state of a task
+type taskState struct {
+	mu           sync.Mutex
+	running      bool
+	lastRun      time.Time
+	lastDuration time.Duration
+	lastErr      error
+	nextRun      time.Time
+}
+
+// TaskStatus is a snapshot of the state of a scheduled task
+type TaskStatus struct {
+	Name         string
+	Enabled      bool
+	Running      bool
+	LastRun      time.Time
+	LastDuration time.Duration
+	LastError    error
+	NextRun      time.Time
+}
+
+// Scheduler runs every registered task on its own schedule
+type Scheduler struct {
+	mu      sync.Mutex
+	tasks   []*ScheduledTask
+	stop    chan struct{}
+	started bool
+	ctx     context.Context // Given to every run, cancelled when the daemon shuts down
+	runs    sync.WaitGroup  // Runs in progress
+}
+
+// NewScheduler creates an empty scheduler
+func NewScheduler() *Scheduler {
+	return &Scheduler{stop: make(chan struct{}), ctx: context.Background()}
+}
+
+// Register adds a task to the scheduler, its schedule is taken from the task configuration
+func (s *Scheduler) Register(name string, conf models.SchedulerTaskConfig, run func(ctx context.Context) error) error {
+	task := &ScheduledTask{Name: name, Enabled: conf.Enabled, Run: run, state: &taskState{}}
+
+	if conf.Cron != "" && conf.Interval != "" {
+		return fmt.Errorf("TASK %s: CRON AND INTERVAL CANNOT BE BOTH SET", name)
+	}
+	switch {
+	case conf.Cron != "":
+		schedule, err := cron.ParseCron(conf.Cron)
+		if err != nil {
+			return fmt.Errorf("TASK %s: %v", name, err)
+		}
+		task.Schedule = schedule
+	case conf.Interval != "":
+		interval, err := time.ParseDuration(conf.Interval)
+		if err != nil || interval <= 0 {
+			return fmt.Errorf("TASK %s: INVALID INTERVAL %q", name, conf.Interval)
+		}
+		task.Schedule = intervalSchedule{interval: interval}
+	default:
+		if conf.Enabled {
+			return fmt.Errorf("TASK %s: NO CRON NOR INTERVAL SET", name)
+		}
+	}
+
+	if conf.Jitter != "" {
+		jitter, err := time.ParseDuration(conf.Jitter)
+		if err != nil || jitter < 0 {
+			return fmt.Errorf("TASK %s: INVALID JITTER %q", name, conf.Jitter)
+		}
+		task.Jitter = jitter
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, existing := range s.tasks {
+		if existing.Name == name {
+			return fmt.Errorf("TASK %s IS ALREADY REGISTERED", name)
+		}
+	}
+	s.tasks = append(s.tasks, task)
+	return nil
+}
+
+// Start launches a goroutine per enabled task, it returns immediately. The scheduler stops when the context is cancelled
+func (s *Scheduler) Start(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started {
+		return
+	}
+	s.started = true
+	s.ctx = ctx
+	s.startLoops()
+
+	go func() {
+		<-ctx.Done()
+		s.Stop()
+	}()
+}
+
+// startLoops starts the loop of every enabled task, s.mu must be held
+func (s *Scheduler) startLoops() {
+	for _, task := range s.tasks {
+		if !task.Enabled {
+			logger.Info("Scheduled task disabled", "task", task.Name)
+			continue
+		}
+		go s.loop(task, s.stop)
+	}
+}
+
+// Reload replaces the registered tasks with the ones registered by the given function. If it fails, the current tasks
+// are kept. Runs in progress are not interrupted, and a task keeping its name keeps its state, so it still can't overlap
+func (s *Scheduler) Reload(register func(*Scheduler) error) error {
+	next := NewScheduler()
+	if err := register(next); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, task := range next.tasks {
+		for _, previous := range s.tasks {
+			if previous.Name == task.Name {
+				task.state = previous.state
+				break
+			}
+		}
+	}
+
+	s.tasks = next.tasks
+	if s.started {
+		close(s.stop)
+		s.stop = make(chan struct{})
+		s.startLoops()
+	}
+	return nil
+}
+
+// Stop stops scheduling new runs, runs already started are not interrupted
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started {
+		close(s.stop)
+		s.stop = make(chan struct{})
+		s.started = false
+	}
+}
+
+// Wait waits for the runs in progress to finish, it returns false if some are still running after the timeout
+func (s *Scheduler) Wait(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		s.runs.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// loop waits for the next run of a task and executes it, until the scheduler is stopped
+func (s *Scheduler) loop(task *ScheduledTask, stop chan struct{}) {
+	for {
+		// The cron expressions are read in the time zone of the configuration, whatever the time zone of the system
+		nextRun := task.Schedule.Next(time.Now().In(config.Get().Location()))
+		if nextRun.IsZero() {
+			logger.Warn("Scheduled task will never run again, its schedule has no next date", "task", task.Name)
+			return
+		}
+		if task.Jitter > 0 {
+			nextRun = nextRun.Add(time.Duration(rand.Int63n(int64(task.Jitter))))
+		}
+
+		task.state.mu.Lock()
+		task.state.nextRun = nextRun
+		task.state.mu.Unlock()
+		logger.Debug("Scheduled task planned", "task", task.Name, "next_run", nextRun.Format("02/01/2006 15:04:05"))
+
+		timer := time.NewTimer(time.Until(nextRun))
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		// Each run is in its own goroutine, so a slow task never delays the computation of its next run. It is counted
+		// before the goroutine starts, so Wait can't miss it
+		if !s.beginRun() {
+			return
+		}
+		go func() {
+			defer s.runs.Done()
+			s.run(task)
+		}()
+	}
+}
+
+// RunNow runs a task immediately, unless a previous run of the same task is still going or the scheduler is stopped
+func (s *Scheduler) RunNow(name string) error {
+	task := s.getTask(name)
+	if task == nil {
+		return fmt.Errorf("TASK %s NOT FOUND", name)
+	}
+	if !s.beginRun() {
+		return fmt.Errorf("TASK %s NOT RUN, THE SCHEDULER IS STOPPED", name)
+	}
+	defer s.runs.Done()
+	return s.run(task)
+}
+
+// beginRun counts a new run in progress, unless the scheduler is stopped. The caller must call s.runs.Done once the
+// run is over
+func (s *Scheduler) beginRun() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.started {
+		return false
+	}
+	s.runs.Add(1)
+	return true
+}
+
+// run executes a task, counted as in progress by beginRun
+func (s *Scheduler) run(task *ScheduledTask) error {
+	name := task.Name
+	state := task.state
+	state.mu.Lock()
+	if state.running {
+		state.mu.Unlock()
+		logger.Warn("Scheduled task skipped, the previous run is still in progress", "task", name)
+		return fmt.Errorf("TASK %s IS ALREADY RUNNING", name)
+	}
+	state.running = true
+	state.mu.Unlock()
+
+	s.mu.Lock()
+	ctx := s.ctx
+	s.mu.Unlock()
+
+	logger.Info("Scheduled task started", "task", name)
+	startedAt := time.Now()
+	err := runSafely(ctx, task)
+	duration := time.Since(startedAt)
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	metrics.TaskDuration.ObserveDuration(duration, name, result)
+
+	state.mu.Lock()
+	state.running = false
+	state.lastRun = startedAt
+	state.lastDuration = duration
+	state.lastErr = err
+	state.mu.Unlock()
+
+	if err != nil {
+		logger.Error("Scheduled task failed", "task", name, "duration", duration.Round(time.Millisecond).String(), logging.Err(err))
+	} else {
+		logger.Info("Scheduled task done", "task", name, "duration", duration.Round(time.Millisecond).String())
+	}
+	return err
+}
+
+// runSafely runs a task and turns a panic into an error, so a broken task can't kill the daemon
+func runSafely(ctx context.Context, task *ScheduledTask) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("TASK %s PANICKED: %v", task.Name, r)
+		}
+	}()
+	return task.Run(ctx)
+}
+
+func (s *Scheduler) getTask(name string) *ScheduledTask {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, task := range s.tasks {
+		if task.Name == name {
+			return task
+		}
+	}
+	return nil
+}
+
+// Status returns the state of every registered task, sorted by name
+func (s *Scheduler) Status() []TaskStatus {
+	s.mu.Lock()
+	tasks := append([]*ScheduledTask(nil), s.tasks...)
+	s.mu.Unlock()
+
+	statuses := make([]TaskStatus, 0, len(tasks))
+	for _, task := range tasks {
+		task.state.mu.Lock()
+		statuses = append(statuses, TaskStatus{
+			Name:         task.Name,
+			Enabled:      task.Enabled,
+			Running:      task.state.running,
+			LastRun:      task.state.lastRun,
+			LastDuration: task.state.lastDuration,
+			LastError:    task.state.lastErr,
+			NextRun:      task.state.nextRun,
+		})
+		task.state.mu.Unlock()
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// GetTaskConfig returns the configuration of a task. The servers check and Minecraft statistics tasks fall back on the old
+// "periodicEvents" and "periodicEventsMin" settings when they are not in the scheduler configuration, the server events are
+// pruned daily
+func GetTaskConfig(conf *config.Config, name string) models.SchedulerTaskConfig {
+	if taskConf, ok := conf.Scheduler.Tasks[name]; ok {
+		return taskConf
+	}
+
+	legacyInterval := ""
+	if conf.PeriodicEventsMin > 0 {
+		legacyInterval = fmt.Sprintf("%dm", conf.PeriodicEventsMin)
+	}
+	switch name {
+	case TaskNameServersCheck:
+		return models.SchedulerTaskConfig{Enabled: conf.PeriodicEvents.ServersCheckEnabled && legacyInterval != "", Interval: legacyInterval}
+	case TaskNameMinecraftStats:
+		return models.SchedulerTaskConfig{Enabled: conf.PeriodicEvents.MinecraftStatsEnabled && legacyInterval != "", Interval: legacyInterval}
+	case TaskNamePruneEvents:
+		return models.SchedulerTaskConfig{Enabled: true, Interval: "24h"}
+	}
+	return models.SchedulerTaskConfig{Enabled: false}
+}
@@ -0,0 +1,336 @@
+package leaderboards
+
+// This package contains the LEADERBOARDS : the players ranked on a statistic, on a server or on all of them, all time or
+// during a period. Each leaderboard is posted once on Discord then its message is edited, the message IDs are kept in the
+// state directory
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Corentin-cott/ServeurSentinel/config"
+	"github.com/Corentin-cott/ServeurSentinel/internal/db"
+	"github.com/Corentin-cott/ServeurSentinel/internal/discord"
+	"github.com/Corentin-cott/ServeurSentinel/internal/logging"
+	"github.com/Corentin-cott/ServeurSentinel/internal/models"
+	"github.com/Corentin-cott/ServeurSentinel/internal/services"
+)
+
+var logger = logging.For("leaderboards")
+
+// Name of the file inside the state directory where the messages of the leaderboards are kept
+const messagesFileName = "leaderboards.json"
+
+// stat is a statistic a leaderboard can rank
+type stat struct {
+	title  string
+	value  func(models.MinecraftStatsCounters) int
+	format func(int) string
+}
+
+var stats = map[string]stat{
+	"playtime": {"Temps de jeu", func(c models.MinecraftStatsCounters) int { return c.TimePlayed }, formatTicks},
+	"deaths":   {"Morts", func(c models.MinecraftStatsCounters) int { return c.Deaths }, formatCount("mort", "morts")},
+	"mobsKilled": {"Monstres tués", func(c models.MinecraftStatsCounters) int { return c.Kills },
+		formatCount("monstre", "monstres")},
+	"distance": {"Distance parcourue", func(c models.MinecraftStatsCounters) int { return c.TotalDistance }, formatCentimeters},
+	"blocksMined": {"Blocs minés", func(c models.MinecraftStatsCounters) int { return c.BlocksDestroyed },
+		formatCount("bloc", "blocs")},
+	"advancements": {"Progrès", func(c models.MinecraftStatsCounters) int { return c.Advancements },
+		formatCount("progrès", "progrès")},
+}
+
+// Entry is a ranked player
+type Entry struct {
+	PlayerUUID string `json:"playerUUID"`
+	Name       string `json:"name"`
+	Value      int    `json:"value"`
+	Formatted  string `json:"formatted"`
+}
+
+// Leaderboard is a ranking at a given time
+type Leaderboard struct {
+	Board   models.LeaderboardConfig `json:"board"`
+	Title   string                   `json:"title"`
+	Entries []Entry                  `json:"entries"`
+	From    time.Time                `json:"from"` // Zero for all time
+	To      time.Time                `json:"to"`
+}
+
+// postedMessage is where a leaderboard was posted
+type postedMessage struct {
+	ChannelID string `json:"channelID"`
+	MessageID string `json:"messageID"`
+}
+
+// Key identifies a leaderboard, its message is found with it
+func Key(board models.LeaderboardConfig) string {
+	return fmt.Sprintf("%s/%d/%s", board.Stat, board.ServerID, board.Period)
+}
+
+// Build ranks the players of a leaderboard, at most size of them. The players at 0 are left out
+func Build(board models.LeaderboardConfig, size int, now time.Time) (Leaderboard, error) {
+	boardStat, ok := stats[board.Stat]
+	if !ok {
+		return Leaderboard{}, fmt.Errorf("UNKNOWN LEADERBOARD STATISTIC: %s", board.Stat)
+	}
+	leaderboard := Leaderboard{Board: board, To: now.UTC(), Entries: []Entry{}}
+	if board.Period != "" {
+		period, err := time.ParseDuration(board.Period)
+		if err != nil {
+			return Leaderboard{}, fmt.Errorf("INVALID LEADERBOARD PERIOD: %v", err)
+		}
+		leaderboard.From = now.Add(-period).UTC()
+	}
+	leaderboard.Title = title(board)
+
+	deltas, err := db.GetMinecraftStatsDeltas(board.ServerID, leaderboard.From, leaderboard.To)
+	if err != nil {
+		return Leaderboard{}, err
+	}
+
+	// On all the servers, the values of a player are added up
+	values := make(map[string]int)
+	for _, delta := range deltas {
+		values[delta.PlayerUUID] += boardStat.value(delta.MinecraftStatsCounters)
+	}
+	for playerUUID, value := range values {
+		if value > 0 {
+			leaderboard.Entries = append(leaderboard.Entries, Entry{PlayerUUID: playerUUID, Name: playerName(playerUUID), Value: value, Formatted: boardStat.format(value)})
+		}
+	}
+	sort.Slice(leaderboard.Entries, func(i, j int) bool {
+		if leaderboard.Entries[i].Value != leaderboard.Entries[j].Value {
+			return leaderboard.Entries[i].Value > leaderboard.Entries[j].Value
+		}
+		return strings.ToLower(leaderboard.Entries[i].Name) < strings.ToLower(leaderboard.Entries[j].Name)
+	})
+	if len(leaderboard.Entries) > size {
+		leaderboard.Entries = leaderboard.Entries[:size]
+	}
+	return leaderboard, nil
+}
+
+// BuildAll ranks the players of every leaderboard of the configuration, in their order
+func BuildAll(now time.Time) ([]Leaderboard, error) {
+	conf := config.Get()
+	boards := make([]Leaderboard, 0, len(conf.Leaderboards.Boards))
+	for _, board := range conf.Leaderboards.Boards {
+		leaderboard, err := Build(board, conf.Leaderboards.Size, now)
+		if err != nil {
+			return nil, fmt.Errorf("ERROR WHILE BUILDING LEADERBOARD %s: %v", Key(board), err)
+		}
+		boards = append(boards, leaderboard)
+	}
+	return boards, nil
+}
+
+// playerName returns the last name of a player, or his UUID if none is known
+func playerName(playerUUID string) string {
+	player, err := db.GetPlayerByUUID(playerUUID)
+	if err != nil {
+		return playerUUID
+	}
+	names, err := db.GetPlayerNames(player.ID)
+	if err != nil || len(names) == 0 {
+		return playerUUID
+	}
+	return names[0].Pseudo
+}
+
+// title is the title of a leaderboard, ex: "Temps de jeu sur La Vanilla, cette semaine"
+func title(board models.LeaderboardConfig) string {
+	where := "tous les serveurs"
+	if board.ServerID != 0 {
+		if serverName, err := db.GetServerNameById(board.ServerID); err == nil {
+			where = serverName
+		} else {
+			where = fmt.Sprintf("le serveur %d", board.ServerID)
+		}
+	}
+
+	when := "depuis toujours"
+	if period, err := time.ParseDuration(board.Period); err == nil && period > 0 {
+		days := int(period / (24 * time.Hour))
+		switch {
+		case period == 7*24*time.Hour:
+			when = "cette semaine"
+		case period == 24*time.Hour:
+			when = "aujourd'hui"
+		case period%(24*time.Hour) == 0:
+			when = fmt.Sprintf("ces %d derniers jours", days)
+		default:
+			when = "ces dernières " + board.Period
+		}
+	}
+	return stats[board.Stat].title + " sur " + where + ", " + when
+}
+
+// Embed is the Discord embed of a leaderboard, with the head of the first player
+func Embed(leaderboard Leaderboard) models.EmbedConfig {
+	conf := config.Get()
+	embed := models.EmbedConfig{
+		Title:     "🏆 " + leaderboard.Title,
+		Color:     conf.EmbedColors.Good,
+		Footer:    "Mis à jour",
+		Timestamp: true,
+	}
+	if leaderboard.Board.ServerID != 0 {
+		if server, err := db.GetServerById(leaderboard.Board.ServerID); err == nil && server.EmbedColor != "" {
+			embed.Color = server.EmbedColor
+		}
+	}
+
+	if len(leaderboard.Entries) == 0 {
+		embed.Description = "Personne n'est encore classé."
+		return embed
+	}
+
+	medals := []string{"🥇", "🥈", "🥉"}
+	var description strings.Builder
+	for i, entry := range leaderboard.Entries {
+		rank := fmt.Sprintf("`%d.`", i+1)
+		if i < len(medals) {
+			rank = medals[i]
+		}
+		fmt.Fprintf(&description, "%s **%s** : %s\n", rank, entry.Name, entry.Formatted)
+	}
+	embed.Description = description.String()
+
+	if headURL, err := services.GetMinecraftPlayerHeadURL(leaderboard.Entries[0].PlayerUUID); err == nil {
+		embed.Thumbnail = headURL
+	} else {
+		logger.Warn("Player head not found", "player_uuid", leaderboard.Entries[0].PlayerUUID, logging.Err(err))
+	}
+	return embed
+}
+
+func getMessagesFilePath() string {
+	return filepath.Join(config.Get().StatePath, messagesFileName)
+}
+
+// loadMessages reads where the leaderboards were posted, a missing file means none were
+func loadMessages() (map[string]postedMessage, error) {
+	messages := make(map[string]postedMessage)
+	data, err := os.ReadFile(getMessagesFilePath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return messages, nil
+		}
+		return nil, fmt.Errorf("ERROR WHILE READING THE LEADERBOARD MESSAGES: %v", err)
+	}
+	if err := json.Unmarshal(data, &messages); err != nil {
+		logger.Warn("Leaderboard messages not decoded, they will be posted again", logging.Err(err))
+		return make(map[string]postedMessage), nil
+	}
+	return messages, nil
+}
+
+// saveMessages writes where the leaderboards were posted, next to the file then renamed like the UUID cache
+func saveMessages(messages map[string]postedMessage) error {
+	data, err := json.MarshalIndent(messages, "", "  ")
+	if err != nil {
+		return fmt.Errorf("ERROR WHILE ENCODING THE LEADERBOARD MESSAGES: %v", err)
+	}
+	if err := os.MkdirAll(config.Get().StatePath, 0750); err != nil {
+		return fmt.Errorf("ERROR WHILE CREATING STATE DIRECTORY: %v", err)
+	}
+	tmpPath := getMessagesFilePath() + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0640); err != nil {
+		return fmt.Errorf("ERROR WHILE WRITING THE LEADERBOARD MESSAGES: %v", err)
+	}
+	if err := os.Rename(tmpPath, getMessagesFilePath()); err != nil {
+		return fmt.Errorf("ERROR WHILE WRITING THE LEADERBOARD MESSAGES: %v", err)
+	}
+	return nil
+}
+
+// PublishAll posts or updates every leaderboard of the configuration. A leaderboard whose message was deleted, or whose
+// channel changed, is posted again
+func PublishAll() error {
+	conf := config.Get()
+	if conf.Leaderboards.ChannelID == "" || len(conf.Leaderboards.Boards) == 0 {
+		logger.Info("No leaderboard to publish, leaderboards.channelID or leaderboards.boards is empty")
+		return nil
+	}
+	bot := conf.Bots["mineotterBot"]
+	if !bot.Activated {
+		return fmt.Errorf("THE BOT IS NOT ACTIVATED, THE LEADERBOARDS CAN'T BE PUBLISHED")
+	}
+
+	messages, err := loadMessages()
+	if err != nil {
+		return err
+	}
+
+	channelID := conf.Leaderboards.ChannelID
+	now := time.Now()
+	var failed []string
+	for _, board := range conf.Leaderboards.Boards {
+		key := Key(board)
+		leaderboard, err := Build(board, conf.Leaderboards.Size, now)
+		if err != nil {
+			logger.Error("Leaderboard not built", "leaderboard", key, logging.Err(err))
+			failed = append(failed, key)
+			continue
+		}
+		embed := Embed(leaderboard)
+
+		posted, ok := messages[key]
+		if ok && posted.ChannelID == channelID {
+			err := discord.EditDiscordEmbed(bot, channelID, posted.MessageID, embed)
+			if err == nil {
+				logger.Info("Leaderboard updated", "leaderboard", key)
+				continue
+			}
+			if !errors.Is(err, discord.ErrDiscordMessageNotFound) {
+				logger.Error("Leaderboard not updated", "leaderboard", key, logging.Err(err))
+				failed = append(failed, key)
+				continue
+			}
+			logger.Info("Leaderboard message deleted, it is posted again", "leaderboard", key)
+		}
+
+		messageID, err := discord.PostDiscordEmbed(bot, channelID, embed)
+		if err != nil {
+			logger.Error("Leaderboard not posted", "leaderboard", key, logging.Err(err))
+			failed = append(failed, key)
+			continue
+		}
+		messages[key] = postedMessage{ChannelID: channelID, MessageID: messageID}
+		if err := saveMessages(messages); err != nil {
+			logger.Error("Leaderboard messages not saved", logging.Err(err))
+		}
+		logger.Info("Leaderboard posted", "leaderboard", key)
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("FAILED TO PUBLISH THE LEADERBOARDS: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// formatTicks shows a play time in ticks as hours and minutes
+func formatTicks(ticks int) string {
+	seconds := ticks / 20
+	return fmt.Sprintf("%dh%02d", seconds/3600, seconds%3600/60)
+}
+
+func formatCentimeters(centimeters int) string {
+	return fmt.Sprintf("%.1f km", float64(centimeters)/100000)
+}
+
+func formatCount(singular string, plural string) func(int) string {
+	return func(value int) string {
+		if value == 1 {
+			return "1 " + singular
+		}
+		return fmt.Sprintf("%d %s", value, plural)
+	}
+}
@@ -0,0 +1,218 @@
+package logging
+
+// This package contains the LOGGERS of the daemon, built on log/slog. Each component has its own logger, the level and the
+// format (text or JSON) come from the configuration and can change while running, and the secrets are never written
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Redacted replaces the secrets in the logs
+const Redacted = "[REDACTED]"
+
+// Attribute keys whose value is always redacted, compared in lower case
+var secretKeys = []string{"password", "token", "secret", "dsn", "authorization", "webhook"}
+
+var (
+	level   = new(slog.LevelVar)
+	handler atomic.Pointer[slog.Handler] // The handler in use, swapped as a whole by Setup
+
+	secretsMutex sync.RWMutex
+	secrets      []string // Values redacted wherever they appear
+)
+
+var (
+	outputMutex sync.RWMutex
+	output      io.Writer = os.Stdout // Where the logs are written, see SetOutput
+)
+
+// currentOutput writes to the writer given to SetOutput, so the handlers created by Setup follow it
+type currentOutput struct{}
+
+func (currentOutput) Write(p []byte) (int, error) {
+	outputMutex.RLock()
+	defer outputMutex.RUnlock()
+	return output.Write(p)
+}
+
+// SetOutput writes the logs to another writer than stdout, ex: stderr when the CLI prints JSON on stdout
+func SetOutput(w io.Writer) {
+	outputMutex.Lock()
+	defer outputMutex.Unlock()
+	output = w
+}
+
+func init() {
+	setHandler(slog.NewTextHandler(currentOutput{}, &slog.HandlerOptions{Level: level}))
+}
+
+func setHandler(h slog.Handler) {
+	h = &redactingHandler{next: h}
+	handler.Store(&h)
+}
+
+// Setup chooses the level ("debug", "info", "warn" or "error") and the format ("text" or "json") of the logs, info and
+// text when empty
+func Setup(levelName string, format string) error {
+	newLevel := slog.LevelInfo
+	if levelName != "" {
+		if err := newLevel.UnmarshalText([]byte(levelName)); err != nil {
+			return fmt.Errorf("INVALID LOG LEVEL %q: %v", levelName, err)
+		}
+	}
+	return SetupWriter(newLevel, format, currentOutput{})
+}
+
+// SetupWriter is Setup with a writer, ex: a buffer in the tests
+func SetupWriter(newLevel slog.Level, format string, w io.Writer) error {
+	options := &slog.HandlerOptions{Level: level}
+	switch format {
+	case "", "text":
+		setHandler(slog.NewTextHandler(w, options))
+	case "json":
+		setHandler(slog.NewJSONHandler(w, options))
+	default:
+		return fmt.Errorf("INVALID LOG FORMAT %q, EXPECTED text OR json", format)
+	}
+	level.Set(newLevel)
+	return nil
+}
+
+// SetSecrets replaces the values that are redacted wherever they appear, ex: the bot tokens and the database password
+func SetSecrets(values ...string) {
+	var kept []string
+	for _, value := range values {
+		// A very short value would redact half of the logs
+		if len(value) >= 4 {
+			kept = append(kept, value)
+		}
+	}
+	secretsMutex.Lock()
+	defer secretsMutex.Unlock()
+	secrets = kept
+}
+
+// Redact replaces the secrets in a text
+func Redact(text string) string {
+	secretsMutex.RLock()
+	defer secretsMutex.RUnlock()
+	for _, secret := range secrets {
+		text = strings.ReplaceAll(text, secret, Redacted)
+	}
+	return text
+}
+
+// For returns the logger of a component, ex: For("db"). It follows the changes made by Setup
+func For(component string) *slog.Logger {
+	return slog.New(proxyHandler{}).With("component", component)
+}
+
+// proxyHandler sends the records to the handler in use, with the attributes and groups added to the logger
+type proxyHandler struct {
+	wrap func(slog.Handler) slog.Handler
+}
+
+func (p proxyHandler) current() slog.Handler {
+	h := *handler.Load()
+	if p.wrap != nil {
+		h = p.wrap(h)
+	}
+	return h
+}
+
+func (p proxyHandler) Enabled(ctx context.Context, l slog.Level) bool {
+	return l >= level.Level()
+}
+
+func (p proxyHandler) Handle(ctx context.Context, record slog.Record) error {
+	return p.current().Handle(ctx, record)
+}
+
+func (p proxyHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return proxyHandler{wrap: func(h slog.Handler) slog.Handler {
+		if p.wrap != nil {
+			h = p.wrap(h)
+		}
+		return h.WithAttrs(attrs)
+	}}
+}
+
+func (p proxyHandler) WithGroup(name string) slog.Handler {
+	return proxyHandler{wrap: func(h slog.Handler) slog.Handler {
+		if p.wrap != nil {
+			h = p.wrap(h)
+		}
+		return h.WithGroup(name)
+	}}
+}
+
+// redactingHandler removes the secrets from the messages and the attributes before writing them
+type redactingHandler struct {
+	next slog.Handler
+}
+
+func (r *redactingHandler) Enabled(ctx context.Context, l slog.Level) bool {
+	return r.next.Enabled(ctx, l)
+}
+
+func (r *redactingHandler) Handle(ctx context.Context, record slog.Record) error {
+	redacted := slog.NewRecord(record.Time, record.Level, Redact(record.Message), record.PC)
+	record.Attrs(func(attr slog.Attr) bool {
+		redacted.AddAttrs(redactAttr(attr))
+		return true
+	})
+	return r.next.Handle(ctx, redacted)
+}
+
+func (r *redactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, attr := range attrs {
+		redacted[i] = redactAttr(attr)
+	}
+	return &redactingHandler{next: r.next.WithAttrs(redacted)}
+}
+
+func (r *redactingHandler) WithGroup(name string) slog.Handler {
+	return &redactingHandler{next: r.next.WithGroup(name)}
+}
+
+func redactAttr(attr slog.Attr) slog.Attr {
+	key := strings.ToLower(attr.Key)
+	for _, secretKey := range secretKeys {
+		if strings.Contains(key, secretKey) {
+			return slog.String(attr.Key, Redacted)
+		}
+	}
+
+	value := attr.Value.Resolve()
+	switch value.Kind() {
+	case slog.KindGroup:
+		group := value.Group()
+		redacted := make([]any, len(group))
+		for i, member := range group {
+			redacted[i] = redactAttr(member)
+		}
+		return slog.Group(attr.Key, redacted...)
+	case slog.KindString:
+		return slog.String(attr.Key, Redact(value.String()))
+	case slog.KindAny:
+		// Errors and other values are written as text, the secrets are removed from it
+		if err, ok := value.Any().(error); ok {
+			return slog.String(attr.Key, Redact(err.Error()))
+		}
+		return slog.String(attr.Key, Redact(fmt.Sprint(value.Any())))
+	}
+	return slog.Attr{Key: attr.Key, Value: value}
+}
+
+// Err is the attribute of an error, the same key everywhere
+func Err(err error) slog.Attr {
+	return slog.Any("error", err)
+}
@@ -0,0 +1,66 @@
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestRedaction(t *testing.T) {
+	const secret = "bot-token-1234"
+	var output bytes.Buffer
+	if err := SetupWriter(slog.LevelInfo, "json", &output); err != nil {
+		t.Fatalf("SetupWriter() failed: %v", err)
+	}
+	SetSecrets(secret, "abc") // Too short to be redacted
+	t.Cleanup(func() {
+		SetSecrets()
+		SetupWriter(slog.LevelInfo, "text", currentOutput{})
+	})
+
+	tests := []struct {
+		name string
+		log  func(logger *slog.Logger)
+		want string // Written instead of the secret
+	}{
+		{"message", func(logger *slog.Logger) {
+			logger.Info("Connecting with " + secret)
+		}, `"msg":"Connecting with [REDACTED]"`},
+		{"string attribute", func(logger *slog.Logger) {
+			logger.Info("Connecting", "url", "https://discord.com/"+secret)
+		}, `"url":"https://discord.com/[REDACTED]"`},
+		{"error attribute", func(logger *slog.Logger) {
+			logger.Error("Not connected", Err(errors.New("INVALID TOKEN "+secret)))
+		}, `"error":"INVALID TOKEN [REDACTED]"`},
+		{"group", func(logger *slog.Logger) {
+			logger.Info("Connecting", slog.Group("bot", "name", "mineotter", "url", "/bots/"+secret))
+		}, `"bot":{"name":"mineotter","url":"/bots/[REDACTED]"}`},
+		{"attribute added with With", func(logger *slog.Logger) {
+			logger.With("url", "/bots/"+secret).Info("Connecting")
+		}, `"url":"/bots/[REDACTED]"`},
+		{"group added with WithGroup", func(logger *slog.Logger) {
+			logger.WithGroup("bot").Info("Connecting", "url", "/bots/"+secret)
+		}, `"bot":{"url":"/bots/[REDACTED]"}`},
+		{"secret named key", func(logger *slog.Logger) {
+			logger.Info("Connecting", "DB_Password", "not-a-known-secret")
+		}, `"DB_Password":"[REDACTED]"`},
+		{"short value kept", func(logger *slog.Logger) {
+			logger.Info("Connecting", "name", "abc")
+		}, `"name":"abc"`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			output.Reset()
+			test.log(For("test"))
+			written := output.String()
+			if strings.Contains(written, secret) || strings.Contains(written, "not-a-known-secret") {
+				t.Errorf("the secret was written: %s", written)
+			}
+			if !strings.Contains(written, test.want) {
+				t.Errorf("written %s, want %s", written, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,272 @@
+package metrics
+
+// This package contains the METRICS of the daemon, served in the Prometheus text format on /metrics. Only counters, gauges
+// and histograms are needed, so they are written here instead of adding the Prometheus client to the dependencies
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// collector is a metric family, written as a whole when the metrics are scraped
+type collector interface {
+	write(w io.Writer)
+}
+
+var (
+	registryMutex sync.Mutex
+	registry      []collector // In the order they were created, which is the order they are written
+)
+
+func register(c collector) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	registry = append(registry, c)
+}
+
+// series is the value of a metric for one set of label values
+type series struct {
+	labelValues []string
+	value       float64
+	buckets     []uint64 // Histograms only, cumulated when written
+	sum         float64
+	count       uint64
+}
+
+// family holds the series of a metric by label values
+type family struct {
+	name       string
+	help       string
+	kind       string
+	labelNames []string
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+func newFamily(name string, help string, kind string, labelNames []string) *family {
+	return &family{name: name, help: help, kind: kind, labelNames: labelNames, series: make(map[string]*series)}
+}
+
+// get returns the series of some label values, created at 0. The caller holds the lock
+func (f *family) get(labelValues []string) *series {
+	if len(labelValues) != len(f.labelNames) {
+		panic(fmt.Sprintf("METRIC %s EXPECTS %d LABELS, GOT %d", f.name, len(f.labelNames), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{labelValues: append([]string(nil), labelValues...)}
+		f.series[key] = s
+	}
+	return s
+}
+
+// sorted returns the series sorted by label values, so the output is stable. The caller holds the lock
+func (f *family) sorted() []*series {
+	all := make([]*series, 0, len(f.series))
+	for _, s := range f.series {
+		all = append(all, s)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return strings.Join(all[i].labelValues, "\xff") < strings.Join(all[j].labelValues, "\xff")
+	})
+	return all
+}
+
+func (f *family) writeHeader(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", f.name, f.help, f.name, f.kind)
+}
+
+// labels formats the labels of a series, with an extra label for the histogram buckets
+func (f *family) labels(labelValues []string, extraName string, extraValue string) string {
+	var pairs []string
+	for i, name := range f.labelNames {
+		pairs = append(pairs, name+`="`+escapeLabelValue(labelValues[i])+`"`)
+	}
+	if extraName != "" {
+		pairs = append(pairs, extraName+`="`+extraValue+`"`)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func (f *family) writeValues(w io.Writer) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.writeHeader(w)
+	for _, s := range f.sorted() {
+		fmt.Fprintf(w, "%s%s %s\n", f.name, f.labels(s.labelValues, "", ""), formatFloat(s.value))
+	}
+}
+
+// CounterVec is a value that only goes up, ex: the lines read per log file
+type CounterVec struct {
+	*family
+}
+
+// NewCounterVec creates and registers a counter
+func NewCounterVec(name string, help string, labelNames ...string) *CounterVec {
+	c := &CounterVec{newFamily(name, help, "counter", labelNames)}
+	register(c)
+	return c
+}
+
+// Inc adds 1 to the counter of some label values
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds a positive value to the counter of some label values
+func (c *CounterVec) Add(value float64, labelValues ...string) {
+	if value < 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.get(labelValues).value += value
+}
+
+func (c *CounterVec) write(w io.Writer) {
+	c.writeValues(w)
+}
+
+// GaugeVec is a value that goes up and down, ex: the players online per server
+type GaugeVec struct {
+	*family
+}
+
+// NewGaugeVec creates and registers a gauge
+func NewGaugeVec(name string, help string, labelNames ...string) *GaugeVec {
+	g := &GaugeVec{newFamily(name, help, "gauge", labelNames)}
+	register(g)
+	return g
+}
+
+// Set sets the gauge of some label values
+func (g *GaugeVec) Set(value float64, labelValues ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.get(labelValues).value = value
+}
+
+func (g *GaugeVec) write(w io.Writer) {
+	g.writeValues(w)
+}
+
+// HistogramVec counts observations in buckets, ex: the durations of the database queries
+type HistogramVec struct {
+	*family
+	bounds []float64 // Upper bounds of the buckets, sorted, +Inf is implicit
+}
+
+// NewHistogramVec creates and registers a histogram with the upper bounds of its buckets
+func NewHistogramVec(name string, help string, bounds []float64, labelNames ...string) *HistogramVec {
+	h := &HistogramVec{newFamily(name, help, "histogram", labelNames), append([]float64(nil), bounds...)}
+	sort.Float64s(h.bounds)
+	register(h)
+	return h
+}
+
+// Observe adds a value to the histogram of some label values
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := h.get(labelValues)
+	if s.buckets == nil {
+		s.buckets = make([]uint64, len(h.bounds))
+	}
+	for i, bound := range h.bounds {
+		if value <= bound {
+			s.buckets[i]++
+			break
+		}
+	}
+	s.sum += value
+	s.count++
+}
+
+// ObserveDuration adds a duration to the histogram, in seconds
+func (h *HistogramVec) ObserveDuration(duration time.Duration, labelValues ...string) {
+	h.Observe(duration.Seconds(), labelValues...)
+}
+
+func (h *HistogramVec) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.writeHeader(w)
+	for _, s := range h.sorted() {
+		var cumulated uint64
+		for i, bound := range h.bounds {
+			if s.buckets != nil {
+				cumulated += s.buckets[i]
+			}
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labels(s.labelValues, "le", formatFloat(bound)), cumulated)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labels(s.labelValues, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, h.labels(s.labelValues, "", ""), formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, h.labels(s.labelValues, "", ""), s.count)
+	}
+}
+
+// WriteText writes every metric in the Prometheus text format
+func WriteText(w io.Writer) {
+	registryMutex.Lock()
+	collectors := append([]collector(nil), registry...)
+	registryMutex.Unlock()
+	for _, c := range collectors {
+		c.write(w)
+	}
+}
+
+// Handler serves the metrics in the Prometheus text format
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		WriteText(w)
+	})
+}
+
+// Serve listens on an address like "127.0.0.1:9464" and serves /metrics until the context is cancelled
+func Serve(ctx context.Context, address string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+	server := &http.Server{Addr: address, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("ERROR WHILE SERVING THE METRICS ON %s: %v", address, err)
+	}
+	return nil
+}
+
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriteText(t *testing.T) {
+	// Only the metrics of the test are written, not the ones of the daemon
+	registryMutex.Lock()
+	daemonRegistry := registry
+	registry = nil
+	registryMutex.Unlock()
+	t.Cleanup(func() {
+		registryMutex.Lock()
+		registry = daemonRegistry
+		registryMutex.Unlock()
+	})
+
+	lines := NewCounterVec("test_lines_total", "Lines read.", "file")
+	players := NewGaugeVec("test_players", "Players online.")
+	durations := NewHistogramVec("test_duration_seconds", "Durations.", []float64{1, 0.1, 0.5}, "operation")
+
+	// Created out of order, the series are written sorted by label values
+	lines.Inc("logs/survie.log")
+	lines.Add(2, `C:\logs\"a"`+"\nb")
+	lines.Add(-1, "logs/survie.log") // A counter never goes down
+	lines.Inc("logs/survie.log")
+	players.Set(3)
+	durations.Observe(0.05, "select")
+	durations.Observe(0.3, "select")
+	durations.Observe(0.3, "select")
+	durations.Observe(4, "select")
+	durations.Observe(0.75, "insert")
+
+	want := `# HELP test_lines_total Lines read.
+# TYPE test_lines_total counter
+test_lines_total{file="C:\\logs\\\"a\"\nb"} 2
+test_lines_total{file="logs/survie.log"} 2
+# HELP test_players Players online.
+# TYPE test_players gauge
+test_players 3
+# HELP test_duration_seconds Durations.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{operation="insert",le="0.1"} 0
+test_duration_seconds_bucket{operation="insert",le="0.5"} 0
+test_duration_seconds_bucket{operation="insert",le="1"} 1
+test_duration_seconds_bucket{operation="insert",le="+Inf"} 1
+test_duration_seconds_sum{operation="insert"} 0.75
+test_duration_seconds_count{operation="insert"} 1
+test_duration_seconds_bucket{operation="select",le="0.1"} 1
+test_duration_seconds_bucket{operation="select",le="0.5"} 3
+test_duration_seconds_bucket{operation="select",le="1"} 3
+test_duration_seconds_bucket{operation="select",le="+Inf"} 4
+test_duration_seconds_sum{operation="select"} 4.65
+test_duration_seconds_count{operation="select"} 4
+`
+	// Written twice, scraping doesn't change the output
+	for range 2 {
+		var output strings.Builder
+		WriteText(&output)
+		if output.String() != want {
+			t.Fatalf("WriteText() =\n%s\nwant\n%s", output.String(), want)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package metrics
+
+// This file contains the metrics of the daemon, updated by the packages they measure
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Buckets for the requests to Discord and the database, in seconds
+var requestBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Buckets for the scheduled tasks, in seconds, a backup can take several minutes
+var taskBuckets = []float64{0.1, 1, 5, 15, 30, 60, 300, 900, 1800, 3600}
+
+var (
+	LinesRead = NewCounterVec("serversentinel_log_lines_read_total",
+		"Lines read in the log files of the servers.", "file")
+	TriggerMatches = NewCounterVec("serversentinel_trigger_matches_total",
+		"Lines that matched a trigger.", "trigger")
+	ActionErrors = NewCounterVec("serversentinel_action_errors_total",
+		"Actions of the triggers that failed.", "trigger")
+	DiscordRequestDuration = NewHistogramVec("serversentinel_discord_request_duration_seconds",
+		"Duration of the requests to Discord, bot API and webhooks.", requestBuckets)
+	DiscordFailures = NewCounterVec("serversentinel_discord_failures_total",
+		"Requests to Discord that failed, by HTTP status or \"error\" when no response was received.", "status")
+	DBQueryDuration = NewHistogramVec("serversentinel_db_query_duration_seconds",
+		"Duration of the database queries, by SQL statement type.", requestBuckets, "operation")
+	PlayersOnline = NewGaugeVec("serversentinel_players_online",
+		"Players connected to a server, as seen in its console.", "server_id")
+	ServerUp = NewGaugeVec("serversentinel_server_up",
+		"1 when the server is running, 0 when it stopped or crashed, as seen in its console.", "server_id")
+	TaskDuration = NewHistogramVec("serversentinel_task_duration_seconds",
+		"Duration of the scheduled tasks.", taskBuckets, "task", "result")
+)
+
+// ObserveDiscordRequest records a request to Discord sent at start, with its response or its error
+func ObserveDiscordRequest(start time.Time, resp *http.Response, err error) {
+	DiscordRequestDuration.ObserveDuration(time.Since(start))
+	switch {
+	case err != nil:
+		DiscordFailures.Inc("error")
+	case resp.StatusCode >= 300:
+		DiscordFailures.Inc(strconv.Itoa(resp.StatusCode))
+	}
+}
+
+// SetServerUp records if a server is running
+func SetServerUp(serverID int, up bool) {
+	value := 0.0
+	if up {
+		value = 1
+	}
+	ServerUp.Set(value, strconv.Itoa(serverID))
+}
+
+// SetPlayersOnline records the number of players connected to a server
+func SetPlayersOnline(serverID int, count int) {
+	PlayersOnline.Set(float64(count), strconv.Itoa(serverID))
+}
@@ -1,12 +1,16 @@
 package models
 
+import "time"
+
 // DatabaseConfig is a struct that contains the configuration for the database
 type DatabaseConfig struct {
+	Driver   string `json:"driver"` // "mysql" (default) or "sqlite"
 	Host     string `json:"host"`
 	Port     int    `json:"port"`
 	User     string `json:"user"`
 	Password string `json:"password"`
 	Name     string `json:"name"`
+	Path     string `json:"path"` // Database file, only for SQLite
 }
 
 // BotConfig is a struct that contains the configuration for a bot
@@ -41,6 +45,23 @@ type DiscordChannels struct {
 	ServerStatusChannelID  string `json:"serverStatusChannelID"`
 	MinecraftChatChannelID string `json:"minecraftChatChannelID"`
 	PalworldChatChannelID  string `json:"palworldChatChannelID"`
+	AccountLinkChannelID   string `json:"accountLinkChannelID"` // Where the players send their link code, optional
+}
+
+// DiscordMessage is a message read in a Discord channel
+type DiscordMessage struct {
+	ID         string
+	Content    string
+	AuthorID   string
+	AuthorName string
+	AuthorBot  bool
+}
+
+// EmbedColorsConfig is a struct that contains the colors of the status embeds sent by the daemon
+type EmbedColorsConfig struct {
+	Good    string `json:"good"`
+	Warning string `json:"warning"`
+	Error   string `json:"error"`
 }
 
 // PeriodicEventsConfig is a struct that contains the configuration for the periodic events
@@ -49,14 +70,128 @@ type PeriodicEventsConfig struct {
 	MinecraftStatsEnabled bool `json:"minecraftStatsEnabled"`
 }
 
+// SchedulerConfig is a struct that contains the configuration of every scheduled task, by task name
+type SchedulerConfig struct {
+	Tasks map[string]SchedulerTaskConfig `json:"tasks"`
+}
+
+// SchedulerTaskConfig is a struct that contains the configuration for a scheduled task, either a cron expression or an interval
+type SchedulerTaskConfig struct {
+	Enabled  bool   `json:"enabled"`
+	Cron     string `json:"cron"`
+	Interval string `json:"interval"`
+	Jitter   string `json:"jitter"`
+}
+
+// RestartsConfig is a struct that contains the configuration for the scheduled restarts, servers are indexed by their ID
+type RestartsConfig struct {
+	Warnings []string                       `json:"warnings"`
+	Servers  map[string]ServerRestartConfig `json:"servers"`
+}
+
+// ServerRestartConfig is a struct that contains the restart schedule of a server and what to do when players are online
+type ServerRestartConfig struct {
+	SchedulerTaskConfig
+	WhenPlayersOnline string `json:"whenPlayersOnline"` // "restart" (default), "skip" or "delay"
+	MaxDelay          string `json:"maxDelay"`
+}
+
+// BackupConfig is a struct that contains the configuration for the world backups
+type BackupConfig struct {
+	Path string `json:"path"`
+	Keep int    `json:"keep"`
+}
+
+// MinecraftAPIConfig is a struct that contains the web services used to find the UUIDs and the heads of the Minecraft players
+type MinecraftAPIConfig struct {
+	ProfilesURL  string `json:"profilesURL"`  // The player name is added at the end, ex: https://api.mojang.com/users/profiles/minecraft/
+	HeadsURL     string `json:"headsURL"`     // The player UUID and "/50.png" are added at the end, ex: https://minotar.net/helm/
+	UUIDCacheTTL string `json:"uuidCacheTTL"` // How long a UUID found with the API is kept, ex: "168h"
+}
+
+// StatsHistoryConfig is a struct that contains how long the snapshots of the statistics are kept
+type StatsHistoryConfig struct {
+	KeepAll   string `json:"keepAll"`   // Every snapshot is kept this long, ex: "168h"
+	KeepDaily string `json:"keepDaily"` // Then one snapshot per day is kept this long, "0" to never delete them
+}
+
+// LeaderboardsConfig is a struct that contains the leaderboards posted on Discord, each one is kept up to date in its own message
+type LeaderboardsConfig struct {
+	ChannelID string              `json:"channelID"` // Where the leaderboards are posted, none are posted without it
+	Size      int                 `json:"size"`      // How many players are ranked, 10 by default
+	Boards    []LeaderboardConfig `json:"boards"`
+}
+
+// LeaderboardConfig is a struct that contains what a leaderboard ranks
+type LeaderboardConfig struct {
+	Stat     string `json:"stat"`     // "playtime", "deaths", "mobsKilled", "distance", "blocksMined" or "advancements"
+	ServerID int    `json:"serverId"` // 0 for all the servers
+	Period   string `json:"period"`   // Only what was earned during this duration, ex: "168h". All time if empty
+}
+
+// ReportConfig is a struct that contains where the activity reports are posted and exported
+type ReportConfig struct {
+	ChannelID  string `json:"channelID"`  // Where the reports are posted, none are posted without it
+	Period     string `json:"period"`     // The period a report covers, ending when it is made, "168h" by default
+	ExportPath string `json:"exportPath"` // Where the reports are also written as Markdown and HTML, not written if empty
+	KeepEvents string `json:"keepEvents"` // How long the server events are kept, "8760h" by default, "0" to never delete them
+}
+
+// MetricsConfig is a struct that contains where the Prometheus metrics are served
+type MetricsConfig struct {
+	Listen string `json:"listen"` // Address of the /metrics endpoint, ex: "127.0.0.1:9464". Not served if empty
+}
+
+// APIConfig is a struct that contains where the HTTP API is served and the tokens allowed to use it
+type APIConfig struct {
+	Listen string                    `json:"listen"` // Address of the API, ex: "127.0.0.1:8090". Not served if empty
+	Tokens map[string]APITokenConfig `json:"tokens"` // By name, ex: "panel", the name is written in the logs
+}
+
+// APITokenConfig is a struct that contains a token of the API, sent as "Authorization: Bearer <token>" or pasted in the dashboard
+type APITokenConfig struct {
+	Token string `json:"token"`
+	Role  string `json:"role"` // RoleViewer, RoleOperator or RoleAdmin, "viewer" by default
+}
+
+// The roles of the API tokens, each one can do what the previous ones can
+const (
+	RoleViewer   = "viewer"   // Sees the servers, their consoles, backups and leaderboards
+	RoleOperator = "operator" // Also starts, stops and restarts the servers
+	RoleAdmin    = "admin"    // Also assigns the slots and types commands in the consoles
+)
+
+// Roles lists the roles from the least to the most allowed
+var Roles = []string{RoleViewer, RoleOperator, RoleAdmin}
+
+// LoggingConfig is a struct that contains how the daemon writes its logs
+type LoggingConfig struct {
+	Level  string `json:"level"`  // "debug", "info", "warn" or "error", "info" by default
+	Format string `json:"format"` // "text" or "json", "text" by default
+}
+
 // Type Player is a struct that represents a player in the database
 type Player struct {
 	ID            int
 	UtilisateurID int
 	Jeu           string
 	CompteID      string
-	PremiereCo    string
-	DerniereCo    string
+	PremiereCo    time.Time // UTC
+	DerniereCo    time.Time // UTC
+}
+
+// Type PlayerName is a struct that represents a name a player was seen with
+type PlayerName struct {
+	Pseudo      string
+	PremiereVue time.Time // UTC
+	DerniereVue time.Time // UTC
+}
+
+// Type DiscordUser is a struct that represents a Discord user linked to players
+type DiscordUser struct {
+	ID        int
+	DiscordID string
+	Pseudo    string
 }
 
 // Type Server is a struct that represents a server in the database
@@ -87,14 +222,117 @@ type MinecraftPlayerGameStatistics struct {
 	MobsKilled       map[string]int
 	BlocksDestroyed  int
 	BlocksPlaced     int
-	TotalDistance    int
-	DistanceByFoot   int
+	TotalDistance    int // In cm, every way of moving
+	DistanceByFoot   int // In cm, walking, sprinting, crouching and walking on or under water
 	DistanceByElytra int
 	DistanceByFlight int
+	Distances        map[string]int // In cm, by way of moving, ex: "sprint", "boat", "horse"
 	ItemsCrafted     map[string]int
 	ItemsBroken      map[string]int
+	ItemsUsed        map[string]int // The items used that are not blocks, ex: tools, food
 	Achievements     map[string]bool
-	LastRecordedTime string
+	LastRecordedTime time.Time // UTC
+}
+
+// Types of the server events
+const (
+	EventServerStarted = "demarrage"
+	EventServerStopped = "arret"
+	EventServerCrashed = "crash"
+	EventPlayerJoined  = "connexion"
+	EventPlayerLeft    = "deconnexion"
+	EventPlayerMessage = "message"
+	EventPlayerDeath   = "mort"
+	EventAdvancement   = "progres"
+)
+
+// Type ServerEvent is a struct that represents something that happened on a server, read in its console
+type ServerEvent struct {
+	ID         int64
+	ServerID   int
+	Type       string
+	PlayerName string // Empty for the events of the server itself
+	Details    string // Ex: the advancement or the death message
+	Date       time.Time
+}
+
+// Types of the actors of the audit log
+const (
+	ActorCLI       = "cli"       // A command typed on the machine, the actor is the system user
+	ActorAPI       = "api"       // A call to the API or the dashboard, the actor is the name of the token
+	ActorDiscord   = "discord"   // A message to a bot, the actor is the Discord user
+	ActorScheduler = "scheduler" // A scheduled task, the actor is the name of the task
+)
+
+// Actions of the audit log
+const (
+	AuditStartServer   = "start-server"
+	AuditStopServer    = "stop-server"
+	AuditRestartServer = "restart-server"
+	AuditAssignSlot    = "assign-slot"
+	AuditSendCommand   = "send-command"
+	AuditCheckStart    = "check-start" // A server started by the check of the running servers, it has a slot
+	AuditCheckStop     = "check-stop"  // A server stopped by the check of the running servers, it has no slot
+	AuditRestoreBackup = "restore-backup"
+	AuditLinkAccount   = "link-account"
+)
+
+// Type AuditEntry is a struct that represents an action done on the servers, who did it and how it went
+type AuditEntry struct {
+	ID         int64     `json:"id"`
+	Date       time.Time `json:"date"`
+	ActorType  string    `json:"actorType"`
+	Actor      string    `json:"actor"`
+	Action     string    `json:"action"`
+	ServerID   int       `json:"serverId,omitempty"` // 0 when the action is not about a single server
+	Parameters string    `json:"parameters,omitempty"`
+	Success    bool      `json:"success"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// Type PlayerConnection is a struct that represents a connection of a player to a server
+type PlayerConnection struct {
+	PlayerID int
+	ServerID int
+	Date     time.Time // UTC
+}
+
+// MinecraftStatsCounters are the statistics of a Minecraft player that only grow, kept in the history
+type MinecraftStatsCounters struct {
+	TimePlayed      int `json:"timePlayed"` // In ticks
+	Deaths          int `json:"deaths"`
+	Kills           int `json:"kills"`
+	PlayerKills     int `json:"playerKills"`
+	BlocksDestroyed int `json:"blocksDestroyed"`
+	BlocksPlaced    int `json:"blocksPlaced"`
+	TotalDistance   int `json:"totalDistanceCm"`
+	Advancements    int `json:"advancements"`
+}
+
+// Type MinecraftStatsSnapshot is a struct that represents the counters of a player on a server at a given time
+type MinecraftStatsSnapshot struct {
+	ServerID   int
+	PlayerUUID string
+	RecordedAt time.Time // UTC
+	MinecraftStatsCounters
+}
+
+// Type MinecraftStatsDelta is a struct that represents how much the counters of a player on a server grew during a period
+type MinecraftStatsDelta struct {
+	ServerID   int
+	PlayerUUID string
+	From       time.Time // UTC, the snapshot the period starts from, zero for a player first seen during the period
+	To         time.Time // UTC, the last snapshot of the period
+	MinecraftStatsCounters
+}
+
+// Type Backup is a struct that represents a world backup archive on disk
+type Backup struct {
+	ID        string
+	ServerID  int
+	Path      string
+	Size      int64
+	CreatedAt time.Time
 }
 
 // Trigger is a struct that represents a trigger
@@ -0,0 +1,62 @@
+package presence
+
+// This package keeps track, in memory, of the players currently connected to each server, fed by the console triggers
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Corentin-cott/ServeurSentinel/internal/metrics"
+)
+
+var (
+	mu     sync.RWMutex
+	online = make(map[int]map[string]time.Time) // serverID -> player name -> connection time
+)
+
+// PlayerJoined marks a player as connected to a server
+func PlayerJoined(serverID int, playerName string) {
+	mu.Lock()
+	defer mu.Unlock()
+	if online[serverID] == nil {
+		online[serverID] = make(map[string]time.Time)
+	}
+	online[serverID][playerName] = time.Now()
+	metrics.SetPlayersOnline(serverID, len(online[serverID]))
+}
+
+// PlayerLeft marks a player as disconnected from a server
+func PlayerLeft(serverID int, playerName string) {
+	mu.Lock()
+	defer mu.Unlock()
+	delete(online[serverID], playerName)
+	metrics.SetPlayersOnline(serverID, len(online[serverID]))
+}
+
+// ResetServer forgets every player of a server, used when the server starts, stops or crashes
+func ResetServer(serverID int) {
+	mu.Lock()
+	defer mu.Unlock()
+	delete(online, serverID)
+	metrics.SetPlayersOnline(serverID, 0)
+}
+
+// GetOnlinePlayers returns the names of the players connected to a server, sorted alphabetically
+func GetOnlinePlayers(serverID int) []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	players := make([]string, 0, len(online[serverID]))
+	for name := range online[serverID] {
+		players = append(players, name)
+	}
+	sort.Strings(players)
+	return players
+}
+
+// CountOnlinePlayers returns the number of players connected to a server
+func CountOnlinePlayers(serverID int) int {
+	mu.RLock()
+	defer mu.RUnlock()
+	return len(online[serverID])
+}
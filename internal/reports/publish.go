@@ -0,0 +1,75 @@
+package reports
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Corentin-cott/ServeurSentinel/config"
+	"github.com/Corentin-cott/ServeurSentinel/internal/discord"
+	"github.com/Corentin-cott/ServeurSentinel/internal/logging"
+)
+
+var logger = logging.For("reports")
+
+// Export writes a report as Markdown and HTML in a directory, ex: bilan-2024-06-16.md, and returns the written files
+func Export(report Report, directory string) ([]string, error) {
+	if err := os.MkdirAll(directory, 0750); err != nil {
+		return nil, fmt.Errorf("ERROR WHILE CREATING REPORTS DIRECTORY: %v", err)
+	}
+	page, err := HTML(report)
+	if err != nil {
+		return nil, err
+	}
+
+	baseName := "bilan-" + report.To.In(config.Get().Location()).Format("2006-01-02")
+	files := map[string]string{
+		filepath.Join(directory, baseName+".md"):   Markdown(report),
+		filepath.Join(directory, baseName+".html"): page,
+	}
+	var written []string
+	for path, content := range files {
+		if err := os.WriteFile(path, []byte(content), 0640); err != nil {
+			return written, fmt.Errorf("ERROR WHILE WRITING THE REPORT: %v", err)
+		}
+		written = append(written, path)
+	}
+	return written, nil
+}
+
+// Publish builds the report of the configured period, ending now, posts it on Discord and exports it
+func Publish() error {
+	conf := config.Get()
+	period, err := time.ParseDuration(conf.Report.Period)
+	if err != nil {
+		return fmt.Errorf("INVALID REPORT PERIOD: %v", err)
+	}
+	if conf.Report.ChannelID == "" && conf.Report.ExportPath == "" {
+		logger.Info("No report to make, report.channelID and report.exportPath are empty")
+		return nil
+	}
+
+	now := time.Now()
+	report, err := Build(now.Add(-period), now)
+	if err != nil {
+		return err
+	}
+
+	if conf.Report.ChannelID != "" {
+		if err := discord.SendDiscordEmbedWithModel(conf.Bots["mineotterBot"], conf.Report.ChannelID, Embed(report)); err != nil {
+			return fmt.Errorf("FAILED TO POST THE REPORT: %v", err)
+		}
+		logger.Info("Report posted", "channel_id", conf.Report.ChannelID)
+	}
+	if conf.Report.ExportPath != "" {
+		files, err := Export(report, conf.Report.ExportPath)
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			logger.Info("Report written", "file", file)
+		}
+	}
+	return nil
+}
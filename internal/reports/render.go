@@ -0,0 +1,211 @@
+package reports
+
+import (
+	"fmt"
+	"html/template"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Corentin-cott/ServeurSentinel/config"
+	"github.com/Corentin-cott/ServeurSentinel/internal/models"
+)
+
+// How many advancements a report lists before summing up the others
+const advancementsShown = 10
+
+// Discord refuses the embeds whose description is longer
+const embedDescriptionLimit = 4096
+
+// Title is the title of a report, ex: "Bilan de la semaine" or "Bilan du 01/06 au 15/06"
+func Title(report Report) string {
+	if report.To.Sub(report.From) == 7*24*time.Hour {
+		return "Bilan de la semaine"
+	}
+	location := config.Get().Location()
+	return "Bilan du " + report.From.In(location).Format("02/01") + " au " + report.To.In(location).Format("02/01")
+}
+
+// Embed is the Discord embed of a report
+func Embed(report Report) models.EmbedConfig {
+	conf := config.Get()
+	color := conf.EmbedColors.Good
+	for _, server := range report.Servers {
+		if server.Crashes > 0 {
+			color = conf.EmbedColors.Warning
+		}
+	}
+
+	var description strings.Builder
+	for _, section := range sections(report) {
+		fmt.Fprintf(&description, "**%s**\n", section.title)
+		for _, line := range section.lines {
+			description.WriteString(line + "\n")
+		}
+		description.WriteString("\n")
+	}
+
+	return models.EmbedConfig{
+		Title:       "📊 " + Title(report),
+		Description: truncate(strings.TrimSpace(description.String()), embedDescriptionLimit),
+		Color:       color,
+		Footer:      "Du " + formatDate(report.From) + " au " + formatDate(report.To),
+	}
+}
+
+// Markdown is a report as a Markdown document
+func Markdown(report Report) string {
+	var document strings.Builder
+	fmt.Fprintf(&document, "# %s\n\n_Du %s au %s_\n", Title(report), formatDate(report.From), formatDate(report.To))
+	for _, section := range sections(report) {
+		fmt.Fprintf(&document, "\n## %s\n\n", section.title)
+		for _, line := range section.lines {
+			document.WriteString("- " + line + "\n")
+		}
+	}
+	return document.String()
+}
+
+var htmlTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="fr">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; max-width: 48em; margin: 2em auto; color: #222; }
+h1 { margin-bottom: 0; }
+.period { color: #666; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p class="period">Du {{.From}} au {{.To}}</p>
+{{range .Sections}}<h2>{{.Title}}</h2>
+<ul>
+{{range .Lines}}<li>{{.}}</li>
+{{end}}</ul>
+{{end}}</body>
+</html>
+`))
+
+// HTML is a report as an HTML page
+func HTML(report Report) (string, error) {
+	type htmlSection struct {
+		Title string
+		Lines []string
+	}
+	data := struct {
+		Title    string
+		From     string
+		To       string
+		Sections []htmlSection
+	}{Title: Title(report), From: formatDate(report.From), To: formatDate(report.To)}
+	for _, section := range sections(report) {
+		// The lines are written for Discord and Markdown, the bold marks are removed
+		lines := make([]string, len(section.lines))
+		for i, line := range section.lines {
+			lines[i] = strings.ReplaceAll(line, "**", "")
+		}
+		data.Sections = append(data.Sections, htmlSection{Title: section.title, Lines: lines})
+	}
+
+	var page strings.Builder
+	if err := htmlTemplate.Execute(&page, data); err != nil {
+		return "", fmt.Errorf("ERROR WHILE RENDERING THE REPORT: %v", err)
+	}
+	return page.String(), nil
+}
+
+// section is a part of a report, the same for every format
+type section struct {
+	title string
+	lines []string
+}
+
+func sections(report Report) []section {
+	period := report.To.Sub(report.From)
+
+	players := section{title: "👥 Joueurs"}
+	players.lines = append(players.lines, fmt.Sprintf("**%d** joueurs connectés, dont **%d** nouveaux", report.UniquePlayers, len(report.NewPlayers)))
+	if len(report.NewPlayers) > 0 {
+		players.lines = append(players.lines, "Bienvenue à "+strings.Join(report.NewPlayers, ", "))
+	}
+	if report.PeakPlayers > 0 {
+		players.lines = append(players.lines, fmt.Sprintf("Pic de **%d** joueurs en même temps, le %s", report.PeakPlayers, formatDateTime(report.PeakAt)))
+	}
+
+	servers := section{title: "🖥️ Serveurs"}
+	for _, server := range report.Servers {
+		line := fmt.Sprintf("**%s** : %d joueurs, %s de jeu", server.Name, server.UniquePlayers, formatSeconds(server.Playtime))
+		if server.PeakPlayers > 0 {
+			line += fmt.Sprintf(", pic de %d", server.PeakPlayers)
+		}
+		if server.UptimeKnown {
+			line += fmt.Sprintf(", en ligne %.0f%% du temps", server.UptimePercent(period))
+		}
+		switch server.Crashes {
+		case 0:
+		case 1:
+			line += ", 1 crash"
+		default:
+			line += fmt.Sprintf(", %d crashs", server.Crashes)
+		}
+		servers.lines = append(servers.lines, line)
+	}
+	if len(servers.lines) == 0 {
+		servers.lines = append(servers.lines, "Aucune activité.")
+	}
+
+	result := []section{players, servers}
+	if len(report.TopChatters) > 0 {
+		result = append(result, section{title: "💬 Les plus bavards", lines: rankingLines(report.TopChatters, "message", "messages")})
+	}
+	if len(report.MostDeaths) > 0 {
+		result = append(result, section{title: "💀 Les plus morts", lines: rankingLines(report.MostDeaths, "mort", "morts")})
+	}
+	if len(report.NewAdvancements) > 0 {
+		advancements := section{title: "🏅 Nouveaux progrès"}
+		for i, advancement := range report.NewAdvancements {
+			if i == advancementsShown {
+				advancements.lines = append(advancements.lines, fmt.Sprintf("et %d autres", len(report.NewAdvancements)-advancementsShown))
+				break
+			}
+			advancements.lines = append(advancements.lines, fmt.Sprintf("**%s** : %s (%s)", advancement.PlayerName, advancement.Advancement, advancement.ServerName))
+		}
+		result = append(result, advancements)
+	}
+	return result
+}
+
+func rankingLines(ranking []RankedPlayer, singular string, plural string) []string {
+	lines := make([]string, len(ranking))
+	for i, player := range ranking {
+		unit := plural
+		if player.Value == 1 {
+			unit = singular
+		}
+		lines[i] = fmt.Sprintf("%d. **%s** : %d %s", i+1, player.Name, player.Value, unit)
+	}
+	return lines
+}
+
+func formatSeconds(seconds int64) string {
+	return fmt.Sprintf("%dh%02d", seconds/3600, seconds%3600/60)
+}
+
+func formatDate(t time.Time) string {
+	return t.In(config.Get().Location()).Format("02/01/2006")
+}
+
+func formatDateTime(t time.Time) string {
+	return t.In(config.Get().Location()).Format("02/01/2006 à 15:04")
+}
+
+// truncate cuts a text to a number of characters, ending it with "…" when it is cut
+func truncate(text string, limit int) string {
+	if utf8.RuneCountInString(text) <= limit {
+		return text
+	}
+	runes := []rune(text)
+	return string(runes[:limit-1]) + "…"
+}
@@ -0,0 +1,291 @@
+package reports
+
+// This package contains the ACTIVITY REPORTS : what happened on the servers during a period, built on the connections, the
+// server events and the statistics history. A report is posted as a Discord embed and can be exported as Markdown or HTML
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Corentin-cott/ServeurSentinel/internal/db"
+	"github.com/Corentin-cott/ServeurSentinel/internal/models"
+)
+
+// How many players the rankings of a report show
+const rankingSize = 5
+
+// Report is the activity of the servers during a period
+type Report struct {
+	From            time.Time           `json:"from"`
+	To              time.Time           `json:"to"`
+	UniquePlayers   int                 `json:"uniquePlayers"`
+	NewPlayers      []string            `json:"newPlayers"`
+	PeakPlayers     int                 `json:"peakPlayers"` // Players connected at the same time, on all the servers
+	PeakAt          time.Time           `json:"peakAt"`
+	Servers         []ServerActivity    `json:"servers"`
+	TopChatters     []RankedPlayer      `json:"topChatters"`
+	MostDeaths      []RankedPlayer      `json:"mostDeaths"`
+	NewAdvancements []AdvancementEarned `json:"newAdvancements"`
+}
+
+// ServerActivity is the activity of a server during a period
+type ServerActivity struct {
+	ServerID      int    `json:"serverId"`
+	Name          string `json:"name"`
+	Game          string `json:"game"`
+	UniquePlayers int    `json:"uniquePlayers"`
+	Playtime      int64  `json:"playtimeSeconds"` // From the statistics for Minecraft, else from the connections
+	PeakPlayers   int    `json:"peakPlayers"`
+	Uptime        int64  `json:"uptimeSeconds"`
+	UptimeKnown   bool   `json:"uptimeKnown"` // False when the server never started nor stopped since the events are saved
+	Crashes       int    `json:"crashes"`
+}
+
+// UptimePercent is the part of the period the server was running
+func (s ServerActivity) UptimePercent(period time.Duration) float64 {
+	if period <= 0 {
+		return 0
+	}
+	return min(100, 100*float64(s.Uptime)/period.Seconds())
+}
+
+// RankedPlayer is a player in a ranking of a report
+type RankedPlayer struct {
+	Name  string `json:"name"`
+	Value int    `json:"value"`
+}
+
+// AdvancementEarned is an advancement made during the period
+type AdvancementEarned struct {
+	PlayerName  string    `json:"playerName"`
+	Advancement string    `json:"advancement"`
+	ServerName  string    `json:"serverName"`
+	Date        time.Time `json:"date"`
+}
+
+// serverReplay is the state of a server while its events are replayed
+type serverReplay struct {
+	activity       *ServerActivity
+	running        bool
+	runningSince   time.Time
+	uptime         time.Duration
+	online         map[string]time.Time // Player name -> connection date
+	sessionsLength time.Duration
+	players        map[int]bool
+}
+
+func (r *serverReplay) start(date time.Time) {
+	if !r.running {
+		r.running = true
+		r.runningSince = date
+		r.activity.UptimeKnown = true
+	}
+}
+
+func (r *serverReplay) stop(date time.Time) {
+	if r.running {
+		r.uptime += date.Sub(r.runningSince)
+	}
+	r.running = false
+	r.activity.UptimeKnown = true
+	r.closeSessions(date)
+}
+
+func (r *serverReplay) closeSessions(date time.Time) {
+	for name, joinedAt := range r.online {
+		r.sessionsLength += date.Sub(joinedAt)
+		delete(r.online, name)
+	}
+}
+
+// Build builds the report of a period
+func Build(from time.Time, to time.Time) (Report, error) {
+	from, to = from.UTC(), to.UTC()
+	report := Report{From: from, To: to, NewPlayers: []string{}, Servers: []ServerActivity{}, TopChatters: []RankedPlayer{},
+		MostDeaths: []RankedPlayer{}, NewAdvancements: []AdvancementEarned{}}
+
+	servers, err := db.GetAllServers()
+	if err != nil {
+		return report, err
+	}
+	replays := make(map[int]*serverReplay)
+	var serverIDs []int
+	getReplay := func(serverID int) *serverReplay {
+		if replay, ok := replays[serverID]; ok {
+			return replay
+		}
+		activity := &ServerActivity{ServerID: serverID, Name: "?"}
+		for _, server := range servers {
+			if server.ID == serverID {
+				activity.Name, activity.Game = server.Nom, server.Jeu
+			}
+		}
+		replay := &serverReplay{activity: activity, online: make(map[string]time.Time), players: make(map[int]bool)}
+		replays[serverID] = replay
+		serverIDs = append(serverIDs, serverID)
+		return replay
+	}
+
+	// Connections : unique players
+	connections, err := db.GetConnectionLogs(from, to)
+	if err != nil {
+		return report, err
+	}
+	uniquePlayers := make(map[int]bool)
+	for _, connection := range connections {
+		uniquePlayers[connection.PlayerID] = true
+		getReplay(connection.ServerID).players[connection.PlayerID] = true
+	}
+	report.UniquePlayers = len(uniquePlayers)
+
+	// New players : first connection during the period
+	newPlayers, err := db.FindPlayers(db.PlayerFilter{FirstSeenSince: from})
+	if err != nil {
+		return report, err
+	}
+	for _, player := range newPlayers {
+		if player.PremiereCo.Before(to) {
+			report.NewPlayers = append(report.NewPlayers, playerName(player))
+		}
+	}
+	sort.Slice(report.NewPlayers, func(i, j int) bool {
+		return strings.ToLower(report.NewPlayers[i]) < strings.ToLower(report.NewPlayers[j])
+	})
+
+	// Events : uptime, crashes, connected players, messages, deaths and advancements
+	states, err := db.GetLastServerStates(from)
+	if err != nil {
+		return report, err
+	}
+	lastStateIDs := make(map[int]int64)
+	for _, state := range states {
+		replay := getReplay(state.ServerID)
+		replay.activity.UptimeKnown = true
+		if state.Type == models.EventServerStarted {
+			replay.start(from)
+		}
+		lastStateIDs[state.ServerID] = state.ID
+	}
+
+	// The players still connected at the start of the period, joined since the last start, stop or crash of their server
+	lastPlayerEvents, err := db.GetLastPlayerEvents(from)
+	if err != nil {
+		return report, err
+	}
+	for _, event := range lastPlayerEvents {
+		if event.Type != models.EventPlayerJoined || event.ID < lastStateIDs[event.ServerID] {
+			continue
+		}
+		replay := getReplay(event.ServerID)
+		replay.start(from) // A player can only join a running server
+		replay.online[event.PlayerName] = from
+		replay.activity.PeakPlayers = len(replay.online)
+		report.PeakPlayers++
+		report.PeakAt = from
+	}
+
+	events, err := db.GetServerEvents(from, to)
+	if err != nil {
+		return report, err
+	}
+	messages := make(map[string]int)
+	deaths := make(map[string]int)
+	for _, event := range events {
+		replay := getReplay(event.ServerID)
+		switch event.Type {
+		case models.EventServerStarted:
+			replay.closeSessions(event.Date)
+			replay.start(event.Date)
+		case models.EventServerStopped:
+			replay.stop(event.Date)
+		case models.EventServerCrashed:
+			replay.stop(event.Date)
+			replay.activity.Crashes++
+		case models.EventPlayerJoined:
+			replay.start(event.Date) // A player can only join a running server
+			if _, ok := replay.online[event.PlayerName]; !ok {
+				replay.online[event.PlayerName] = event.Date
+			}
+			replay.activity.PeakPlayers = max(replay.activity.PeakPlayers, len(replay.online))
+			total := 0
+			for _, other := range replays {
+				total += len(other.online)
+			}
+			if total > report.PeakPlayers {
+				report.PeakPlayers = total
+				report.PeakAt = event.Date
+			}
+		case models.EventPlayerLeft:
+			if joinedAt, ok := replay.online[event.PlayerName]; ok {
+				replay.sessionsLength += event.Date.Sub(joinedAt)
+				delete(replay.online, event.PlayerName)
+			}
+		case models.EventPlayerMessage:
+			messages[event.PlayerName]++
+		case models.EventPlayerDeath:
+			deaths[event.PlayerName]++
+		case models.EventAdvancement:
+			report.NewAdvancements = append(report.NewAdvancements, AdvancementEarned{
+				PlayerName: event.PlayerName, Advancement: event.Details, ServerName: replay.activity.Name, Date: event.Date,
+			})
+		}
+	}
+	for _, replay := range replays {
+		if replay.running {
+			replay.uptime += to.Sub(replay.runningSince)
+		}
+		replay.closeSessions(to)
+		replay.activity.UniquePlayers = len(replay.players)
+		replay.activity.Uptime = int64(replay.uptime.Seconds())
+		replay.activity.Playtime = int64(replay.sessionsLength.Seconds())
+	}
+	report.TopChatters = rank(messages)
+	report.MostDeaths = rank(deaths)
+
+	// Statistics : the play time of the Minecraft servers, more precise than the connections
+	deltas, err := db.GetMinecraftStatsDeltas(0, from, to)
+	if err != nil {
+		return report, err
+	}
+	statsPlaytime := make(map[int]int64)
+	for _, delta := range deltas {
+		statsPlaytime[delta.ServerID] += int64(delta.TimePlayed / 20)
+	}
+	for serverID, playtime := range statsPlaytime {
+		getReplay(serverID).activity.Playtime = playtime
+	}
+
+	sort.Ints(serverIDs)
+	for _, serverID := range serverIDs {
+		report.Servers = append(report.Servers, *replays[serverID].activity)
+	}
+	return report, nil
+}
+
+// rank sorts the players by value, the highest first, and keeps the first ones
+func rank(values map[string]int) []RankedPlayer {
+	ranking := make([]RankedPlayer, 0, len(values))
+	for name, value := range values {
+		ranking = append(ranking, RankedPlayer{Name: name, Value: value})
+	}
+	sort.Slice(ranking, func(i, j int) bool {
+		if ranking[i].Value != ranking[j].Value {
+			return ranking[i].Value > ranking[j].Value
+		}
+		return strings.ToLower(ranking[i].Name) < strings.ToLower(ranking[j].Name)
+	})
+	if len(ranking) > rankingSize {
+		ranking = ranking[:rankingSize]
+	}
+	return ranking
+}
+
+// playerName returns the last name of a player, or his account ID if none is known
+func playerName(player models.Player) string {
+	names, err := db.GetPlayerNames(player.ID)
+	if err != nil || len(names) == 0 {
+		return player.CompteID
+	}
+	return names[0].Pseudo
+}
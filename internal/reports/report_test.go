@@ -0,0 +1,114 @@
+package reports
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Corentin-cott/ServeurSentinel/internal/db"
+	"github.com/Corentin-cott/ServeurSentinel/internal/models"
+)
+
+func TestBuild(t *testing.T) {
+	from := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+	to := from.Add(24 * time.Hour)
+
+	repository := db.NewMemoryRepository()
+	repository.AddServer(models.Server{ID: 1, Nom: "Survie", Jeu: "Minecraft"})
+	repository.AddServer(models.Server{ID: 2, Nom: "Palworld", Jeu: "Palworld"})
+	repository.Connections = []db.MemoryConnection{
+		{PlayerID: 2, ServerID: 1, Date: from.Add(time.Hour)},
+		{PlayerID: 3, ServerID: 2, Date: from.Add(7 * time.Hour)},
+	}
+	events := []struct {
+		serverID   int
+		eventType  string
+		playerName string
+		date       time.Time
+	}{
+		// Before the period : Survie is running with Steve connected, Palworld crashed with Zoe connected
+		{1, models.EventServerStarted, "", from.Add(-48 * time.Hour)},
+		{1, models.EventPlayerJoined, "Alex", from.Add(-3 * time.Hour)},
+		{1, models.EventPlayerJoined, "Steve", from.Add(-2 * time.Hour)},
+		{1, models.EventPlayerLeft, "Alex", from.Add(-time.Hour)},
+		{2, models.EventServerStarted, "", from.Add(-10 * time.Hour)},
+		{2, models.EventPlayerJoined, "Zoe", from.Add(-6 * time.Hour)},
+		{2, models.EventServerCrashed, "", from.Add(-5 * time.Hour)},
+		// During the period
+		{1, models.EventPlayerJoined, "Alex", from.Add(time.Hour)},
+		{1, models.EventPlayerMessage, "Steve", from.Add(90 * time.Minute)},
+		{1, models.EventPlayerLeft, "Steve", from.Add(2 * time.Hour)},
+		{1, models.EventPlayerDeath, "Alex", from.Add(3 * time.Hour)},
+		{1, models.EventServerCrashed, "", from.Add(4 * time.Hour)},
+		{1, models.EventServerStarted, "", from.Add(5 * time.Hour)},
+		{2, models.EventServerStarted, "", from.Add(6 * time.Hour)},
+		{2, models.EventPlayerJoined, "Zoe", from.Add(7 * time.Hour)},
+	}
+	for i, event := range events {
+		repository.Events = append(repository.Events, models.ServerEvent{
+			ID: int64(i + 1), ServerID: event.serverID, Type: event.eventType, PlayerName: event.playerName, Date: event.date,
+		})
+	}
+	db.SetRepository(repository)
+	t.Cleanup(func() { db.SetRepository(nil) })
+
+	report, err := Build(from, to)
+	if err != nil {
+		t.Fatalf("Build() failed: %v", err)
+	}
+
+	if report.UniquePlayers != 2 {
+		t.Errorf("UniquePlayers = %d, want 2", report.UniquePlayers)
+	}
+	// Steve, connected before the period, and Alex
+	if report.PeakPlayers != 2 || !report.PeakAt.Equal(from.Add(time.Hour)) {
+		t.Errorf("peak = %d at %v, want 2 at %v", report.PeakPlayers, report.PeakAt, from.Add(time.Hour))
+	}
+	if want := []RankedPlayer{{"Steve", 1}}; !slices.Equal(report.TopChatters, want) {
+		t.Errorf("TopChatters = %v, want %v", report.TopChatters, want)
+	}
+	if want := []RankedPlayer{{"Alex", 1}}; !slices.Equal(report.MostDeaths, want) {
+		t.Errorf("MostDeaths = %v, want %v", report.MostDeaths, want)
+	}
+
+	want := []ServerActivity{
+		{
+			ServerID: 1, Name: "Survie", Game: "Minecraft", UniquePlayers: 1,
+			Playtime:    int64((5 * time.Hour).Seconds()), // Steve from the start of the period, Alex until the crash
+			PeakPlayers: 2,
+			Uptime:      int64((23 * time.Hour).Seconds()), // Down from the crash to the restart
+			UptimeKnown: true,
+			Crashes:     1,
+		},
+		{
+			ServerID: 2, Name: "Palworld", Game: "Palworld", UniquePlayers: 1,
+			Playtime:    int64((17 * time.Hour).Seconds()), // Zoe left with the crash before the period
+			PeakPlayers: 1,
+			Uptime:      int64((18 * time.Hour).Seconds()),
+			UptimeKnown: true,
+		},
+	}
+	if !slices.Equal(report.Servers, want) {
+		t.Errorf("Servers = %+v\nwant %+v", report.Servers, want)
+	}
+}
+
+func TestBuildUnknownUptime(t *testing.T) {
+	from := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+	repository := db.NewMemoryRepository()
+	repository.AddServer(models.Server{ID: 1, Nom: "Survie", Jeu: "Minecraft"})
+	repository.Events = []models.ServerEvent{
+		{ID: 1, ServerID: 1, Type: models.EventPlayerMessage, PlayerName: "Steve", Date: from.Add(time.Hour)},
+	}
+	db.SetRepository(repository)
+	t.Cleanup(func() { db.SetRepository(nil) })
+
+	report, err := Build(from, from.Add(24*time.Hour))
+	if err != nil {
+		t.Fatalf("Build() failed: %v", err)
+	}
+	// Never started nor stopped since the events are saved
+	if len(report.Servers) != 1 || report.Servers[0].UptimeKnown || report.Servers[0].Uptime != 0 {
+		t.Errorf("Servers = %+v, want Survie without a known uptime", report.Servers)
+	}
+}
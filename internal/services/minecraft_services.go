@@ -5,18 +5,28 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"regexp"
+	"time"
 
+	"github.com/Corentin-cott/ServeurSentinel/config"
+	"github.com/Corentin-cott/ServeurSentinel/internal/logging"
 	"github.com/Corentin-cott/ServeurSentinel/internal/models"
 )
 
-// GetMinecraftPlayerUUID gets the UUID of a Minecraft player by their username
+var logger = logging.For("services")
+
+// GetMinecraftPlayerUUID gets the UUID of a Minecraft player by their username, from the UUID cache or else the Mojang API
 func GetMinecraftPlayerUUID(playerName string) (string, error) {
+	if playerUUID, ok := getCachedUUID(playerName); ok {
+		return playerUUID, nil
+	}
+
 	// Send a request to the Mojang API to get the player UUID by their username
-	APIUrl := "https://api.mojang.com/users/profiles/minecraft/" + playerName
-	fmt.Println("Getting Minecraft player UUID for player " + playerName + " with API URL : " + APIUrl + " ...")
-	resp, err := http.Get(APIUrl)
+	APIUrl := config.Get().MinecraftAPI.ProfilesURL + url.PathEscape(playerName)
+	logger.Debug("Asking the Minecraft profiles API for a player UUID", "player", playerName, "url", APIUrl)
+	resp, err := httpClient.Get(APIUrl)
 	if err != nil {
 		return "", fmt.Errorf("FAILED TO SEND REQUEST TO MOJANG API: %v", err)
 	}
@@ -43,17 +53,26 @@ func GetMinecraftPlayerUUID(playerName string) (string, error) {
 
 	// Format the UUID to the standard format
 	playerUUID = FormatMinecraftUUID(playerUUID)
+	setCachedUUID(playerName, playerUUID)
 
-	fmt.Println("Player UUID retrieved successfully : " + playerUUID + " for player name : " + playerName)
+	logger.Debug("Player UUID found", "player", playerName, "player_uuid", playerUUID)
 	return playerUUID, nil
 }
 
-// GetMinecraftPlayerHeadURL gets the URL of the head of a Minecraft player by their UUID
+// GetMinecraftPlayerHeadURL gets the URL of the head of a Minecraft player by their UUID, checked once per UUID cache TTL
 func GetMinecraftPlayerHeadURL(playerUUID string) (string, error) {
-	// Send a request to the Crafatar API to get the player head URL by their UUID
-	APIUrl := "https://minotar.net/helm/" + playerUUID + "/50.png"
-	fmt.Println("Getting Minecraft player head URL for player " + playerUUID + " with API URL : " + APIUrl + " ...")
-	resp, err := http.Get(APIUrl)
+	APIUrl := config.Get().MinecraftAPI.HeadsURL + playerUUID + "/50.png"
+
+	uuidCacheMutex.Lock()
+	checkedAt, ok := headURLsChecked[APIUrl]
+	uuidCacheMutex.Unlock()
+	if ok && time.Since(checkedAt) <= getUUIDCacheTTL() {
+		return APIUrl, nil
+	}
+
+	// Send a request to the heads API to check it knows the player
+	logger.Debug("Checking a player head with the heads API", "player_uuid", playerUUID, "url", APIUrl)
+	resp, err := httpClient.Get(APIUrl)
 	if err != nil {
 		return "", fmt.Errorf("FAILED TO SEND REQUEST TO CRAFATAR API: %v", err)
 	}
@@ -63,13 +82,17 @@ func GetMinecraftPlayerHeadURL(playerUUID string) (string, error) {
 		return "", fmt.Errorf("FAILED TO GET PLAYER HEAD URL, STATUS CODE: %d", resp.StatusCode)
 	}
 
-	fmt.Println("Player head URL retrieved successfully for player " + playerUUID + " : " + APIUrl)
+	uuidCacheMutex.Lock()
+	headURLsChecked[APIUrl] = time.Now()
+	uuidCacheMutex.Unlock()
+
+	logger.Debug("Player head found", "player_uuid", playerUUID, "url", APIUrl)
 	return APIUrl, nil
 }
 
 // GetMinecraftPlayerServerSave gets a list of the Minecraft player UUIDs inside a server directory
 func GetMinecraftPlayerServerUUIDSaves(server models.Server) ([]string, error) {
-	fmt.Println("Getting Minecraft player saves for server " + server.Nom + " inside directory " + server.PathServ + server.NomMonde + "/stats ...")
+	logger.Debug("Reading the Minecraft player saves", "server_id", server.ID, "directory", server.PathServ+server.NomMonde+"/stats")
 
 	if server.Jeu != "Minecraft" {
 		return nil, fmt.Errorf("%s IS NOT A MINECRAFT SERVER", server.Nom)
@@ -100,79 +123,10 @@ func GetMinecraftPlayerServerUUIDSaves(server models.Server) ([]string, error) {
 
 	nbPlayerFound := len(playerUUIDs)
 
-	fmt.Println("Player saves retrieved successfully for server " + server.Nom + " : " + fmt.Sprint(nbPlayerFound) + " players found.")
+	logger.Debug("Minecraft player saves read", "server_id", server.ID, "players", nbPlayerFound)
 	return playerUUIDs, nil
 }
 
-// GetMinecraftPlayerGameStatistics gets the game statistics of a Minecraft player with his server save
-func GetMinecraftPlayerGameStatistics(playerID int, playerUUID string, server models.Server) (int, string, models.MinecraftPlayerGameStatistics, error) {
-	fmt.Println("Getting Minecraft statistics for player " + playerUUID + " in server " + server.Nom + "...")
-
-	if server.Jeu != "Minecraft" {
-		return 0, "", models.MinecraftPlayerGameStatistics{}, fmt.Errorf("%s IS NOT A MINECRAFT SERVER", server.Nom)
-	}
-
-	// Get the file path
-	playerStatsFile := server.PathServ + server.NomMonde + "/stats/" + FormatMinecraftUUID(playerUUID) + ".json"
-
-	// Check if file exists
-	if _, err := os.Stat(playerStatsFile); os.IsNotExist(err) {
-		return 0, "", models.MinecraftPlayerGameStatistics{}, fmt.Errorf("PLAYER STATISTICS FILE NOT FOUND")
-	}
-
-	// Read file
-	playerStatsJSON, err := os.ReadFile(playerStatsFile)
-	if err != nil {
-		return 0, "", models.MinecraftPlayerGameStatistics{}, fmt.Errorf("FAILED TO READ PLAYER STATISTICS FILE: %v", err)
-	}
-
-	// Define a temporary struct to match Minecraft's JSON structure
-	var rawStats struct {
-		Stats struct {
-			Custom  map[string]int `json:"minecraft:custom"`
-			Mined   map[string]int `json:"minecraft:mined"`
-			Killed  map[string]int `json:"minecraft:killed"`
-			Crafted map[string]int `json:"minecraft:crafted"`
-			Used    map[string]int `json:"minecraft:used"`
-			Broken  map[string]int `json:"minecraft:broken"`
-		} `json:"stats"`
-	}
-
-	// Unmarshal JSON into temporary struct
-	if err := json.Unmarshal(playerStatsJSON, &rawStats); err != nil {
-		return 0, "", models.MinecraftPlayerGameStatistics{}, fmt.Errorf("FAILED TO UNMARSHAL PLAYER STATISTICS JSON: %v", err)
-	}
-
-	// Depending on the server version, the stats can be in different formats/keys
-	var play_time int
-	if rawStats.Stats.Custom["minecraft:play_time"] == 0 {
-		play_time = rawStats.Stats.Custom["minecraft:play_one_minute"]
-	} else {
-		play_time = rawStats.Stats.Custom["minecraft:play_time"]
-	}
-
-	// Map extracted data to your model
-	playerStats := models.MinecraftPlayerGameStatistics{
-		TimePlayed:       play_time,
-		Deaths:           rawStats.Stats.Custom["minecraft:deaths"],
-		Kills:            rawStats.Stats.Killed["minecraft:player"],
-		PlayerKills:      rawStats.Stats.Custom["minecraft:player_kills"],
-		BlocksDestroyed:  sumValues(rawStats.Stats.Mined),
-		BlocksPlaced:     sumValues(rawStats.Stats.Used),
-		TotalDistance:    rawStats.Stats.Custom["minecraft:walk_one_cm"],
-		DistanceByFoot:   rawStats.Stats.Custom["minecraft:walk_one_cm"],
-		DistanceByElytra: rawStats.Stats.Custom["minecraft:aviate_one_cm"],
-		DistanceByFlight: rawStats.Stats.Custom["minecraft:fly_one_cm"],
-		ItemsCrafted:     rawStats.Stats.Crafted,
-		ItemsBroken:      rawStats.Stats.Broken,
-		MobsKilled:       rawStats.Stats.Killed,
-		Achievements:     make(map[string]bool),
-	}
-
-	fmt.Println("Stats registered for player " + playerUUID + " in server " + server.Nom)
-	return playerID, playerUUID, playerStats, nil
-}
-
 func FormatMinecraftUUID(uuid string) string {
 	if len(uuid) != 32 {
 		return uuid // Return as is if not in expected format
@@ -193,11 +147,3 @@ func IsValidMinecraftUUID(uuid string) (bool, error) {
 	}
 	return uuidBool, nil
 }
-
-func sumValues(m map[string]int) int {
-	total := 0
-	for _, value := range m {
-		total += value
-	}
-	return total
-}
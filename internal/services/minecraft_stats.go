@@ -0,0 +1,354 @@
+package services
+
+// This file contains the READING of the Minecraft statistics : the stats file of a player, in the format of 1.13 and later
+// or the older flat format, and his advancements file for the completed advancements
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/Corentin-cott/ServeurSentinel/internal/logging"
+	"github.com/Corentin-cott/ServeurSentinel/internal/models"
+)
+
+// Categories of the stats file since 1.13
+const (
+	statCustom   = "minecraft:custom"
+	statMined    = "minecraft:mined"
+	statUsed     = "minecraft:used"
+	statCrafted  = "minecraft:crafted"
+	statBroken   = "minecraft:broken"
+	statPickedUp = "minecraft:picked_up"
+	statDropped  = "minecraft:dropped"
+	statKilled   = "minecraft:killed"
+	statKilledBy = "minecraft:killed_by"
+)
+
+// Categories of the flat format before 1.13, "stat.<category>.<item or entity>"
+var legacyStatCategories = map[string]string{
+	"mineBlock":      statMined,
+	"useItem":        statUsed,
+	"craftItem":      statCrafted,
+	"breakItem":      statBroken,
+	"pickup":         statPickedUp,
+	"drop":           statDropped,
+	"killEntity":     statKilled,
+	"entityKilledBy": statKilledBy,
+}
+
+// Custom statistics of the flat format whose name changed in 1.13, the others only went from camelCase to snake_case
+var legacyCustomStats = map[string]string{
+	"playOneMinute": "minecraft:play_time",
+	"swimOneCm":     "minecraft:walk_on_water_one_cm",
+	"diveOneCm":     "minecraft:walk_under_water_one_cm",
+}
+
+// Ways of moving counted as on foot
+var footDistances = []string{"walk", "sprint", "crouch", "walk_on_water", "walk_under_water"}
+
+// Items that can be used but never placed, the blocks are counted as placed when they are used
+var notPlaceableItems = map[string]bool{
+	"bow": true, "crossbow": true, "trident": true, "shield": true, "mace": true, "fishing_rod": true,
+	"carrot_on_a_stick": true, "warped_fungus_on_a_stick": true, "flint_and_steel": true, "shears": true, "brush": true,
+	"spyglass": true, "goat_horn": true, "elytra": true, "turtle_helmet": true, "totem_of_undying": true,
+	"ender_pearl": true, "ender_eye": true, "snowball": true, "egg": true, "wind_charge": true, "fire_charge": true,
+	"experience_bottle": true, "potion": true, "splash_potion": true, "lingering_potion": true, "glass_bottle": true,
+	"honey_bottle": true, "firework_rocket": true, "bone_meal": true, "lead": true, "name_tag": true, "saddle": true,
+	"compass": true, "recovery_compass": true, "clock": true, "map": true, "filled_map": true, "bundle": true,
+	"book": true, "writable_book": true, "written_book": true, "enchanted_book": true, "bucket": true,
+	"armor_stand": true, "item_frame": true, "glow_item_frame": true, "painting": true, "end_crystal": true,
+	"apple": true, "golden_apple": true, "enchanted_golden_apple": true, "bread": true, "cookie": true,
+	"melon_slice": true, "baked_potato": true, "poisonous_potato": true, "carrot": true, "potato": true,
+	"golden_carrot": true, "pumpkin_pie": true, "rotten_flesh": true, "spider_eye": true, "chorus_fruit": true,
+	"dried_kelp": true, "beetroot": true, "sweet_berries": true, "glow_berries": true, "beef": true,
+	"porkchop": true, "chicken": true, "mutton": true, "rabbit": true, "cod": true, "salmon": true,
+	"tropical_fish": true, "pufferfish": true,
+}
+
+// Suffixes and prefixes of the items that can't be placed either : tools, armor, vehicles, food...
+var notPlaceableSuffixes = []string{
+	"_sword", "_pickaxe", "_axe", "_shovel", "_hoe", "_helmet", "_chestplate", "_leggings", "_boots", "_horse_armor",
+	"_bucket", "_spawn_egg", "_boat", "_raft", "_minecart", "_stew", "_soup", "_dye", "_pottery_sherd",
+	"_banner_pattern", "_smithing_template",
+}
+var notPlaceablePrefixes = []string{"cooked_", "music_disc_"}
+
+// GetMinecraftPlayerGameStatistics gets the game statistics of a Minecraft player with his server save
+func GetMinecraftPlayerGameStatistics(playerID int, playerUUID string, server models.Server) (int, string, models.MinecraftPlayerGameStatistics, error) {
+	logger.Debug("Reading the Minecraft statistics of a player", "player_uuid", playerUUID, "server_id", server.ID)
+
+	if server.Jeu != "Minecraft" {
+		return 0, "", models.MinecraftPlayerGameStatistics{}, fmt.Errorf("%s IS NOT A MINECRAFT SERVER", server.Nom)
+	}
+
+	// Get the file path
+	playerStatsFile := server.PathServ + server.NomMonde + "/stats/" + FormatMinecraftUUID(playerUUID) + ".json"
+
+	// Check if file exists
+	if _, err := os.Stat(playerStatsFile); os.IsNotExist(err) {
+		return 0, "", models.MinecraftPlayerGameStatistics{}, fmt.Errorf("PLAYER STATISTICS FILE NOT FOUND")
+	}
+
+	// Read file
+	playerStatsJSON, err := os.ReadFile(playerStatsFile)
+	if err != nil {
+		return 0, "", models.MinecraftPlayerGameStatistics{}, fmt.Errorf("FAILED TO READ PLAYER STATISTICS FILE: %v", err)
+	}
+
+	stats, legacyAchievements, err := ParseMinecraftStats(playerStatsJSON)
+	if err != nil {
+		return 0, "", models.MinecraftPlayerGameStatistics{}, err
+	}
+	playerStats := BuildMinecraftPlayerGameStatistics(stats)
+
+	// Before 1.12 the achievements are in the stats file, since then the advancements have their own file
+	for achievement := range legacyAchievements {
+		playerStats.Achievements[achievement] = true
+	}
+	advancements, err := GetMinecraftPlayerAdvancements(playerUUID, server)
+	if err != nil {
+		logger.Warn("Advancements not read", "player_uuid", playerUUID, "server_id", server.ID, logging.Err(err))
+	}
+	for advancement := range advancements {
+		playerStats.Achievements[advancement] = true
+	}
+
+	logger.Debug("Minecraft statistics read", "player_uuid", playerUUID, "server_id", server.ID)
+	return playerID, playerUUID, playerStats, nil
+}
+
+// ParseMinecraftStats reads a stats file into the categories of 1.13 and later, the flat format of the older versions is
+// converted. The achievements of the flat format are returned apart
+func ParseMinecraftStats(data []byte) (map[string]map[string]int, map[string]bool, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, nil, fmt.Errorf("FAILED TO UNMARSHAL PLAYER STATISTICS JSON: %v", err)
+	}
+
+	stats := make(map[string]map[string]int)
+	achievements := make(map[string]bool)
+
+	if rawStats, ok := raw["stats"]; ok {
+		if err := json.Unmarshal(rawStats, &stats); err != nil {
+			return nil, nil, fmt.Errorf("FAILED TO UNMARSHAL PLAYER STATISTICS JSON: %v", err)
+		}
+		return stats, achievements, nil
+	}
+
+	add := func(category string, key string, value int) {
+		if stats[category] == nil {
+			stats[category] = make(map[string]int)
+		}
+		stats[category][key] += value
+	}
+	for key, rawValue := range raw {
+		value, ok := legacyStatValue(rawValue)
+		if !ok {
+			continue
+		}
+		if achievement, found := strings.CutPrefix(key, "achievement."); found {
+			if value > 0 {
+				achievements["minecraft:achievement/"+camelToSnake(achievement)] = true
+			}
+			continue
+		}
+		name, found := strings.CutPrefix(key, "stat.")
+		if !found {
+			continue
+		}
+		statName, target, hasTarget := strings.Cut(name, ".")
+		if !hasTarget {
+			if renamed, ok := legacyCustomStats[statName]; ok {
+				add(statCustom, renamed, value)
+			} else {
+				add(statCustom, "minecraft:"+camelToSnake(statName), value)
+			}
+			continue
+		}
+		category, ok := legacyStatCategories[statName]
+		if !ok {
+			continue
+		}
+		add(category, legacyStatTarget(category, target), value)
+	}
+	return stats, achievements, nil
+}
+
+// legacyStatValue reads a value of the flat format, a number or an object with a value like exploreAllBiomes
+func legacyStatValue(rawValue json.RawMessage) (int, bool) {
+	var value int
+	if err := json.Unmarshal(rawValue, &value); err == nil {
+		return value, true
+	}
+	var withProgress struct {
+		Value int `json:"value"`
+	}
+	if err := json.Unmarshal(rawValue, &withProgress); err == nil {
+		return withProgress.Value, true
+	}
+	return 0, false
+}
+
+// legacyStatTarget converts an item or an entity of the flat format, ex: "minecraft.stone" or "Zombie". The numeric IDs
+// of the oldest versions are kept as they are
+func legacyStatTarget(category string, target string) string {
+	if category == statKilled || category == statKilledBy {
+		return "minecraft:" + camelToSnake(target)
+	}
+	if namespace, item, found := strings.Cut(target, "."); found {
+		return namespace + ":" + item
+	}
+	if _, err := strconv.Atoi(target); err == nil {
+		return target
+	}
+	return "minecraft:" + target
+}
+
+func camelToSnake(name string) string {
+	var snake strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				snake.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		snake.WriteRune(r)
+	}
+	return snake.String()
+}
+
+// BuildMinecraftPlayerGameStatistics computes the statistics kept by the database from the categories of a stats file
+func BuildMinecraftPlayerGameStatistics(stats map[string]map[string]int) models.MinecraftPlayerGameStatistics {
+	custom := stats[statCustom]
+
+	// Depending on the server version, the play time is play_one_minute (in ticks despite its name) or play_time
+	playTime := custom["minecraft:play_time"]
+	if playTime == 0 {
+		playTime = custom["minecraft:play_one_minute"]
+	}
+
+	playerStats := models.MinecraftPlayerGameStatistics{
+		TimePlayed:       playTime,
+		Deaths:           custom["minecraft:deaths"],
+		Kills:            custom["minecraft:mob_kills"],
+		PlayerKills:      custom["minecraft:player_kills"],
+		MobsKilled:       nonNilMap(stats[statKilled]),
+		BlocksDestroyed:  sumValues(stats[statMined]),
+		Distances:        make(map[string]int),
+		ItemsCrafted:     nonNilMap(stats[statCrafted]),
+		ItemsBroken:      nonNilMap(stats[statBroken]),
+		ItemsUsed:        make(map[string]int),
+		Achievements:     make(map[string]bool),
+		DistanceByElytra: custom["minecraft:aviate_one_cm"],
+		DistanceByFlight: custom["minecraft:fly_one_cm"],
+	}
+
+	// Every way of moving has its "<way>_one_cm" statistic
+	for key, value := range custom {
+		way, found := strings.CutSuffix(strings.TrimPrefix(key, "minecraft:"), "_one_cm")
+		if !found {
+			continue
+		}
+		playerStats.Distances[way] += value
+		playerStats.TotalDistance += value
+	}
+	for _, way := range footDistances {
+		playerStats.DistanceByFoot += playerStats.Distances[way]
+	}
+
+	// Using a block places it, the other items are kept apart
+	for item, value := range stats[statUsed] {
+		if IsPlaceableMinecraftItem(item) {
+			playerStats.BlocksPlaced += value
+		} else {
+			playerStats.ItemsUsed[item] = value
+		}
+	}
+
+	return playerStats
+}
+
+// IsPlaceableMinecraftItem tells if using an item places a block. The stats file doesn't say it, so the items that can't
+// be placed are recognized by their name, and the numeric IDs of the oldest versions are blocks under 256
+func IsPlaceableMinecraftItem(item string) bool {
+	if id, err := strconv.Atoi(item); err == nil {
+		return id < 256
+	}
+	_, name, found := strings.Cut(item, ":")
+	if !found {
+		name = item
+	}
+	if notPlaceableItems[name] {
+		return false
+	}
+	for _, suffix := range notPlaceableSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return false
+		}
+	}
+	for _, prefix := range notPlaceablePrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// GetMinecraftPlayerAdvancements gets the completed advancements of a Minecraft player, without the recipes. A player
+// without advancements file has none, ex: a server older than 1.12
+func GetMinecraftPlayerAdvancements(playerUUID string, server models.Server) (map[string]bool, error) {
+	advancementsFile := server.PathServ + server.NomMonde + "/advancements/" + FormatMinecraftUUID(playerUUID) + ".json"
+	data, err := os.ReadFile(advancementsFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string]bool{}, nil
+		}
+		return nil, fmt.Errorf("FAILED TO READ PLAYER ADVANCEMENTS FILE: %v", err)
+	}
+	return ParseMinecraftAdvancements(data)
+}
+
+// ParseMinecraftAdvancements reads an advancements file, the recipes unlocked are advancements too but are left out
+func ParseMinecraftAdvancements(data []byte) (map[string]bool, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("FAILED TO UNMARSHAL PLAYER ADVANCEMENTS JSON: %v", err)
+	}
+
+	advancements := make(map[string]bool)
+	for id, rawAdvancement := range raw {
+		if id == "DataVersion" || strings.Contains(id, ":recipes/") {
+			continue
+		}
+		var advancement struct {
+			Done bool `json:"done"`
+		}
+		if err := json.Unmarshal(rawAdvancement, &advancement); err != nil {
+			continue
+		}
+		if advancement.Done {
+			advancements[id] = true
+		}
+	}
+	return advancements, nil
+}
+
+func nonNilMap(m map[string]int) map[string]int {
+	if m == nil {
+		return make(map[string]int)
+	}
+	return m
+}
+
+func sumValues(m map[string]int) int {
+	total := 0
+	for _, value := range m {
+		total += value
+	}
+	return total
+}
@@ -0,0 +1,193 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMinecraftStats(t *testing.T) {
+	tests := []struct {
+		name             string
+		json             string
+		wantStats        map[string]map[string]int
+		wantAchievements map[string]bool
+	}{
+		{
+			"1.13 and later",
+			`{"stats": {"minecraft:custom": {"minecraft:deaths": 3, "minecraft:play_time": 7200}, "minecraft:mined": {"minecraft:stone": 64}}, "DataVersion": 3953}`,
+			map[string]map[string]int{
+				"minecraft:custom": {"minecraft:deaths": 3, "minecraft:play_time": 7200},
+				"minecraft:mined":  {"minecraft:stone": 64},
+			},
+			map[string]bool{},
+		},
+		{
+			"flat format",
+			`{"stat.playOneMinute": 7200, "stat.deaths": 3, "stat.walkOneCm": 1000, "stat.swimOneCm": 50,
+			  "stat.mineBlock.minecraft.stone": 64, "stat.useItem.minecraft.torch": 10, "stat.useItem.1": 5,
+			  "stat.killEntity.Zombie": 7, "stat.entityKilledBy.CaveSpider": 1}`,
+			map[string]map[string]int{
+				"minecraft:custom": {
+					"minecraft:play_time": 7200, "minecraft:deaths": 3, "minecraft:walk_one_cm": 1000,
+					"minecraft:walk_on_water_one_cm": 50,
+				},
+				"minecraft:mined":     {"minecraft:stone": 64},
+				"minecraft:used":      {"minecraft:torch": 10, "1": 5},
+				"minecraft:killed":    {"minecraft:zombie": 7},
+				"minecraft:killed_by": {"minecraft:cave_spider": 1},
+			},
+			map[string]bool{},
+		},
+		{
+			"flat format achievements",
+			`{"achievement.openInventory": 1, "achievement.buildPickaxe": 0, "achievement.exploreAllBiomes": {"value": 1, "progress": ["Beach"]}}`,
+			map[string]map[string]int{},
+			map[string]bool{"minecraft:achievement/open_inventory": true, "minecraft:achievement/explore_all_biomes": true},
+		},
+		{
+			"unknown flat keys ignored",
+			`{"stat.unknownCategory.minecraft.stone": 3, "other": 1, "stat.text": "abc"}`,
+			map[string]map[string]int{},
+			map[string]bool{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stats, achievements, err := ParseMinecraftStats([]byte(test.json))
+			if err != nil {
+				t.Fatalf("ParseMinecraftStats() failed: %v", err)
+			}
+			if !reflect.DeepEqual(stats, test.wantStats) {
+				t.Errorf("stats = %v, want %v", stats, test.wantStats)
+			}
+			if !reflect.DeepEqual(achievements, test.wantAchievements) {
+				t.Errorf("achievements = %v, want %v", achievements, test.wantAchievements)
+			}
+		})
+	}
+}
+
+func TestParseMinecraftStatsErrors(t *testing.T) {
+	for _, data := range []string{"", "not json", `{"stats": []}`, `{"stats": {"minecraft:custom": {"minecraft:deaths": "3"}}}`} {
+		if _, _, err := ParseMinecraftStats([]byte(data)); err == nil {
+			t.Errorf("ParseMinecraftStats(%q) succeeded, want an error", data)
+		}
+	}
+}
+
+func TestBuildMinecraftPlayerGameStatistics(t *testing.T) {
+	stats := map[string]map[string]int{
+		"minecraft:custom": {
+			"minecraft:play_time":     7200,
+			"minecraft:deaths":        3,
+			"minecraft:mob_kills":     12,
+			"minecraft:player_kills":  1,
+			"minecraft:walk_one_cm":   1000,
+			"minecraft:sprint_one_cm": 500,
+			"minecraft:boat_one_cm":   300,
+			"minecraft:aviate_one_cm": 2000,
+			"minecraft:fly_one_cm":    150,
+		},
+		"minecraft:mined":   {"minecraft:stone": 64, "minecraft:dirt": 10},
+		"minecraft:used":    {"minecraft:stone": 20, "minecraft:torch": 5, "minecraft:diamond_pickaxe": 300, "minecraft:bread": 4},
+		"minecraft:killed":  {"minecraft:zombie": 12},
+		"minecraft:crafted": {"minecraft:torch": 16},
+	}
+
+	got := BuildMinecraftPlayerGameStatistics(stats)
+
+	ints := []struct {
+		name string
+		got  int
+		want int
+	}{
+		{"TimePlayed", got.TimePlayed, 7200},
+		{"Deaths", got.Deaths, 3},
+		{"Kills", got.Kills, 12},
+		{"PlayerKills", got.PlayerKills, 1},
+		{"BlocksDestroyed", got.BlocksDestroyed, 74},
+		{"BlocksPlaced", got.BlocksPlaced, 25},
+		{"TotalDistance", got.TotalDistance, 3950},
+		{"DistanceByFoot", got.DistanceByFoot, 1500},
+		{"DistanceByElytra", got.DistanceByElytra, 2000},
+		{"DistanceByFlight", got.DistanceByFlight, 150},
+	}
+	for _, value := range ints {
+		if value.got != value.want {
+			t.Errorf("%s = %d, want %d", value.name, value.got, value.want)
+		}
+	}
+
+	maps := []struct {
+		name string
+		got  map[string]int
+		want map[string]int
+	}{
+		{"Distances", got.Distances, map[string]int{"walk": 1000, "sprint": 500, "boat": 300, "aviate": 2000, "fly": 150}},
+		{"ItemsUsed", got.ItemsUsed, map[string]int{"minecraft:diamond_pickaxe": 300, "minecraft:bread": 4}},
+		{"ItemsCrafted", got.ItemsCrafted, map[string]int{"minecraft:torch": 16}},
+		{"ItemsBroken", got.ItemsBroken, map[string]int{}},
+		{"MobsKilled", got.MobsKilled, map[string]int{"minecraft:zombie": 12}},
+	}
+	for _, value := range maps {
+		if !reflect.DeepEqual(value.got, value.want) {
+			t.Errorf("%s = %v, want %v", value.name, value.got, value.want)
+		}
+	}
+	if got.Achievements == nil {
+		t.Errorf("Achievements is nil, the advancements are added to it")
+	}
+}
+
+func TestBuildMinecraftPlayerGameStatisticsPlayTime(t *testing.T) {
+	tests := []struct {
+		name   string
+		custom map[string]int
+		want   int
+	}{
+		{"play_time", map[string]int{"minecraft:play_time": 100}, 100},
+		{"play_one_minute before 1.17", map[string]int{"minecraft:play_one_minute": 80}, 80},
+		{"play_time first", map[string]int{"minecraft:play_time": 100, "minecraft:play_one_minute": 80}, 100},
+		{"nothing", nil, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := BuildMinecraftPlayerGameStatistics(map[string]map[string]int{"minecraft:custom": test.custom})
+			if got.TimePlayed != test.want {
+				t.Errorf("TimePlayed = %d, want %d", got.TimePlayed, test.want)
+			}
+		})
+	}
+}
+
+func TestIsPlaceableMinecraftItem(t *testing.T) {
+	tests := []struct {
+		item string
+		want bool
+	}{
+		{"minecraft:stone", true},
+		{"minecraft:torch", true},
+		{"minecraft:oak_planks", true},
+		{"stone", true},
+		{"minecraft:bow", false},
+		{"minecraft:ender_pearl", false},
+		{"minecraft:diamond_sword", false},
+		{"minecraft:netherite_pickaxe", false},
+		{"minecraft:water_bucket", false},
+		{"minecraft:zombie_spawn_egg", false},
+		{"minecraft:oak_boat", false},
+		{"minecraft:cooked_beef", false},
+		{"minecraft:music_disc_cat", false},
+		{"1", true},    // Stone before 1.8
+		{"255", true},  // Last block ID
+		{"256", false}, // First item ID, the iron shovel
+		{"diamond_axe", false},
+	}
+	for _, test := range tests {
+		t.Run(test.item, func(t *testing.T) {
+			if got := IsPlaceableMinecraftItem(test.item); got != test.want {
+				t.Errorf("IsPlaceableMinecraftItem(%q) = %v, want %v", test.item, got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package services
+
+// This file contains the RESOLUTION of the Minecraft player names into UUIDs. In order : the usercache.json of the server,
+// the offline UUID for the servers in offline mode, the UUID cache of the daemon, then the Mojang API
+
+import (
+	"bufio"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Corentin-cott/ServeurSentinel/config"
+	"github.com/Corentin-cott/ServeurSentinel/internal/logging"
+	"github.com/Corentin-cott/ServeurSentinel/internal/models"
+)
+
+// Name of the UUID cache file inside the state directory
+const uuidCacheFileName = "minecraft_uuids.json"
+
+// The web services can be slow, a chat line never waits longer than this
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// cachedUUID is a UUID found with the Mojang API
+type cachedUUID struct {
+	Name       string    `json:"name"`
+	UUID       string    `json:"uuid"`
+	ResolvedAt time.Time `json:"resolvedAt"`
+}
+
+var (
+	uuidCacheMutex  sync.Mutex
+	uuidCache       map[string]cachedUUID // lowercase player name -> UUID, nil until loaded
+	headURLsChecked = make(map[string]time.Time)
+)
+
+func getUUIDCacheFilePath() string {
+	return filepath.Join(config.Get().StatePath, uuidCacheFileName)
+}
+
+func getUUIDCacheTTL() time.Duration {
+	ttl, err := time.ParseDuration(config.Get().MinecraftAPI.UUIDCacheTTL)
+	if err != nil {
+		return 0 // Not possible once validated
+	}
+	return ttl
+}
+
+// loadUUIDCache reads the cache file the first time, a missing or broken file is an empty cache. uuidCacheMutex must be held
+func loadUUIDCache() {
+	if uuidCache != nil {
+		return
+	}
+	uuidCache = make(map[string]cachedUUID)
+	data, err := os.ReadFile(getUUIDCacheFilePath())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Error("Minecraft UUID cache not read", logging.Err(err))
+		}
+		return
+	}
+	if err := json.Unmarshal(data, &uuidCache); err != nil {
+		logger.Warn("Minecraft UUID cache not decoded, it is emptied", logging.Err(err))
+		uuidCache = make(map[string]cachedUUID)
+	}
+}
+
+// saveUUIDCache writes the cache file, next to it then renamed like the log offsets. uuidCacheMutex must be held
+func saveUUIDCache() error {
+	data, err := json.MarshalIndent(uuidCache, "", "  ")
+	if err != nil {
+		return fmt.Errorf("ERROR WHILE ENCODING THE MINECRAFT UUID CACHE: %v", err)
+	}
+	if err := os.MkdirAll(config.Get().StatePath, 0750); err != nil {
+		return fmt.Errorf("ERROR WHILE CREATING STATE DIRECTORY: %v", err)
+	}
+	tmpPath := getUUIDCacheFilePath() + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0640); err != nil {
+		return fmt.Errorf("ERROR WHILE WRITING THE MINECRAFT UUID CACHE: %v", err)
+	}
+	if err := os.Rename(tmpPath, getUUIDCacheFilePath()); err != nil {
+		return fmt.Errorf("ERROR WHILE WRITING THE MINECRAFT UUID CACHE: %v", err)
+	}
+	return nil
+}
+
+// getCachedUUID returns the cached UUID of a player, if it is not older than the TTL
+func getCachedUUID(playerName string) (string, bool) {
+	uuidCacheMutex.Lock()
+	defer uuidCacheMutex.Unlock()
+	loadUUIDCache()
+	cached, ok := uuidCache[strings.ToLower(playerName)]
+	if !ok || time.Since(cached.ResolvedAt) > getUUIDCacheTTL() {
+		return "", false
+	}
+	return cached.UUID, true
+}
+
+func setCachedUUID(playerName string, playerUUID string) {
+	uuidCacheMutex.Lock()
+	defer uuidCacheMutex.Unlock()
+	loadUUIDCache()
+	uuidCache[strings.ToLower(playerName)] = cachedUUID{Name: playerName, UUID: playerUUID, ResolvedAt: time.Now().UTC()}
+	if err := saveUUIDCache(); err != nil {
+		logger.Error("Minecraft UUID cache not saved", logging.Err(err))
+	}
+}
+
+// ResolveMinecraftPlayerUUID finds the UUID of a player on a server, the Mojang API is only called when nothing else knows it
+func ResolveMinecraftPlayerUUID(playerName string, server models.Server) (string, error) {
+	if playerUUID, ok := FindUUIDInUserCache(server, playerName); ok {
+		return playerUUID, nil
+	}
+	if !IsMinecraftServerOnline(server) {
+		return OfflineMinecraftUUID(playerName), nil
+	}
+	return GetMinecraftPlayerUUID(playerName)
+}
+
+// FindUUIDInUserCache looks for a player in the usercache.json of a server, where the server keeps the players it has seen
+func FindUUIDInUserCache(server models.Server, playerName string) (string, bool) {
+	data, err := os.ReadFile(filepath.Join(server.PathServ, "usercache.json"))
+	if err != nil {
+		return "", false
+	}
+	var entries []struct {
+		Name string `json:"name"`
+		UUID string `json:"uuid"`
+	}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		logger.Warn("usercache.json not decoded", "server_id", server.ID, logging.Err(err))
+		return "", false
+	}
+	for _, entry := range entries {
+		if strings.EqualFold(entry.Name, playerName) && entry.UUID != "" {
+			return FormatMinecraftUUID(strings.ReplaceAll(entry.UUID, "-", "")), true
+		}
+	}
+	return "", false
+}
+
+// IsMinecraftServerOnline reads online-mode in the server.properties of a server, a server is online unless it says otherwise
+func IsMinecraftServerOnline(server models.Server) bool {
+	file, err := os.Open(filepath.Join(server.PathServ, "server.properties"))
+	if err != nil {
+		return true
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		key, value, found := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if found && strings.TrimSpace(key) == "online-mode" {
+			return strings.TrimSpace(value) != "false"
+		}
+	}
+	return true
+}
+
+// OfflineMinecraftUUID computes the UUID given by a server in offline mode : the version 3 UUID of "OfflinePlayer:<name>"
+func OfflineMinecraftUUID(playerName string) string {
+	hash := md5.Sum([]byte("OfflinePlayer:" + playerName))
+	hash[6] = hash[6]&0x0f | 0x30 // Version 3
+	hash[8] = hash[8]&0x3f | 0x80 // RFC 4122 variant
+	return FormatMinecraftUUID(hex.EncodeToString(hash[:]))
+}
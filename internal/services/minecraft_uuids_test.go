@@ -0,0 +1,99 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Corentin-cott/ServeurSentinel/config"
+	"github.com/Corentin-cott/ServeurSentinel/internal/models"
+)
+
+func TestOfflineMinecraftUUID(t *testing.T) {
+	// The UUID a server in offline mode gives to Notch
+	if got, want := OfflineMinecraftUUID("Notch"), "b50ad385-829d-3141-a216-7e7d7539ba7f"; got != want {
+		t.Errorf("OfflineMinecraftUUID(Notch) = %s, want %s", got, want)
+	}
+}
+
+func TestFindUUIDInUserCache(t *testing.T) {
+	server := models.Server{ID: 1, PathServ: t.TempDir()}
+	userCache := `[
+		{"name": "Notch", "uuid": "069a79f4-44e9-4726-a5be-fca90e38aaf5", "expiresOn": "2025-04-01 12:00:00 +0000"},
+		{"name": "jeb_", "uuid": "853c80ef3c3749fdaa49938b674adae6", "expiresOn": "2025-04-01 12:00:00 +0000"}
+	]`
+	if err := os.WriteFile(filepath.Join(server.PathServ, "usercache.json"), []byte(userCache), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		wantUUID string
+		wantOK   bool
+	}{
+		{"Notch", "069a79f4-44e9-4726-a5be-fca90e38aaf5", true},
+		{"NOTCH", "069a79f4-44e9-4726-a5be-fca90e38aaf5", true}, // Minecraft names are case-insensitive
+		{"jeb_", "853c80ef-3c37-49fd-aa49-938b674adae6", true},  // Formatted with dashes
+		{"Dinnerbone", "", false},
+	}
+	for _, test := range tests {
+		playerUUID, ok := FindUUIDInUserCache(server, test.name)
+		if playerUUID != test.wantUUID || ok != test.wantOK {
+			t.Errorf("FindUUIDInUserCache(%s) = %q, %v, want %q, %v", test.name, playerUUID, ok, test.wantUUID, test.wantOK)
+		}
+	}
+
+	if _, ok := FindUUIDInUserCache(models.Server{PathServ: t.TempDir()}, "Notch"); ok {
+		t.Error("FindUUIDInUserCache() found a player without usercache.json")
+	}
+}
+
+func TestIsMinecraftServerOnline(t *testing.T) {
+	tests := []struct {
+		name       string
+		properties string
+		want       bool
+	}{
+		{"offline", "motd=A server\nonline-mode=false\n", false},
+		{"offline with spaces", "# Minecraft server properties\n online-mode = false \n", false},
+		{"online", "online-mode=true\n", true},
+		{"not set", "motd=A server\n", true},
+		{"no file", "", true},
+	}
+	for _, test := range tests {
+		server := models.Server{PathServ: t.TempDir()}
+		if test.properties != "" {
+			if err := os.WriteFile(filepath.Join(server.PathServ, "server.properties"), []byte(test.properties), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got := IsMinecraftServerOnline(server); got != test.want {
+			t.Errorf("%s: IsMinecraftServerOnline() = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestGetCachedUUIDExpires(t *testing.T) {
+	config.Set(config.Config{StatePath: t.TempDir(), MinecraftAPI: models.MinecraftAPIConfig{UUIDCacheTTL: "24h"}})
+	t.Cleanup(func() {
+		config.Set(config.Config{})
+		uuidCacheMutex.Lock()
+		uuidCache = nil
+		uuidCacheMutex.Unlock()
+	})
+
+	uuidCacheMutex.Lock()
+	uuidCache = map[string]cachedUUID{
+		"notch": {Name: "Notch", UUID: "069a79f4-44e9-4726-a5be-fca90e38aaf5", ResolvedAt: time.Now().Add(-time.Hour)},
+		"jeb_":  {Name: "jeb_", UUID: "853c80ef-3c37-49fd-aa49-938b674adae6", ResolvedAt: time.Now().Add(-25 * time.Hour)},
+	}
+	uuidCacheMutex.Unlock()
+
+	if playerUUID, ok := getCachedUUID("NOTCH"); !ok || playerUUID != "069a79f4-44e9-4726-a5be-fca90e38aaf5" {
+		t.Errorf("getCachedUUID(NOTCH) = %q, %v, want the cached UUID", playerUUID, ok)
+	}
+	if playerUUID, ok := getCachedUUID("jeb_"); ok {
+		t.Errorf("getCachedUUID(jeb_) = %q, want nothing once the TTL is over", playerUUID)
+	}
+}
@@ -1,9 +1,7 @@
 package services
 
-import "fmt"
-
 // GetPlfayerUUID gets the UUID of a player by their username, function is not implemented yet
 func GetPlayerUUID(playerName string) (string, error) {
-	fmt.Println("GetPlayerUUID called")
+	logger.Debug("GetPlayerUUID called", "player", playerName)
 	return "", nil
 }
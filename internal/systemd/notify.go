@@ -0,0 +1,117 @@
+package systemd
+
+// This file contains the NOTIFICATIONS sent to systemd, see sd_notify(3). The protocol is a datagram sent on the unix
+// socket given in $NOTIFY_SOCKET, so it doesn't need libsystemd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Corentin-cott/ServeurSentinel/internal/logging"
+)
+
+var logger = logging.For("systemd")
+
+// Notify sends a state to systemd, ex: "READY=1". It returns false without error when not started by systemd
+func Notify(state string) (bool, error) {
+	socketPath := os.Getenv("NOTIFY_SOCKET")
+	if socketPath == "" {
+		return false, nil
+	}
+
+	// A socket starting with "@" is in the abstract namespace
+	if socketPath[0] == '@' {
+		socketPath = "\x00" + socketPath[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socketPath, Net: "unixgram"})
+	if err != nil {
+		return false, fmt.Errorf("ERROR WHILE CONNECTING TO THE SYSTEMD NOTIFY SOCKET: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return false, fmt.Errorf("ERROR WHILE NOTIFYING SYSTEMD: %v", err)
+	}
+	return true, nil
+}
+
+// NotifyReady tells systemd the daemon is started, with a status displayed by "systemctl status"
+func NotifyReady(status string) {
+	notifyAndLog("READY=1\nSTATUS=" + status)
+}
+
+// NotifyStatus updates the status displayed by "systemctl status"
+func NotifyStatus(status string) {
+	notifyAndLog("STATUS=" + status)
+}
+
+// NotifyReloading tells systemd the configuration is being reloaded, NotifyReady must be called when it's done
+func NotifyReloading() {
+	notifyAndLog("RELOADING=1\nSTATUS=Reloading the configuration...")
+}
+
+// NotifyStopping tells systemd the daemon is shutting down
+func NotifyStopping() {
+	notifyAndLog("STOPPING=1\nSTATUS=Stopping...")
+}
+
+func notifyAndLog(state string) {
+	if _, err := Notify(state); err != nil {
+		logger.Error("Systemd not notified", logging.Err(err))
+	}
+}
+
+// WatchdogInterval returns the watchdog timeout set in the unit file, or 0 if the watchdog is disabled for this process
+func WatchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+
+	// The watchdog may be meant for another process, ex: a child started with the same environment
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+// RunWatchdog pings the systemd watchdog while the check succeeds, until the context is cancelled. When the check fails
+// the pings stop, so systemd restarts the daemon once the watchdog timeout is reached
+func RunWatchdog(ctx context.Context, check func() error) {
+	interval := WatchdogInterval()
+	if interval == 0 {
+		return
+	}
+	logger.Info("Systemd watchdog enabled", "timeout", interval.String())
+
+	// Pinging at half the timeout leaves room for one late ping
+	ticker := time.NewTicker(interval / 2)
+	defer ticker.Stop()
+	healthy := true
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := check(); err != nil {
+			if healthy {
+				logger.Error("Health check failed, the systemd watchdog won't be notified", logging.Err(err))
+				NotifyStatus("Unhealthy: " + err.Error())
+			}
+			healthy = false
+			continue
+		}
+		if !healthy {
+			logger.Info("Health check succeeded again, notifying the systemd watchdog")
+		}
+		healthy = true
+		notifyAndLog("WATCHDOG=1")
+	}
+}
@@ -3,18 +3,25 @@ package tmux
 import (
 	"fmt"
 	"os/exec"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/Corentin-cott/ServeurSentinel/config"
+	"github.com/Corentin-cott/ServeurSentinel/internal/audit"
 	"github.com/Corentin-cott/ServeurSentinel/internal/db"
 	"github.com/Corentin-cott/ServeurSentinel/internal/discord"
+	"github.com/Corentin-cott/ServeurSentinel/internal/logging"
 	"github.com/Corentin-cott/ServeurSentinel/internal/models"
+	"github.com/Corentin-cott/ServeurSentinel/internal/presence"
 )
 
-// Check if the active servers match the servers in the database
-func CheckRunningServers() (string, error) {
+var logger = logging.For("tmux")
+
+// Check if the active servers match the servers in the database, the servers started or stopped are recorded in the audit
+// log under the given actor
+func CheckRunningServers(actor audit.Actor) (string, error) {
 	var message strings.Builder
 	errorMessages := ""
 
@@ -34,10 +41,7 @@ func CheckRunningServers() (string, error) {
 		return "", fmt.Errorf("ERROR WHILE GETTING PARTENARIAT SERVER: %v", err)
 	}
 
-	fmt.Println("Supposed to be running servers:")
-	fmt.Println("- Primary:", primaryServer.Nom)
-	fmt.Println("- Secondary:", secondaryServer.Nom)
-	fmt.Println("- Partenariat:", partenariatServer.Nom)
+	logger.Debug("Servers supposed to be running", "primary", primaryServer.Nom, "secondary", secondaryServer.Nom, "partner", partenariatServer.Nom)
 
 	// Get the active tmux sessions
 	activeSessions, err := GetTmuxSessions()
@@ -55,7 +59,9 @@ func CheckRunningServers() (string, error) {
 
 		// If a server is running but shouldn't be, stop it
 		if !isSupposedToBeRunning {
-			if err := StopServerTmux(session); err != nil {
+			err := StopServerTmux(session)
+			recordCheckStop(actor, session, err)
+			if err != nil {
 				errorMessages += fmt.Sprintf("ERROR WHILE STOPPING %s: %v\n", session, err)
 			} else {
 				fmt.Fprintf(&message, "✘ Stopped server: %s (not supposed to be running)\n", session)
@@ -86,7 +92,9 @@ func CheckRunningServers() (string, error) {
 				continue
 			}
 
-			if err := StartServerTmux(sessionID, server); err != nil {
+			err := StartServerTmux(sessionID, server)
+			audit.Record(actor, models.AuditCheckStart, server.ID, audit.Params("session", strconv.Itoa(sessionID)), err)
+			if err != nil {
 				errorMessages += fmt.Sprintf("ERROR WHILE STARTING %s: %v\n", server.Nom, err)
 			} else {
 				fmt.Fprintf(&message, "✔ Started server: %s (supposed to be running)\n", server.Nom)
@@ -105,6 +113,15 @@ func CheckRunningServers() (string, error) {
 	return message.String(), nil
 }
 
+// recordCheckStop records a server stopped by the check of the running servers, its tmux session is named after it
+func recordCheckStop(actor audit.Actor, session string, err error) {
+	serverID := 0
+	if server, getErr := db.GetServerByName(session); getErr == nil {
+		serverID = server.ID
+	}
+	audit.Record(actor, models.AuditCheckStop, serverID, audit.Params("session", session), err)
+}
+
 // Check if a server is currently running in a tmux session
 func IsServerRunning(serverName string) (bool, error) {
 	command := fmt.Sprintf("tmux list-sessions -F '#{session_name}' | grep -w \"%s\" | wc -l", serverName)
@@ -146,7 +163,8 @@ func StartServerTmux(sessionID int, server models.Server) error {
 		return fmt.Errorf("SERVER %s IS ALREADY RUNNING", server.Nom)
 	}
 
-	fmt.Println("Starting the tmux session for", server.Nom+"...")
+	serverLogger := logger.With("server_id", server.ID, "server", server.Nom)
+	serverLogger.Info("Starting the tmux session")
 
 	if server.Jeu == "Minecraft" {
 		// Extract the main version of Minecraft
@@ -158,7 +176,7 @@ func StartServerTmux(sessionID int, server models.Server) error {
 
 		// Map the main version of Minecraft to the corresponding Java version
 		javaVersion, err := GetJavaVersionForMinecraftVersion(mcMainVersion, server.Modpack)
-		fmt.Println("Java version for Minecraft version", mcMainVersion, ":", javaVersion)
+		serverLogger.Debug("Java version chosen", "minecraft_version", mcMainVersion, "java_version", javaVersion)
 
 		if err != nil {
 			return fmt.Errorf("ERROR WHILE GETTING JAVA VERSION FOR MINECRAFT VERSION: %v", err)
@@ -166,9 +184,10 @@ func StartServerTmux(sessionID int, server models.Server) error {
 	}
 
 	// Build the full command to start the server using its StartScript
+	logFilePath := filepath.Join(config.Get().ServersLogPath, strconv.Itoa(sessionID)+".log")
 	command := fmt.Sprintf(
-		"cd %s && tmux new-session -d -s '%s' './%s | tee -a /opt/serversentinel/serverslog/%s.log'",
-		server.PathServ, server.Nom, server.StartScript, strconv.Itoa(sessionID),
+		"cd %s && tmux new-session -d -s '%s' './%s | tee -a %s'",
+		server.PathServ, server.Nom, server.StartScript, logFilePath,
 	)
 
 	// Execute the command
@@ -177,7 +196,7 @@ func StartServerTmux(sessionID int, server models.Server) error {
 		return fmt.Errorf("ERROR WHILE STARTING THE TMUX SESSION: %v", err)
 	}
 
-	fmt.Printf("✔ Server %s started using StartScript: %s\n", server.Nom, server.StartScript)
+	serverLogger.Info("Server started", "start_script", server.StartScript)
 	return nil
 }
 
@@ -192,7 +211,7 @@ func StopServerTmux(serverName string) error {
 		return fmt.Errorf("SERVER %s IS NOT RUNNING", serverName)
 	}
 
-	fmt.Println("Stopping the tmux session for", serverName+"...")
+	logger.Info("Stopping the tmux session", "server", serverName)
 
 	// Send the stop command to the tmux session
 	command := fmt.Sprintf("tmux send-keys -t '%s' 'stop' C-m", serverName)
@@ -224,13 +243,39 @@ func StopServerTmux(serverName string) error {
 		return fmt.Errorf("ERROR WHILE GETTING SERVER BY NAME: %v", err)
 	}
 
+	// The session is closed, nobody is connected anymore even if the log never said they left
+	presence.ResetServer(server.ID)
+
 	if server.Jeu == "Minecraft" {
-		discord.SendDiscordEmbed(config.AppConfig.Bots["mineotterBot"], config.AppConfig.DiscordChannels.MinecraftChatChannelID, serverName+" se ferme.", "Merci d'avoir joué !", server.EmbedColor)
+		discord.QueueDiscordEmbed(config.Get().Bots["mineotterBot"], config.Get().DiscordChannels.MinecraftChatChannelID, serverName+" se ferme.", "Merci d'avoir joué !", server.EmbedColor)
 	} else {
-		discord.SendDiscordEmbed(config.AppConfig.Bots["multiloutreBot"], config.AppConfig.DiscordChannels.PalworldChatChannelID, serverName+" se ferme.", "Merci d'avoir joué !", server.EmbedColor)
+		discord.QueueDiscordEmbed(config.Get().Bots["multiloutreBot"], config.Get().DiscordChannels.PalworldChatChannelID, serverName+" se ferme.", "Merci d'avoir joué !", server.EmbedColor)
+	}
+
+	logger.Info("Server stopped", "server_id", server.ID, "server", serverName)
+	return nil
+}
+
+// SendCommandToServer types a command in the console of a server running in a tmux session
+func SendCommandToServer(serverName string, command string) error {
+	isRunning, err := IsServerRunning(serverName)
+	if err != nil {
+		return fmt.Errorf("ERROR WHILE CHECKING THE TMUX SESSION: %v", err)
+	}
+	if !isRunning {
+		return fmt.Errorf("SERVER %s IS NOT RUNNING", serverName)
+	}
+
+	// The command is sent literally (-l) so tmux never interprets it as key names, then validated with Enter
+	err = exec.Command("tmux", "send-keys", "-t", serverName, "-l", command).Run()
+	if err != nil {
+		return fmt.Errorf("ERROR WHILE SENDING COMMAND TO %s: %v", serverName, err)
+	}
+	err = exec.Command("tmux", "send-keys", "-t", serverName, "Enter").Run()
+	if err != nil {
+		return fmt.Errorf("ERROR WHILE SENDING COMMAND TO %s: %v", serverName, err)
 	}
 
-	fmt.Printf("✔ Server %s stopped\n", serverName)
 	return nil
 }
 
@@ -239,7 +284,7 @@ func GetTmuxSessions() ([]string, error) {
 	commandOutput, err := exec.Command("tmux", "list-sessions", "-F", "#{session_name}").Output()
 	if err != nil {
 		// Error can just be that there are no tmux sessions, so we return an empty array
-		fmt.Println("✘ No tmux sessions found, or error while getting tmux sessions:", err)
+		logger.Debug("No tmux sessions found, or tmux failed", logging.Err(err))
 		return []string{}, nil
 	}
 
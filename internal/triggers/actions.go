@@ -6,17 +6,66 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"net/http"
 	"os"
+	"path/filepath"
 	"regexp"
+	"time"
 
 	"github.com/Corentin-cott/ServeurSentinel/config"
+	"github.com/Corentin-cott/ServeurSentinel/internal/accounts"
 	"github.com/Corentin-cott/ServeurSentinel/internal/db"
 	"github.com/Corentin-cott/ServeurSentinel/internal/discord"
+	"github.com/Corentin-cott/ServeurSentinel/internal/logging"
+	"github.com/Corentin-cott/ServeurSentinel/internal/metrics"
 	"github.com/Corentin-cott/ServeurSentinel/internal/models"
+	"github.com/Corentin-cott/ServeurSentinel/internal/presence"
 	"github.com/Corentin-cott/ServeurSentinel/internal/services"
+	"github.com/Corentin-cott/ServeurSentinel/internal/tmux"
 )
 
+var logger = logging.For("triggers")
+
+// Actions are the actions of the triggers, with what they depend on
+type Actions struct {
+	Servers     db.ServerRepository
+	Players     db.PlayerRepository
+	Events      db.EventRepository
+	Notifier    discord.Notifier
+	Config      func() *config.Config                         // Read at each action, so a reloaded configuration is used right away
+	SendCommand func(serverName string, command string) error // Types a command in the console of a server, to answer a player in game
+}
+
+// NewActions creates the actions using the given repositories and notifier, with the current configuration
+func NewActions(servers db.ServerRepository, players db.PlayerRepository, events db.EventRepository, notifier discord.Notifier) *Actions {
+	return &Actions{Servers: servers, Players: players, Events: events, Notifier: notifier, Config: config.Get, SendCommand: tmux.SendCommandToServer}
+}
+
+// printError logs the error of a Discord message, it doesn't stop the action
+func (a *Actions) printError(err error) {
+	if err != nil {
+		logger.Error("Discord message not sent", logging.Err(err))
+	}
+}
+
+// actionError logs the error that stopped the action of a trigger and counts it in the metrics
+func actionError(triggerName string, serverID int, err error) {
+	metrics.ActionErrors.Inc(triggerName)
+	logger.Error("Trigger action failed", "trigger", triggerName, "server_id", serverID, logging.Err(err))
+}
+
+// saveEvent saves an event of a server for the reports, it doesn't stop the action
+func (a *Actions) saveEvent(serverID int, eventType string, playerName string, details string) {
+	switch eventType {
+	case models.EventServerStarted:
+		metrics.SetServerUp(serverID, true)
+	case models.EventServerStopped, models.EventServerCrashed:
+		metrics.SetServerUp(serverID, false)
+	}
+	if err := a.Events.SaveServerEvent(serverID, eventType, playerName, details); err != nil {
+		logger.Error("Server event not saved", "server_id", serverID, "event", eventType, "player", playerName, logging.Err(err))
+	}
+}
+
 // WriteToLogFile writes a line to a log file
 func WriteToLogFile(logPath string, line string) error {
 	// Open the log file
@@ -36,7 +85,7 @@ func WriteToLogFile(logPath string, line string) error {
 
 func SendToDiscordWebhook(serverType string, message string) error {
 	// fmt.Println("Sending message to Discord webhook for server type:", serverType)
-	webhookURL := config.AppConfig.DiscordWebhooks[serverType].URL
+	webhookURL := config.Get().DiscordWebhooks[serverType].URL
 	if webhookURL == "" {
 		return fmt.Errorf("ERROR: WEBHOOK URL FOR SERVER TYPE %s NOT FOUND", serverType)
 	}
@@ -49,7 +98,9 @@ func SendToDiscordWebhook(serverType string, message string) error {
 		return fmt.Errorf("ERROR MARSHALING DISCORD PAYLOAD: %v", err)
 	}
 
-	resp, err := http.Post(webhookURL, "application/json", bytes.NewBuffer(jsonPayload))
+	start := time.Now()
+	resp, err := discord.HTTPClient.Post(webhookURL, "application/json", bytes.NewBuffer(jsonPayload))
+	metrics.ObserveDiscordRequest(start, resp, err)
 	if err != nil {
 		return fmt.Errorf("ERROR WHILE SENDING DISCORD WEBHOOK: %v", err)
 	}
@@ -62,18 +113,26 @@ func SendToDiscordWebhook(serverType string, message string) error {
 	return nil
 }
 
-// Define the functions for each game, here is Minecraft
-func handleMinecraftPlayerMessage(line string) (string, string, string, string, error) {
-	playerChatRegex := regexp.MustCompile(`\[(\d{2}:\d{2}:\d{2})\] \[Server thread/INFO](?: \[.+?/MinecraftServer])?: <(.+?)> (.+)`)
-	matches := playerChatRegex.FindStringSubmatch(line)
+var minecraftChatRegex = regexp.MustCompile(`\[(\d{2}:\d{2}:\d{2})\] \[Server thread/INFO](?: \[.+?/MinecraftServer])?: <(.+?)> (.+)`)
+
+// parseMinecraftChat returns the player name and the message of a Minecraft chat line
+func parseMinecraftChat(line string) (string, string, error) {
+	matches := minecraftChatRegex.FindStringSubmatch(line)
 	if len(matches) < 4 {
-		return "", "", "", "", fmt.Errorf("ERROR WHILE EXTRACTING CHAT PLAYER NAME FOR MINECRAFT")
+		return "", "", fmt.Errorf("ERROR WHILE EXTRACTING CHAT PLAYER NAME FOR MINECRAFT")
+	}
+	return matches[2], matches[3], nil
+}
+
+// Define the functions for each game, here is Minecraft
+func handleMinecraftPlayerMessage(line string, server models.Server) (string, string, string, string, error) {
+	playerName, message, err := parseMinecraftChat(line)
+	if err != nil {
+		return "", "", "", "", err
 	}
-	playerName := matches[2]
-	message := matches[3]
 
 	// Get player UUID and head URL for Minecraft
-	playerUUID, err := services.GetMinecraftPlayerUUID(playerName)
+	playerUUID, err := services.ResolveMinecraftPlayerUUID(playerName, server)
 	if err != nil {
 		return "", "", "", "", fmt.Errorf("ERROR WHILE GETTING PLAYER UUID: %v", err)
 	}
@@ -88,7 +147,7 @@ func handleMinecraftPlayerMessage(line string) (string, string, string, string,
 }
 
 // Define the functions for each game, here is Palworld
-func handlePalworldPlayerMessage(line string) (string, string, string, string, error) {
+func handlePalworldPlayerMessage(line string, server models.Server) (string, string, string, string, error) {
 	playerChatRegex := regexp.MustCompile(`\[\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}\] \[CHAT\] <(.+?)> (.+)`)
 	matches := playerChatRegex.FindStringSubmatch(line)
 	if len(matches) < 3 {
@@ -100,15 +159,15 @@ func handlePalworldPlayerMessage(line string) (string, string, string, string, e
 }
 
 // Create a map for game-specific actions
-var gameActionsMap = map[string]func(string) (string, string, string, string, error){
+var gameActionsMap = map[string]func(string, models.Server) (string, string, string, string, error){
 	"Minecraft": handleMinecraftPlayerMessage,
 	"Palworld":  handlePalworldPlayerMessage,
 }
 
 // Action when a player message is detected
-func PlayerMessageAction(line string, serverID int) error {
+func (a *Actions) PlayerMessageAction(line string, serverID int) error {
 	// Server infos
-	server, err := db.GetServerById(serverID)
+	server, err := a.Servers.GetServerById(serverID)
 	if err != nil {
 		return fmt.Errorf("ERROR WHILE GETTING SERVER BY ID FOR PLAYER MESSAGE: %v", err)
 	}
@@ -120,10 +179,11 @@ func PlayerMessageAction(line string, serverID int) error {
 	}
 
 	// Call the specific action function for the game
-	playerName, message, playerHeadURL, titleURL, err := actionFunc(line)
+	playerName, message, playerHeadURL, titleURL, err := actionFunc(line, server)
 	if err != nil {
 		return err
 	}
+	a.saveEvent(serverID, models.EventPlayerMessage, playerName, "")
 
 	// Bot config
 	botName := "mineotterBot" // Can be extended similarly using the mappage if needed
@@ -138,10 +198,7 @@ func PlayerMessageAction(line string, serverID int) error {
 		Footer:      "Message venant de " + server.Nom,
 	}
 
-	err = discord.SendDiscordEmbedWithModel(config.AppConfig.Bots[botName], config.AppConfig.DiscordChannels.MinecraftChatChannelID, embed)
-	if err != nil {
-		return fmt.Errorf("ERROR WHILE SENDING DISCORD EMBED: %v", err)
-	}
+	a.printError(a.Notifier.SendEmbedWithModel(a.Config().Bots[botName], a.Config().DiscordChannels.MinecraftChatChannelID, embed))
 	return nil
 }
 
@@ -172,9 +229,9 @@ var gameJoinActionsMap = map[string]func(string) (string, error){
 }
 
 // Action when a player joined the server
-func PlayerJoinedAction(line string, serverID int) error {
+func (a *Actions) PlayerJoinedAction(line string, serverID int) error {
 	// Server infos
-	server, err := db.GetServerById(serverID)
+	server, err := a.Servers.GetServerById(serverID)
 	if err != nil {
 		return fmt.Errorf("ERROR WHILE GETTING SERVER BY ID FOR PLAYER JOINED: %v", err)
 	}
@@ -200,34 +257,82 @@ func PlayerJoinedAction(line string, serverID int) error {
 	}
 
 	// Send the Discord embed message
-	discord.SendDiscordEmbed(config.AppConfig.Bots[botName], config.AppConfig.DiscordChannels.MinecraftChatChannelID, playerName+" a rejoint "+server.Nom, "", server.EmbedColor)
+	a.printError(a.Notifier.SendEmbed(a.Config().Bots[botName], a.Config().DiscordChannels.MinecraftChatChannelID, playerName+" a rejoint "+server.Nom, "", server.EmbedColor))
+
+	presence.PlayerJoined(serverID, playerName)
+	a.saveEvent(serverID, models.EventPlayerJoined, playerName, "")
 
 	// Handle player connection log in DB
-	playerID, err := db.CheckAndInsertPlayerWithPlayerName(playerName, 1, "now")
+	playerID, err := a.Players.CheckAndInsertPlayerWithPlayerName(playerName, serverID, "now")
 	if err != nil {
 		return fmt.Errorf("ERROR WHILE CHECKING OR INSERTING PLAYER: %v", err)
 	}
 
-	err = db.SaveConnectionLog(playerID, serverID)
+	err = a.Players.SavePlayerName(playerID, playerName)
+	if err != nil {
+		return fmt.Errorf("ERROR WHILE SAVING NAME OF PLAYER %v IN DATABASE: %v", playerName, err)
+	}
+
+	err = a.Players.SaveConnectionLog(playerID, serverID)
 	if err != nil {
 		return fmt.Errorf("ERROR WHILE SAVING CONNECTION LOG: FOR PLAYER %v IN DATABASE: %v", playerName, err)
 	}
 
-	err = db.UpdatePlayerLastConnection(playerID)
+	err = a.Players.UpdatePlayerLastConnection(playerID)
 	if err != nil {
 		return fmt.Errorf("ERROR WHILE UPDATING LAST CONNECTION FOR PLAYER %v IN DATABASE: %v", playerName, err)
 	}
 
 	// Log to file
-	WriteToLogFile("/var/log/serversentinel/playerjoined.log", playerName)
+	WriteToLogFile(filepath.Join(a.Config().LogPath, "playerjoined.log"), playerName)
 
 	return nil
 }
 
+// Action when a Minecraft player asks for a code to link their account to Discord
+func (a *Actions) PlayerLinkCommandAction(line string, serverID int) error {
+	server, err := a.Servers.GetServerById(serverID)
+	if err != nil {
+		return fmt.Errorf("ERROR WHILE GETTING SERVER BY ID FOR PLAYER LINK COMMAND: %v", err)
+	}
+	if server.Jeu != "Minecraft" {
+		return fmt.Errorf("ERROR: SERVER GAME %v IS NOT SUPPORTED", server.Jeu)
+	}
+
+	playerName, _, err := parseMinecraftChat(line)
+	if err != nil {
+		return err
+	}
+
+	if !accounts.IsLinkingEnabled(a.Config()) {
+		return a.SendCommand(server.Nom, tellrawCommand(playerName, "La liaison de compte Discord n'est pas activée sur ce serveur."))
+	}
+
+	playerID, err := a.Players.CheckAndInsertPlayerWithPlayerName(playerName, serverID, "now")
+	if err != nil {
+		return fmt.Errorf("ERROR WHILE CHECKING OR INSERTING PLAYER: %v", err)
+	}
+
+	code, err := accounts.CreateLinkCode(playerID, playerName)
+	if err != nil {
+		return err
+	}
+
+	minutes := int(accounts.LinkCodeTTL.Minutes())
+	return a.SendCommand(server.Nom, tellrawCommand(playerName, fmt.Sprintf("Ton code de liaison est %s, envoie-le dans le salon de liaison du Discord. Il expire dans %d minutes.", code, minutes)))
+}
+
+// tellrawCommand builds the command writing a private message to a player. Unlike tell, tellraw doesn't echo the message in
+// the console, which is forwarded to Discord and to the live console
+func tellrawCommand(playerName string, message string) string {
+	text, _ := json.Marshal(map[string]string{"text": message, "color": "yellow"})
+	return "tellraw " + playerName + " " + string(text)
+}
+
 // Action when a Minecraft player get an advancement
-func PlayerGetAdvancementAction(line string, serverID int) error {
+func (a *Actions) PlayerGetAdvancementAction(line string, serverID int) error {
 	// Server infos
-	server, err := db.GetServerById(serverID)
+	server, err := a.Servers.GetServerById(serverID)
 	if err != nil {
 		return fmt.Errorf("ERROR WHILE GETTING SERVER BY ID FOR PLAYER GET ADVANCEMENT: %v", err)
 	}
@@ -240,6 +345,7 @@ func PlayerGetAdvancementAction(line string, serverID int) error {
 	}
 	playerName := matches[2]
 	advancement := matches[3]
+	a.saveEvent(serverID, models.EventAdvancement, playerName, advancement)
 
 	// Bot config
 	botName := "mineotterBot"
@@ -258,21 +364,19 @@ func PlayerGetAdvancementAction(line string, serverID int) error {
 	}
 
 	// Send the Discord embed message
-	err = discord.SendDiscordEmbedWithModel(config.AppConfig.Bots[botName], config.AppConfig.DiscordChannels.MinecraftChatChannelID, embed)
-	if err != nil {
-		return fmt.Errorf("ERROR WHILE SENDING DISCORD EMBED: %v", err)
-	}
+	a.printError(a.Notifier.SendEmbedWithModel(a.Config().Bots[botName], a.Config().DiscordChannels.MinecraftChatChannelID, embed))
 
 	return nil
 }
 
 // Action when a Minecraft player dies
-func PlayerDeathAction(deathMessage string, playername string, serverID int) error {
+func (a *Actions) PlayerDeathAction(deathMessage string, playername string, serverID int) error {
 	// Server infos
-	server, err := db.GetServerById(serverID)
+	server, err := a.Servers.GetServerById(serverID)
 	if err != nil {
 		return fmt.Errorf("ERROR WHILE GETTING SERVER BY ID FOR PLAYER DEATH: %v", err)
 	}
+	a.saveEvent(serverID, models.EventPlayerDeath, playername, deathMessage)
 
 	// Bot config
 	botName := "mineotterBot"
@@ -289,10 +393,7 @@ func PlayerDeathAction(deathMessage string, playername string, serverID int) err
 		AuthorIcon:  "",
 		Timestamp:   true,
 	}
-	err = discord.SendDiscordEmbedWithModel(config.AppConfig.Bots[botName], config.AppConfig.DiscordChannels.MinecraftChatChannelID, embedtwo)
-	if err != nil {
-		return fmt.Errorf("ERROR WHILE SENDING DISCORD EMBED: %v", err)
-	}
+	a.printError(a.Notifier.SendEmbedWithModel(a.Config().Bots[botName], a.Config().DiscordChannels.MinecraftChatChannelID, embedtwo))
 
 	return nil
 }
@@ -324,9 +425,9 @@ var gameLeaveActionsMap = map[string]func(string) (string, error){
 }
 
 // Action when a player left the server
-func PlayerLeftAction(line string, serverID int) error {
+func (a *Actions) PlayerLeftAction(line string, serverID int) error {
 	// Server infos
-	server, err := db.GetServerById(serverID)
+	server, err := a.Servers.GetServerById(serverID)
 	if err != nil {
 		return fmt.Errorf("ERROR WHILE GETTING SERVER BY ID FOR PLAYER LEFT: %v", err)
 	}
@@ -351,11 +452,14 @@ func PlayerLeftAction(line string, serverID int) error {
 		botName = "multiloutreBot"
 	}
 
+	presence.PlayerLeft(serverID, playerName)
+	a.saveEvent(serverID, models.EventPlayerLeft, playerName, "")
+
 	// Send the Discord embed message
-	discord.SendDiscordEmbed(config.AppConfig.Bots[botName], config.AppConfig.DiscordChannels.MinecraftChatChannelID, playerName+" a quitté "+server.Nom, "", server.EmbedColor)
+	a.printError(a.Notifier.SendEmbed(a.Config().Bots[botName], a.Config().DiscordChannels.MinecraftChatChannelID, playerName+" a quitté "+server.Nom, "", server.EmbedColor))
 
 	// Log to file
-	WriteToLogFile("/var/log/serversentinel/playerdisconnected.log", playerName)
+	WriteToLogFile(filepath.Join(a.Config().LogPath, "playerdisconnected.log"), playerName)
 
 	return nil
 }
@@ -0,0 +1,301 @@
+package triggers
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Corentin-cott/ServeurSentinel/config"
+	"github.com/Corentin-cott/ServeurSentinel/internal/db"
+	"github.com/Corentin-cott/ServeurSentinel/internal/discord"
+	"github.com/Corentin-cott/ServeurSentinel/internal/models"
+	"github.com/Corentin-cott/ServeurSentinel/internal/presence"
+)
+
+const (
+	minecraftServerID = 5
+	palworldServerID  = 6
+	chatChannelID     = "123456789012345678"
+)
+
+// newTestActions creates actions on a memory repository with a Minecraft and a Palworld server, the embeds are recorded
+func newTestActions(t *testing.T) (*Actions, *db.MemoryRepository, *discord.RecordingNotifier) {
+	t.Helper()
+	repository := db.NewMemoryRepository()
+	repository.AddServer(models.Server{ID: minecraftServerID, Nom: "Survie", Jeu: "Minecraft", EmbedColor: "#9adfba"})
+	repository.AddServer(models.Server{ID: palworldServerID, Nom: "Palworld", Jeu: "Palworld", EmbedColor: "#f0c040"})
+
+	conf := &config.Config{
+		Bots: map[string]models.BotConfig{
+			"mineotterBot":   {Activated: true, BotToken: "mineotter"},
+			"multiloutreBot": {Activated: true, BotToken: "multiloutre"},
+		},
+		DiscordChannels: models.DiscordChannels{MinecraftChatChannelID: chatChannelID},
+		LogPath:         t.TempDir(),
+	}
+	notifier := &discord.RecordingNotifier{}
+	actions := NewActions(repository, repository, repository, notifier)
+	actions.Config = func() *config.Config { return conf }
+
+	t.Cleanup(func() {
+		presence.ResetServer(minecraftServerID)
+		presence.ResetServer(palworldServerID)
+	})
+	return actions, repository, notifier
+}
+
+func TestActions(t *testing.T) {
+	tests := []struct {
+		name      string
+		run       func(a *Actions) error
+		wantTitle string // Title of the only embed expected
+		wantBot   string
+		wantEvent models.ServerEvent // Type, player and details of the only event expected
+	}{
+		{
+			"Minecraft player joined",
+			func(a *Actions) error {
+				return a.PlayerJoinedAction("[12:00:00] [Server thread/INFO]: Steve joined the game", minecraftServerID)
+			},
+			"Steve a rejoint Survie", "mineotter",
+			models.ServerEvent{Type: models.EventPlayerJoined, PlayerName: "Steve"},
+		},
+		{
+			"Minecraft player joined on a modded server",
+			func(a *Actions) error {
+				return a.PlayerJoinedAction("[12:00:00] [Server thread/INFO] [minecraft/MinecraftServer]: Alex joined the game", minecraftServerID)
+			},
+			"Alex a rejoint Survie", "mineotter",
+			models.ServerEvent{Type: models.EventPlayerJoined, PlayerName: "Alex"},
+		},
+		{
+			"Palworld player joined",
+			func(a *Actions) error {
+				return a.PlayerJoinedAction("[2025-03-14 12:00:00] [LOG] Zoe 192.168.1.10 connected the server", palworldServerID)
+			},
+			"Zoe a rejoint Palworld", "multiloutre",
+			models.ServerEvent{Type: models.EventPlayerJoined, PlayerName: "Zoe"},
+		},
+		{
+			"Minecraft player left",
+			func(a *Actions) error {
+				return a.PlayerLeftAction("[12:30:00] [Server thread/INFO]: Steve left the game", minecraftServerID)
+			},
+			"Steve a quitté Survie", "mineotter",
+			models.ServerEvent{Type: models.EventPlayerLeft, PlayerName: "Steve"},
+		},
+		{
+			"Palworld player left",
+			func(a *Actions) error {
+				return a.PlayerLeftAction("[2025-03-14 12:30:00] [LOG] Zoe left the server", palworldServerID)
+			},
+			"Zoe a quitté Palworld", "multiloutre",
+			models.ServerEvent{Type: models.EventPlayerLeft, PlayerName: "Zoe"},
+		},
+		{
+			"Palworld player message",
+			func(a *Actions) error {
+				return a.PlayerMessageAction("[2025-03-14 12:10:00] [CHAT] <Zoe> Bonjour !", palworldServerID)
+			},
+			"Zoe", "mineotter",
+			models.ServerEvent{Type: models.EventPlayerMessage, PlayerName: "Zoe"},
+		},
+		{
+			"advancement",
+			func(a *Actions) error {
+				return a.PlayerGetAdvancementAction("[12:15:00] [Server thread/INFO]: Steve has made the advancement [Stone Age]", minecraftServerID)
+			},
+			"Stone Age", "mineotter",
+			models.ServerEvent{Type: models.EventAdvancement, PlayerName: "Steve", Details: "Stone Age"},
+		},
+		{
+			"death",
+			func(a *Actions) error {
+				return a.PlayerDeathAction("Steve was slain by Zombie", "Steve", minecraftServerID)
+			},
+			"Steve est mort !", "mineotter",
+			models.ServerEvent{Type: models.EventPlayerDeath, PlayerName: "Steve", Details: "Steve was slain by Zombie"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actions, repository, notifier := newTestActions(t)
+			if err := test.run(actions); err != nil {
+				t.Fatalf("action failed: %v", err)
+			}
+
+			sent := notifier.Sent()
+			if len(sent) != 1 {
+				t.Fatalf("%d embeds sent, want 1", len(sent))
+			}
+			if sent[0].Embed.Title != test.wantTitle || sent[0].Bot.BotToken != test.wantBot || sent[0].ChannelID != chatChannelID {
+				t.Errorf("embed %q sent by %s in %s, want %q sent by %s in %s",
+					sent[0].Embed.Title, sent[0].Bot.BotToken, sent[0].ChannelID, test.wantTitle, test.wantBot, chatChannelID)
+			}
+
+			if len(repository.Events) != 1 {
+				t.Fatalf("%d events saved, want 1", len(repository.Events))
+			}
+			event := repository.Events[0]
+			if event.Type != test.wantEvent.Type || event.PlayerName != test.wantEvent.PlayerName || event.Details != test.wantEvent.Details {
+				t.Errorf("event %s %q %q saved, want %s %q %q",
+					event.Type, event.PlayerName, event.Details, test.wantEvent.Type, test.wantEvent.PlayerName, test.wantEvent.Details)
+			}
+		})
+	}
+}
+
+func TestActionsErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(a *Actions) error
+	}{
+		{"unknown server", func(a *Actions) error {
+			return a.PlayerJoinedAction("[12:00:00] [Server thread/INFO]: Steve joined the game", 99)
+		}},
+		{"line of another game", func(a *Actions) error {
+			return a.PlayerJoinedAction("[12:00:00] [Server thread/INFO]: Steve joined the game", palworldServerID)
+		}},
+		{"not a join line", func(a *Actions) error {
+			return a.PlayerJoinedAction("[12:00:00] [Server thread/INFO]: Done (3.2s)!", minecraftServerID)
+		}},
+		{"too short to be a player", func(a *Actions) error {
+			return a.PlayerLeftAction("[12:30:00] [Server thread/INFO]: ab lost connection", minecraftServerID)
+		}},
+		{"not a chat line", func(a *Actions) error {
+			return a.PlayerMessageAction("[2025-03-14 12:10:00] [LOG] Zoe left the server", palworldServerID)
+		}},
+		{"not an advancement line", func(a *Actions) error {
+			return a.PlayerGetAdvancementAction("[12:15:00] [Server thread/INFO]: Steve has reached the goal", minecraftServerID)
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actions, repository, notifier := newTestActions(t)
+			if err := test.run(actions); err == nil {
+				t.Errorf("action succeeded, want an error")
+			}
+			if len(notifier.Sent()) != 0 || len(repository.Events) != 0 || len(repository.Players) != 0 {
+				t.Errorf("failed action sent %d embeds, saved %d events and %d players, want none",
+					len(notifier.Sent()), len(repository.Events), len(repository.Players))
+			}
+		})
+	}
+}
+
+func TestPlayerJoinedActionSavesThePlayer(t *testing.T) {
+	actions, repository, _ := newTestActions(t)
+	repository.AccountIDs["Steve"] = "069a79f4-44e9-4726-a5be-fca90e38aaf5"
+
+	for i := 0; i < 2; i++ {
+		if err := actions.PlayerJoinedAction("[12:00:00] [Server thread/INFO]: Steve joined the game", minecraftServerID); err != nil {
+			t.Fatalf("PlayerJoinedAction() failed: %v", err)
+		}
+	}
+
+	players, _ := repository.GetAllMinecraftPlayers()
+	if len(players) != 1 || players[0].CompteID != "069a79f4-44e9-4726-a5be-fca90e38aaf5" {
+		t.Fatalf("players = %+v, want Steve once with his account ID", players)
+	}
+	names, _ := repository.GetPlayerNames(players[0].ID)
+	if len(names) != 1 || names[0].Pseudo != "Steve" {
+		t.Errorf("names = %+v, want Steve", names)
+	}
+	if len(repository.Connections) != 2 {
+		t.Errorf("%d connections saved, want 2", len(repository.Connections))
+	}
+	if online := presence.GetOnlinePlayers(minecraftServerID); !slices.Equal(online, []string{"Steve"}) {
+		t.Errorf("online players = %v, want [Steve]", online)
+	}
+
+	if err := actions.PlayerLeftAction("[12:30:00] [Server thread/INFO]: Steve left the game", minecraftServerID); err != nil {
+		t.Fatalf("PlayerLeftAction() failed: %v", err)
+	}
+	if online := presence.GetOnlinePlayers(minecraftServerID); len(online) != 0 {
+		t.Errorf("online players = %v after leaving, want none", online)
+	}
+}
+
+func TestPlayerLinkCommandAction(t *testing.T) {
+	actions, repository, _ := newTestActions(t)
+	var commands []string
+	actions.SendCommand = func(serverName string, command string) error {
+		commands = append(commands, serverName+": "+command)
+		return nil
+	}
+	line := "[12:00:00] [Server thread/INFO]: <Steve> !lier"
+
+	// Without a link channel the player is told the linking is disabled
+	if err := actions.PlayerLinkCommandAction(line, minecraftServerID); err != nil {
+		t.Fatalf("PlayerLinkCommandAction() failed: %v", err)
+	}
+	if len(commands) != 1 || !strings.HasPrefix(commands[0], "Survie: tellraw Steve ") || !strings.Contains(tellrawText(t, commands[0]), "pas activée") {
+		t.Fatalf("commands = %q, want the linking disabled message", commands)
+	}
+
+	actions.Config().DiscordChannels.AccountLinkChannelID = chatChannelID
+	commands = nil
+	if err := actions.PlayerLinkCommandAction(line, minecraftServerID); err != nil {
+		t.Fatalf("PlayerLinkCommandAction() failed: %v", err)
+	}
+	// Sent with tellraw, the code is not echoed in the console forwarded to Discord
+	if len(commands) != 1 || !strings.HasPrefix(commands[0], "Survie: tellraw Steve ") ||
+		!strings.HasPrefix(tellrawText(t, commands[0]), "Ton code de liaison est ") {
+		t.Errorf("commands = %q, want the link code", commands)
+	}
+	if players, _ := repository.GetAllMinecraftPlayers(); len(players) != 1 {
+		t.Errorf("%d players saved, want Steve", len(players))
+	}
+}
+
+// tellrawText returns the text of a tellraw command recorded as "server: tellraw player {json}"
+func tellrawText(t *testing.T, command string) string {
+	t.Helper()
+	var message struct {
+		Text string `json:"text"`
+	}
+	if _, raw, found := strings.Cut(command, " {"); !found || json.Unmarshal([]byte("{"+raw), &message) != nil {
+		t.Fatalf("%q is not a tellraw command with a JSON text", command)
+	}
+	return message.Text
+}
+
+func TestPlayerDisconnectedCondition(t *testing.T) {
+	actions, _, _ := newTestActions(t)
+	triggers := GetTriggers(actions, []string{"PlayerDisconnectedMinecraftServer"})
+	if len(triggers) != 1 {
+		t.Fatalf("%d triggers found, want 1", len(triggers))
+	}
+
+	tests := []struct {
+		line string
+		want bool
+	}{
+		{"[12:30:00] [Server thread/INFO]: Steve left the game", true},
+		{"[12:30:00] [Server thread/INFO] [minecraft/MinecraftServer]: Steve left the game", true},
+		// Followed by "left the game", the player must leave only once
+		{"[12:30:00] [Server thread/INFO]: Steve lost connection: Disconnected", false},
+		{"[12:30:00] [Server thread/INFO]: Steve lost connection: Timed out", false},
+		{"[12:30:00] [Server thread/INFO]: <Steve> Alex left the game", false},
+	}
+	for _, test := range tests {
+		if got := triggers[0].Condition(test.line); got != test.want {
+			t.Errorf("Condition(%q) = %v, want %v", test.line, got, test.want)
+		}
+	}
+}
+
+func TestActionsDiscordFailure(t *testing.T) {
+	actions, repository, notifier := newTestActions(t)
+	notifier.Err = errors.New("DISCORD IS DOWN")
+
+	// A message not sent doesn't stop the action, the player is still saved
+	if err := actions.PlayerJoinedAction("[12:00:00] [Server thread/INFO]: Steve joined the game", minecraftServerID); err != nil {
+		t.Fatalf("PlayerJoinedAction() failed: %v", err)
+	}
+	if len(repository.Players) != 1 || len(repository.Connections) != 1 {
+		t.Errorf("%d players and %d connections saved, want 1 and 1", len(repository.Players), len(repository.Connections))
+	}
+}
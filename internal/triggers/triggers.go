@@ -1,18 +1,16 @@
 package triggers
 
 import (
-	"fmt"
 	"regexp"
 	"strings"
 
-	"github.com/Corentin-cott/ServeurSentinel/config"
-	"github.com/Corentin-cott/ServeurSentinel/internal/db"
-	"github.com/Corentin-cott/ServeurSentinel/internal/discord"
+	"github.com/Corentin-cott/ServeurSentinel/internal/accounts"
 	"github.com/Corentin-cott/ServeurSentinel/internal/models"
+	"github.com/Corentin-cott/ServeurSentinel/internal/presence"
 )
 
-// GetTriggers returns the list of triggers filtered by names
-func GetTriggers(selectedTriggers []string) []models.Trigger {
+// GetTriggers returns the list of triggers filtered by names, their actions use the given dependencies
+func GetTriggers(actions *Actions, selectedTriggers []string) []models.Trigger {
 	// All available triggers
 	allTriggers := []models.Trigger{
 		{
@@ -24,7 +22,7 @@ func GetTriggers(selectedTriggers []string) []models.Trigger {
 			},
 			Action: func(line string, serverID int) {
 				// Here you can define the action that will be executed
-				fmt.Println("Example trigger action executed")
+				logger.Info("Example trigger action executed", "server_id", serverID)
 			},
 		},
 		{
@@ -34,9 +32,22 @@ func GetTriggers(selectedTriggers []string) []models.Trigger {
 				return isPlayerMessage(line)
 			},
 			Action: func(line string, serverID int) {
-				err := PlayerMessageAction(line, serverID)
+				err := actions.PlayerMessageAction(line, serverID)
 				if err != nil {
-					fmt.Println("ERROR WHILE PROCESSING PLAYER MESSAGE: " + err.Error())
+					actionError("PlayerChatInServer", serverID, err)
+				}
+			},
+		},
+		{
+			// This trigger is used to detect when a Minecraft player asks for a code to link their account to Discord
+			Name: "PlayerLinkCommand",
+			Condition: func(line string) bool {
+				return isPlayerMessage(line) && strings.HasSuffix(line, "> "+accounts.LinkCommand)
+			},
+			Action: func(line string, serverID int) {
+				err := actions.PlayerLinkCommandAction(line, serverID)
+				if err != nil {
+					actionError("PlayerLinkCommand", serverID, err)
 				}
 			},
 		},
@@ -51,13 +62,16 @@ func GetTriggers(selectedTriggers []string) []models.Trigger {
 				return match
 			},
 			Action: func(line string, serverID int) {
+				presence.ResetServer(serverID)
+				actions.saveEvent(serverID, models.EventServerStarted, "", "")
+
 				// Server infos
-				server, err := db.GetServerById(serverID)
+				server, err := actions.Servers.GetServerById(serverID)
 				if err != nil {
-					fmt.Println("ERROR WHILE GETTING SERVER BY ID FOR MINECRAFT SERVER STARTED: " + err.Error())
+					actionError("MinecraftServerStarted", serverID, err)
 					return
 				}
-				discord.SendDiscordEmbed(config.AppConfig.Bots["mineotterBot"], config.AppConfig.DiscordChannels.MinecraftChatChannelID, server.Nom+" viens d'ouvrir !", "Connectez-vous !\nLe serveur "+server.Jeu+" est en ligne !", server.EmbedColor)
+				actions.printError(actions.Notifier.SendEmbed(actions.Config().Bots["mineotterBot"], actions.Config().DiscordChannels.MinecraftChatChannelID, server.Nom+" viens d'ouvrir !", "Connectez-vous !\nLe serveur "+server.Jeu+" est en ligne !", server.EmbedColor))
 			},
 		},
 		{
@@ -71,13 +85,16 @@ func GetTriggers(selectedTriggers []string) []models.Trigger {
 				return match
 			},
 			Action: func(line string, serverID int) {
+				presence.ResetServer(serverID)
+				actions.saveEvent(serverID, models.EventServerStopped, "", "")
+
 				// Server infos
-				server, err := db.GetServerById(serverID)
+				server, err := actions.Servers.GetServerById(serverID)
 				if err != nil {
-					fmt.Println("ERROR WHILE GETTING SERVER BY ID FOR MINECRAFT SERVER STOPPED: " + err.Error())
+					actionError("MinecraftServerStopped", serverID, err)
 					return
 				}
-				discord.SendDiscordEmbed(config.AppConfig.Bots["mineotterBot"], config.AppConfig.DiscordChannels.MinecraftChatChannelID, server.Nom+" viens de fermer !", "Le serveur "+server.Jeu+" est hors ligne !", server.EmbedColor)
+				actions.printError(actions.Notifier.SendEmbed(actions.Config().Bots["mineotterBot"], actions.Config().DiscordChannels.MinecraftChatChannelID, server.Nom+" viens de fermer !", "Le serveur "+server.Jeu+" est hors ligne !", server.EmbedColor))
 			},
 		},
 		{
@@ -91,13 +108,16 @@ func GetTriggers(selectedTriggers []string) []models.Trigger {
 				return match
 			},
 			Action: func(line string, serverID int) {
+				presence.ResetServer(serverID)
+				actions.saveEvent(serverID, models.EventServerCrashed, "", "")
+
 				// Server infos
-				server, err := db.GetServerById(serverID)
+				server, err := actions.Servers.GetServerById(serverID)
 				if err != nil {
-					fmt.Println("ERROR WHILE GETTING SERVER BY ID FOR MINECRAFT SERVER CRASHED: " + err.Error())
+					actionError("MinecraftServerCrashed", serverID, err)
 					return
 				}
-				discord.SendDiscordEmbed(config.AppConfig.Bots["mineotterBot"], config.AppConfig.DiscordChannels.MinecraftChatChannelID, server.Nom+" vient de crash !", "Le serveur "+server.Jeu+" est hors ligne !", server.EmbedColor)
+				actions.printError(actions.Notifier.SendEmbed(actions.Config().Bots["mineotterBot"], actions.Config().DiscordChannels.MinecraftChatChannelID, server.Nom+" vient de crash !", "Le serveur "+server.Jeu+" est hors ligne !", server.EmbedColor))
 			},
 		},
 		{
@@ -110,9 +130,9 @@ func GetTriggers(selectedTriggers []string) []models.Trigger {
 				return strings.Contains(line, "joined the game")
 			},
 			Action: func(line string, serverID int) {
-				err := PlayerJoinedAction(line, serverID)
+				err := actions.PlayerJoinedAction(line, serverID)
 				if err != nil {
-					fmt.Println("ERROR WHILE PROCESSING PLAYER JOINED: " + err.Error())
+					actionError("PlayerJoinedMinecraftServer", serverID, err)
 				}
 			},
 		},
@@ -123,12 +143,13 @@ func GetTriggers(selectedTriggers []string) []models.Trigger {
 				if isPlayerMessage(line) {
 					return false
 				}
-				return strings.Contains(line, "lost connection: Disconnected")
+				// Minecraft logs "left the game" whatever the reason of the disconnection, after the "lost connection" line
+				return strings.Contains(line, "left the game")
 			},
 			Action: func(line string, serverID int) {
-				err := PlayerLeftAction(line, serverID)
+				err := actions.PlayerLeftAction(line, serverID)
 				if err != nil {
-					fmt.Println("ERROR WHILE PROCESSING PLAYER DISCONNECTED: " + err.Error())
+					actionError("PlayerDisconnectedMinecraftServer", serverID, err)
 				}
 			},
 		},
@@ -142,9 +163,9 @@ func GetTriggers(selectedTriggers []string) []models.Trigger {
 				return strings.Contains(line, "has made the advancement")
 			},
 			Action: func(line string, serverID int) {
-				err := PlayerGetAdvancementAction(line, serverID)
+				err := actions.PlayerGetAdvancementAction(line, serverID)
 				if err != nil {
-					fmt.Println("ERROR WHILE PROCESSING PLAYER GET ADVANCEMENT: " + err.Error())
+					actionError("PlayerGetAdvancement", serverID, err)
 				}
 			},
 		},
@@ -160,11 +181,10 @@ func GetTriggers(selectedTriggers []string) []models.Trigger {
 			},
 			Action: func(line string, serverID int) {
 				_, deathMessage, playername := isPlayerDeathMessage(line)
-				fmt.Println("Player death detected for: " + playername)
-				fmt.Println("Death message: " + deathMessage)
-				err := PlayerDeathAction(deathMessage, playername, serverID)
+				logger.Debug("Player death detected", "player", playername, "server_id", serverID, "message", deathMessage)
+				err := actions.PlayerDeathAction(deathMessage, playername, serverID)
 				if err != nil {
-					fmt.Println("ERROR WHILE PROCESSING PLAYER DEATH: " + err.Error())
+					actionError("PlayerDeath", serverID, err)
 				}
 			},
 		},
@@ -179,13 +199,16 @@ func GetTriggers(selectedTriggers []string) []models.Trigger {
 				return palworldServerStartedRegex.MatchString(strings.TrimSpace(line))
 			},
 			Action: func(line string, serverID int) {
+				presence.ResetServer(serverID)
+				actions.saveEvent(serverID, models.EventServerStarted, "", "")
+
 				// Server infos
-				server, err := db.GetServerById(serverID)
+				server, err := actions.Servers.GetServerById(serverID)
 				if err != nil {
-					fmt.Println("ERROR WHILE GETTING SERVER BY ID FOR MINECRAFT SERVER STARTED: " + err.Error())
+					actionError("PalworldServerStarted", serverID, err)
 					return
 				}
-				discord.SendDiscordEmbed(config.AppConfig.Bots["multiloutreBot"], config.AppConfig.DiscordChannels.PalworldChatChannelID, server.Nom+" viens d'ouvrir !", "Connectez-vous !\nLe serveur "+server.Jeu+" est en ligne !", server.EmbedColor)
+				actions.printError(actions.Notifier.SendEmbed(actions.Config().Bots["multiloutreBot"], actions.Config().DiscordChannels.PalworldChatChannelID, server.Nom+" viens d'ouvrir !", "Connectez-vous !\nLe serveur "+server.Jeu+" est en ligne !", server.EmbedColor))
 			},
 		},
 		{
@@ -199,9 +222,9 @@ func GetTriggers(selectedTriggers []string) []models.Trigger {
 				return match
 			},
 			Action: func(line string, serverID int) {
-				err := PlayerJoinedAction(line, serverID)
+				err := actions.PlayerJoinedAction(line, serverID)
 				if err != nil {
-					fmt.Println("ERROR WHILE PROCESSING PLAYER JOINED: " + err.Error())
+					actionError("PlayerJoinedPalworldServer", serverID, err)
 				}
 			},
 		},
@@ -215,9 +238,9 @@ func GetTriggers(selectedTriggers []string) []models.Trigger {
 				return strings.Contains(line, "left the server.")
 			},
 			Action: func(line string, serverID int) {
-				err := PlayerLeftAction(line, serverID)
+				err := actions.PlayerLeftAction(line, serverID)
 				if err != nil {
-					fmt.Println("ERROR WHILE PROCESSING PLAYER DISCONNECTED: " + err.Error())
+					actionError("PlayerDisconnectedPalworldServer", serverID, err)
 				}
 			},
 		},